@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/auth"
+	"github.com/aliselcukkaya/account-editor/internal/automation"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/heartbeat"
+	"github.com/aliselcukkaya/account-editor/internal/legacy"
+	"github.com/aliselcukkaya/account-editor/internal/maintenance"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+)
+
+// runCommand executes a CLI subcommand and reports whether one was given
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "migrate-from-fastapi":
+		migrateFromFastAPI(args[1:])
+	case "normalize-results":
+		normalizeResults(args[1:])
+	case "recover-admin", "--recover-admin":
+		recoverAdmin(args[1:])
+	case "heartbeat":
+		sendHeartbeat(args[1:])
+	case "verify-audit-log":
+		verifyAuditLog(args[1:])
+	case "encrypt-fields":
+		encryptFields(args[1:])
+	case "panel-conformance":
+		panelConformance(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", args[0])
+		os.Exit(2)
+	}
+
+	return true
+}
+
+// migrateFromFastAPI imports users, tasks and settings from the legacy Python backend database
+func migrateFromFastAPI(args []string) {
+	fs := flag.NewFlagSet("migrate-from-fastapi", flag.ExitOnError)
+	source := fs.String("source", "", "path to the legacy SQLAlchemy SQLite database")
+	dryRun := fs.Bool("dry-run", false, "report what would be imported without writing")
+	skipIncompatible := fs.Bool("skip-incompatible", false, "skip users whose password hash is not bcrypt instead of importing them as inactive")
+	fs.Parse(args)
+
+	if *source == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if _, err := os.Stat(*source); err != nil {
+		log.Fatal("Cannot read legacy database: ", err)
+	}
+
+	database.Initialize()
+
+	report, err := legacy.Import(database.GetDB(), legacy.Options{
+		SourcePath:       *source,
+		DryRun:           *dryRun,
+		SkipIncompatible: *skipIncompatible,
+	})
+	if err != nil {
+		log.Fatal("Legacy import failed: ", err)
+	}
+
+	report.LogReport(*dryRun)
+}
+
+// normalizeResults repairs or quarantines task rows whose result is NULL or invalid JSON
+func normalizeResults(args []string) {
+	fs := flag.NewFlagSet("normalize-results", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report affected rows without modifying them")
+	fs.Parse(args)
+
+	database.Initialize()
+
+	report, err := maintenance.NormalizeResults(database.GetDB(), *dryRun)
+	if err != nil {
+		log.Fatal("Result normalization failed: ", err)
+	}
+
+	log.Printf("Scanned %d tasks: %d valid, %d pending, %d repaired, %d unwrapped, %d quarantined (dry run: %v)",
+		report.Scanned, report.Valid, report.Pending, report.Repaired, report.Unwrapped, report.Quarantined, report.DryRun)
+	if len(report.TaskIDs) > 0 {
+		log.Printf("Affected task IDs: %v", report.TaskIDs)
+	}
+}
+
+// recoverAdmin issues a one-time login token for a locked-out admin. It only
+// runs on the server host, so access to the host is the proof of authority.
+func recoverAdmin(args []string) {
+	fs := flag.NewFlagSet("recover-admin", flag.ExitOnError)
+	username := fs.String("username", "admin", "admin account to recover")
+	ttl := fs.Duration("ttl", 15*time.Minute, "how long the token stays valid")
+	fs.Parse(args)
+
+	if *ttl <= 0 || *ttl > 24*time.Hour {
+		log.Fatal("-ttl must be between 1s and 24h")
+	}
+
+	database.Initialize()
+
+	grant, err := auth.IssueRecoveryToken(database.GetDB(), *username, *ttl)
+	if err != nil {
+		log.Fatalf("Cannot issue a recovery token for %q: %v", *username, err)
+	}
+
+	log.Printf("WARNING: break-glass recovery token issued for admin %q; this is recorded in the audit log", grant.User.Username)
+	if grant.Reactivated {
+		log.Printf("WARNING: the deactivated account %q was reactivated", grant.User.Username)
+	}
+
+	fmt.Printf("One-time recovery token (valid until %s):\n\n  %s\n\n", grant.ExpiresAt.Format(time.RFC3339), grant.Token)
+	fmt.Printf("Exchange it for a session with POST /auth/recover {\"token\": \"...\"}, then set a new password.\n")
+}
+
+// sendHeartbeat pings the configured uptime service for a job that runs outside
+// the server, such as a backup script: "heartbeat -job backup" after a backup
+// and "heartbeat -job backup -fail <reason>" when it failed
+func sendHeartbeat(args []string) {
+	fs := flag.NewFlagSet("heartbeat", flag.ExitOnError)
+	job := fs.String("job", heartbeat.JobBackup, "job to report")
+	failure := fs.String("fail", "", "report the run as failed with this reason")
+	fs.Parse(args)
+
+	database.Initialize()
+
+	cfg, err := heartbeat.LoadConfig(database.GetDB())
+	if err != nil {
+		log.Fatal("Cannot load the heartbeat configuration: ", err)
+	}
+	if !cfg.Enabled {
+		log.Println("Heartbeats are disabled; nothing sent")
+		return
+	}
+
+	var runErr error
+	if *failure != "" {
+		runErr = errors.New(*failure)
+	}
+	if err := heartbeat.Send(cfg, *job, runErr); err != nil {
+		log.Fatalf("Heartbeat of job %s failed: %v", *job, err)
+	}
+	log.Printf("Heartbeat of job %s sent", *job)
+}
+
+// verifyAuditLog checks the audit log chain and exits with status 1 when an
+// entry was modified or deleted
+func verifyAuditLog(args []string) {
+	fs := flag.NewFlagSet("verify-audit-log", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	database.Initialize()
+
+	report, err := audit.VerifyChain(database.GetDB())
+	if err != nil {
+		log.Fatal("Audit log verification failed: ", err)
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		log.Printf("Verified %d chained entries (%d written before chaining); newest entry ID %d has hash %s",
+			report.Checked, report.Unchained, report.HeadID, report.HeadHash)
+		for _, b := range report.Breaks {
+			log.Printf("Entry ID %d: %s", b.ID, b.Reason)
+		}
+		if report.TotalBreaks > len(report.Breaks) {
+			log.Printf("... and %d more", report.TotalBreaks-len(report.Breaks))
+		}
+	}
+
+	if !report.Valid {
+		log.Printf("The audit log chain is broken at %d entries", report.TotalBreaks)
+		os.Exit(1)
+	}
+	log.Println("The audit log chain is intact")
+}
+
+// encryptFields encrypts stored secrets with the current FIELD_ENCRYPTION_KEY,
+// after encryption is enabled or the key is rotated
+func encryptFields(args []string) {
+	fs := flag.NewFlagSet("encrypt-fields", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "count the stored values by key without rewriting them")
+	fs.Parse(args)
+
+	database.Initialize()
+
+	report, err := maintenance.ReencryptFields(database.GetDB(), *dryRun)
+	if err != nil {
+		log.Fatal("Field encryption failed: ", err)
+	}
+
+	for _, col := range report.Columns {
+		log.Printf("%s: values by key %v, %d re-encrypted", col.Column, col.Keys, col.Rewritten)
+	}
+	for _, key := range report.Settings {
+		log.Printf("Setting %s re-encrypted", key)
+	}
+	log.Printf("Current key: %q (dry run: %v)", report.CurrentKey, report.DryRun)
+}
+
+// panelConformance runs the panel conformance suite against the panel profile
+// of a user, or a panel given on the command line, and exits with status 1
+// unless the panel is compatible
+func panelConformance(args []string) {
+	fs := flag.NewFlagSet("panel-conformance", flag.ExitOnError)
+	username := fs.String("user", "", "user whose panel settings are tested")
+	websiteURL := fs.String("url", "", "panel URL, instead of -user")
+	apiKey := fs.String("api-key", "", "panel API key, with -url")
+	authUser := fs.String("auth-user", "", "panel auth user, with -url")
+	pkg := fs.Int("package", 0, "package ID the trial line is created and extended with")
+	lineUsername := fs.String("line-username", "", "username of the trial line (default a random conformance-... name)")
+	timeout := fs.Duration("timeout", 30*time.Second, "timeout of every panel request")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	if (*username == "") == (*websiteURL == "") || *pkg <= 0 {
+		fmt.Fprintln(os.Stderr, "give either -user or -url with -api-key and -auth-user, and -package")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if *username != "" {
+		database.Initialize()
+
+		var user models.User
+		if err := database.GetDB().Where("username = ?", *username).First(&user).Error; err != nil {
+			log.Fatalf("Cannot find user %q: %v", *username, err)
+		}
+		var profile models.UserSettings
+		if err := database.ForUser(user.ID).Where("user_id = ?", user.ID).First(&profile).Error; err != nil {
+			log.Fatalf("User %q has no panel settings: %v", *username, err)
+		}
+		*websiteURL, *apiKey, *authUser = profile.WebsiteURL, profile.APIKey, profile.AuthUser
+	}
+
+	client := automation.NewAPIClient(*websiteURL, *apiKey, *authUser)
+	client.HTTPClient.Timeout = *timeout
+
+	report, err := automation.RunConformance(client, automation.ConformanceOptions{Package: *pkg, Username: *lineUsername})
+	if err != nil {
+		log.Fatal("Panel conformance test failed: ", err)
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		log.Printf("Testing %s with trial line %q", report.WebsiteURL, report.Username)
+		for _, s := range report.Steps {
+			log.Printf("%-28s %-4s %6dms %s", s.Name, s.Status, s.DurationMS, s.Error)
+			for _, p := range s.Problems {
+				log.Printf("    - %s", p)
+			}
+		}
+		log.Printf("%d passed, %d failed, %d skipped in %dms", report.Passed, report.Failed, report.Skipped, report.DurationMS)
+	}
+
+	if !report.Compatible {
+		log.Printf("The panel at %s is not compatible", report.WebsiteURL)
+		os.Exit(1)
+	}
+	log.Printf("The panel at %s is compatible", report.WebsiteURL)
+}
@@ -0,0 +1,92 @@
+// Command fakepanel serves an in-memory panel implementing the API the
+// account editor uses, for integration tests and for trying the product
+// without a real panel. Lines live in memory and are lost on exit.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/automation"
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8099", "listen address")
+	apiKey := flag.String("api-key", "fake", "API key the panel accepts")
+	authUser := flag.String("auth-user", "fake", "auth user the panel accepts")
+	latency := flag.Duration("latency", 0, "delay added to every response")
+	jitter := flag.Duration("jitter", 0, "random extra delay of up to this duration")
+	errorRate := flag.Float64("error-rate", 0, "share of requests (0-1) that fail")
+	errorMode := flag.String("error-mode", automation.PanelFaultUnavailable,
+		"how failed requests are answered: "+strings.Join(automation.PanelFaults, ", "))
+	control := flag.Bool("control", true, "serve the /_fakepanel endpoints that change the profile and reset the panel")
+	logRequests := flag.Bool("log", false, "log every request")
+	flag.Parse()
+
+	profile := automation.SimulatedPanelProfile{
+		LatencyMS: int(latency.Milliseconds()),
+		JitterMS:  int(jitter.Milliseconds()),
+		ErrorRate: *errorRate,
+		ErrorMode: *errorMode,
+	}
+	if err := profile.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	panel := automation.NewSimulatedPanel(*apiKey, *authUser, profile)
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(gin.Recovery())
+	if *logRequests {
+		r.Use(gin.Logger())
+	}
+	panel.Register(r)
+	if *control {
+		setupControlRoutes(r.Group("/_fakepanel"), panel)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("Fake panel listening on http://%s (API key %q, auth user %q, latency %s±%s, error rate %g as %s)",
+		*addr, *apiKey, *authUser, *latency, *jitter, *errorRate, *errorMode)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatal("Fake panel stopped: ", err)
+	}
+}
+
+// setupControlRoutes registers the endpoints tests use to drive the panel.
+// They need no credentials, so the panel should only listen locally.
+func setupControlRoutes(router *gin.RouterGroup, panel *automation.SimulatedPanel) {
+	router.GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"profile": panel.Profile(), "stats": panel.Stats()})
+	})
+	router.PUT("/profile", func(c *gin.Context) {
+		var req automation.SimulatedPanelProfile
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := req.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		panel.SetProfile(req)
+		c.JSON(http.StatusOK, req)
+	})
+	router.POST("/reset", func(c *gin.Context) {
+		panel.Reset()
+		c.JSON(http.StatusOK, gin.H{"profile": panel.Profile(), "stats": panel.Stats()})
+	})
+}
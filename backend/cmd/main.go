@@ -2,12 +2,37 @@ package main
 
 import (
 	"log"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/aliselcukkaya/account-editor/internal/artifacts"
+	"github.com/aliselcukkaya/account-editor/internal/audit"
 	"github.com/aliselcukkaya/account-editor/internal/auth"
 	"github.com/aliselcukkaya/account-editor/internal/automation"
+	"github.com/aliselcukkaya/account-editor/internal/billing"
+	"github.com/aliselcukkaya/account-editor/internal/branding"
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/dashboard"
 	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/erasure"
+	"github.com/aliselcukkaya/account-editor/internal/fieldcrypt"
+	"github.com/aliselcukkaya/account-editor/internal/graphql"
+	"github.com/aliselcukkaya/account-editor/internal/heartbeat"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/maintenance"
 	"github.com/aliselcukkaya/account-editor/internal/middleware"
 	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/notify"
+	"github.com/aliselcukkaya/account-editor/internal/onboarding"
+	"github.com/aliselcukkaya/account-editor/internal/quota"
+	"github.com/aliselcukkaya/account-editor/internal/schemas"
+	"github.com/aliselcukkaya/account-editor/internal/scim"
+	"github.com/aliselcukkaya/account-editor/internal/slo"
+	"github.com/aliselcukkaya/account-editor/internal/status"
+	"github.com/aliselcukkaya/account-editor/internal/storage"
+	"github.com/aliselcukkaya/account-editor/internal/tenantexport"
+	"github.com/aliselcukkaya/account-editor/internal/uptime"
 	"github.com/aliselcukkaya/account-editor/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
@@ -41,9 +66,79 @@ func createDefaultAdminUser(db *gorm.DB) {
 }
 
 func main() {
+	// Load configuration from the environment
+	cfg := config.Load()
+	if cfg.JWTSecret != "" {
+		utils.SecretKey = []byte(cfg.JWTSecret)
+	}
+	models.ResultCompressionThreshold = cfg.ResultCompressThresholdBytes
+	if err := fieldcrypt.SetKeys(cfg.FieldEncryptionKey, strings.Split(cfg.FieldEncryptionPreviousKeys, ",")); err != nil {
+		log.Fatalf("Invalid field encryption key: %v", err)
+	}
+
+	// Run a CLI subcommand instead of the server if one was given
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
 	// Initialize database
 	database.Initialize()
 
+	// Report background job runs as heartbeats and alert superadmins of failing jobs
+	jobs.SetHeartbeatFunc(func(job string, err error) {
+		heartbeat.Ping(database.GetDB(), job, err)
+	})
+	jobs.SetAlertFunc(notify.JobAlert)
+
+	// Schedule SQLite integrity checks and VACUUM/ANALYZE
+	maintenance.StartSQLiteMaintenance(database.GetDB())
+
+	// Move finished tasks past the retention window to the archive table
+	maintenance.StartTaskArchiver(database.GetDB())
+
+	// Start tasks held outside their execution window once it opens
+	automation.StartHeldTaskDispatcher(database.GetDB())
+
+	// Delete expired imports and the rows of imports committed a week ago
+	automation.StartImportPurger(database.GetDB())
+
+	// Enqueue extend tasks for lines covered by renewal rules when the renewal scheduler is enabled
+	automation.StartRenewalScheduler(database.GetDB())
+
+	// Probe configured panels periodically when the uptime monitor is enabled
+	uptime.StartMonitor(database.GetDB())
+
+	// Validate stored panel API keys periodically when the credential monitor is enabled
+	uptime.StartCredentialMonitor(database.GetDB())
+	billing.StartSubscriptionSweeper(database.GetDB())
+
+	// Send queued webhook notifications and retry failed ones
+	notify.StartWebhookDispatcher(database.GetDB())
+
+	// Send notification digests once their interval has passed
+	notify.StartDigestFlusher(database.GetDB())
+
+	// Forward audit entries to the SIEM when forwarding is configured
+	audit.StartForwarder(database.GetDB())
+
+	// Add recorded route and task latencies to the daily SLO rollups
+	slo.StartFlusher(database.GetDB())
+
+	// Serve the simulated panel for load tests when bench mode is enabled
+	automation.StartBenchPanel()
+
+	// Initialize artifact storage and expire old artifacts hourly
+	storage.Initialize()
+	storage.StartCleanup(storage.Get(), artifacts.LifecycleRules(), time.Hour)
+
+	// Erase accounts whose deletion grace period has ended
+	erasure.StartEraser(database.GetDB())
+
+	// Heartbeat URLs can be configured for every registered job
+	for _, name := range jobs.Names() {
+		heartbeat.AddJob(name)
+	}
+
 	// Create default admin user
 	createDefaultAdminUser(database.GetDB())
 
@@ -55,6 +150,9 @@ func main() {
 	limiter := middleware.NewIPRateLimiter(rate.Limit(10), 20)
 
 	// Add middleware
+	r.Use(middleware.RequestID())
+	r.Use(slo.Middleware())
+	r.Use(middleware.HandleForwardedHeaders())
 	r.Use(middleware.SecurityHeaders())
 	r.Use(middleware.RateLimiterMiddleware(limiter))
 	r.Use(middleware.CORSMiddleware())
@@ -68,6 +166,36 @@ func main() {
 		})
 	})
 
+	// Public branding for the login page and white-label UI
+	brandingGroup := r.Group("/branding")
+	{
+		branding.SetupRoutes(brandingGroup)
+	}
+
+	// Public JSON Schemas of task and webhook payloads
+	schemasGroup := r.Group("/schemas")
+	{
+		schemas.SetupRoutes(schemasGroup)
+	}
+
+	// Public coarse health for status pages
+	statusGroup := r.Group("/status")
+	{
+		status.SetupRoutes(statusGroup)
+	}
+
+	// Public billing routes (Stripe webhooks, authenticated by signature)
+	billingGroup := r.Group("/billing")
+	{
+		billing.SetupRoutes(billingGroup)
+	}
+
+	// Public panel webhooks (authenticated by the profile's signing secret)
+	panelWebhookGroup := r.Group(automation.PanelWebhookPrefix)
+	{
+		automation.SetupWebhookRoutes(panelWebhookGroup)
+	}
+
 	// Public auth routes (login)
 	authGroup := r.Group("/auth")
 	{
@@ -76,29 +204,97 @@ func main() {
 
 	// Protected auth routes (status)
 	protectedAuthGroup := r.Group("/auth")
-	protectedAuthGroup.Use(middleware.AuthRequired(), middleware.GetCurrentUser(database.GetDB()))
+	protectedAuthGroup.Use(middleware.AuthRequired(), middleware.GetCurrentUser(database.GetDB()), middleware.CSRFRequired())
 	{
 		auth.SetupProtectedRoutes(protectedAuthGroup)
+		erasure.SetupRoutes(protectedAuthGroup)
 	}
 
 	// Automation routes
 	automationGroup := r.Group("/automation")
-	automationGroup.Use(middleware.AuthRequired(), middleware.GetCurrentUser(database.GetDB()))
+	automationGroup.Use(middleware.AuthRequired(), middleware.GetCurrentUser(database.GetDB()), middleware.CSRFRequired(), middleware.TOSRequired(database.GetDB()),
+		middleware.SubAccountScope(database.GetDB()))
 	{
 		automation.SetupRoutes(automationGroup)
+		artifacts.SetupProtectedRoutes(automationGroup)
+		uptime.SetupRoutes(automationGroup)
+		quota.SetupRoutes(automationGroup)
+	}
+
+	// Optional GraphQL endpoint for dashboards
+	if cfg.GraphQLEnabled {
+		graphqlGroup := r.Group("/graphql")
+		graphqlGroup.Use(middleware.AuthRequired(), middleware.GetCurrentUser(database.GetDB()), middleware.CSRFRequired(), middleware.TOSRequired(database.GetDB()))
+		{
+			graphql.SetupRoutes(graphqlGroup)
+		}
+	}
+
+	// In-app notifications
+	notificationGroup := r.Group("/notifications")
+	notificationGroup.Use(middleware.AuthRequired(), middleware.GetCurrentUser(database.GetDB()), middleware.CSRFRequired())
+	{
+		notify.SetupRoutes(notificationGroup)
+	}
+
+	// Subscription billing; sub-accounts see the subscription of their reseller account
+	protectedBillingGroup := r.Group("/billing")
+	protectedBillingGroup.Use(middleware.AuthRequired(), middleware.GetCurrentUser(database.GetDB()), middleware.CSRFRequired(),
+		middleware.SubAccountScope(database.GetDB()))
+	{
+		billing.SetupProtectedRoutes(protectedBillingGroup)
+	}
+
+	// User-defined dashboard widgets
+	dashboardGroup := r.Group("/dashboard")
+	dashboardGroup.Use(middleware.AuthRequired(), middleware.GetCurrentUser(database.GetDB()), middleware.CSRFRequired(), middleware.TOSRequired(database.GetDB()))
+	{
+		dashboard.SetupRoutes(dashboardGroup)
+	}
+
+	// Onboarding progress for new users
+	onboardingGroup := r.Group("/onboarding")
+	onboardingGroup.Use(middleware.AuthRequired(), middleware.GetCurrentUser(database.GetDB()), middleware.CSRFRequired())
+	{
+		onboarding.SetupRoutes(onboardingGroup)
+	}
+
+	// SCIM 2.0 provisioning for identity providers (authenticated by SCIM_TOKEN)
+	scimGroup := r.Group("/scim/v2")
+	scimGroup.Use(scim.TokenRequired())
+	{
+		scim.SetupRoutes(scimGroup)
+	}
+
+	// Signed artifact downloads (no bearer token, authorized by URL signature)
+	downloadGroup := r.Group(artifacts.DownloadPrefix)
+	{
+		artifacts.SetupRoutes(downloadGroup)
 	}
 
 	// Admin routes
 	adminGroup := r.Group("/admin")
-	adminGroup.Use(middleware.AuthRequired(), middleware.GetCurrentUser(database.GetDB()), middleware.AdminRequired())
+	adminGroup.Use(middleware.AuthRequired(), middleware.GetCurrentUser(database.GetDB()), middleware.CSRFRequired(), middleware.AdminRequired(), middleware.PermissionRequired())
 	{
 		auth.SetupAdminRoutes(adminGroup)
+		erasure.SetupAdminRoutes(adminGroup)
+		automation.SetupAdminRoutes(adminGroup)
+		audit.SetupAdminRoutes(adminGroup)
+		branding.SetupAdminRoutes(adminGroup)
+		uptime.SetupAdminRoutes(adminGroup)
+		maintenance.SetupAdminRoutes(adminGroup)
+		onboarding.SetupAdminRoutes(adminGroup)
+		billing.SetupAdminRoutes(adminGroup)
+		notify.SetupAdminRoutes(adminGroup)
+		slo.SetupAdminRoutes(adminGroup)
+		heartbeat.SetupAdminRoutes(adminGroup)
+		tenantexport.SetupAdminRoutes(adminGroup)
 	}
 
 	// Start the server
-	log.Println("Starting server on :8080")
-	log.Println("Access the API at http://localhost:8080")
-	if err := r.Run(":8080"); err != nil {
+	log.Printf("Starting server on :%s (auth mode: %s)", cfg.Port, cfg.AuthMode)
+	log.Printf("Access the API at http://localhost:%s", cfg.Port)
+	if err := r.Run(":" + cfg.Port); err != nil {
 		log.Fatal("Error starting server: ", err)
 	}
 }
@@ -3,6 +3,7 @@ module github.com/aliselcukkaya/account-editor
 go 1.24.1
 
 require (
+	filippo.io/age v1.2.1
 	github.com/gin-contrib/cors v1.7.4
 	github.com/gin-gonic/gin v1.10.0
 	github.com/golang-jwt/jwt/v5 v5.2.1
@@ -0,0 +1,167 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/middleware"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/storage"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Artifact kinds, used as the top-level storage prefix
+const (
+	KindExport      = "exports"
+	KindReceipt     = "receipts"
+	KindBackup      = "backups"
+	KindDebugBundle = "debug"
+	KindAvatar      = "avatars"
+)
+
+// userKinds are the artifact kinds owned by and listed for individual users
+var userKinds = []string{KindExport, KindReceipt, KindDebugBundle}
+
+// DownloadPrefix is the route prefix signed download URLs point to
+const DownloadPrefix = "/downloads"
+
+// ArtifactInfo describes a generated file available for download
+type ArtifactInfo struct {
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+	DownloadURL string    `json:"download_url"`
+}
+
+// Key builds the storage key for a user's artifact
+func Key(userID int, kind, name string) string {
+	return path.Join(kind, strconv.Itoa(userID), path.Base(name))
+}
+
+// Write stores a generated artifact for a user and returns its key
+func Write(ctx context.Context, userID int, kind, name string, data []byte) (string, error) {
+	key := Key(userID, kind, name)
+	if err := storage.Get().Put(ctx, key, bytes.NewReader(data)); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// DownloadURL returns a short-lived signed absolute URL for an artifact key
+func DownloadURL(c *gin.Context, key string) string {
+	ttl := time.Duration(config.Get().SignedURLTTLMinutes) * time.Minute
+	return utils.AbsoluteURL(c, utils.SignURL(DownloadPrefix+"/"+key, ttl))
+}
+
+// LifecycleRules returns the retention rules applied to stored artifacts
+func LifecycleRules() []storage.Rule {
+	cfg := config.Get()
+	artifactAge := time.Duration(cfg.ArtifactRetentionHours) * time.Hour
+
+	return []storage.Rule{
+		{Prefix: KindExport + "/", MaxAge: artifactAge},
+		{Prefix: KindReceipt + "/", MaxAge: artifactAge},
+		{Prefix: KindDebugBundle + "/", MaxAge: artifactAge},
+		{Prefix: KindBackup + "/", MaxAge: time.Duration(cfg.BackupRetentionDays) * 24 * time.Hour},
+	}
+}
+
+// resolveKey validates a key taken from a request path
+func resolveKey(raw string) (string, error) {
+	key := strings.TrimPrefix(path.Clean("/"+raw), "/")
+	if key == "" || key == "." || strings.Contains(key, "..") {
+		return "", errors.New("invalid artifact path")
+	}
+	return key, nil
+}
+
+// ListArtifacts returns the current user's artifacts with signed download URLs
+func ListArtifacts(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	response := []ArtifactInfo{}
+	for _, kind := range userKinds {
+		objects, err := storage.Get().List(c.Request.Context(), kind+"/"+strconv.Itoa(u.ID)+"/")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list artifacts"})
+			return
+		}
+
+		for _, obj := range objects {
+			response = append(response, ArtifactInfo{
+				Name:        path.Base(obj.Key),
+				Kind:        kind,
+				Size:        obj.Size,
+				CreatedAt:   obj.ModTime,
+				DownloadURL: DownloadURL(c, obj.Key),
+			})
+		}
+	}
+
+	utils.RespondList(c, response, int64(len(response)), "")
+}
+
+// Download streams an artifact; access is granted by the URL signature alone
+func Download(c *gin.Context) {
+	key, err := resolveKey(c.Param("filepath"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reader, err := storage.Get().Get(c.Request.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer reader.Close()
+
+	// Images such as avatars are shown inline, everything else is downloaded
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if strings.HasPrefix(contentType, "image/") {
+		c.Header("Content-Disposition", `inline; filename="`+path.Base(key)+`"`)
+		c.Header("Content-Type", contentType)
+	} else {
+		c.Header("Content-Disposition", `attachment; filename="`+path.Base(key)+`"`)
+		c.Header("Content-Type", "application/octet-stream")
+	}
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		c.Error(err)
+	}
+}
+
+// SetupRoutes configures the public, signature-protected download route
+func SetupRoutes(router *gin.RouterGroup) {
+	router.GET("/*filepath", middleware.SignedURLRequired(), Download)
+}
+
+// SetupProtectedRoutes configures the artifact routes that require authentication
+func SetupProtectedRoutes(router *gin.RouterGroup) {
+	router.GET("/artifacts", ListArtifacts)
+}
@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Audit actions
+const (
+	ActionLogin           = "auth.login"
+	ActionLoginFailed     = "auth.login_failed"
+	ActionUserCreated     = "user.created"
+	ActionUserUpdated     = "user.updated"
+	ActionUserDeleted     = "user.deleted"
+	ActionProfileUpdated  = "user.profile_updated"
+	ActionAvatarUpdated   = "user.avatar_updated"
+	ActionPasswordChanged = "user.password_changed"
+	ActionSettingsSaved   = "settings.updated"
+	ActionTaskForceFail   = "task.force_failed"
+	ActionTaskRequeued    = "task.requeued"
+	ActionMaintenance     = "system.maintenance"
+	ActionTOSAccepted     = "user.tos_accepted"
+	ActionTOSUpdated      = "system.tos_updated"
+	ActionBrandingUpdated = "system.branding_updated"
+	ActionPlanCreated     = "plan.created"
+	ActionPlanUpdated     = "plan.updated"
+	ActionPlanDeleted     = "plan.deleted"
+	ActionPlanAssigned    = "user.plan_assigned"
+	ActionCouponCreated   = "coupon.created"
+	ActionCouponUpdated   = "coupon.updated"
+	ActionCouponRedeemed  = "coupon.redeemed"
+	ActionCouponRejected  = "coupon.rejected"
+	ActionTemplateSaved   = "notification.template_saved"
+	ActionTemplateDeleted = "notification.template_deleted"
+	// Panel error explanations shown for failed tasks
+	ActionPanelErrorSaved   = "panel_error.saved"
+	ActionPanelErrorDeleted = "panel_error.deleted"
+	// Localized panel messages mapped to error codes
+	ActionPanelErrorNormalizationSaved   = "panel_error.normalization_saved"
+	ActionPanelErrorNormalizationDeleted = "panel_error.normalization_deleted"
+	// Forwarding of audit entries to an external SIEM
+	ActionAuditForwardingUpdated = "system.audit_forwarding_updated"
+	ActionSLOTargetsUpdated      = "system.slo_targets_updated"
+	ActionHeartbeatsUpdated      = "system.heartbeats_updated"
+	ActionBenchSeeded            = "system.bench_seeded"
+	ActionShadowModeUpdated      = "system.shadow_mode_updated"
+	ActionJobTriggered           = "system.job_triggered"
+	ActionJobSchedulesUpdated    = "system.job_schedules_updated"
+	// Break-glass admin recovery from the server host
+	ActionRecoveryIssued = "auth.recovery_token_issued"
+	ActionRecoveryLogin  = "auth.recovery_login"
+	// Self-registration
+	ActionSignupRequested = "auth.signup_requested"
+	ActionSignupVerified  = "auth.signup_verified"
+	ActionSignupApproved  = "user.signup_approved"
+	ActionSignupRejected  = "user.signup_rejected"
+	// Self-service account deletion
+	ActionDeletionRequested = "user.deletion_requested"
+	ActionDeletionCanceled  = "user.deletion_canceled"
+	ActionUserErased        = "user.erased"
+	// Encrypted export of an organization's data
+	ActionTenantExported = "user.tenant_exported"
+
+	ActionPresetsSaved         = "settings.presets_updated"
+	ActionWebhookSecretRotated = "settings.webhook_secret_rotated"
+	ActionWebhookSecretRemoved = "settings.webhook_secret_removed"
+	ActionSettingsRollout      = "settings.rollout"
+
+	ActionSubAccountCreated = "user.subaccount_created"
+	ActionSubAccountUpdated = "user.subaccount_updated"
+	ActionSubAccountRevoked = "user.subaccount_revoked"
+
+	// Service clients using the client credentials grant
+	ActionClientCreated       = "client.created"
+	ActionClientUpdated       = "client.updated"
+	ActionClientSecretRotated = "client.secret_rotated"
+	ActionClientDeleted       = "client.deleted"
+)
+
+// Record stores an audit entry for the request's authenticated user.
+// Failures are logged and never interrupt the request.
+func Record(c *gin.Context, action, targetType string, targetID interface{}, details map[string]interface{}) {
+	entry := models.AuditLog{
+		Action:     action,
+		TargetType: targetType,
+		Details:    details,
+		IPAddress:  c.ClientIP(),
+		RequestID:  c.GetString("request_id"),
+	}
+	if targetID != nil {
+		entry.TargetID = fmt.Sprint(targetID)
+	}
+
+	// A sub-account working in its parent's data is the actor, not the parent
+	user, exists := c.Get("subaccount")
+	if !exists {
+		user, exists = c.Get("user")
+	}
+	if exists {
+		if u, ok := user.(models.User); ok {
+			entry.ActorID = &u.ID
+			entry.ActorUsername = u.Username
+		}
+	}
+
+	save(entry)
+}
+
+// RecordActor stores an audit entry for a known actor outside of the user context,
+// e.g. the login handler before the user is set on the request
+func RecordActor(c *gin.Context, actor *models.User, username, action string, details map[string]interface{}) {
+	entry := models.AuditLog{
+		ActorUsername: username,
+		Action:        action,
+		TargetType:    "user",
+		Details:       details,
+		IPAddress:     c.ClientIP(),
+		RequestID:     c.GetString("request_id"),
+	}
+	if actor != nil {
+		entry.ActorID = &actor.ID
+		entry.TargetID = fmt.Sprint(actor.ID)
+	}
+
+	save(entry)
+}
+
+// RecordService stores an audit entry for a request made by an integration
+// authenticated without a user, e.g. an identity provider using SCIM
+func RecordService(c *gin.Context, service, action, targetType string, targetID interface{}, details map[string]interface{}) {
+	entry := models.AuditLog{
+		ActorUsername: service,
+		Action:        action,
+		TargetType:    targetType,
+		Details:       details,
+		IPAddress:     c.ClientIP(),
+		RequestID:     c.GetString("request_id"),
+	}
+	if targetID != nil {
+		entry.TargetID = fmt.Sprint(targetID)
+	}
+
+	save(entry)
+}
+
+// RecordSystem stores an audit entry for actions without a request, such as CLI commands
+func RecordSystem(action, targetType string, targetID interface{}, details map[string]interface{}) {
+	entry := models.AuditLog{
+		ActorUsername: "system",
+		Action:        action,
+		TargetType:    targetType,
+		Details:       details,
+	}
+	if targetID != nil {
+		entry.TargetID = fmt.Sprint(targetID)
+	}
+
+	save(entry)
+}
+
+func save(entry models.AuditLog) {
+	db := database.GetDB()
+	if db == nil {
+		return
+	}
+	if err := createChained(db, &entry); err != nil {
+		log.Printf("Failed to write audit log entry %s: %v", entry.Action, err)
+		return
+	}
+	wakeForwarder()
+}
@@ -0,0 +1,497 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/fieldcrypt"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/settings"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Forwarding transports
+const (
+	TransportHTTP   = "http"
+	TransportSyslog = "syslog"
+)
+
+const (
+	// forwardInterval is how often new audit entries are looked for
+	forwardInterval = 5 * time.Second
+	// forwardRetryBase is the delay after the first failed send; it doubles with every failure
+	forwardRetryBase = 5 * time.Second
+	// forwardRetryMax caps the delay between retries
+	forwardRetryMax = 5 * time.Minute
+	// defaultForwardBatchSize is the number of entries sent at once unless configured
+	defaultForwardBatchSize = 100
+	// maxForwardBatchSize limits the configured batch size
+	maxForwardBatchSize = 1000
+	// forwardTimeout bounds a single send
+	forwardTimeout = 10 * time.Second
+	// forwardLeaseDuration is how long an instance keeps forwarding to itself
+	// without renewing its lease, which it does before every batch
+	forwardLeaseDuration = time.Minute
+	// maskedSecret replaces the auth header in responses; sending it back keeps the stored value
+	maskedSecret = "********"
+	// authHeaderContext binds the encrypted auth header to this setting
+	authHeaderContext = "system_settings.audit.forwarding.auth_header"
+)
+
+// ForwardConfig configures forwarding of audit log entries to a SIEM
+type ForwardConfig struct {
+	Enabled   bool   `json:"enabled"`
+	Transport string `json:"transport"`
+	// URL is the HTTP endpoint (http/https) or the syslog server (udp://host:514, tcp://host:601)
+	URL string `json:"url"`
+	// AuthHeader is sent as the Authorization header of HTTP requests
+	AuthHeader string `json:"auth_header,omitempty"`
+	// Actions limits forwarding to actions with one of these prefixes, e.g. "auth."; empty forwards all
+	Actions   []string `json:"actions"`
+	BatchSize int      `json:"batch_size"`
+}
+
+// ForwardStatus is the in-memory state of the forwarder
+type ForwardStatus struct {
+	LastForwardedID     int        `json:"last_forwarded_id"`
+	LastForwardedAt     *time.Time `json:"last_forwarded_at"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	NextAttemptAt       *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+var (
+	forwardMu     sync.Mutex
+	forwardStatus ForwardStatus
+
+	// forwardWake triggers a forwarding round right after an entry is written
+	forwardWake = make(chan struct{}, 1)
+
+	forwardClient = &http.Client{Timeout: forwardTimeout}
+
+	// forwardHolder identifies this instance in the forwarding lease
+	forwardHolder = uuid.NewString()
+)
+
+// LoadForwardConfig returns the stored forwarding configuration
+func LoadForwardConfig(db *gorm.DB) (ForwardConfig, error) {
+	var cfg ForwardConfig
+	value, err := settings.Get(db, settings.KeyAuditForwarding)
+	if err != nil || value == "" {
+		return cfg, err
+	}
+	if err := json.Unmarshal([]byte(value), &cfg); err != nil {
+		return cfg, fmt.Errorf("error decoding audit forwarding: %v", err)
+	}
+	if cfg.AuthHeader, err = fieldcrypt.DecryptString(cfg.AuthHeader, authHeaderContext); err != nil {
+		return cfg, fmt.Errorf("error decrypting the auth header: %v", err)
+	}
+	return cfg, nil
+}
+
+// SaveForwardConfig stores the forwarding configuration. Forwarding enabled for
+// the first time starts after the newest entry, so the history is not replayed.
+func SaveForwardConfig(db *gorm.DB, cfg ForwardConfig, updatedBy *int) error {
+	// The auth header is a secret, so it is stored encrypted
+	authHeader, err := fieldcrypt.EncryptString(cfg.AuthHeader, authHeaderContext)
+	if err != nil {
+		return err
+	}
+	cfg.AuthHeader = authHeader
+
+	value, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := settings.Set(db, settings.KeyAuditForwarding, string(value), updatedBy); err != nil {
+		return err
+	}
+
+	if cfg.Enabled {
+		cursor, err := settings.Get(db, settings.KeyAuditForwardCursor)
+		if err != nil {
+			return err
+		}
+		if cursor == "" {
+			var lastID int
+			if err := db.Model(&models.AuditLog{}).Select("COALESCE(MAX(id), 0)").Scan(&lastID).Error; err != nil {
+				return err
+			}
+			if err := settings.Set(db, settings.KeyAuditForwardCursor, strconv.Itoa(lastID), updatedBy); err != nil {
+				return err
+			}
+		}
+	}
+
+	wakeForwarder()
+	return nil
+}
+
+// Validate trims the fields and checks them for the transport
+func (f *ForwardConfig) Validate() error {
+	f.Transport = strings.ToLower(strings.TrimSpace(f.Transport))
+	f.URL = strings.TrimSpace(f.URL)
+	f.AuthHeader = strings.TrimSpace(f.AuthHeader)
+
+	if f.BatchSize == 0 {
+		f.BatchSize = defaultForwardBatchSize
+	}
+	if f.BatchSize < 1 || f.BatchSize > maxForwardBatchSize {
+		return fmt.Errorf("batch_size must be between 1 and %d", maxForwardBatchSize)
+	}
+
+	if !f.Enabled && f.URL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(f.URL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("url must be an absolute URL")
+	}
+	switch f.Transport {
+	case TransportHTTP:
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("url must be an http or https URL for the http transport")
+		}
+	case TransportSyslog:
+		if u.Scheme != "udp" && u.Scheme != "tcp" {
+			return fmt.Errorf("url must be udp://host:port or tcp://host:port for the syslog transport")
+		}
+		if u.Port() == "" {
+			return fmt.Errorf("url must include the syslog port")
+		}
+		if f.AuthHeader != "" {
+			return fmt.Errorf("auth_header applies to the http transport only")
+		}
+	default:
+		return fmt.Errorf("transport must be http or syslog")
+	}
+	return nil
+}
+
+// matches reports whether an action is forwarded
+func (f ForwardConfig) matches(action string) bool {
+	if len(f.Actions) == 0 {
+		return true
+	}
+	for _, prefix := range f.Actions {
+		if strings.HasPrefix(action, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetForwardStatus returns the current state of the forwarder
+func GetForwardStatus() ForwardStatus {
+	forwardMu.Lock()
+	defer forwardMu.Unlock()
+	return forwardStatus
+}
+
+// wakeForwarder starts a forwarding round without waiting for the next tick
+func wakeForwarder() {
+	select {
+	case forwardWake <- struct{}{}:
+	default:
+	}
+}
+
+// forwardBackoff returns the delay after the given number of consecutive failures
+func forwardBackoff(failures int) time.Duration {
+	delay := forwardRetryBase << (failures - 1)
+	if failures > 20 || delay > forwardRetryMax {
+		return forwardRetryMax
+	}
+	return delay
+}
+
+// StartForwarder sends new audit entries to the configured SIEM in batches, in
+// order, retrying failed batches with exponential backoff. The position is kept
+// in a system setting, so entries written while the SIEM or the server was down
+// are sent once it is back. Only the instance holding the forwarding lease
+// sends, so entries are not forwarded once per instance.
+func StartForwarder(db *gorm.DB) {
+	jobs.Register(jobs.Job{
+		Name:        "audit_forwarding",
+		Description: "Send new audit entries to the SIEM",
+		Interval:    forwardInterval,
+		MinInterval: time.Second,
+		RunOnStart:  true,
+		Wake:        forwardWake,
+		Run: func() error {
+			forwardPending(db)
+			return nil
+		},
+	})
+}
+
+// forwardPending sends batches until no entries are left or a send fails
+func forwardPending(db *gorm.DB) {
+	cfg, err := LoadForwardConfig(db)
+	if err != nil {
+		log.Printf("Failed to load audit forwarding configuration: %v", err)
+		return
+	}
+	if !cfg.Enabled {
+		return
+	}
+
+	forwardMu.Lock()
+	next := forwardStatus.NextAttemptAt
+	forwardMu.Unlock()
+	if next != nil && time.Now().Before(*next) {
+		return
+	}
+
+	for {
+		claimed, err := claimForwardLease(db)
+		if err != nil {
+			log.Printf("Failed to claim the audit forwarding lease: %v", err)
+			return
+		}
+		if !claimed {
+			return
+		}
+
+		value, err := settings.Get(db, settings.KeyAuditForwardCursor)
+		if err != nil {
+			log.Printf("Failed to load audit forwarding cursor: %v", err)
+			return
+		}
+		cursor, _ := strconv.Atoi(value)
+
+		var entries []models.AuditLog
+		if err := db.Where("id > ?", cursor).Order("id").Limit(cfg.BatchSize).Find(&entries).Error; err != nil {
+			log.Printf("Failed to load audit entries to forward: %v", err)
+			return
+		}
+		if len(entries) == 0 {
+			return
+		}
+
+		batch := make([]models.AuditLog, 0, len(entries))
+		for _, entry := range entries {
+			if cfg.matches(entry.Action) {
+				batch = append(batch, entry)
+			}
+		}
+
+		if len(batch) > 0 {
+			if err := sendAuditBatch(cfg, batch); err != nil {
+				recordForwardFailure(err)
+				return
+			}
+		}
+
+		lastID := entries[len(entries)-1].ID
+		if err := settings.Set(db, settings.KeyAuditForwardCursor, strconv.Itoa(lastID), nil); err != nil {
+			log.Printf("Failed to save audit forwarding cursor: %v", err)
+			return
+		}
+		recordForwardSuccess(lastID)
+
+		if len(entries) < cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// claimForwardLease takes or renews the forwarding lease for this instance. It
+// returns false while another instance holds an unexpired lease.
+func claimForwardLease(db *gorm.DB) (bool, error) {
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.SystemSetting{Key: settings.KeyAuditForwardLease}).Error; err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	claim := db.Model(&models.SystemSetting{}).
+		Where("key = ? AND (value = '' OR value = ? OR updated_at < ?)",
+			settings.KeyAuditForwardLease, forwardHolder, now.Add(-forwardLeaseDuration)).
+		Updates(map[string]interface{}{"value": forwardHolder, "updated_at": now})
+	if claim.Error != nil {
+		return false, claim.Error
+	}
+	return claim.RowsAffected == 1, nil
+}
+
+// recordForwardFailure schedules the next attempt after a failed send
+func recordForwardFailure(err error) {
+	forwardMu.Lock()
+	defer forwardMu.Unlock()
+
+	forwardStatus.ConsecutiveFailures++
+	forwardStatus.LastError = err.Error()
+	next := time.Now().Add(forwardBackoff(forwardStatus.ConsecutiveFailures))
+	forwardStatus.NextAttemptAt = &next
+
+	log.Printf("Failed to forward audit entries (attempt %d, retrying at %s): %v",
+		forwardStatus.ConsecutiveFailures, next.Format(time.RFC3339), err)
+}
+
+// recordForwardSuccess clears the failure state after a batch was sent
+func recordForwardSuccess(lastID int) {
+	forwardMu.Lock()
+	defer forwardMu.Unlock()
+
+	now := time.Now()
+	forwardStatus.LastForwardedID = lastID
+	forwardStatus.LastForwardedAt = &now
+	forwardStatus.ConsecutiveFailures = 0
+	forwardStatus.LastError = ""
+	forwardStatus.NextAttemptAt = nil
+}
+
+// forwardedEntry is the JSON representation of an audit entry sent to the SIEM
+type forwardedEntry struct {
+	ID            int                    `json:"id"`
+	Timestamp     time.Time              `json:"timestamp"`
+	Action        string                 `json:"action"`
+	ActorID       *int                   `json:"actor_id"`
+	ActorUsername string                 `json:"actor_username"`
+	TargetType    string                 `json:"target_type"`
+	TargetID      string                 `json:"target_id"`
+	Details       map[string]interface{} `json:"details"`
+	IPAddress     string                 `json:"ip_address"`
+	RequestID     string                 `json:"request_id"`
+	Host          string                 `json:"host"`
+}
+
+func newForwardedEntry(entry models.AuditLog, host string) forwardedEntry {
+	return forwardedEntry{
+		ID:            entry.ID,
+		Timestamp:     entry.CreatedAt.UTC(),
+		Action:        entry.Action,
+		ActorID:       entry.ActorID,
+		ActorUsername: entry.ActorUsername,
+		TargetType:    entry.TargetType,
+		TargetID:      entry.TargetID,
+		Details:       entry.Details,
+		IPAddress:     entry.IPAddress,
+		RequestID:     entry.RequestID,
+		Host:          host,
+	}
+}
+
+// sendAuditBatch sends entries with the configured transport
+func sendAuditBatch(cfg ForwardConfig, entries []models.AuditLog) error {
+	host, _ := os.Hostname()
+	forwarded := make([]forwardedEntry, len(entries))
+	for i, entry := range entries {
+		forwarded[i] = newForwardedEntry(entry, host)
+	}
+
+	if cfg.Transport == TransportSyslog {
+		return sendSyslog(cfg.URL, host, forwarded)
+	}
+	return sendHTTP(cfg, forwarded)
+}
+
+// sendHTTP posts the batch as a JSON array
+func sendHTTP(cfg ForwardConfig, entries []forwardedEntry) error {
+	payload, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", cfg.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.AuthHeader != "" {
+		req.Header.Set("Authorization", cfg.AuthHeader)
+	}
+
+	resp, err := forwardClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendSyslog writes one RFC 5424 message per entry with the entry as JSON
+// payload. TCP messages are newline delimited; UDP sends one datagram each.
+func sendSyslog(rawURL, host string, entries []forwardedEntry) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout(u.Scheme, u.Host, forwardTimeout)
+	if err != nil {
+		return fmt.Errorf("error connecting to syslog server: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(forwardTimeout))
+
+	if host == "" {
+		host = "-"
+	}
+	for _, entry := range entries {
+		payload, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		// Facility security/authorization (10), severity notice (5)
+		msg := fmt.Sprintf("<85>1 %s %s account-editor - %s - %s",
+			entry.Timestamp.Format(time.RFC3339Nano), host, syslogMsgID(entry.Action), payload)
+		if u.Scheme == "tcp" {
+			msg += "\n"
+		}
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			return fmt.Errorf("error writing to syslog server: %v", err)
+		}
+	}
+	return nil
+}
+
+// syslogMsgID returns the action as an RFC 5424 MSGID (printable ASCII, at most 32 characters)
+func syslogMsgID(action string) string {
+	id := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, action)
+	if len(id) > 32 {
+		id = id[:32]
+	}
+	if id == "" {
+		return "-"
+	}
+	return id
+}
+
+// SendTestEvent sends a single test entry with the given configuration
+func SendTestEvent(cfg ForwardConfig) error {
+	return sendAuditBatch(cfg, []models.AuditLog{{
+		ActorUsername: "system",
+		Action:        "system.audit_forwarding_test",
+		TargetType:    "settings",
+		TargetID:      "audit_forwarding",
+		Details:       map[string]interface{}{"message": "Audit forwarding test event"},
+		CreatedAt:     time.Now(),
+	}})
+}
@@ -0,0 +1,207 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/internal/artifacts"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetAuditLogs lists audit entries, newest first (admin only)
+func GetAuditLogs(c *gin.Context) {
+	page, err := utils.ParsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page == nil {
+		page = &utils.Page{Limit: utils.DefaultPageSize}
+	}
+
+	query := database.GetReadDB().Model(&models.AuditLog{})
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit logs"})
+		return
+	}
+
+	var entries []models.AuditLog
+	if err := page.Apply(query).Preload("Actor").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit logs"})
+		return
+	}
+
+	n, next := page.NextCursor(len(entries), func(i int) utils.Cursor {
+		return utils.Cursor{CreatedAt: entries[i].CreatedAt, ID: entries[i].ID}
+	})
+	entries = entries[:n]
+	c.Header("X-Next-Cursor", next)
+
+	response := make([]gin.H, 0, len(entries))
+	for _, entry := range entries {
+		item := gin.H{
+			"id":                 entry.ID,
+			"actor_id":           entry.ActorID,
+			"actor_username":     entry.ActorUsername,
+			"actor_display_name": "",
+			"actor_avatar_url":   "",
+			"action":             entry.Action,
+			"target_type":        entry.TargetType,
+			"target_id":          entry.TargetID,
+			"details":            entry.Details,
+			"ip_address":         entry.IPAddress,
+			"request_id":         entry.RequestID,
+			"created_at":         entry.CreatedAt,
+			"hash":               entry.Hash,
+		}
+		if entry.Actor != nil {
+			item["actor_display_name"] = entry.Actor.DisplayName
+			if entry.Actor.AvatarKey != "" {
+				item["actor_avatar_url"] = artifacts.DownloadURL(c, entry.Actor.AvatarKey)
+			}
+		}
+		response = append(response, item)
+	}
+
+	utils.RespondList(c, response, total, next)
+}
+
+// VerifyAuditLogs verifies the audit log chain and reports entries that were
+// modified or deleted (admin only)
+func VerifyAuditLogs(c *gin.Context) {
+	report, err := VerifyChain(database.GetReadDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify audit logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// forwardingResponse is the forwarding configuration with the auth header masked
+func forwardingResponse(cfg ForwardConfig) gin.H {
+	authHeader := ""
+	if cfg.AuthHeader != "" {
+		authHeader = maskedSecret
+	}
+	if cfg.Actions == nil {
+		cfg.Actions = []string{}
+	}
+	return gin.H{
+		"enabled":     cfg.Enabled,
+		"transport":   cfg.Transport,
+		"url":         cfg.URL,
+		"auth_header": authHeader,
+		"actions":     cfg.Actions,
+		"batch_size":  cfg.BatchSize,
+		"status":      GetForwardStatus(),
+	}
+}
+
+// bindForwardConfig binds and validates a forwarding configuration, keeping the
+// stored auth header when the masked value is sent back. It responds on failure.
+func bindForwardConfig(c *gin.Context) (ForwardConfig, bool) {
+	var req ForwardConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+
+	if req.AuthHeader == maskedSecret {
+		current, err := LoadForwardConfig(database.GetDB())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to load settings")})
+			return req, false
+		}
+		req.AuthHeader = current.AuthHeader
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	return req, true
+}
+
+// GetAuditForwarding returns the SIEM forwarding configuration and its status (admin only)
+func GetAuditForwarding(c *gin.Context) {
+	cfg, err := LoadForwardConfig(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to load settings")})
+		return
+	}
+
+	c.JSON(http.StatusOK, forwardingResponse(cfg))
+}
+
+// UpdateAuditForwarding replaces the SIEM forwarding configuration (admin only)
+func UpdateAuditForwarding(c *gin.Context) {
+	req, ok := bindForwardConfig(c)
+	if !ok {
+		return
+	}
+
+	var updatedBy *int
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			updatedBy = &u.ID
+		}
+	}
+
+	if err := SaveForwardConfig(database.GetDB(), req, updatedBy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update settings")})
+		return
+	}
+
+	Record(c, ActionAuditForwardingUpdated, "settings", "audit_forwarding", map[string]interface{}{
+		"enabled":   req.Enabled,
+		"transport": req.Transport,
+		"url":       req.URL,
+		"actions":   req.Actions,
+	})
+
+	c.JSON(http.StatusOK, forwardingResponse(req))
+}
+
+// TestAuditForwarding sends a test event with the submitted configuration (admin only)
+func TestAuditForwarding(c *gin.Context) {
+	req, ok := bindForwardConfig(c)
+	if !ok {
+		return
+	}
+	if req.URL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url is required"})
+		return
+	}
+
+	if err := SendTestEvent(req); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   i18n.T(c, "Failed to deliver the test event"),
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Test event delivered")})
+}
+
+// SetupAdminRoutes configures the audit log routes for admins
+func SetupAdminRoutes(router *gin.RouterGroup) {
+	router.GET("/audit-logs", GetAuditLogs)
+	router.GET("/audit-logs/verify", VerifyAuditLogs)
+	router.GET("/settings/audit-forwarding", GetAuditForwarding)
+	router.PUT("/settings/audit-forwarding", UpdateAuditForwarding)
+	router.POST("/settings/audit-forwarding/test", TestAuditForwarding)
+}
@@ -0,0 +1,372 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/middleware"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+const (
+	// clientTokenTTL is how long a service client token is valid
+	clientTokenTTL = time.Hour
+	// maxClientRateLimit bounds the requests per minute a client can be granted
+	maxClientRateLimit = 6000
+)
+
+// clientTokenLimiter slows down guessing of client secrets: a burst of 10, then one attempt every 2 seconds per IP
+var clientTokenLimiter = middleware.NewIPRateLimiter(rate.Every(2*time.Second), 10)
+
+// ClientTokenRequest is an OAuth 2.0 client credentials token request, as
+// form fields or JSON. The credentials may also be sent with HTTP Basic auth.
+type ClientTokenRequest struct {
+	GrantType    string `form:"grant_type" json:"grant_type"`
+	ClientID     string `form:"client_id" json:"client_id"`
+	ClientSecret string `form:"client_secret" json:"client_secret"`
+	// Scope optionally narrows the token to some of the client's scopes
+	Scope string `form:"scope" json:"scope"`
+}
+
+type CreateClientRequest struct {
+	Name               string   `json:"name" binding:"required"`
+	UserID             int      `json:"user_id" binding:"required"`
+	Scopes             []string `json:"scopes" binding:"required"`
+	RateLimitPerMinute int      `json:"rate_limit_per_minute"`
+}
+
+type UpdateClientRequest struct {
+	Name               *string  `json:"name"`
+	Scopes             []string `json:"scopes"`
+	RateLimitPerMinute *int     `json:"rate_limit_per_minute"`
+	IsActive           *bool    `json:"is_active"`
+}
+
+// hashClientSecret returns the stored form of a client secret
+func hashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validateScopes checks that every scope is known
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		known := false
+		for _, s := range middleware.AllScopes {
+			if s == scope {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown scope %q; scopes are %s", scope, strings.Join(middleware.AllScopes, ", "))
+		}
+	}
+	return nil
+}
+
+func validateRateLimit(perMin int) error {
+	if perMin < 0 || perMin > maxClientRateLimit {
+		return fmt.Errorf("rate_limit_per_minute must be between 0 and %d", maxClientRateLimit)
+	}
+	return nil
+}
+
+// oauthError responds with an RFC 6749 error
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+// ClientToken issues a scoped token to a service client with the client
+// credentials grant. The token acts on behalf of the client's user.
+func ClientToken(c *gin.Context) {
+	var req ClientTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		req.ClientID, req.ClientSecret = id, secret
+	}
+	if req.GrantType != "client_credentials" {
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "grant_type must be client_credentials")
+		return
+	}
+
+	db := database.GetDB()
+
+	var client models.ServiceClient
+	err := db.Where("client_id = ?", req.ClientID).First(&client).Error
+	if err != nil || !client.IsActive ||
+		subtle.ConstantTimeCompare([]byte(hashClientSecret(req.ClientSecret)), []byte(client.SecretHash)) != 1 {
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "Invalid client credentials")
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, client.UserID).Error; err != nil || !user.IsActive || user.ErasedAt != nil {
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "The client's user is inactive")
+		return
+	}
+
+	scopes := client.Scopes
+	if req.Scope != "" {
+		scopes = strings.Fields(req.Scope)
+		for _, scope := range scopes {
+			if !client.HasScope(scope) {
+				oauthError(c, http.StatusBadRequest, "invalid_scope", fmt.Sprintf("the client is not granted %q", scope))
+				return
+			}
+		}
+	}
+	scope := strings.Join(scopes, " ")
+
+	token, err := utils.CreateClientToken(user.Username, client.ClientID, scope, clientTokenTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		return
+	}
+
+	now := time.Now()
+	if err := db.Model(&client).Update("last_used_at", now).Error; err != nil {
+		// Log the error but don't fail the token request
+		fmt.Printf("Failed to update last use of client %s: %v\n", client.ClientID, err)
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "bearer",
+		"expires_in":   int(clientTokenTTL.Seconds()),
+		"scope":        scope,
+	})
+}
+
+// GetClients lists the registered service clients (admin only)
+func GetClients(c *gin.Context) {
+	query := database.GetDB().Order("id")
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var clients []models.ServiceClient
+	if err := query.Find(&clients).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	utils.RespondList(c, clients, int64(len(clients)), "")
+}
+
+// CreateClient registers a service client for a user and returns its secret,
+// which is shown only once (admin only)
+func CreateClient(c *gin.Context) {
+	var req CreateClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateScopes(req.Scopes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateRateLimit(req.RateLimitPerMinute); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, req.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "User not found")})
+		return
+	}
+
+	clientID, err := randomHex(12)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+		return
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+		return
+	}
+
+	client := models.ServiceClient{
+		ClientID:           "cli_" + clientID,
+		SecretHash:         hashClientSecret(secret),
+		Name:               strings.TrimSpace(req.Name),
+		UserID:             user.ID,
+		Scopes:             req.Scopes,
+		RateLimitPerMinute: req.RateLimitPerMinute,
+		IsActive:           true,
+	}
+	if admin, exists := c.Get("user"); exists {
+		if u, ok := admin.(models.User); ok {
+			client.CreatedBy = &u.ID
+		}
+	}
+	if err := db.Create(&client).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionClientCreated, "service_client", client.ID, map[string]interface{}{
+		"client_id": client.ClientID,
+		"name":      client.Name,
+		"user_id":   client.UserID,
+		"scopes":    client.Scopes,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"client":        client,
+		"client_secret": secret,
+	})
+}
+
+// findClient loads the client addressed by the :id parameter, responding on failure
+func findClient(c *gin.Context, db *gorm.DB) (models.ServiceClient, bool) {
+	var client models.ServiceClient
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid client ID"})
+		return client, false
+	}
+	if err := db.First(&client, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Client not found")})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		}
+		return client, false
+	}
+	return client, true
+}
+
+// UpdateClient changes a client's name, scopes, rate limit or active state (admin only)
+func UpdateClient(c *gin.Context) {
+	db := database.GetDB()
+	client, ok := findClient(c, db)
+	if !ok {
+		return
+	}
+
+	var req UpdateClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Name != nil {
+		client.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Scopes != nil {
+		if err := validateScopes(req.Scopes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		client.Scopes = req.Scopes
+	}
+	if req.RateLimitPerMinute != nil {
+		if err := validateRateLimit(*req.RateLimitPerMinute); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		client.RateLimitPerMinute = *req.RateLimitPerMinute
+	}
+	if req.IsActive != nil {
+		client.IsActive = *req.IsActive
+	}
+
+	if err := db.Select("name", "scopes", "rate_limit_per_minute", "is_active").Updates(&client).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionClientUpdated, "service_client", client.ID, map[string]interface{}{
+		"client_id":             client.ClientID,
+		"scopes":                client.Scopes,
+		"rate_limit_per_minute": client.RateLimitPerMinute,
+		"is_active":             client.IsActive,
+	})
+
+	c.JSON(http.StatusOK, client)
+}
+
+// RotateClientSecret replaces a client's secret; tokens issued before stay
+// valid until they expire (admin only)
+func RotateClientSecret(c *gin.Context) {
+	db := database.GetDB()
+	client, ok := findClient(c, db)
+	if !ok {
+		return
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create client"})
+		return
+	}
+	if err := db.Model(&client).Update("secret_hash", hashClientSecret(secret)).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionClientSecretRotated, "service_client", client.ID, map[string]interface{}{
+		"client_id": client.ClientID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"client":        client,
+		"client_secret": secret,
+	})
+}
+
+// DeleteClient removes a client; its tokens stop working immediately (admin only)
+func DeleteClient(c *gin.Context) {
+	db := database.GetDB()
+	client, ok := findClient(c, db)
+	if !ok {
+		return
+	}
+
+	if err := db.Delete(&client).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionClientDeleted, "service_client", client.ID, map[string]interface{}{
+		"client_id": client.ClientID,
+		"name":      client.Name,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Client deleted")})
+}
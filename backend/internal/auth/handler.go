@@ -6,7 +6,11 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/config"
 	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/middleware"
 	"github.com/aliselcukkaya/account-editor/internal/models"
 	"github.com/aliselcukkaya/account-editor/pkg/utils"
 	"github.com/gin-gonic/gin"
@@ -19,7 +23,7 @@ type UserStatus struct {
 }
 
 type TokenResponse struct {
-	AccessToken string `json:"access_token"`
+	AccessToken string `json:"access_token,omitempty"`
 	TokenType   string `json:"token_type"`
 	Username    string `json:"username"`
 }
@@ -33,12 +37,29 @@ type CreateUserRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
 	IsAdmin  bool   `json:"is_admin"`
+	// AdminRole is superadmin (the default) or support; ignored for non-admins
+	AdminRole string `json:"admin_role"`
 }
 
 type UpdateUserRequest struct {
-	Password string `json:"password"`
-	IsAdmin  bool   `json:"is_admin"`
-	IsActive bool   `json:"is_active"`
+	Password  string `json:"password"`
+	IsAdmin   bool   `json:"is_admin"`
+	IsActive  bool   `json:"is_active"`
+	AdminRole string `json:"admin_role"`
+}
+
+// adminRole validates the requested admin role, returning the value to store
+func adminRole(isAdmin bool, role string) (string, bool) {
+	if !isAdmin {
+		return "", true
+	}
+	switch role {
+	case "", models.AdminRoleSuperadmin:
+		return models.AdminRoleSuperadmin, true
+	case models.AdminRoleSupport:
+		return models.AdminRoleSupport, true
+	}
+	return "", false
 }
 
 // GetUserStatus returns the status of the currently authenticated user
@@ -47,7 +68,7 @@ func GetUserStatus(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "User not authenticated",
+			"error": i18n.T(c, "User not authenticated"),
 		})
 		return
 	}
@@ -61,9 +82,14 @@ func GetUserStatus(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"is_active":  u.IsActive,
-		"is_admin":   u.IsAdmin,
-		"created_at": u.CreatedAt,
+		"is_active":   u.IsActive,
+		"is_admin":    u.IsAdmin,
+		"admin_role":  u.Role(),
+		"permissions": middleware.Permissions(u),
+		"created_at":  u.CreatedAt,
+
+		"deletion_scheduled_at": u.DeletionScheduledAt,
+		"parent_id":             u.ParentID,
 	})
 }
 
@@ -79,12 +105,20 @@ func Login(c *gin.Context) {
 
 	user, err := utils.AuthenticateUser(db, req.Username, req.Password)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		// The typed username is not recorded, it may be personal data the
+		// signed chain would keep after erasure; known users are referenced by ID
+		details := map[string]interface{}{"method": "password"}
+		var known models.User
+		if db.Select("id").Where("username = ?", req.Username).First(&known).Error == nil {
+			details["user_id"] = known.ID
+		}
+		audit.RecordActor(c, nil, "", audit.ActionLoginFailed, details)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "Invalid username or password")})
 		return
 	}
 
 	if !user.IsActive {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is inactive. Please contact administrator."})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "Account is inactive. Please contact administrator.")})
 		return
 	}
 
@@ -96,12 +130,36 @@ func Login(c *gin.Context) {
 		fmt.Printf("Failed to update last login time: %v\n", err)
 	}
 
+	audit.RecordActor(c, user, user.Username, audit.ActionLogin, nil)
+
+	respondWithSession(c, user)
+}
+
+// respondWithSession issues an access token for the user, as a cookie session
+// in cookie mode or in the response body otherwise
+func respondWithSession(c *gin.Context, user *models.User) {
 	token, err := utils.CreateAccessToken(user.Username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
 		return
 	}
 
+	// In cookie mode the token never reaches JavaScript
+	if config.Get().CookieAuthEnabled() {
+		csrfToken, err := utils.GenerateCSRFToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+			return
+		}
+
+		utils.SetSessionCookies(c, token, csrfToken)
+		c.JSON(http.StatusOK, TokenResponse{
+			TokenType: "cookie",
+			Username:  user.Username,
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, TokenResponse{
 		AccessToken: token,
 		TokenType:   "bearer",
@@ -109,6 +167,12 @@ func Login(c *gin.Context) {
 	})
 }
 
+// Logout clears the session cookies used in cookie auth mode
+func Logout(c *gin.Context) {
+	utils.ClearSessionCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Logged out successfully")})
+}
+
 // CreateUser creates a new user (admin only)
 func CreateUser(c *gin.Context) {
 	var req CreateUserRequest
@@ -117,16 +181,22 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
+	role, ok := adminRole(req.IsAdmin, req.AdminRole)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Admin role must be superadmin or support")})
+		return
+	}
+
 	db := database.GetDB()
 
 	// Check if user already exists
 	var existingUser models.User
 	result := db.Where("username = ?", req.Username).First(&existingUser)
 	if result.Error == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Username already registered"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Username already registered")})
 		return
 	} else if result.Error != gorm.ErrRecordNotFound {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
 		return
 	}
 
@@ -142,6 +212,7 @@ func CreateUser(c *gin.Context) {
 		Username:       req.Username,
 		HashedPassword: hashedPassword,
 		IsAdmin:        req.IsAdmin,
+		AdminRole:      role,
 	}
 
 	if err := db.Create(&user).Error; err != nil {
@@ -149,11 +220,18 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 
+	audit.Record(c, audit.ActionUserCreated, "user", user.ID, map[string]interface{}{
+		"username":   user.Username,
+		"is_admin":   user.IsAdmin,
+		"admin_role": user.Role(),
+	})
+
 	c.JSON(http.StatusCreated, gin.H{
-		"id":       user.ID,
-		"username": user.Username,
-		"is_admin": user.IsAdmin,
-		"message":  "User created successfully",
+		"id":         user.ID,
+		"username":   user.Username,
+		"is_admin":   user.IsAdmin,
+		"admin_role": user.Role(),
+		"message":    i18n.T(c, "User created successfully"),
 	})
 }
 
@@ -161,28 +239,65 @@ func CreateUser(c *gin.Context) {
 func GetUsers(c *gin.Context) {
 	db := database.GetDB()
 
+	page, err := utils.ParsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := db.Model(&models.User{})
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+
+	if page != nil {
+		query = page.Apply(query)
+	}
+
 	var users []models.User
-	if err := db.Find(&users).Error; err != nil {
+	if err := query.Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
 		return
 	}
 
+	next := ""
+	if page != nil {
+		var n int
+		n, next = page.NextCursor(len(users), func(i int) utils.Cursor {
+			return utils.Cursor{CreatedAt: users[i].CreatedAt, ID: users[i].ID}
+		})
+		users = users[:n]
+		c.Header("X-Next-Cursor", next)
+	}
+
 	// Map to response format without exposing sensitive data
-	var response []gin.H
+	response := []gin.H{}
 	for _, user := range users {
 		userData := gin.H{
 			"id":            user.ID,
 			"username":      user.Username,
+			"display_name":  user.DisplayName,
+			"avatar_url":    AvatarURL(c, user),
 			"is_admin":      user.IsAdmin,
+			"admin_role":    user.Role(),
 			"is_active":     user.IsActive,
+			"is_demo":       user.IsDemo,
+			"plan_id":       user.PlanID,
+			"parent_id":     user.ParentID,
 			"created_at":    user.CreatedAt,
 			"last_login_at": user.LastLoginAt,
+
+			"tos_version_accepted":  user.TOSVersionAccepted,
+			"deletion_scheduled_at": user.DeletionScheduledAt,
 		}
 
 		response = append(response, userData)
 	}
 
-	c.JSON(http.StatusOK, response)
+	utils.RespondList(c, response, total, next)
 }
 
 // UpdateUser updates a user (admin only)
@@ -200,15 +315,21 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	role, ok := adminRole(req.IsAdmin, req.AdminRole)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Admin role must be superadmin or support")})
+		return
+	}
+
 	db := database.GetDB()
 
 	// Find user by ID
 	var user models.User
 	if err := db.First(&user, userID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "User not found")})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
 		}
 		return
 	}
@@ -221,8 +342,11 @@ func UpdateUser(c *gin.Context) {
 			return
 		}
 		user.HashedPassword = hashedPassword
+		// A password set by an admin is temporary until the user changes it
+		user.PasswordChangedAt = nil
 	}
 	user.IsAdmin = req.IsAdmin
+	user.AdminRole = role
 	user.IsActive = req.IsActive
 
 	// Save changes
@@ -231,12 +355,20 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	audit.Record(c, audit.ActionUserUpdated, "user", user.ID, map[string]interface{}{
+		"is_admin":         user.IsAdmin,
+		"admin_role":       user.Role(),
+		"is_active":        user.IsActive,
+		"password_changed": req.Password != "",
+	})
+
 	c.JSON(http.StatusOK, gin.H{
-		"id":        user.ID,
-		"username":  user.Username,
-		"is_admin":  user.IsAdmin,
-		"is_active": user.IsActive,
-		"message":   "User updated successfully",
+		"id":         user.ID,
+		"username":   user.Username,
+		"is_admin":   user.IsAdmin,
+		"admin_role": user.Role(),
+		"is_active":  user.IsActive,
+		"message":    i18n.T(c, "User updated successfully"),
 	})
 }
 
@@ -255,9 +387,9 @@ func DeleteUser(c *gin.Context) {
 	var user models.User
 	if err := db.First(&user, userID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "User not found")})
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
 		}
 		return
 	}
@@ -268,19 +400,47 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
+	audit.Record(c, audit.ActionUserDeleted, "user", user.ID, map[string]interface{}{
+		"username": user.Username,
+	})
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User deleted successfully",
+		"message": i18n.T(c, "User deleted successfully"),
 	})
 }
 
 // SetupRoutes configures the auth routes
 func SetupRoutes(router *gin.RouterGroup) {
 	router.POST("/token", Login)
+	router.POST("/logout", Logout)
+	router.POST("/recover", middleware.RateLimiterMiddleware(recoverLimiter), RecoverLogin)
+	router.POST("/client-token", middleware.RateLimiterMiddleware(clientTokenLimiter), ClientToken)
+
+	if config.Get().IntrospectionTokens != "" {
+		router.POST("/introspect", serviceTokenRequired(), Introspect)
+	}
+
+	if config.Get().SignupEnabled {
+		router.POST("/signup", middleware.RateLimiterMiddleware(signupLimiter), Signup)
+		router.GET("/signup/verify", middleware.RateLimiterMiddleware(verifyLimiter), VerifySignup)
+		router.POST("/signup/verify", middleware.RateLimiterMiddleware(verifyLimiter), VerifySignup)
+	}
 }
 
 // SetupProtectedRoutes configures the protected auth routes that require authentication
 func SetupProtectedRoutes(router *gin.RouterGroup) {
 	router.GET("/status", GetUserStatus)
+	router.GET("/me", GetProfile)
+	router.PUT("/me", UpdateProfile)
+	router.PUT("/me/password", ChangePassword)
+	router.PUT("/me/avatar", UploadAvatar)
+	router.DELETE("/me/avatar", DeleteAvatar)
+	router.GET("/tos", GetTOSStatus)
+	router.POST("/tos/accept", AcceptTOS)
+	router.GET("/me/subaccounts", GetSubAccounts)
+	router.POST("/me/subaccounts", CreateSubAccount)
+	router.PUT("/me/subaccounts/:id", UpdateSubAccount)
+	router.DELETE("/me/subaccounts/:id", RevokeSubAccount)
 }
 
 // SetupAdminRoutes configures the admin auth routes
@@ -289,4 +449,14 @@ func SetupAdminRoutes(router *gin.RouterGroup) {
 	router.GET("/users", GetUsers)
 	router.PUT("/users/:id", UpdateUser)
 	router.DELETE("/users/:id", DeleteUser)
+	router.GET("/signups", GetSignups)
+	router.POST("/signups/:id/approve", ApproveSignup)
+	router.POST("/signups/:id/reject", RejectSignup)
+	router.GET("/settings/tos", GetTOSSettings)
+	router.PUT("/settings/tos", UpdateTOSSettings)
+	router.GET("/clients", GetClients)
+	router.POST("/clients", CreateClient)
+	router.PUT("/clients/:id", UpdateClient)
+	router.POST("/clients/:id/rotate-secret", RotateClientSecret)
+	router.DELETE("/clients/:id", DeleteClient)
 }
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/middleware"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// IntrospectRequest carries the token to introspect, as a form field as in
+// RFC 7662 or as JSON
+type IntrospectRequest struct {
+	Token string `form:"token" json:"token" binding:"required"`
+}
+
+// introspectionServices parses INTROSPECTION_TOKENS, name=token pairs
+// separated by commas, into the service name of every token
+func introspectionServices() map[string]string {
+	services := map[string]string{}
+	for _, pair := range strings.Split(config.Get().IntrospectionTokens, ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		name, token = strings.TrimSpace(name), strings.TrimSpace(token)
+		if !ok || name == "" || token == "" {
+			continue
+		}
+		services[token] = name
+	}
+	return services
+}
+
+// serviceTokenRequired authenticates a sibling service by one of the
+// INTROSPECTION_TOKENS and sets its name as "service"
+func serviceTokenRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, provided, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+		provided = strings.TrimSpace(provided)
+		if ok && strings.EqualFold(scheme, "bearer") && provided != "" {
+			for token, name := range introspectionServices() {
+				if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+					c.Set("service", name)
+					c.Next()
+					return
+				}
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid service token"})
+	}
+}
+
+// tokenRoles returns the roles of a user: "user" for everyone, "admin" and the
+// admin role for admins, and "subaccount" for staff of a reseller
+func tokenRoles(u models.User) []string {
+	roles := []string{"user"}
+	if u.IsAdmin {
+		roles = append(roles, "admin", u.Role())
+	}
+	if u.ParentID != nil {
+		roles = append(roles, "subaccount")
+	}
+	return roles
+}
+
+// Introspect tells a sibling service whether an access token is valid and
+// whom it belongs to, so it can authorize requests without the JWT secret.
+// Invalid, expired and tokens of inactive users all answer {"active": false}.
+func Introspect(c *gin.Context) {
+	var req IntrospectRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	inactive := gin.H{"active": false}
+
+	claims, err := utils.VerifyToken(req.Token)
+	if err != nil {
+		c.JSON(http.StatusOK, inactive)
+		return
+	}
+
+	var user models.User
+	if err := database.GetDB().Where("username = ?", claims.Username).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, inactive)
+		return
+	}
+	if !user.IsActive || user.ErasedAt != nil {
+		c.JSON(http.StatusOK, inactive)
+		return
+	}
+
+	// Tokens of service clients end with the client
+	if claims.ClientID != "" {
+		var client models.ServiceClient
+		if err := database.GetDB().Where("client_id = ?", claims.ClientID).First(&client).Error; err != nil ||
+			!client.IsActive || client.UserID != user.ID {
+			c.JSON(http.StatusOK, inactive)
+			return
+		}
+	}
+
+	response := gin.H{
+		"active":      true,
+		"token_type":  "access_token",
+		"sub":         user.Username,
+		"username":    user.Username,
+		"user_id":     user.ID,
+		"roles":       tokenRoles(user),
+		"permissions": middleware.Permissions(user),
+		"parent_id":   user.ParentID,
+	}
+	if claims.ClientID != "" {
+		response["client_id"] = claims.ClientID
+		response["scope"] = claims.Scope
+	}
+	if claims.ExpiresAt != nil {
+		response["exp"] = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response["iat"] = claims.IssuedAt.Unix()
+	}
+
+	c.JSON(http.StatusOK, response)
+}
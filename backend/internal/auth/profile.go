@@ -0,0 +1,440 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF decoder for avatar validation
+	_ "image/jpeg" // register JPEG decoder for avatar validation
+	_ "image/png"  // register PNG decoder for avatar validation
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/artifacts"
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/notify"
+	"github.com/aliselcukkaya/account-editor/internal/schemas"
+	"github.com/aliselcukkaya/account-editor/internal/storage"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// telegramChatPattern accepts numeric chat IDs and @channel usernames
+var telegramChatPattern = regexp.MustCompile(`^(-?[0-9]+|@[A-Za-z0-9_]{5,32})$`)
+
+// localePattern accepts language tags like "en" or "en-US"
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// validateNotificationDestinations checks the email, webhook and Telegram destinations
+func validateNotificationDestinations(prefs *models.NotificationDefaults) error {
+	prefs.Email = strings.TrimSpace(prefs.Email)
+	prefs.WebhookURL = strings.TrimSpace(prefs.WebhookURL)
+	prefs.TelegramChatID = strings.TrimSpace(prefs.TelegramChatID)
+
+	if prefs.Email != "" {
+		if addr, err := mail.ParseAddress(prefs.Email); err != nil || addr.Address != prefs.Email {
+			return fmt.Errorf("Notification email must be a plain email address")
+		}
+	}
+	if prefs.WebhookURL != "" {
+		u, err := url.Parse(prefs.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Webhook URL must be an http or https URL")
+		}
+	}
+	if prefs.TelegramChatID != "" && !telegramChatPattern.MatchString(prefs.TelegramChatID) {
+		return fmt.Errorf("Telegram chat ID must be numeric or an @channel name")
+	}
+	return nil
+}
+
+// validateDigestRules checks that digest rules name known kinds, at most once each, with a sane interval
+func validateDigestRules(rules []models.DigestRule) error {
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if !notify.Kinds[rule.Kind] {
+			return fmt.Errorf("Unknown notification kind: %s", rule.Kind)
+		}
+		if seen[rule.Kind] {
+			return fmt.Errorf("Only one digest rule per notification kind is allowed")
+		}
+		seen[rule.Kind] = true
+		if rule.IntervalMinutes < 1 || rule.IntervalMinutes > notify.MaxDigestMinutes {
+			return fmt.Errorf("Digest interval must be between 1 and %d minutes", notify.MaxDigestMinutes)
+		}
+	}
+	return nil
+}
+
+// validateNotificationFilters checks that filters name known kinds, task
+// types and statuses and that none of them is empty
+func validateNotificationFilters(filters []models.NotificationFilter) error {
+	if len(filters) > notify.MaxFilters {
+		return fmt.Errorf("At most %d notification filters are allowed", notify.MaxFilters)
+	}
+
+	taskNames := make(map[string]bool, len(schemas.TaskNames))
+	for _, name := range schemas.TaskNames {
+		taskNames[name] = true
+	}
+
+	for _, filter := range filters {
+		if len(filter.Kinds) == 0 && len(filter.TaskNames) == 0 && len(filter.Statuses) == 0 &&
+			len(filter.Tags) == 0 && len(filter.BatchIDs) == 0 {
+			return fmt.Errorf("Notification filters must set at least one condition")
+		}
+		for _, kind := range filter.Kinds {
+			if !notify.Kinds[kind] {
+				return fmt.Errorf("Unknown notification kind: %s", kind)
+			}
+		}
+		for _, name := range filter.TaskNames {
+			if !taskNames[name] {
+				return fmt.Errorf("Unknown task type: %s", name)
+			}
+		}
+		for _, status := range filter.Statuses {
+			if !notify.FilterStatuses[status] {
+				return fmt.Errorf("Notification filter statuses must be completed or failed")
+			}
+		}
+	}
+	return nil
+}
+
+// ChangePasswordRequest is used by users to replace their own password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// minPasswordLength is the shortest password a user may choose
+const minPasswordLength = 8
+
+// UpdateProfileRequest holds the profile fields a user may change; nil fields are left unchanged
+type UpdateProfileRequest struct {
+	DisplayName          *string                      `json:"display_name"`
+	Timezone             *string                      `json:"timezone"`
+	Locale               *string                      `json:"locale"`
+	NotificationDefaults *models.NotificationDefaults `json:"notification_defaults"`
+}
+
+// profileResponse builds the self profile representation of a user
+func profileResponse(c *gin.Context, u models.User) gin.H {
+	return gin.H{
+		"id":                    u.ID,
+		"username":              u.Username,
+		"display_name":          u.DisplayName,
+		"avatar_url":            AvatarURL(c, u),
+		"timezone":              u.Timezone,
+		"locale":                u.Locale,
+		"notification_defaults": u.NotificationDefaults,
+		"is_admin":              u.IsAdmin,
+		"admin_role":            u.Role(),
+		"created_at":            u.CreatedAt,
+		"last_login_at":         u.LastLoginAt,
+	}
+}
+
+// GetProfile returns the current user's profile
+func GetProfile(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	c.JSON(http.StatusOK, profileResponse(c, u))
+}
+
+// UpdateProfile updates the current user's non-security profile fields
+func UpdateProfile(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+
+	if req.DisplayName != nil {
+		name := strings.TrimSpace(*req.DisplayName)
+		if len(name) > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Display name must be at most 100 characters")})
+			return
+		}
+		updates["display_name"] = name
+	}
+
+	if req.Timezone != nil {
+		if *req.Timezone != "" {
+			if _, err := time.LoadLocation(*req.Timezone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Unknown timezone")})
+				return
+			}
+		}
+		updates["timezone"] = *req.Timezone
+	}
+
+	if req.Locale != nil {
+		if *req.Locale != "" && !localePattern.MatchString(*req.Locale) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Locale must look like en or en-US")})
+			return
+		}
+		updates["locale"] = *req.Locale
+	}
+
+	if req.NotificationDefaults != nil {
+		for _, channel := range req.NotificationDefaults.Channels {
+			if !notify.Channels[channel] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Unknown notification channel: %s", channel)})
+				return
+			}
+		}
+		if err := validateDigestRules(req.NotificationDefaults.Digests); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateNotificationFilters(req.NotificationDefaults.Filters); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateNotificationDestinations(req.NotificationDefaults); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		u.NotificationDefaults = *req.NotificationDefaults
+	}
+
+	db := database.GetDB()
+	if len(updates) > 0 {
+		if err := db.Model(&u).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update profile")})
+			return
+		}
+	}
+	// Serialized fields go through Select so the JSON serializer is applied
+	if req.NotificationDefaults != nil {
+		if err := db.Model(&u).Select("notification_defaults").Updates(&u).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update profile")})
+			return
+		}
+	}
+
+	if err := db.First(&u, u.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionProfileUpdated, "user", u.ID, nil)
+
+	c.JSON(http.StatusOK, profileResponse(c, u))
+}
+
+const (
+	// maxAvatarBytes limits the size of uploaded avatar images
+	maxAvatarBytes = 2 << 20
+	// maxAvatarDimension limits the width and height of avatar images
+	maxAvatarDimension = 2048
+)
+
+// avatarExtensions maps accepted image formats to file extensions
+var avatarExtensions = map[string]string{
+	"png":  ".png",
+	"jpeg": ".jpg",
+	"gif":  ".gif",
+}
+
+// AvatarURL returns a signed URL for the user's avatar, or "" if none is set
+func AvatarURL(c *gin.Context, u models.User) string {
+	if u.AvatarKey == "" {
+		return ""
+	}
+	return artifacts.DownloadURL(c, u.AvatarKey)
+}
+
+// UploadAvatar validates an uploaded image and stores it as the current user's avatar
+func UploadAvatar(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Image is required in the avatar field")})
+		return
+	}
+	if file.Size > maxAvatarBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Avatar must be at most 2 MB")})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Failed to read uploaded file")})
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxAvatarBytes+1))
+	if err != nil || len(data) > maxAvatarBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Failed to read uploaded file")})
+		return
+	}
+
+	// Decode the header to make sure the file really is a supported image
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	ext, supported := avatarExtensions[format]
+	if err != nil || !supported {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Avatar must be a PNG, JPEG or GIF image")})
+		return
+	}
+	if cfg.Width > maxAvatarDimension || cfg.Height > maxAvatarDimension {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Avatar must be at most 2048x2048 pixels")})
+		return
+	}
+
+	key := artifacts.Key(u.ID, artifacts.KindAvatar, uuid.New().String()+ext)
+	if err := storage.Get().Put(c.Request.Context(), key, bytes.NewReader(data)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to store avatar")})
+		return
+	}
+
+	db := database.GetDB()
+	oldKey := u.AvatarKey
+	if err := db.Model(&u).Update("avatar_key", key).Error; err != nil {
+		storage.Get().Delete(c.Request.Context(), key)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update profile")})
+		return
+	}
+
+	if oldKey != "" {
+		if err := storage.Get().Delete(c.Request.Context(), oldKey); err != nil {
+			log.Printf("Failed to delete previous avatar %s: %v", oldKey, err)
+		}
+	}
+
+	audit.Record(c, audit.ActionAvatarUpdated, "user", u.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"avatar_url": AvatarURL(c, u),
+		"message":    i18n.T(c, "Avatar updated successfully"),
+	})
+}
+
+// DeleteAvatar removes the current user's avatar
+func DeleteAvatar(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	if u.AvatarKey != "" {
+		if err := database.GetDB().Model(&u).Update("avatar_key", "").Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update profile")})
+			return
+		}
+		if err := storage.Get().Delete(c.Request.Context(), u.AvatarKey); err != nil {
+			log.Printf("Failed to delete avatar %s: %v", u.AvatarKey, err)
+		}
+		audit.Record(c, audit.ActionAvatarUpdated, "user", u.ID, map[string]interface{}{"removed": true})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Avatar removed")})
+}
+
+// ChangePassword replaces the current user's password after checking the current one
+func ChangePassword(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !utils.CheckPasswordHash(req.CurrentPassword, u.HashedPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Current password is incorrect")})
+		return
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Password must be at least 8 characters")})
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "New password must differ from the current password")})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to hash password")})
+		return
+	}
+
+	now := time.Now()
+	if err := database.GetDB().Model(&u).Updates(map[string]interface{}{
+		"hashed_password":     hashedPassword,
+		"password_changed_at": &now,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update profile")})
+		return
+	}
+
+	audit.Record(c, audit.ActionPasswordChanged, "user", u.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Password changed successfully")})
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/middleware"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+// recoverLimiter slows down guessing of recovery tokens: a burst of 5, then one attempt every 6 seconds per IP
+var recoverLimiter = middleware.NewIPRateLimiter(rate.Every(6*time.Second), 5)
+
+// ErrNotAdmin is returned when a recovery token is requested for a user who is not an admin
+var ErrNotAdmin = errors.New("user is not an admin")
+
+type RecoverRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RecoveryGrant is an issued recovery token
+type RecoveryGrant struct {
+	Token       string
+	User        models.User
+	ExpiresAt   time.Time
+	Reactivated bool
+}
+
+// hashRecoveryToken returns the stored form of a recovery token
+func hashRecoveryToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueRecoveryToken creates a one-time login token for an admin, replacing
+// any unused one. A deactivated admin is reactivated so the token can be used.
+// It is meant for the CLI only, run by someone with access to the server host.
+func IssueRecoveryToken(db *gorm.DB, username string, ttl time.Duration) (RecoveryGrant, error) {
+	var grant RecoveryGrant
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return grant, err
+	}
+	grant.Token = hex.EncodeToString(b)
+	grant.ExpiresAt = time.Now().Add(ttl)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("username = ?", username).First(&grant.User).Error; err != nil {
+			return err
+		}
+		if !grant.User.IsAdmin {
+			return ErrNotAdmin
+		}
+
+		if !grant.User.IsActive {
+			if err := tx.Model(&grant.User).Update("is_active", true).Error; err != nil {
+				return err
+			}
+			grant.Reactivated = true
+		}
+
+		if err := tx.Where("user_id = ? AND used_at IS NULL", grant.User.ID).Delete(&models.RecoveryToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.RecoveryToken{
+			UserID:    grant.User.ID,
+			TokenHash: hashRecoveryToken(grant.Token),
+			ExpiresAt: grant.ExpiresAt,
+		}).Error
+	})
+	if err != nil {
+		return RecoveryGrant{}, err
+	}
+
+	audit.RecordSystem(audit.ActionRecoveryIssued, "user", grant.User.ID, map[string]interface{}{
+		"username":    grant.User.Username,
+		"expires_at":  grant.ExpiresAt,
+		"reactivated": grant.Reactivated,
+	})
+	return grant, nil
+}
+
+// RecoverLogin exchanges a one-time recovery token for a session
+func RecoverLogin(c *gin.Context) {
+	var req RecoverRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	now := time.Now()
+
+	var token models.RecoveryToken
+	if err := db.Where("token_hash = ?", hashRecoveryToken(req.Token)).First(&token).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+			return
+		}
+		audit.RecordActor(c, nil, "", audit.ActionLoginFailed, map[string]interface{}{"method": "recovery_token"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "Invalid or expired recovery token")})
+		return
+	}
+
+	// Claim the token atomically so it cannot be used twice
+	result := db.Model(&models.RecoveryToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", token.ID, now).
+		Updates(map[string]interface{}{"used_at": now, "used_ip": c.ClientIP()})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if result.RowsAffected == 0 {
+		audit.RecordActor(c, nil, "", audit.ActionLoginFailed, map[string]interface{}{"method": "recovery_token", "user_id": token.UserID})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "Invalid or expired recovery token")})
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, token.UserID).Error; err != nil || !user.IsActive || !user.IsAdmin {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "Invalid or expired recovery token")})
+		return
+	}
+
+	user.LastLoginAt = &now
+	if err := db.Model(&user).Update("last_login_at", now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.RecordActor(c, &user, user.Username, audit.ActionRecoveryLogin, map[string]interface{}{
+		"token_issued_at": token.CreatedAt,
+	})
+
+	respondWithSession(c, &user)
+}
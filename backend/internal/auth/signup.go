@@ -0,0 +1,489 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/branding"
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/middleware"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/notify"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+// signupLimiter limits signups to a burst of 3, then one every 10 minutes per IP
+var signupLimiter = middleware.NewIPRateLimiter(rate.Every(10*time.Minute), 3)
+
+// verifyLimiter slows down guessing of verification tokens, like recoverLimiter
+var verifyLimiter = middleware.NewIPRateLimiter(rate.Every(6*time.Second), 5)
+
+// signupTokenTTL is how long a verification link stays valid
+const signupTokenTTL = 24 * time.Hour
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{3,50}$`)
+
+var (
+	errSignupNotPending = errors.New("signup is not awaiting approval")
+	errUsernameTaken    = errors.New("username already registered")
+)
+
+type SignupRequest struct {
+	Username string `json:"username" binding:"required"`
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type VerifySignupRequest struct {
+	Token string `json:"token"`
+}
+
+type RejectSignupRequest struct {
+	Reason string `json:"reason"`
+}
+
+// emailApplicant sends an email to the applicant of a signup in their locale;
+// render returns the subject and text for the locale and product name.
+// Failures are only logged.
+func emailApplicant(s models.SignupRequest, render func(locale, product string) (subject, text string)) {
+	locale := i18n.Resolve(s.Locale, "")
+	brand, _ := branding.Load(database.GetDB())
+	subject, text := render(locale, brand.ProductName)
+	err := notify.SendEmail(s.Email, subject, text)
+	if err != nil {
+		log.Printf("Failed to email signup ID %d: %v", s.ID, err)
+	}
+}
+
+// Signup registers a request for a new non-admin account and emails a
+// verification link. The account is created once the email is verified and,
+// if SIGNUP_REQUIRE_APPROVAL is set, an admin has approved it.
+func Signup(c *gin.Context) {
+	var req SignupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = strings.TrimSpace(req.Email)
+	if !usernamePattern.MatchString(req.Username) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Username must be 3-50 letters, digits, dots, dashes or underscores")})
+		return
+	}
+	if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Email must be a plain email address")})
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Password must be at least 8 characters")})
+		return
+	}
+
+	db := database.GetDB()
+	now := time.Now()
+
+	// Unverified signups that expired no longer hold their username or email
+	if err := db.Where("status = ? AND expires_at <= ?", models.SignupPendingVerification, now).
+		Delete(&models.SignupRequest{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var users int64
+	if err := db.Model(&models.User{}).Where("username = ?", req.Username).Count(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	var open []models.SignupRequest
+	if err := db.Where("(username = ? OR email = ?) AND status IN ?", req.Username, req.Email,
+		[]string{models.SignupPendingVerification, models.SignupPendingApproval}).Find(&open).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	for _, s := range open {
+		if s.Email == req.Email {
+			c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "A signup with this email address is already pending")})
+			return
+		}
+		users++
+	}
+	if users > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "Username already registered")})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create verification token"})
+		return
+	}
+	token := hex.EncodeToString(b)
+
+	signup := models.SignupRequest{
+		Username:       req.Username,
+		Email:          req.Email,
+		HashedPassword: hashedPassword,
+		TokenHash:      hashRecoveryToken(token),
+		ExpiresAt:      now.Add(signupTokenTTL),
+		Status:         models.SignupPendingVerification,
+		IPAddress:      c.ClientIP(),
+		Locale:         i18n.ParseAcceptLanguage(c.GetHeader("Accept-Language")),
+	}
+	if err := db.Create(&signup).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	locale := i18n.Resolve(signup.Locale, "")
+	brand, _ := branding.Load(db)
+	link := utils.AbsoluteURL(c, "/auth/signup/verify?token="+url.QueryEscape(token))
+	err = notify.SendEmail(signup.Email,
+		i18n.Translate(locale, "Verify your email address for %s", brand.ProductName),
+		i18n.Translate(locale, "Open this link within 24 hours to verify your email address:\n\n%s\n\nIf you did not sign up, ignore this email.", link))
+	if err != nil {
+		log.Printf("Failed to send verification email for signup ID %d: %v", signup.ID, err)
+		db.Delete(&signup)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": i18n.T(c, "Verification email could not be sent")})
+		return
+	}
+
+	// Applicants are recorded by signup ID only: the signed chain cannot be
+	// rewritten when their account is erased
+	audit.RecordActor(c, nil, "", audit.ActionSignupRequested, map[string]interface{}{
+		"signup_id": signup.ID,
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":     signup.Status,
+		"expires_at": signup.ExpiresAt,
+		"message":    i18n.T(c, "Check your email to verify your address"),
+	})
+}
+
+// VerifySignup confirms the email address of a signup with the token from the
+// verification link, given as the token query parameter or in the JSON body
+func VerifySignup(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" && c.Request.Method == http.MethodPost {
+		var req VerifySignupRequest
+		if err := c.ShouldBindJSON(&req); err == nil {
+			token = req.Token
+		}
+	}
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Invalid or expired verification link")})
+		return
+	}
+
+	db := database.GetDB()
+	now := time.Now()
+
+	var signup models.SignupRequest
+	if err := db.Where("token_hash = ?", hashRecoveryToken(token)).First(&signup).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Invalid or expired verification link")})
+		return
+	}
+
+	// Claim the verification atomically so it happens once; opening the link
+	// again only reports the status
+	result := db.Model(&models.SignupRequest{}).
+		Where("id = ? AND status = ? AND expires_at > ?", signup.ID, models.SignupPendingVerification, now).
+		Updates(map[string]interface{}{"status": models.SignupPendingApproval, "verified_at": now})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if result.RowsAffected == 0 {
+		if signup.Status == models.SignupPendingVerification {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Invalid or expired verification link")})
+			return
+		}
+		respondSignupStatus(c, signup.Status)
+		return
+	}
+	signup.Status = models.SignupPendingApproval
+	signup.VerifiedAt = &now
+
+	audit.RecordActor(c, nil, "", audit.ActionSignupVerified, map[string]interface{}{
+		"signup_id": signup.ID,
+	})
+
+	if !config.Get().SignupRequireApproval {
+		user, err := approveSignup(db, &signup, nil)
+		if err != nil {
+			if err == errUsernameTaken {
+				c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "Username already registered")})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+			return
+		}
+		audit.RecordActor(c, &user, user.Username, audit.ActionSignupApproved, map[string]interface{}{
+			"signup_id": signup.ID,
+		})
+	}
+
+	respondSignupStatus(c, signup.Status)
+}
+
+// respondSignupStatus tells the applicant what happens next with their signup
+func respondSignupStatus(c *gin.Context, status string) {
+	message := ""
+	switch status {
+	case models.SignupPendingApproval:
+		message = i18n.T(c, "Email verified. An administrator will review your signup.")
+	case models.SignupApproved:
+		message = i18n.T(c, "Email verified. You can now log in.")
+	default:
+		message = i18n.T(c, "This signup was rejected")
+	}
+	c.JSON(http.StatusOK, gin.H{"status": status, "message": message})
+}
+
+// approveSignup creates the non-admin user of a verified signup and marks it
+// approved by the reviewer, or automatically when reviewer is nil
+func approveSignup(db *gorm.DB, signup *models.SignupRequest, reviewer *models.User) (models.User, error) {
+	now := time.Now()
+	user := models.User{
+		Username:          signup.Username,
+		HashedPassword:    signup.HashedPassword,
+		IsActive:          true,
+		PasswordChangedAt: &now,
+		Locale:            signup.Locale,
+		NotificationDefaults: models.NotificationDefaults{
+			Email: signup.Email,
+		},
+	}
+
+	updates := map[string]interface{}{"status": models.SignupApproved, "reviewed_at": now}
+	if reviewer != nil {
+		updates["reviewed_by"] = reviewer.ID
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.SignupRequest{}).
+			Where("id = ? AND status = ?", signup.ID, models.SignupPendingApproval).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errSignupNotPending
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ?", signup.Username).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errUsernameTaken
+		}
+
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.SignupRequest{}).Where("id = ?", signup.ID).Update("user_id", user.ID).Error
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	signup.Status = models.SignupApproved
+	signup.ReviewedAt = &now
+	signup.UserID = &user.ID
+	if reviewer != nil {
+		signup.ReviewedBy = &reviewer.ID
+	}
+
+	username := signup.Username
+	go emailApplicant(*signup, func(locale, product string) (string, string) {
+		return i18n.Translate(locale, "Your %s account is ready", product),
+			i18n.Translate(locale, "Your signup was approved. You can now log in as %s.", username)
+	})
+	return user, nil
+}
+
+// GetSignups lists signup requests, newest first (admin only)
+func GetSignups(c *gin.Context) {
+	page, err := utils.ParsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := database.GetDB().Model(&models.SignupRequest{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	if page != nil {
+		query = page.Apply(query)
+	} else {
+		query = query.Order("created_at DESC, id DESC")
+	}
+
+	var signups []models.SignupRequest
+	if err := query.Find(&signups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	next := ""
+	if page != nil {
+		var n int
+		n, next = page.NextCursor(len(signups), func(i int) utils.Cursor {
+			return utils.Cursor{CreatedAt: signups[i].CreatedAt, ID: signups[i].ID}
+		})
+		signups = signups[:n]
+		c.Header("X-Next-Cursor", next)
+	}
+
+	utils.RespondList(c, signups, total, next)
+}
+
+// findSignup loads the signup named by the id parameter or writes an error response
+func findSignup(c *gin.Context) (models.SignupRequest, bool) {
+	var signup models.SignupRequest
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signup ID"})
+		return signup, false
+	}
+	if err := database.GetDB().First(&signup, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Signup not found")})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		}
+		return signup, false
+	}
+	return signup, true
+}
+
+// ApproveSignup creates the user of a verified signup (admin only)
+func ApproveSignup(c *gin.Context) {
+	signup, ok := findSignup(c)
+	if !ok {
+		return
+	}
+
+	var reviewer *models.User
+	user, _ := c.Get("user")
+	if u, ok := user.(models.User); ok {
+		reviewer = &u
+	}
+
+	created, err := approveSignup(database.GetDB(), &signup, reviewer)
+	if err != nil {
+		switch err {
+		case errSignupNotPending:
+			c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "Signup is not awaiting approval")})
+		case errUsernameTaken:
+			c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "Username already registered")})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		}
+		return
+	}
+
+	audit.Record(c, audit.ActionSignupApproved, "user", created.ID, map[string]interface{}{
+		"signup_id": signup.ID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"signup":  signup,
+		"user_id": created.ID,
+		"message": i18n.T(c, "Signup approved"),
+	})
+}
+
+// RejectSignup declines a pending signup with an optional reason that is
+// emailed to verified applicants (admin only)
+func RejectSignup(c *gin.Context) {
+	signup, ok := findSignup(c)
+	if !ok {
+		return
+	}
+
+	var req RejectSignupRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": models.SignupRejected, "reviewed_at": now, "reject_reason": req.Reason}
+	user, _ := c.Get("user")
+	if u, ok := user.(models.User); ok {
+		updates["reviewed_by"] = u.ID
+	}
+
+	result := database.GetDB().Model(&models.SignupRequest{}).
+		Where("id = ? AND status IN ?", signup.ID, []string{models.SignupPendingVerification, models.SignupPendingApproval}).
+		Updates(updates)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "Signup is not awaiting approval")})
+		return
+	}
+
+	audit.Record(c, audit.ActionSignupRejected, "signup", signup.ID, map[string]interface{}{
+		"reason": req.Reason,
+	})
+
+	// Only verified addresses are emailed, so rejecting spam sends nothing
+	if signup.Status == models.SignupPendingApproval {
+		if req.Reason != "" {
+			reason := req.Reason
+			go emailApplicant(signup, func(locale, product string) (string, string) {
+				return i18n.Translate(locale, "Your %s signup", product),
+					i18n.Translate(locale, "Your signup was not approved: %s", reason)
+			})
+		} else {
+			go emailApplicant(signup, func(locale, product string) (string, string) {
+				return i18n.Translate(locale, "Your %s signup", product),
+					i18n.Translate(locale, "Your signup was not approved.")
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Signup rejected")})
+}
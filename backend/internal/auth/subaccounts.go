@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxSubAccounts limits the sub-accounts of a user, revoked ones included
+const maxSubAccounts = 25
+
+type SubAccountRequest struct {
+	Username    string `json:"username" binding:"required"`
+	Password    string `json:"password" binding:"required"`
+	DisplayName string `json:"display_name"`
+}
+
+type SubAccountUpdateRequest struct {
+	DisplayName *string `json:"display_name"`
+	Password    *string `json:"password"`
+	IsActive    *bool   `json:"is_active"`
+}
+
+func subAccountResponse(sub models.User, tasks int64) gin.H {
+	return gin.H{
+		"id":            sub.ID,
+		"username":      sub.Username,
+		"display_name":  sub.DisplayName,
+		"is_active":     sub.IsActive,
+		"created_at":    sub.CreatedAt,
+		"last_login_at": sub.LastLoginAt,
+		"tasks_created": tasks,
+	}
+}
+
+// parentUser returns the current user if they may manage sub-accounts, or
+// writes an error response; sub-accounts cannot have sub-accounts of their own
+func parentUser(c *gin.Context) (models.User, bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return models.User{}, false
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return models.User{}, false
+	}
+	if u.ParentID != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": i18n.T(c, "Sub-accounts cannot use this endpoint")})
+		return models.User{}, false
+	}
+	return u, true
+}
+
+// findSubAccount loads one of the parent's sub-accounts by the :id parameter
+func findSubAccount(c *gin.Context, db *gorm.DB, parent models.User) (models.User, bool) {
+	var sub models.User
+	if err := db.Where("id = ? AND parent_id = ?", c.Param("id"), parent.ID).First(&sub).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Sub-account not found")})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		}
+		return sub, false
+	}
+	return sub, true
+}
+
+// GetSubAccounts lists the current user's sub-accounts with how many tasks each created
+func GetSubAccounts(c *gin.Context) {
+	u, ok := parentUser(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var subs []models.User
+	if err := db.Where("parent_id = ?", u.ID).Order("username").Find(&subs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var counts []struct {
+		CreatedBy int
+		Count     int64
+	}
+	if err := db.Model(&models.AutomationTask{}).Select("created_by, COUNT(*) AS count").
+		Where("user_id = ? AND created_by IS NOT NULL", u.ID).Group("created_by").Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	tasks := map[int]int64{}
+	for _, row := range counts {
+		tasks[row.CreatedBy] = row.Count
+	}
+
+	items := []gin.H{}
+	for _, sub := range subs {
+		items = append(items, subAccountResponse(sub, tasks[sub.ID]))
+	}
+
+	utils.RespondList(c, items, int64(len(items)), "")
+}
+
+// CreateSubAccount adds a sub-account that works in the current user's
+// automation data with the given credentials
+func CreateSubAccount(c *gin.Context) {
+	u, ok := parentUser(c)
+	if !ok {
+		return
+	}
+
+	var req SubAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if !usernamePattern.MatchString(req.Username) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Username must be 3-50 letters, digits, dots, dashes or underscores")})
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Password must be at least 8 characters")})
+		return
+	}
+
+	db := database.GetDB()
+	var count int64
+	if err := db.Model(&models.User{}).Where("parent_id = ?", u.ID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if count >= maxSubAccounts {
+		c.JSON(http.StatusForbidden, gin.H{"error": i18n.T(c, "You can have at most %d sub-accounts", maxSubAccounts)})
+		return
+	}
+
+	var existing int64
+	if err := db.Model(&models.User{}).Where("username = ?", req.Username).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Username already registered")})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to hash password")})
+		return
+	}
+
+	sub := models.User{
+		Username:       req.Username,
+		HashedPassword: hashedPassword,
+		IsActive:       true,
+		ParentID:       &u.ID,
+		DisplayName:    strings.TrimSpace(req.DisplayName),
+		Timezone:       u.Timezone,
+		Locale:         u.Locale,
+	}
+	if err := db.Create(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to create user")})
+		return
+	}
+
+	audit.Record(c, audit.ActionSubAccountCreated, "user", sub.ID, map[string]interface{}{
+		"username": sub.Username,
+	})
+
+	c.JSON(http.StatusCreated, subAccountResponse(sub, 0))
+}
+
+// UpdateSubAccount renames, re-enables or resets the password of one of the
+// current user's sub-accounts
+func UpdateSubAccount(c *gin.Context) {
+	u, ok := parentUser(c)
+	if !ok {
+		return
+	}
+
+	var req SubAccountUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	sub, ok := findSubAccount(c, db, u)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.DisplayName != nil {
+		sub.DisplayName = strings.TrimSpace(*req.DisplayName)
+		updates["display_name"] = sub.DisplayName
+	}
+	if req.IsActive != nil {
+		sub.IsActive = *req.IsActive
+		updates["is_active"] = sub.IsActive
+	}
+	if req.Password != nil {
+		if len(*req.Password) < minPasswordLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Password must be at least 8 characters")})
+			return
+		}
+		hashedPassword, err := utils.HashPassword(*req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to hash password")})
+			return
+		}
+		updates["hashed_password"] = hashedPassword
+		// The sub-account picks its own password again after a reset
+		updates["password_changed_at"] = nil
+	}
+	if len(updates) > 0 {
+		if err := db.Model(&sub).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+			return
+		}
+
+		audit.Record(c, audit.ActionSubAccountUpdated, "user", sub.ID, map[string]interface{}{
+			"username":       sub.Username,
+			"is_active":      sub.IsActive,
+			"password_reset": req.Password != nil,
+		})
+	}
+
+	var tasks int64
+	db.Model(&models.AutomationTask{}).Where("user_id = ? AND created_by = ?", u.ID, sub.ID).Count(&tasks)
+	c.JSON(http.StatusOK, subAccountResponse(sub, tasks))
+}
+
+// RevokeSubAccount deactivates one of the current user's sub-accounts so it
+// can no longer sign in; its tasks stay attributed to it
+func RevokeSubAccount(c *gin.Context) {
+	u, ok := parentUser(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	sub, ok := findSubAccount(c, db, u)
+	if !ok {
+		return
+	}
+
+	if err := db.Model(&sub).Update("is_active", false).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionSubAccountRevoked, "user", sub.ID, map[string]interface{}{
+		"username": sub.Username,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Sub-account revoked")})
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/settings"
+	"github.com/gin-gonic/gin"
+)
+
+type AcceptTOSRequest struct {
+	Version string `json:"version" binding:"required"`
+}
+
+type UpdateTOSRequest struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+}
+
+// currentTOS returns the configured terms of service version and URL
+func currentTOS(c *gin.Context) (string, string, bool) {
+	db := database.GetDB()
+	version, err := settings.Get(db, settings.KeyTOSVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return "", "", false
+	}
+	url, err := settings.Get(db, settings.KeyTOSURL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return "", "", false
+	}
+	return version, url, true
+}
+
+// GetTOSStatus returns the current terms of service version and whether the user accepted it
+func GetTOSStatus(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	version, url, ok := currentTOS(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"current_version":  version,
+		"url":              url,
+		"accepted_version": u.TOSVersionAccepted,
+		"accepted_at":      u.TOSAcceptedAt,
+		"required":         version != "" && u.TOSVersionAccepted != version,
+	})
+}
+
+// AcceptTOS records that the current user accepted the current terms of service.
+// The version must match so a client cannot accept terms it has not shown.
+func AcceptTOS(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	var req AcceptTOSRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	version, _, ok := currentTOS(c)
+	if !ok {
+		return
+	}
+	if version == "" || req.Version != version {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           i18n.T(c, "Terms of service version does not match the current version"),
+			"current_version": version,
+		})
+		return
+	}
+
+	now := time.Now()
+	if err := database.GetDB().Model(&u).Updates(map[string]interface{}{
+		"tos_version_accepted": version,
+		"tos_accepted_at":      &now,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionTOSAccepted, "user", u.ID, map[string]interface{}{"version": version})
+
+	c.JSON(http.StatusOK, gin.H{
+		"accepted_version": version,
+		"accepted_at":      now,
+		"message":          i18n.T(c, "Terms of service accepted"),
+	})
+}
+
+// GetTOSSettings returns the terms of service configuration (admin only)
+func GetTOSSettings(c *gin.Context) {
+	version, url, ok := currentTOS(c)
+	if !ok {
+		return
+	}
+
+	var pending int64
+	if version != "" {
+		database.GetDB().Model(&models.User{}).
+			Where("is_active = ? AND (tos_version_accepted IS NULL OR tos_version_accepted <> ?)", true, version).
+			Count(&pending)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"version":       version,
+		"url":           url,
+		"pending_users": pending,
+	})
+}
+
+// UpdateTOSSettings sets the current terms of service version (admin only).
+// Bumping the version requires every user to accept again; an empty version disables the check.
+func UpdateTOSSettings(c *gin.Context) {
+	var req UpdateTOSRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var updatedBy *int
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			updatedBy = &u.ID
+		}
+	}
+
+	db := database.GetDB()
+	if err := settings.Set(db, settings.KeyTOSVersion, req.Version, updatedBy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if err := settings.Set(db, settings.KeyTOSURL, req.URL, updatedBy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionTOSUpdated, "settings", settings.KeyTOSVersion, map[string]interface{}{
+		"version": req.Version,
+		"url":     req.URL,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"version": req.Version,
+		"url":     req.URL,
+		"message": i18n.T(c, "Settings updated successfully"),
+	})
+}
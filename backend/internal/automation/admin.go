@@ -0,0 +1,224 @@
+package automation
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/quota"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// defaultStuckMinutes is how long a task may stay pending/running before it is considered stuck
+const defaultStuckMinutes = 30
+
+type ForceFailRequest struct {
+	Reason string `json:"reason"`
+}
+
+// stuckCutoff returns the time before which a pending or running task that has
+// not progressed counts as stuck, from older_than_minutes (30 by default)
+func stuckCutoff(c *gin.Context) (time.Time, bool) {
+	minutes := defaultStuckMinutes
+	if v := c.Query("older_than_minutes"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "older_than_minutes must be a positive integer")})
+			return time.Time{}, false
+		}
+		minutes = n
+	}
+	return time.Now().Add(-time.Duration(minutes) * time.Minute), true
+}
+
+// GetStuckTasks lists pending or running tasks that have not progressed for a while (admin only)
+func GetStuckTasks(c *gin.Context) {
+	cutoff, ok := stuckCutoff(c)
+	if !ok {
+		return
+	}
+
+	var tasks []models.AutomationTask
+	db := database.GetDB()
+	if err := db.Preload("User").
+		Where("status IN ? AND updated_at < ?", []string{"pending", "running"}, cutoff).
+		// Tasks of held batches are waiting on purpose
+		Where("batch_id IS NULL OR batch_id NOT IN (?)",
+			db.Model(&models.TaskBatch{}).Select("id").Where("status IN ?", []string{BatchStatusWaitingOnPanel, BatchStatusHeld})).
+		Order("updated_at ASC").
+		Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to retrieve tasks")})
+		return
+	}
+
+	response := []gin.H{}
+	for _, task := range tasks {
+		response = append(response, gin.H{
+			"id":             task.ID,
+			"user_id":        task.UserID,
+			"username":       task.User.Username,
+			"name":           task.Name,
+			"target_website": task.TargetWebsite,
+			"status":         task.Status,
+			"created_at":     task.CreatedAt,
+			"updated_at":     task.UpdatedAt,
+			"stuck_minutes":  int(time.Since(task.UpdatedAt).Minutes()),
+			"requeueable":    len(task.Request) > 0,
+		})
+	}
+
+	utils.RespondList(c, response, int64(len(response)), "")
+}
+
+// findStuckTask loads a task by URL ID and checks it is pending or running and
+// has not progressed since the cutoff
+func findStuckTask(c *gin.Context, db *gorm.DB, cutoff time.Time) (*models.AutomationTask, bool) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Invalid task ID")})
+		return nil, false
+	}
+
+	var task models.AutomationTask
+	if err := db.First(&task, taskID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Task not found")})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		}
+		return nil, false
+	}
+
+	if task.Status != "pending" && task.Status != "running" {
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "Only pending or running tasks can be changed")})
+		return nil, false
+	}
+	if !task.UpdatedAt.Before(cutoff) {
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "The task is still progressing and is not stuck")})
+		return nil, false
+	}
+
+	return &task, true
+}
+
+// ForceFailTask marks a stuck task as failed (admin only)
+func ForceFailTask(c *gin.Context) {
+	var req ForceFailRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = i18n.T(c, "Task was stuck and has been marked as failed by an administrator")
+	}
+
+	cutoff, ok := stuckCutoff(c)
+	if !ok {
+		return
+	}
+	db := database.GetDB()
+	task, ok := findStuckTask(c, db, cutoff)
+	if !ok {
+		return
+	}
+
+	resultJSON, _ := json.Marshal(map[string]interface{}{
+		"success": false,
+		"error":   req.Reason,
+	})
+
+	// Claim the task only if it is still stuck, so a task that progressed in
+	// the meantime is left to its worker
+	now := time.Now()
+	claim := db.Model(&models.AutomationTask{}).
+		Where("id = ? AND status IN ? AND updated_at < ?", task.ID, []string{"pending", "running"}, cutoff).
+		Updates(map[string]interface{}{"status": "failed", "result": models.JSON(resultJSON), "completed_at": &now, "updated_at": now})
+	if claim.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update task")})
+		return
+	}
+	if claim.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "The task is still progressing and is not stuck")})
+		return
+	}
+
+	audit.Record(c, audit.ActionTaskForceFail, "task", task.ID, map[string]interface{}{"reason": req.Reason})
+	log.Printf("Task ID %d force-failed by admin", task.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"id":      task.ID,
+		"status":  "failed",
+		"message": i18n.T(c, "Task marked as failed"),
+	})
+}
+
+// RequeueTask runs a stuck task again with its original request, after the
+// same checks as a new task (admin only)
+func RequeueTask(c *gin.Context) {
+	cutoff, ok := stuckCutoff(c)
+	if !ok {
+		return
+	}
+	db := database.GetDB()
+	task, ok := findStuckTask(c, db, cutoff)
+	if !ok {
+		return
+	}
+
+	if len(task.Request) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": i18n.T(c, "Task was created before requests were recorded and cannot be requeued")})
+		return
+	}
+
+	var req TaskRequest
+	if err := json.Unmarshal(task.Request, &req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": i18n.T(c, "Stored task request is invalid")})
+		return
+	}
+
+	var settings models.UserSettings
+	if err := db.Where("user_id = ?", task.UserID).First(&settings).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": i18n.T(c, "Task owner has no settings configured")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	status, heldUntil, err := requeueTask(db, settings, task.ID, req, cutoff)
+	switch {
+	case err == ErrTaskNotStuck:
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "The task is still progressing and is not stuck")})
+		return
+	case err == quota.ErrExceeded:
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": i18n.T(c, "Daily task quota reached")})
+		return
+	case err == ErrCredentialsInvalid:
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "The task owner's panel rejected the saved API key")})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update task")})
+		return
+	}
+
+	if status == TaskStatusHeld {
+		c.Header("X-Held-Until", heldUntil.Format(time.RFC3339))
+	}
+	audit.Record(c, audit.ActionTaskRequeued, "task", task.ID, nil)
+	log.Printf("Task ID %d requeued by admin", task.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"id":      task.ID,
+		"status":  status,
+		"message": i18n.T(c, "Task requeued"),
+	})
+}
@@ -21,12 +21,15 @@ type APIClient struct {
 }
 
 type CreateAccountRequest struct {
-	Username      string `json:"username,omitempty"`
-	Password      string `json:"password,omitempty"`
-	Package       int    `json:"package"`
-	ResellerNotes string `json:"reseller_notes,omitempty"`
-	Bouquets      []int  `json:"bouquets,omitempty"`
-	RID           string `json:"rid"`
+	Username       string `json:"username,omitempty"`
+	Password       string `json:"password,omitempty"`
+	Package        int    `json:"package"`
+	MaxConnections int    `json:"max_connections,omitempty"`
+	ResellerNotes  string `json:"reseller_notes,omitempty"`
+	Bouquets       []int  `json:"bouquets,omitempty"`
+	// IsTrial creates a trial line, which panels usually give out without credits
+	IsTrial bool   `json:"is_trial,omitempty"`
+	RID     string `json:"rid"`
 }
 
 type CreateAccountResponse struct {
@@ -48,6 +51,25 @@ type ExtendPackageResponse struct {
 	RID               string    `json:"rid"`
 }
 
+type DisableLineRequest struct {
+	RID string `json:"rid"`
+}
+
+type DisableLineResponse struct {
+	LineID    string `json:"line_id"`
+	IsEnabled bool   `json:"is_enabled"`
+	RID       string `json:"rid"`
+}
+
+type DeleteLineRequest struct {
+	RID string `json:"rid"`
+}
+
+type DeleteLineResponse struct {
+	LineID string `json:"line_id"`
+	RID    string `json:"rid"`
+}
+
 type Line struct {
 	LineID         string    `json:"line_id"`
 	Username       string    `json:"username"`
@@ -95,6 +117,45 @@ func formatConnectionError(status int) string {
 	}
 }
 
+// requestError wraps a failure to reach the panel at all
+func requestError(err error) error {
+	return &PanelError{
+		Code:    PanelErrorConnectivity,
+		Message: fmt.Sprintf("error making request: %v", err),
+	}
+}
+
+// responseError turns a non-200 panel response into a PanelError
+func responseError(resp *http.Response) error {
+	var errorResp struct {
+		Error string `json:"error"`
+		RID   string `json:"rid"`
+	}
+	bodyBytes, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		return fmt.Errorf("error reading error response: %v", readErr)
+	}
+
+	// Check if response is HTML
+	if isHTMLResponse(bodyBytes) {
+		return &PanelError{
+			Code:    PanelErrorConnectivity,
+			Status:  resp.StatusCode,
+			Message: "connection error: " + formatConnectionError(resp.StatusCode),
+		}
+	}
+
+	// Try to decode JSON if it looks like JSON
+	if len(bodyBytes) > 0 && bodyBytes[0] == '{' {
+		if err := json.Unmarshal(bodyBytes, &errorResp); err == nil {
+			return providerPanelError(resp, fmt.Sprintf("API error: %s (RID: %s)", errorResp.Error, errorResp.RID), errorResp.RID)
+		}
+		return providerPanelError(resp, fmt.Sprintf("error response (status %d): %s", resp.StatusCode, string(bodyBytes)), "")
+	}
+
+	return providerPanelError(resp, fmt.Sprintf("unexpected response (status %d): %s", resp.StatusCode, string(bodyBytes)), "")
+}
+
 // Update error handling in CreateAccount method
 func (c *APIClient) CreateAccount(req CreateAccountRequest) (*CreateAccountResponse, error) {
 	jsonData, err := json.Marshal(req)
@@ -113,34 +174,12 @@ func (c *APIClient) CreateAccount(req CreateAccountRequest) (*CreateAccountRespo
 
 	resp, err := c.HTTPClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
+		return nil, requestError(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errorResp struct {
-			Error string `json:"error"`
-			RID   string `json:"rid"`
-		}
-		bodyBytes, readErr := io.ReadAll(resp.Body)
-		if readErr != nil {
-			return nil, fmt.Errorf("error reading error response: %v", readErr)
-		}
-
-		// Check if response is HTML
-		if isHTMLResponse(bodyBytes) {
-			return nil, fmt.Errorf("connection error: %s", formatConnectionError(resp.StatusCode))
-		}
-
-		// Try to decode JSON if it looks like JSON
-		if len(bodyBytes) > 0 && bodyBytes[0] == '{' {
-			if err := json.Unmarshal(bodyBytes, &errorResp); err != nil {
-				return nil, fmt.Errorf("error response (status %d): %s", resp.StatusCode, string(bodyBytes))
-			}
-			return nil, fmt.Errorf("API error: %s (RID: %s)", errorResp.Error, errorResp.RID)
-		}
-
-		return nil, fmt.Errorf("unexpected response (status %d): %s", resp.StatusCode, string(bodyBytes))
+		return nil, responseError(resp)
 	}
 
 	var response CreateAccountResponse
@@ -162,34 +201,12 @@ func (c *APIClient) FindAccount(username string) ([]Line, error) {
 
 	resp, err := c.HTTPClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
+		return nil, requestError(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errorResp struct {
-			Error string `json:"error"`
-			RID   string `json:"rid"`
-		}
-		bodyBytes, readErr := io.ReadAll(resp.Body)
-		if readErr != nil {
-			return nil, fmt.Errorf("error reading error response: %v", readErr)
-		}
-
-		// Check if response is HTML
-		if isHTMLResponse(bodyBytes) {
-			return nil, fmt.Errorf("connection error: %s", formatConnectionError(resp.StatusCode))
-		}
-
-		// Try to decode JSON if it looks like JSON
-		if len(bodyBytes) > 0 && bodyBytes[0] == '{' {
-			if err := json.Unmarshal(bodyBytes, &errorResp); err != nil {
-				return nil, fmt.Errorf("error response (status %d): %s", resp.StatusCode, string(bodyBytes))
-			}
-			return nil, fmt.Errorf("API error: %s (RID: %s)", errorResp.Error, errorResp.RID)
-		}
-
-		return nil, fmt.Errorf("unexpected response (status %d): %s", resp.StatusCode, string(bodyBytes))
+		return nil, responseError(resp)
 	}
 
 	var lines []Line
@@ -217,37 +234,83 @@ func (c *APIClient) ExtendPackage(lineID string, req ExtendPackageRequest) (*Ext
 
 	resp, err := c.HTTPClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
+		return nil, requestError(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		var errorResp struct {
-			Error string `json:"error"`
-			RID   string `json:"rid"`
-		}
-		bodyBytes, readErr := io.ReadAll(resp.Body)
-		if readErr != nil {
-			return nil, fmt.Errorf("error reading error response: %v", readErr)
-		}
+		return nil, responseError(resp)
+	}
 
-		// Check if response is HTML
-		if isHTMLResponse(bodyBytes) {
-			return nil, fmt.Errorf("connection error: %s", formatConnectionError(resp.StatusCode))
-		}
+	var response ExtendPackageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %v", err)
+	}
 
-		// Try to decode JSON if it looks like JSON
-		if len(bodyBytes) > 0 && bodyBytes[0] == '{' {
-			if err := json.Unmarshal(bodyBytes, &errorResp); err != nil {
-				return nil, fmt.Errorf("error response (status %d): %s", resp.StatusCode, string(bodyBytes))
-			}
-			return nil, fmt.Errorf("API error: %s (RID: %s)", errorResp.Error, errorResp.RID)
-		}
+	return &response, nil
+}
 
-		return nil, fmt.Errorf("unexpected response (status %d): %s", resp.StatusCode, string(bodyBytes))
+// DisableLine disables a line on the panel; it keeps its expiry and can be renewed later
+func (c *APIClient) DisableLine(lineID string, req DisableLineRequest) (*DisableLineResponse, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %v", err)
 	}
 
-	var response ExtendPackageResponse
+	httpReq, err := http.NewRequest("POST", fmt.Sprintf("%s/ext/line/%s/disable", c.BaseURL, lineID), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Api-Key", c.APIKey)
+	httpReq.Header.Set("X-Auth-User", c.AuthUser)
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var response DisableLineResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %v", err)
+	}
+
+	return &response, nil
+}
+
+// DeleteLine removes a line from the panel for good
+func (c *APIClient) DeleteLine(lineID string, req DeleteLineRequest) (*DeleteLineResponse, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %v", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", fmt.Sprintf("%s/ext/line/%s/delete", c.BaseURL, lineID), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Api-Key", c.APIKey)
+	httpReq.Header.Set("X-Auth-User", c.AuthUser)
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, requestError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	var response DeleteLineResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, fmt.Errorf("error decoding response: %v", err)
 	}
@@ -260,6 +323,22 @@ func (c *APIClient) IsSimulationMode() bool {
 	return c.APIKey == "test" && c.AuthUser == "test"
 }
 
+// simulatedPrice returns the mock credit cost of a package
+func simulatedPrice(pkg int) float64 {
+	switch pkg {
+	case 103:
+		return 270.0
+	case 106:
+		return 500.0
+	case 112:
+		return 950.0
+	case 124:
+		return 1800.0
+	default:
+		return 100.0
+	}
+}
+
 // SimulateCreateAccount returns mock data for a create account request
 func (c *APIClient) SimulateCreateAccount(req CreateAccountRequest) (*CreateAccountResponse, error) {
 	// Generate consistent yet random-looking values
@@ -269,21 +348,7 @@ func (c *APIClient) SimulateCreateAccount(req CreateAccountRequest) (*CreateAcco
 	expireAt := time.Now().AddDate(0, req.Package/100, 0)
 
 	// Calculate mock transaction amount
-	var transactionAmount float64
-	switch req.Package {
-	case 101:
-		transactionAmount = 100.0
-	case 103:
-		transactionAmount = 270.0
-	case 106:
-		transactionAmount = 500.0
-	case 112:
-		transactionAmount = 950.0
-	case 124:
-		transactionAmount = 1800.0
-	default:
-		transactionAmount = 100.0
-	}
+	transactionAmount := simulatedPrice(req.Package)
 
 	return &CreateAccountResponse{
 		LineID:            lineID,
@@ -328,21 +393,7 @@ func (c *APIClient) SimulateExtendPackage(lineID string, req ExtendPackageReques
 	expireAt := time.Now().AddDate(0, req.Package/100, 0)
 
 	// Calculate mock transaction amount
-	var transactionAmount float64
-	switch req.Package {
-	case 101:
-		transactionAmount = 100.0
-	case 103:
-		transactionAmount = 270.0
-	case 106:
-		transactionAmount = 500.0
-	case 112:
-		transactionAmount = 950.0
-	case 124:
-		transactionAmount = 1800.0
-	default:
-		transactionAmount = 100.0
-	}
+	transactionAmount := simulatedPrice(req.Package)
 
 	return &ExtendPackageResponse{
 		LineID:            lineID,
@@ -351,3 +402,74 @@ func (c *APIClient) SimulateExtendPackage(lineID string, req ExtendPackageReques
 		RID:               req.RID,
 	}, nil
 }
+
+// SimulateDisableLine returns mock data for a disable line request
+func (c *APIClient) SimulateDisableLine(lineID string, req DisableLineRequest) (*DisableLineResponse, error) {
+	return &DisableLineResponse{
+		LineID:    lineID,
+		IsEnabled: false,
+		RID:       req.RID,
+	}, nil
+}
+
+// credentialCheckUsername is looked up by CheckCredentials; no line is expected to use it
+const credentialCheckUsername = "__credential_check__"
+
+// CheckCredentials makes a cheap authenticated call so that a rejected API key
+// shows up as a PanelError with the credentials code
+func (c *APIClient) CheckCredentials() error {
+	if c.IsSimulationMode() {
+		return nil
+	}
+
+	httpReq, err := http.NewRequest("GET", fmt.Sprintf("%s/ext/lines?username=%s", c.BaseURL, credentialCheckUsername), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+
+	httpReq.Header.Set("X-Api-Key", c.APIKey)
+	httpReq.Header.Set("X-Auth-User", c.AuthUser)
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return requestError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return nil
+}
+
+// Ping checks that the panel is reachable and returns the response time.
+// Any HTTP response below 500 counts as reachable; credentials are not checked.
+func (c *APIClient) Ping() (time.Duration, error) {
+	if c.IsSimulationMode() {
+		return 0, nil
+	}
+
+	start := time.Now()
+	httpReq, err := http.NewRequest("GET", c.BaseURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %v", err)
+	}
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return 0, requestError(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	elapsed := time.Since(start)
+	if resp.StatusCode >= 500 {
+		return elapsed, &PanelError{
+			Code:    PanelErrorConnectivity,
+			Status:  resp.StatusCode,
+			Message: "connection error: " + formatConnectionError(resp.StatusCode),
+		}
+	}
+	return elapsed, nil
+}
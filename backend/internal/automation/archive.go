@@ -0,0 +1,122 @@
+package automation
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// archivedTaskResponse formats an archived task like GetTask does for live tasks
+func archivedTaskResponse(task models.AutomationTaskArchive) gin.H {
+	var result interface{}
+	if len(task.Result) == 0 || json.Unmarshal([]byte(task.Result), &result) != nil {
+		result = map[string]interface{}{
+			"success": false,
+			"error":   "Failed to parse task result data",
+		}
+	}
+
+	return gin.H{
+		"id":             task.ID,
+		"user_id":        task.UserID,
+		"name":           task.Name,
+		"target_website": task.TargetWebsite,
+		"status":         task.Status,
+		"created_at":     task.CreatedAt,
+		"updated_at":     task.UpdatedAt,
+		"completed_at":   task.CompletedAt,
+		"archived_at":    task.ArchivedAt,
+		"result":         result,
+	}
+}
+
+// GetArchivedTasks returns the current user's archived tasks
+func GetArchivedTasks(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	page, err := utils.ParsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tasks []models.AutomationTaskArchive
+	query := database.GetReadDB().Model(&models.AutomationTaskArchive{}).Where("user_id = ?", u.ID)
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve archived tasks"})
+		return
+	}
+
+	if page != nil {
+		query = page.Apply(query)
+	} else {
+		query = query.Order("created_at DESC")
+	}
+
+	if err := query.Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve archived tasks"})
+		return
+	}
+
+	next := ""
+	if page != nil {
+		var n int
+		n, next = page.NextCursor(len(tasks), func(i int) utils.Cursor {
+			return utils.Cursor{CreatedAt: tasks[i].CreatedAt, ID: tasks[i].ID}
+		})
+		tasks = tasks[:n]
+		c.Header("X-Next-Cursor", next)
+	}
+
+	response := make([]gin.H, 0, len(tasks))
+	for _, task := range tasks {
+		response = append(response, archivedTaskResponse(task))
+	}
+
+	utils.RespondList(c, response, total, next)
+}
+
+// GetArchivedTask returns a single archived task
+func GetArchivedTask(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	var task models.AutomationTaskArchive
+	db := database.GetReadDB()
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), u.ID).First(&task).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, archivedTaskResponse(task))
+}
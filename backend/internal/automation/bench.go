@@ -0,0 +1,308 @@
+package automation
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/settings"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	maxBenchUsers        = 1000
+	maxBenchTasksPerUser = 1000
+	maxBenchTasks        = 100000
+)
+
+type BenchSeedRequest struct {
+	Users        int `json:"users" binding:"required"`
+	TasksPerUser int `json:"tasks_per_user"`
+	// Panel replaces the simulated panel profile before the tasks start
+	Panel *SimulatedPanelProfile `json:"panel"`
+	// PanelURL points bench users at another panel; defaults to the simulated one
+	PanelURL string `json:"panel_url"`
+}
+
+// BenchRun is the progress of a seeding run
+type BenchRun struct {
+	ID           string     `json:"id"`
+	Users        int        `json:"users"`
+	Tasks        int        `json:"tasks"`
+	Enqueued     int        `json:"enqueued"`
+	Rejected     int        `json:"rejected"`
+	PanelURL     string     `json:"panel_url"`
+	StartedAt    time.Time  `json:"started_at"`
+	EnqueuedAt   *time.Time `json:"enqueued_at"`
+	EnqueueRate  float64    `json:"enqueue_per_second"`
+	LastError    string     `json:"last_error,omitempty"`
+	usersCreated []models.UserSettings
+}
+
+// benchCredential is the API key and auth user of bench users; the simulated
+// panel rejects anything else, so real panels are never sent bench traffic
+const benchCredential = "bench"
+
+var (
+	benchMu   sync.Mutex
+	benchRuns []*BenchRun
+
+	bench = NewSimulatedPanel(benchCredential, benchCredential, SimulatedPanelProfile{LatencyMS: 200, JitterMS: 100})
+)
+
+// benchPanelURL is the address bench users reach the simulated panel at
+func benchPanelURL() string {
+	addr := config.Get().BenchPanelAddr
+	if strings.HasPrefix(addr, ":") {
+		addr = "127.0.0.1" + addr
+	}
+	return "http://" + addr
+}
+
+// benchRequest returns a random task for a bench user; about half of the
+// tasks act on lines that already exist on the simulated panel
+func benchRequest(rng *rand.Rand, panelURL, prefix string, existingLines int, n int) TaskRequest {
+	existing := fmt.Sprintf("%s-line%d", prefix, rng.Intn(existingLines))
+	switch roll := rng.Intn(10); {
+	case roll < 4:
+		return TaskRequest{
+			Name:          "create_account",
+			TargetWebsite: panelURL,
+			Username:      fmt.Sprintf("%s-new%d", prefix, n),
+			Password:      fmt.Sprintf("Bench%04d!", rng.Intn(10000)),
+			Package:       demoPackages[rng.Intn(len(demoPackages))],
+		}
+	case roll < 7:
+		return TaskRequest{Name: "find_account", TargetWebsite: panelURL, Username: existing}
+	default:
+		return TaskRequest{Name: "extend_package", TargetWebsite: panelURL, Username: existing, Package: 101}
+	}
+}
+
+// runBenchTasks enqueues the run's tasks through the regular task path, so they
+// are executed exactly like user-created tasks
+func runBenchTasks(db *gorm.DB, run *BenchRun, tasksPerUser int) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	existingLines := tasksPerUser/4 + 1
+
+	for n := 0; n < tasksPerUser; n++ {
+		for _, s := range run.usersCreated {
+			prefix := fmt.Sprintf("bench-%s-%d", run.ID, s.UserID)
+			_, _, err := enqueueTask(db, s, benchRequest(rng, s.WebsiteURL, prefix, existingLines, n), nil)
+
+			benchMu.Lock()
+			if err != nil {
+				run.Rejected++
+				run.LastError = err.Error()
+			} else {
+				run.Enqueued++
+			}
+			benchMu.Unlock()
+		}
+	}
+
+	now := time.Now()
+	benchMu.Lock()
+	run.EnqueuedAt = &now
+	if elapsed := now.Sub(run.StartedAt).Seconds(); elapsed > 0 {
+		run.EnqueueRate = float64(run.Enqueued) / elapsed
+	}
+	benchMu.Unlock()
+
+	log.Printf("Bench run %s: enqueued %d tasks (%d rejected) in %s", run.ID, run.Enqueued, run.Rejected, now.Sub(run.StartedAt))
+}
+
+// SeedBench creates synthetic users pointed at the simulated panel and enqueues
+// their tasks in the background (admin only, bench mode)
+func SeedBench(c *gin.Context) {
+	var req BenchSeedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Users < 1 || req.Users > maxBenchUsers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("users must be between 1 and %d", maxBenchUsers)})
+		return
+	}
+	if req.TasksPerUser < 0 || req.TasksPerUser > maxBenchTasksPerUser || req.Users*req.TasksPerUser > maxBenchTasks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("tasks_per_user must be between 0 and %d, and at most %d tasks in total", maxBenchTasksPerUser, maxBenchTasks)})
+		return
+	}
+	if req.Panel != nil {
+		if err := req.Panel.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		bench.SetProfile(*req.Panel)
+	}
+
+	panelURL := strings.TrimRight(strings.TrimSpace(req.PanelURL), "/")
+	if panelURL == "" {
+		panelURL = benchPanelURL()
+	}
+
+	// Bench users share one password; hashing one per user would dominate the seeding time
+	password := strings.ReplaceAll(uuid.New().String(), "-", "")[:16]
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	db := database.GetDB()
+	tosVersion, err := settings.Get(db, settings.KeyTOSVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	now := time.Now()
+	run := &BenchRun{
+		ID:        uuid.New().String()[:8],
+		Users:     req.Users,
+		Tasks:     req.Users * req.TasksPerUser,
+		PanelURL:  panelURL,
+		StartedAt: now,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i := 0; i < req.Users; i++ {
+			user := models.User{
+				Username:           fmt.Sprintf("bench-%s-%04d", run.ID, i+1),
+				HashedPassword:     hashedPassword,
+				IsActive:           true,
+				PasswordChangedAt:  &now,
+				DisplayName:        "Bench User",
+				TOSVersionAccepted: tosVersion,
+			}
+			if tosVersion != "" {
+				user.TOSAcceptedAt = &now
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+
+			s := models.UserSettings{
+				UserID:               user.ID,
+				WebsiteURL:           panelURL,
+				APIKey:               benchCredential,
+				AuthUser:             benchCredential,
+				ConnectionVerifiedAt: &now,
+			}
+			if err := tx.Create(&s).Error; err != nil {
+				return err
+			}
+			run.usersCreated = append(run.usersCreated, s)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	// Lines the find and extend tasks act on
+	for _, s := range run.usersCreated {
+		prefix := fmt.Sprintf("bench-%s-%d", run.ID, s.UserID)
+		for j := 0; j < req.TasksPerUser/4+1; j++ {
+			bench.addLine(fmt.Sprintf("%s-line%d", prefix, j), "", 101, now.AddDate(0, 1, 0), false)
+		}
+	}
+
+	benchMu.Lock()
+	benchRuns = append(benchRuns, run)
+	benchMu.Unlock()
+
+	go runBenchTasks(db, run, req.TasksPerUser)
+
+	audit.Record(c, audit.ActionBenchSeeded, "bench", run.ID, map[string]interface{}{
+		"users":     run.Users,
+		"tasks":     run.Tasks,
+		"panel_url": panelURL,
+		"panel":     bench.Profile(),
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"run":      run,
+		"password": password,
+		"panel":    bench.Profile(),
+	})
+}
+
+// GetBench returns the simulated panel profile and statistics and the seeding runs (admin only, bench mode)
+func GetBench(c *gin.Context) {
+	benchMu.Lock()
+	runs := make([]BenchRun, 0, len(benchRuns))
+	for _, run := range benchRuns {
+		runs = append(runs, *run)
+	}
+	benchMu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"panel_url": benchPanelURL(),
+		"panel":     bench.Profile(),
+		"stats":     bench.Stats(),
+		"runs":      runs,
+	})
+}
+
+// UpdateBenchPanel changes the simulated panel's latency and failures, also
+// during a run (admin only, bench mode)
+func UpdateBenchPanel(c *gin.Context) {
+	var req SimulatedPanelProfile
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bench.SetProfile(req)
+	c.JSON(http.StatusOK, req)
+}
+
+// setupBenchRoutes registers the bench routes when bench mode is enabled
+func setupBenchRoutes(router *gin.RouterGroup) {
+	if !config.Get().BenchMode {
+		return
+	}
+	router.GET("/bench", GetBench)
+	router.POST("/bench/seed", SeedBench)
+	router.PUT("/bench/panel", UpdateBenchPanel)
+}
+
+// StartBenchPanel serves the simulated panel on BENCH_PANEL_ADDR when bench
+// mode is enabled. It runs on its own listener so that panel traffic is not
+// counted by the API's rate limiter and latency tracking.
+func StartBenchPanel() {
+	cfg := config.Get()
+	if !cfg.BenchMode {
+		return
+	}
+
+	r := gin.New()
+	r.Use(gin.Recovery())
+	bench.Register(r)
+
+	go func() {
+		if err := http.ListenAndServe(cfg.BenchPanelAddr, r); err != nil {
+			log.Printf("Simulated bench panel stopped: %v", err)
+		}
+	}()
+
+	log.Printf("WARNING: bench mode is enabled; simulated panel listening on %s", cfg.BenchPanelAddr)
+}
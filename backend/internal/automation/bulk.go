@@ -0,0 +1,445 @@
+package automation
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/middleware"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/notify"
+	"github.com/aliselcukkaya/account-editor/internal/quota"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BatchStatusWaitingOnPanel marks a batch held until its panel is reachable again
+const BatchStatusWaitingOnPanel = "waiting_on_panel"
+
+const (
+	// maxBulkTasks limits how many tasks a single bulk request may create
+	maxBulkTasks = 5000
+	// bulkInsertBatchSize is the number of rows per INSERT statement
+	bulkInsertBatchSize = 200
+)
+
+type BulkTaskRequest struct {
+	Tasks []TaskRequest `json:"tasks" binding:"required"`
+}
+
+// BulkRowError describes why a row of a bulk request was rejected
+type BulkRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// csvColumns are the task fields a CSV column can fill
+var csvColumns = []string{"name", "target_website", "username", "password", "package", "max_connections", "note"}
+
+// parseBulkCSV reads task rows from a CSV file with a header line.
+// Recognized columns: name, target_website, username, password, package,
+// max_connections, note.
+func parseBulkCSV(r io.Reader) ([]TaskRequest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %v", err)
+	}
+
+	columns := make(map[string]int)
+	for i, col := range header {
+		columns[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("CSV must have a name column")
+	}
+
+	var tasks []TaskRequest
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV: %v", err)
+		}
+
+		req, err := csvTask(record, columns, len(tasks)+2)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, req)
+	}
+
+	return tasks, nil
+}
+
+// csvTask builds a task request from a CSV record. Columns maps task fields to
+// record indexes; line is the record's line number for error messages.
+func csvTask(record []string, columns map[string]int, line int) (TaskRequest, error) {
+	get := func(col string) string {
+		if i, ok := columns[col]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	req := TaskRequest{
+		Name:          get("name"),
+		TargetWebsite: get("target_website"),
+		Username:      get("username"),
+		Password:      get("password"),
+		Note:          get("note"),
+	}
+	if pkg := get("package"); pkg != "" {
+		n, err := strconv.Atoi(pkg)
+		if err != nil {
+			return req, fmt.Errorf("invalid package %q on line %d", pkg, line)
+		}
+		req.Package = n
+	}
+	if conns := get("max_connections"); conns != "" {
+		n, err := strconv.Atoi(conns)
+		if err != nil {
+			return req, fmt.Errorf("invalid max_connections %q on line %d", conns, line)
+		}
+		req.MaxConnections = n
+	}
+	return req, nil
+}
+
+// validateBulkTasks checks each row and fills in the target website from
+// settings and empty fields from the user's presets
+func validateBulkTasks(tasks []TaskRequest, u models.User, websiteURL string) []BulkRowError {
+	var rowErrors []BulkRowError
+
+	for i := range tasks {
+		req := &tasks[i]
+		if req.TargetWebsite == "" {
+			req.TargetWebsite = websiteURL
+		}
+		applyPresets(u, req)
+
+		if errs := validateTaskRequest(*req); len(errs) > 0 {
+			rowErrors = append(rowErrors, BulkRowError{Row: i + 1, Error: strings.Join(errs, "; ")})
+		}
+	}
+
+	return rowErrors
+}
+
+// CreateBulkTasks creates many tasks in one transaction and executes them as a batch.
+// Accepts either a JSON body ({"tasks": [...]}) or a multipart CSV upload in the "file" field.
+func CreateBulkTasks(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	var requests []TaskRequest
+	source := "json"
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		source = "csv"
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file is required in the file field"})
+			return
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+			return
+		}
+		defer f.Close()
+
+		requests, err = parseBulkCSV(f)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		var req BulkTaskRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		requests = req.Tasks
+	}
+
+	createBatch(c, u, requests, source, nil)
+}
+
+// createBatch validates task requests, stores them as a batch and starts it,
+// writing the response with the extra fields. It returns the batch and whether
+// it was created.
+func createBatch(c *gin.Context, u models.User, requests []TaskRequest, source string, extra gin.H) (models.TaskBatch, bool) {
+	created := func(body gin.H) {
+		for k, v := range extra {
+			body[k] = v
+		}
+		c.JSON(http.StatusCreated, body)
+	}
+
+	if len(requests) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "No tasks provided")})
+		return models.TaskBatch{}, false
+	}
+	if len(requests) > maxBulkTasks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A batch may contain at most %d tasks", maxBulkTasks)})
+		return models.TaskBatch{}, false
+	}
+
+	db := database.GetDB()
+
+	settings, ok := loadTaskSettings(c, db, u.ID)
+	if !ok {
+		return models.TaskBatch{}, false
+	}
+	if settings.CredentialStatus == models.CredentialStatusInvalid {
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "Your panel rejected the saved API key. Update your settings before creating tasks.")})
+		return models.TaskBatch{}, false
+	}
+
+	if err := quota.AllowBatch(db, u, len(requests)); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": i18n.T(c, "The batch is larger than your plan allows")})
+		return models.TaskBatch{}, false
+	}
+	if err := quota.AllowTasks(db, u, len(requests)); err != nil {
+		if err == quota.ErrExceeded {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": i18n.T(c, "Daily task quota reached")})
+			return models.TaskBatch{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return models.TaskBatch{}, false
+	}
+
+	if rowErrors := validateBulkTasks(requests, u, settings.WebsiteURL); len(rowErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  i18n.T(c, "Some rows are invalid"),
+			"errors": rowErrors,
+		})
+		return models.TaskBatch{}, false
+	}
+
+	batch := models.TaskBatch{
+		UserID: u.ID,
+		Status: "pending",
+		Source: source,
+		Total:  len(requests),
+	}
+	tasks := make([]models.AutomationTask, len(requests))
+	createdBy := middleware.SubAccountID(c)
+
+	// Insert the batch and all of its tasks in a single transaction using multi-row INSERTs
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for i, req := range requests {
+			requestJSON, err := json.Marshal(req)
+			if err != nil {
+				return err
+			}
+
+			tasks[i] = models.AutomationTask{
+				UserID:        u.ID,
+				BatchID:       &batch.ID,
+				Name:          req.Name,
+				Status:        "pending",
+				TargetWebsite: req.TargetWebsite,
+				Request:       json.RawMessage(requestJSON),
+				CreatedBy:     createdBy,
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			}
+		}
+
+		return tx.CreateInBatches(&tasks, bulkInsertBatchSize).Error
+	})
+	if err != nil {
+		log.Printf("Failed to create batch for user ID %d: %v", u.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tasks"})
+		return models.TaskBatch{}, false
+	}
+	go notify.CheckQuota(u.ID)
+
+	// Outside the execution window the batch waits for the dispatcher
+	if open, opensAt := executionWindowOpen(db, settings); !open {
+		batch.Status = BatchStatusHeld
+		db.Model(&batch).Update("status", batch.Status)
+
+		created(gin.H{
+			"batch_id":   batch.ID,
+			"status":     batch.Status,
+			"total":      batch.Total,
+			"held_until": opensAt,
+			"message":    i18n.T(c, "Outside the execution window; tasks will start when it opens"),
+		})
+		return batch, true
+	}
+
+	// Check the panel first so a whole batch does not fail against an unreachable panel
+	apiClient := NewAPIClient(settings.WebsiteURL, settings.APIKey, settings.AuthUser)
+	if _, err := apiClient.Ping(); err != nil {
+		log.Printf("Panel unreachable for batch ID %d, holding it until the panel recovers: %v", batch.ID, err)
+		batch.Status = BatchStatusWaitingOnPanel
+		db.Model(&batch).Update("status", batch.Status)
+
+		created(gin.H{
+			"batch_id": batch.ID,
+			"status":   batch.Status,
+			"total":    batch.Total,
+			"message":  i18n.T(c, "Panel is unreachable; the batch will start when it recovers"),
+		})
+		return batch, true
+	}
+
+	go executeBatch(batch.ID, tasks, requests, apiClient)
+
+	created(gin.H{
+		"batch_id": batch.ID,
+		"status":   batch.Status,
+		"total":    batch.Total,
+		"message":  i18n.T(c, "Tasks created successfully"),
+	})
+	return batch, true
+}
+
+// ResumeWaitingBatches starts the user's batches that were held because the panel
+// was unreachable. Called by the uptime monitor after a successful probe; the
+// held task dispatcher also pings the panel and resumes them every minute.
+func ResumeWaitingBatches(db *gorm.DB, userID int) {
+	apiClient, err := newClientForUser(db, userID)
+	if err != nil {
+		log.Printf("Failed to load settings to resume batches for user ID %d: %v", userID, err)
+		return
+	}
+	resumeBatches(db, userID, BatchStatusWaitingOnPanel, apiClient)
+}
+
+// resumeBatches starts the user's batches that are in the given waiting status
+func resumeBatches(db *gorm.DB, userID int, status string, apiClient *APIClient) {
+	var batches []models.TaskBatch
+	if err := db.Where("user_id = ? AND status = ?", userID, status).
+		Order("id").
+		Find(&batches).Error; err != nil {
+		log.Printf("Failed to load %s batches for user ID %d: %v", status, userID, err)
+		return
+	}
+
+	for _, batch := range batches {
+		// Claim the batch so concurrent callers cannot start it twice
+		result := db.Model(&models.TaskBatch{}).
+			Where("id = ? AND status = ?", batch.ID, status).
+			Update("status", "running")
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		var tasks []models.AutomationTask
+		if err := db.Where("batch_id = ? AND status = ?", batch.ID, "pending").Order("id").Find(&tasks).Error; err != nil {
+			log.Printf("Failed to load tasks of batch ID %d: %v", batch.ID, err)
+			continue
+		}
+
+		requests := make([]TaskRequest, len(tasks))
+		for i, task := range tasks {
+			if err := json.Unmarshal(task.Request, &requests[i]); err != nil {
+				log.Printf("Invalid stored request for task ID %d: %v", task.ID, err)
+			}
+		}
+
+		log.Printf("Starting %s batch ID %d (%d tasks)", status, batch.ID, len(tasks))
+		go executeBatch(batch.ID, tasks, requests, apiClient)
+	}
+}
+
+// executeBatch runs the tasks of a batch one after another so a large batch does
+// not flood the panel, and emits a single summary once the whole batch is done
+func executeBatch(batchID int, tasks []models.AutomationTask, requests []TaskRequest, apiClient *APIClient) {
+	db := database.GetDB()
+	db.Model(&models.TaskBatch{}).Where("id = ?", batchID).Update("status", "running")
+
+	for i, task := range tasks {
+		executeTask(task.ID, requests[i], apiClient)
+	}
+
+	now := time.Now()
+	db.Model(&models.TaskBatch{}).Where("id = ?", batchID).Updates(map[string]interface{}{
+		"status":       "completed",
+		"completed_at": &now,
+	})
+
+	log.Printf("Batch ID %d finished (%d tasks)", batchID, len(tasks))
+}
+
+// GetBatch returns a batch with task counts per status
+func GetBatch(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var batch models.TaskBatch
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), u.ID).First(&batch).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Batch not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var counts []struct {
+		Status string
+		Count  int
+	}
+	if err := db.Model(&models.AutomationTask{}).
+		Select("status, COUNT(*) AS count").
+		Where("batch_id = ?", batch.ID).
+		Group("status").
+		Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	statusCounts := make(map[string]int)
+	for _, row := range counts {
+		statusCounts[row.Status] = row.Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"batch":  batch,
+		"counts": statusCounts,
+	})
+}
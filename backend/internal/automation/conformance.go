@@ -0,0 +1,302 @@
+package automation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Conformance step outcomes
+const (
+	ConformancePass = "pass"
+	ConformanceFail = "fail"
+	ConformanceSkip = "skip"
+)
+
+// ErrConformanceSimulated is returned for profiles using the simulation
+// credentials, which never reach a panel
+var ErrConformanceSimulated = errors.New("the profile uses the simulation credentials, so there is no panel to test")
+
+// ConformanceOptions configures a conformance run
+type ConformanceOptions struct {
+	// Package is the package ID the trial line is created and extended with
+	Package int
+	// Username of the trial line; a random "conformance-..." name when empty
+	Username string
+}
+
+// ConformanceStep is the outcome of one step of the suite
+type ConformanceStep struct {
+	Name       string   `json:"name"`
+	Status     string   `json:"status"`
+	DurationMS int64    `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+	ErrorCode  string   `json:"error_code,omitempty"`
+	Problems   []string `json:"problems,omitempty"`
+}
+
+// ConformanceReport tells whether a panel supports everything the tasks use
+type ConformanceReport struct {
+	WebsiteURL string            `json:"website_url"`
+	Username   string            `json:"username"`
+	LineID     string            `json:"line_id,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	DurationMS int64             `json:"duration_ms"`
+	Compatible bool              `json:"compatible"`
+	Passed     int               `json:"passed"`
+	Failed     int               `json:"failed"`
+	Skipped    int               `json:"skipped"`
+	Steps      []ConformanceStep `json:"steps"`
+}
+
+// conformanceRun carries the state shared by the steps of a run
+type conformanceRun struct {
+	client *APIClient
+	report *ConformanceReport
+	line   Line
+	// failed is set once a step failed; steps that need the line are skipped
+	failed bool
+}
+
+// step runs one step of the suite. fn returns the problems found in the
+// panel's answers, or an error when the call itself failed.
+func (r *conformanceRun) step(name string, needsLine bool, fn func() ([]string, error)) {
+	s := ConformanceStep{Name: name}
+	if needsLine && (r.failed || r.line.LineID == "") {
+		s.Status = ConformanceSkip
+		r.report.Skipped++
+		r.report.Steps = append(r.report.Steps, s)
+		return
+	}
+
+	start := time.Now()
+	problems, err := fn()
+	s.DurationMS = time.Since(start).Milliseconds()
+	s.Problems = problems
+	switch {
+	case err != nil:
+		s.Status = ConformanceFail
+		s.Error = sanitizeErrorMessage(err.Error())
+		s.ErrorCode = panelErrorCode(err)
+	case len(problems) > 0:
+		s.Status = ConformanceFail
+	default:
+		s.Status = ConformancePass
+	}
+
+	if s.Status == ConformanceFail {
+		r.report.Failed++
+		if needsLine {
+			r.failed = true
+		}
+	} else {
+		r.report.Passed++
+	}
+	r.report.Steps = append(r.report.Steps, s)
+}
+
+// findTrialLine looks the trial line up by username and checks it is listed once
+func (r *conformanceRun) findTrialLine() (Line, []string, error) {
+	lines, err := r.client.FindAccount(r.report.Username)
+	if err != nil {
+		return Line{}, nil, err
+	}
+	for _, l := range lines {
+		if l.LineID == r.line.LineID {
+			var problems []string
+			if len(lines) > 1 {
+				problems = append(problems, fmt.Sprintf("%d lines listed for a username created once", len(lines)))
+			}
+			if l.Username != r.report.Username {
+				problems = append(problems, fmt.Sprintf("username is %q, expected %q", l.Username, r.report.Username))
+			}
+			return l, problems, nil
+		}
+	}
+	return Line{}, []string{fmt.Sprintf("line %s is not listed for username %q", r.line.LineID, r.report.Username)}, nil
+}
+
+// checkRID reports a panel that does not echo the request ID
+func checkRID(got, sent string) []string {
+	if got != sent {
+		return []string{fmt.Sprintf("rid is %q, expected the request's %q", got, sent)}
+	}
+	return nil
+}
+
+// RunConformance runs a scripted suite against a panel: it creates a trial
+// line, finds, extends, disables and deletes it, checking every answer the way
+// the tasks rely on it. The trial line is deleted at the end; if the panel
+// cannot delete it, it is left disabled.
+func RunConformance(client *APIClient, opts ConformanceOptions) (*ConformanceReport, error) {
+	if client.IsSimulationMode() {
+		return nil, ErrConformanceSimulated
+	}
+	if opts.Username == "" {
+		opts.Username = "conformance-" + uuid.New().String()[:8]
+	}
+	report := &ConformanceReport{
+		WebsiteURL: client.BaseURL,
+		Username:   opts.Username,
+		StartedAt:  time.Now(),
+		Steps:      []ConformanceStep{},
+	}
+	r := &conformanceRun{client: client, report: report}
+
+	r.step("connectivity", false, func() ([]string, error) {
+		_, err := client.Ping()
+		return nil, err
+	})
+
+	r.step("credentials", false, func() ([]string, error) {
+		return nil, client.CheckCredentials()
+	})
+
+	r.step("rejects_invalid_credentials", false, func() ([]string, error) {
+		bad := NewAPIClient(client.BaseURL, "invalid-"+uuid.New().String(), client.AuthUser)
+		bad.HTTPClient.Timeout = client.HTTPClient.Timeout
+		err := bad.CheckCredentials()
+		if err == nil {
+			return []string{"an invalid API key was accepted"}, nil
+		}
+		if code := panelErrorCode(err); code != PanelErrorCredentials {
+			return []string{fmt.Sprintf("an invalid API key failed with error code %q instead of %q, so the credential monitor cannot detect revoked keys", code, PanelErrorCredentials)}, nil
+		}
+		return nil, nil
+	})
+
+	r.step("find_unknown_username", false, func() ([]string, error) {
+		lines, err := client.FindAccount("conformance-missing-" + uuid.New().String()[:8])
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) > 0 {
+			return []string{fmt.Sprintf("%d lines listed for a username that does not exist", len(lines))}, nil
+		}
+		return nil, nil
+	})
+
+	// The steps below work on the trial line and stop at the first failure
+	r.step("create_trial", false, func() ([]string, error) {
+		rid := uuid.New().String()
+		resp, err := client.CreateAccount(CreateAccountRequest{
+			Username:      opts.Username,
+			Password:      uuid.New().String()[:12],
+			Package:       opts.Package,
+			ResellerNotes: "panel-conformance trial line, safe to delete",
+			IsTrial:       true,
+			RID:           rid,
+		})
+		if err != nil {
+			r.failed = true
+			return nil, err
+		}
+		r.line = Line{LineID: resp.LineID, ExpireAt: resp.ExpireAt}
+		report.LineID = resp.LineID
+
+		problems := checkRID(resp.RID, rid)
+		if resp.LineID == "" {
+			problems = append(problems, "no line_id returned")
+		}
+		if !resp.ExpireAt.After(time.Now()) {
+			problems = append(problems, fmt.Sprintf("expire_at %s is not in the future", resp.ExpireAt.Format(time.RFC3339)))
+		}
+		return problems, nil
+	})
+
+	r.step("find", true, func() ([]string, error) {
+		line, problems, err := r.findTrialLine()
+		if err != nil || line.LineID == "" {
+			return problems, err
+		}
+		if !line.IsEnabled {
+			problems = append(problems, "a new line is listed as disabled")
+		}
+		if !line.IsTrial {
+			problems = append(problems, "the line is not listed as a trial")
+		}
+		if line.Password == "" {
+			problems = append(problems, "no password listed, so extend results cannot show it")
+		}
+		r.line = line
+		return problems, nil
+	})
+
+	r.step("extend", true, func() ([]string, error) {
+		before := r.line.ExpireAt
+		rid := uuid.New().String()
+		resp, err := client.ExtendPackage(r.line.LineID, ExtendPackageRequest{Package: opts.Package, RID: rid})
+		if err != nil {
+			return nil, err
+		}
+
+		problems := checkRID(resp.RID, rid)
+		if resp.LineID != r.line.LineID {
+			problems = append(problems, fmt.Sprintf("line_id is %q, expected %q", resp.LineID, r.line.LineID))
+		}
+		if !resp.ExpireAt.After(before) {
+			problems = append(problems, fmt.Sprintf("expire_at %s did not move past %s", resp.ExpireAt.Format(time.RFC3339), before.Format(time.RFC3339)))
+		}
+
+		line, findProblems, err := r.findTrialLine()
+		if err != nil {
+			return problems, err
+		}
+		problems = append(problems, findProblems...)
+		if line.LineID != "" && !line.ExpireAt.Equal(resp.ExpireAt) {
+			problems = append(problems, fmt.Sprintf("the line is listed with expire_at %s, the extension returned %s", line.ExpireAt.Format(time.RFC3339), resp.ExpireAt.Format(time.RFC3339)))
+		}
+		return problems, nil
+	})
+
+	r.step("disable", true, func() ([]string, error) {
+		rid := uuid.New().String()
+		resp, err := client.DisableLine(r.line.LineID, DisableLineRequest{RID: rid})
+		if err != nil {
+			return nil, err
+		}
+
+		problems := checkRID(resp.RID, rid)
+		if resp.IsEnabled {
+			problems = append(problems, "is_enabled is still true")
+		}
+
+		line, findProblems, err := r.findTrialLine()
+		if err != nil {
+			return problems, err
+		}
+		problems = append(problems, findProblems...)
+		if line.LineID != "" && line.IsEnabled {
+			problems = append(problems, "the line is still listed as enabled")
+		}
+		return problems, nil
+	})
+
+	// The line is deleted even after a failed step, so the panel is left clean
+	r.failed = false
+	r.step("delete", true, func() ([]string, error) {
+		rid := uuid.New().String()
+		resp, err := client.DeleteLine(r.line.LineID, DeleteLineRequest{RID: rid})
+		if err != nil {
+			return nil, err
+		}
+
+		problems := checkRID(resp.RID, rid)
+		lines, err := client.FindAccount(report.Username)
+		if err != nil {
+			return problems, err
+		}
+		for _, l := range lines {
+			if l.LineID == r.line.LineID {
+				problems = append(problems, "the line is still listed after deletion")
+			}
+		}
+		return problems, nil
+	})
+
+	report.DurationMS = time.Since(report.StartedAt).Milliseconds()
+	report.Compatible = report.Failed == 0 && report.Skipped == 0
+	return report, nil
+}
@@ -0,0 +1,361 @@
+package automation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/settings"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// demoWebsiteURL is the panel address of demo users; it is never contacted
+	demoWebsiteURL = "https://demo.panel.invalid"
+
+	// demoStartingCredit is topped up before any seeded purchase
+	demoStartingCredit = 10000.0
+
+	defaultDemoLines = 12
+	defaultDemoTasks = 30
+	maxDemoLines     = 200
+	maxDemoTasks     = 1000
+)
+
+// demoPackages are the packages seeded lines are bought with
+var demoPackages = []int{101, 101, 103, 106, 112}
+
+type DemoUserRequest struct {
+	// Username defaults to demo-<random>
+	Username string `json:"username"`
+	// Lines is the number of seeded lines, each created by a create_account task
+	Lines *int `json:"lines"`
+	// Tasks is the number of extra find/extend tasks in the seeded history
+	Tasks *int `json:"tasks"`
+}
+
+// demoSeeder builds the seeded history of a demo user inside one transaction
+type demoSeeder struct {
+	tx     *gorm.DB
+	rng    *rand.Rand
+	userID int
+	now    time.Time
+	lines  []*models.Line
+}
+
+// addTask stores a finished sample task with a result shaped like a real execution
+func (s *demoSeeder) addTask(req TaskRequest, status string, result map[string]interface{}, at time.Time) (models.AutomationTask, error) {
+	requestJSON, err := json.Marshal(req)
+	if err != nil {
+		return models.AutomationTask{}, err
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return models.AutomationTask{}, err
+	}
+
+	completedAt := at.Add(time.Duration(1+s.rng.Intn(20)) * time.Second)
+	task := models.AutomationTask{
+		UserID:        s.userID,
+		Name:          req.Name,
+		TargetWebsite: req.TargetWebsite,
+		Status:        status,
+		Request:       json.RawMessage(requestJSON),
+		Result:        models.JSON(resultJSON),
+		CreatedAt:     at,
+		UpdatedAt:     completedAt,
+		CompletedAt:   &completedAt,
+	}
+	return task, s.tx.Create(&task).Error
+}
+
+// addTransaction stores a credit transaction at the given time
+func (s *demoSeeder) addTransaction(taskID *int, lineID, txType string, pkg int, amount float64, note string, at time.Time) error {
+	return s.tx.Create(&models.Transaction{
+		UserID:    s.userID,
+		TaskID:    taskID,
+		LineID:    lineID,
+		Type:      txType,
+		PackageID: pkg,
+		Amount:    amount,
+		Note:      note,
+		CreatedAt: at,
+	}).Error
+}
+
+// randomTime returns a time between the given number of days ago and now
+func (s *demoSeeder) randomTime(days int) time.Time {
+	return s.now.Add(-time.Duration(s.rng.Int63n(int64(days) * int64(24*time.Hour))))
+}
+
+// seedLine creates a line through a completed create_account task
+func (s *demoSeeder) seedLine(i int) error {
+	pkg := demoPackages[s.rng.Intn(len(demoPackages))]
+	months := pkg % 100
+	req := TaskRequest{
+		Name:          "create_account",
+		TargetWebsite: demoWebsiteURL,
+		Username:      fmt.Sprintf("customer%03d", i+1),
+		Password:      fmt.Sprintf("Demo%04d!", s.rng.Intn(10000)),
+		Package:       pkg,
+	}
+
+	// Spread purchases so that some lines have already expired and some expire soon
+	createdAt := s.randomTime(months*30 + 10)
+	expireAt := createdAt.AddDate(0, months, 0)
+	lineID := "sim-" + uuid.New().String()
+	price := simulatedPrice(pkg)
+
+	task, err := s.addTask(req, "completed", map[string]interface{}{
+		"success": true,
+		"data": map[string]interface{}{
+			"line_id":            lineID,
+			"username":           req.Username,
+			"password":           req.Password,
+			"expire_at":          expireAt,
+			"transaction_amount": price,
+			"rid":                uuid.New().String(),
+		},
+	}, createdAt)
+	if err != nil {
+		return err
+	}
+
+	line := &models.Line{
+		UserID:     s.userID,
+		LineID:     lineID,
+		Username:   req.Username,
+		PackageID:  pkg,
+		ExpireAt:   &expireAt,
+		LastTaskID: &task.ID,
+		CreatedAt:  task.CreatedAt,
+	}
+	if err := s.tx.Create(line).Error; err != nil {
+		return err
+	}
+	s.lines = append(s.lines, line)
+
+	return s.addTransaction(&task.ID, lineID, models.TransactionPurchase, pkg, -price, "", task.CreatedAt)
+}
+
+// seedHistoryTask creates a find, extend or failed task against a seeded line
+func (s *demoSeeder) seedHistoryTask() error {
+	line := s.lines[s.rng.Intn(len(s.lines))]
+	at := s.randomTime(60)
+	if at.Before(line.CreatedAt) {
+		at = line.CreatedAt.Add(time.Hour)
+	}
+
+	switch roll := s.rng.Intn(10); {
+	case roll == 0:
+		// A typo in the username, as happens with real batches
+		req := TaskRequest{Name: "extend_package", TargetWebsite: demoWebsiteURL, Username: line.Username + "x", Package: 101}
+		_, err := s.addTask(req, "failed", map[string]interface{}{
+			"success":    false,
+			"error":      "No accounts found with the provided username",
+			"error_code": PanelErrorNotFound,
+		}, at)
+		return err
+
+	case roll < 5:
+		req := TaskRequest{Name: "find_account", TargetWebsite: demoWebsiteURL, Username: line.Username}
+		task, err := s.addTask(req, "completed", map[string]interface{}{
+			"success": true,
+			"data": []Line{{
+				LineID:    line.LineID,
+				Username:  line.Username,
+				Password:  fmt.Sprintf("Demo%04d!", s.rng.Intn(10000)),
+				ExpireAt:  *line.ExpireAt,
+				PackageID: line.PackageID,
+			}},
+		}, at)
+		if err != nil {
+			return err
+		}
+		return s.tx.Model(line).Update("last_task_id", task.ID).Error
+
+	default:
+		pkg := demoPackages[s.rng.Intn(len(demoPackages))]
+		req := TaskRequest{Name: "extend_package", TargetWebsite: demoWebsiteURL, Username: line.Username, Package: pkg}
+		base := *line.ExpireAt
+		if base.Before(at) {
+			base = at
+		}
+		expireAt := base.AddDate(0, pkg%100, 0)
+		price := simulatedPrice(pkg)
+
+		task, err := s.addTask(req, "completed", map[string]interface{}{
+			"success": true,
+			"data": map[string]interface{}{
+				"line_id":            line.LineID,
+				"username":           line.Username,
+				"password":           fmt.Sprintf("Demo%04d!", s.rng.Intn(10000)),
+				"expire_at":          expireAt,
+				"transaction_amount": price,
+				"rid":                uuid.New().String(),
+			},
+		}, at)
+		if err != nil {
+			return err
+		}
+
+		line.ExpireAt = &expireAt
+		line.PackageID = pkg
+		if err := s.tx.Model(line).Updates(map[string]interface{}{
+			"expire_at":    expireAt,
+			"package_id":   pkg,
+			"last_task_id": task.ID,
+		}).Error; err != nil {
+			return err
+		}
+		return s.addTransaction(&task.ID, line.LineID, models.TransactionRenewal, pkg, -price, "", task.CreatedAt)
+	}
+}
+
+// ProvisionDemoUser creates a simulated demo user with seeded lines, credit and task history (admin only)
+func ProvisionDemoUser(c *gin.Context) {
+	var req DemoUserRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	lines, tasks := defaultDemoLines, defaultDemoTasks
+	if req.Lines != nil {
+		lines = *req.Lines
+	}
+	if req.Tasks != nil {
+		tasks = *req.Tasks
+	}
+	if lines < 1 || lines > maxDemoLines {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("lines must be between 1 and %d", maxDemoLines)})
+		return
+	}
+	if tasks < 0 || tasks > maxDemoTasks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("tasks must be between 0 and %d", maxDemoTasks)})
+		return
+	}
+
+	username := strings.TrimSpace(req.Username)
+	if username == "" {
+		username = "demo-" + uuid.New().String()[:8]
+	}
+	password := strings.ReplaceAll(uuid.New().String(), "-", "")[:16]
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	db := database.GetDB()
+
+	var count int64
+	if err := db.Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Username already registered")})
+		return
+	}
+
+	// Demo users skip onboarding and the terms of service prompt
+	tosVersion, err := settings.Get(db, settings.KeyTOSVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	now := time.Now()
+	user := models.User{
+		Username:           username,
+		HashedPassword:     hashedPassword,
+		IsActive:           true,
+		IsDemo:             true,
+		PasswordChangedAt:  &now,
+		DisplayName:        "Demo User",
+		TOSVersionAccepted: tosVersion,
+	}
+	if tosVersion != "" {
+		user.TOSAcceptedAt = &now
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.UserSettings{
+			UserID:               user.ID,
+			WebsiteURL:           demoWebsiteURL,
+			APIKey:               "test",
+			AuthUser:             "test",
+			ConnectionVerifiedAt: &now,
+		}).Error; err != nil {
+			return err
+		}
+
+		seeder := &demoSeeder{
+			tx:     tx,
+			rng:    rand.New(rand.NewSource(now.UnixNano())),
+			userID: user.ID,
+			now:    now,
+		}
+
+		if err := seeder.addTransaction(nil, "", models.TransactionTopUp, 0, demoStartingCredit,
+			"Demo starting credit", now.AddDate(0, -25, 0)); err != nil {
+			return err
+		}
+		for i := 0; i < lines; i++ {
+			if err := seeder.seedLine(i); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < tasks; i++ {
+			if err := seeder.seedHistoryTask(); err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.RenewalRule{
+			UserID:           user.ID,
+			Name:             "Renew monthly lines",
+			Package:          101,
+			DaysBeforeExpiry: 3,
+			Enabled:          true,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	audit.Record(c, audit.ActionUserCreated, "user", user.ID, map[string]interface{}{
+		"username": user.Username,
+		"demo":     true,
+		"lines":    lines,
+		"tasks":    lines + tasks,
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":       user.ID,
+		"username": user.Username,
+		"password": password,
+		"lines":    lines,
+		"tasks":    lines + tasks,
+		"message":  i18n.T(c, "User created successfully"),
+	})
+}
@@ -0,0 +1,157 @@
+package automation
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/branding"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// documentPage is the branded frame of receipts and credential cards
+const documentPage = `<!DOCTYPE html>
+<html lang="{{.Locale}}">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} - {{.Brand.ProductName}}</title>
+<style>
+body { font-family: sans-serif; color: #222; max-width: 480px; margin: 2em auto; }
+header { border-bottom: 4px solid {{.Brand.AccentColor | css}}; padding-bottom: .5em; margin-bottom: 1em; }
+header img { max-height: 48px; vertical-align: middle; margin-right: .5em; }
+h1 { color: {{.Brand.AccentColor | css}}; font-size: 1.4em; }
+th { text-align: left; padding: .25em 1em .25em 0; color: #555; font-weight: normal; }
+td { font-weight: bold; }
+footer { margin-top: 2em; font-size: .85em; color: #555; }
+</style>
+</head>
+<body>
+<header>{{if .Brand.LogoURL}}<img src="{{.Brand.LogoURL}}" alt="">{{end}}<strong>{{.Brand.ProductName}}</strong></header>
+<h1>{{.Title}}</h1>
+<table>
+{{range .Rows}}<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{if or .Brand.SupportEmail .Brand.SupportURL}}<footer>{{.Support}}
+{{if .Brand.SupportEmail}}<a href="mailto:{{.Brand.SupportEmail}}">{{.Brand.SupportEmail}}</a>{{end}}
+{{if .Brand.SupportURL}}<a href="{{.Brand.SupportURL}}">{{.Brand.SupportURL}}</a>{{end}}</footer>{{end}}
+</body>
+</html>
+`
+
+var documentTemplate = template.Must(template.New("document").Funcs(template.FuncMap{
+	// css passes the validated accent color through the CSS context
+	"css": func(s string) template.CSS { return template.CSS(s) },
+}).Parse(documentPage))
+
+// documentRow is a labelled value of a document
+type documentRow struct {
+	Label string
+	Value string
+}
+
+// lineTaskResult is the result of a completed create or extend task
+type lineTaskResult struct {
+	Success bool `json:"success"`
+	Data    struct {
+		LineID            string    `json:"line_id"`
+		Username          string    `json:"username"`
+		Password          string    `json:"password"`
+		ExpireAt          time.Time `json:"expire_at"`
+		TransactionAmount float64   `json:"transaction_amount"`
+	} `json:"data"`
+}
+
+// loadLineTask returns a completed create or extend task of the current user
+// with its result, or writes an error response
+func loadLineTask(c *gin.Context) (models.AutomationTask, lineTaskResult, bool) {
+	var result lineTaskResult
+	u, ok := currentUser(c)
+	if !ok {
+		return models.AutomationTask{}, result, false
+	}
+
+	var task models.AutomationTask
+	if err := database.GetDB().Where("id = ? AND user_id = ?", c.Param("id"), u.ID).First(&task).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Task not found")})
+		return task, result, false
+	}
+	if (task.Name != "create_account" && task.Name != "extend_package") || task.Status != "completed" ||
+		json.Unmarshal(task.Result, &result) != nil || !result.Success {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Documents are only available for completed create and extend tasks")})
+		return task, result, false
+	}
+	return task, result, true
+}
+
+// renderDocument writes a branded HTML document
+func renderDocument(c *gin.Context, title string, rows []documentRow) {
+	brand, err := branding.Load(database.GetDB())
+	if err != nil {
+		log.Printf("Failed to load branding, using the defaults: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = documentTemplate.Execute(&buf, map[string]interface{}{
+		"Locale":  i18n.FromContext(c),
+		"Title":   title,
+		"Rows":    rows,
+		"Brand":   brand,
+		"Support": i18n.T(c, "Questions? Contact support:"),
+	})
+	if err != nil {
+		log.Printf("Failed to render %s: %v", title, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Internal server error")})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// GetTaskReceipt renders a branded receipt for a completed create or extend task
+func GetTaskReceipt(c *gin.Context) {
+	task, result, ok := loadLineTask(c)
+	if !ok {
+		return
+	}
+
+	operation := i18n.T(c, "New line")
+	if task.Name == "extend_package" {
+		operation = i18n.T(c, "Extension")
+	}
+	var completedAt time.Time
+	if task.CompletedAt != nil {
+		completedAt = *task.CompletedAt
+	}
+
+	renderDocument(c, i18n.T(c, "Receipt"), []documentRow{
+		{Label: i18n.T(c, "Task"), Value: "#" + strconv.Itoa(task.ID)},
+		{Label: i18n.T(c, "Date"), Value: completedAt.UTC().Format("2006-01-02 15:04 UTC")},
+		{Label: i18n.T(c, "Operation"), Value: operation},
+		{Label: i18n.T(c, "Username"), Value: result.Data.Username},
+		{Label: i18n.T(c, "Line ID"), Value: result.Data.LineID},
+		{Label: i18n.T(c, "Valid until"), Value: result.Data.ExpireAt.UTC().Format("2006-01-02")},
+		{Label: i18n.T(c, "Amount"), Value: strconv.FormatFloat(result.Data.TransactionAmount, 'f', 2, 64)},
+	})
+}
+
+// GetTaskCredentialCard renders a branded card with the line's login details
+// for a completed create or extend task, to hand to the end customer
+func GetTaskCredentialCard(c *gin.Context) {
+	_, result, ok := loadLineTask(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	renderDocument(c, i18n.T(c, "Your login details"), []documentRow{
+		{Label: i18n.T(c, "Username"), Value: result.Data.Username},
+		{Label: i18n.T(c, "Password"), Value: result.Data.Password},
+		{Label: i18n.T(c, "Valid until"), Value: result.Data.ExpireAt.UTC().Format("2006-01-02")},
+	})
+}
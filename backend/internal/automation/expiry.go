@@ -0,0 +1,327 @@
+package automation
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultGraceDays is the grace period of a rule that was never saved
+	defaultGraceDays = 7
+	// maxGraceDays limits how long after expiry a rule may wait
+	maxGraceDays = 365
+)
+
+// Review statuses of expired lines
+const (
+	ExpiryStatusDue      = "due"
+	ExpiryStatusGrace    = "grace"
+	ExpiryStatusExcluded = "excluded"
+	ExpiryStatusQueued   = "queued"
+	ExpiryStatusDisabled = "disabled"
+)
+
+type ExpiryDisableRuleRequest struct {
+	Enabled   bool `json:"enabled"`
+	GraceDays *int `json:"grace_days"`
+}
+
+type ExpiryExclusionRequest struct {
+	Username string `json:"username" binding:"required"`
+	Note     string `json:"note"`
+}
+
+// ExpiredLine is a line past its expiry with what the expiry rule does with it
+type ExpiredLine struct {
+	models.Line
+	Status string `json:"status"`
+	// DisableAfter is when the grace period of the line ends
+	DisableAfter time.Time `json:"disable_after"`
+}
+
+// loadExpiryRule returns the user's expiry rule, or a disabled default
+func loadExpiryRule(db *gorm.DB, userID int) (models.ExpiryDisableRule, error) {
+	rule := models.ExpiryDisableRule{UserID: userID, GraceDays: defaultGraceDays}
+	err := db.Where("user_id = ?", userID).First(&rule).Error
+	if err == gorm.ErrRecordNotFound {
+		err = nil
+	}
+	return rule, err
+}
+
+// excludedUsernames returns the line usernames the user excluded from the expiry rule
+func excludedUsernames(db *gorm.DB, userID int) (map[string]bool, error) {
+	var usernames []string
+	if err := db.Model(&models.ExpiryDisableExclusion{}).Where("user_id = ?", userID).
+		Pluck("username", &usernames).Error; err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[string]bool, len(usernames))
+	for _, username := range usernames {
+		excluded[username] = true
+	}
+	return excluded, nil
+}
+
+// expiryStatus returns what the expiry rule does with an expired line
+func expiryStatus(line models.Line, rule models.ExpiryDisableRule, excluded map[string]bool, now time.Time) string {
+	switch {
+	case line.DisabledAt != nil:
+		return ExpiryStatusDisabled
+	case line.DisableQueuedAt != nil:
+		return ExpiryStatusQueued
+	case excluded[line.Username]:
+		return ExpiryStatusExcluded
+	case line.ExpireAt.AddDate(0, 0, rule.GraceDays).After(now):
+		return ExpiryStatusGrace
+	}
+	return ExpiryStatusDue
+}
+
+// GetExpiryDisableRule returns the current user's expiry rule
+func GetExpiryDisableRule(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	rule, err := loadExpiryRule(database.GetDB(), u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// UpdateExpiryDisableRule turns the current user's expiry rule on or off and
+// sets its grace period
+func UpdateExpiryDisableRule(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req ExpiryDisableRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	rule, err := loadExpiryRule(db, u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if req.GraceDays != nil {
+		if *req.GraceDays < 0 || *req.GraceDays > maxGraceDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "grace_days must be between 0 and 365"})
+			return
+		}
+		rule.GraceDays = *req.GraceDays
+	}
+	rule.Enabled = req.Enabled
+
+	if err := db.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// GetExpiryReview lists the current user's expired lines with what the expiry
+// rule does with each, soonest expired first (filter: status)
+func GetExpiryReview(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	rule, err := loadExpiryRule(db, u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	excluded, err := excludedUsernames(db, u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	now := time.Now()
+	var lines []models.Line
+	if err := db.Where("user_id = ? AND expire_at IS NOT NULL AND expire_at < ?", u.ID, now).
+		Order("expire_at").Find(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	status := c.Query("status")
+	items := []ExpiredLine{}
+	for _, line := range lines {
+		item := ExpiredLine{
+			Line:         line,
+			Status:       expiryStatus(line, rule, excluded, now),
+			DisableAfter: line.ExpireAt.AddDate(0, 0, rule.GraceDays),
+		}
+		if status != "" && item.Status != status {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	utils.RespondList(c, items, int64(len(items)), "")
+}
+
+// GetExpiryExclusions lists the customers excluded from the current user's expiry rule
+func GetExpiryExclusions(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var exclusions []models.ExpiryDisableExclusion
+	if err := database.GetDB().Where("user_id = ?", u.ID).Order("username").Find(&exclusions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	utils.RespondList(c, exclusions, int64(len(exclusions)), "")
+}
+
+// CreateExpiryExclusion excludes a customer's lines from the current user's
+// expiry rule; excluding a customer again updates the note
+func CreateExpiryExclusion(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req ExpiryExclusionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username is required"})
+		return
+	}
+
+	db := database.GetDB()
+	exclusion := models.ExpiryDisableExclusion{UserID: u.ID, Username: req.Username, Note: req.Note}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "username"}},
+		DoUpdates: clause.AssignmentColumns([]string{"note"}),
+	}).Create(&exclusion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	db.Where("user_id = ? AND username = ?", u.ID, req.Username).First(&exclusion)
+
+	c.JSON(http.StatusCreated, exclusion)
+}
+
+// DeleteExpiryExclusion lets the current user's expiry rule disable a customer's lines again
+func DeleteExpiryExclusion(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	result := database.GetDB().Where("id = ? AND user_id = ?", c.Param("id"), u.ID).Delete(&models.ExpiryDisableExclusion{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Exclusion not found")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Exclusion deleted")})
+}
+
+// runExpiryDisabling creates a disable_account task for every line past the
+// grace period of its user's expiry rule. It runs with the renewal rules, so a
+// line renewed by a rule gets a new expiry before it would be disabled.
+func runExpiryDisabling(db *gorm.DB) error {
+	var rules []models.ExpiryDisableRule
+	if err := db.Where("enabled = ?", true).Find(&rules).Error; err != nil {
+		log.Printf("Failed to load expiry disable rules: %v", err)
+		return fmt.Errorf("error loading expiry disable rules: %v", err)
+	}
+
+	failed := 0
+	now := time.Now()
+	for _, rule := range rules {
+		cutoff := now.AddDate(0, 0, -rule.GraceDays)
+		var settings models.UserSettings
+		if err := db.Where("user_id = ?", rule.UserID).First(&settings).Error; err != nil {
+			continue
+		}
+		// Disabling would only fail until the user fixes the API key
+		if settings.CredentialStatus == models.CredentialStatusInvalid {
+			continue
+		}
+
+		excluded, err := excludedUsernames(db, rule.UserID)
+		if err != nil {
+			continue
+		}
+
+		var lines []models.Line
+		if err := db.Where("user_id = ? AND disable_queued_at IS NULL AND disabled_at IS NULL AND expire_at IS NOT NULL AND expire_at < ?",
+			rule.UserID, cutoff).Find(&lines).Error; err != nil {
+			continue
+		}
+
+		queued := map[string]bool{}
+		for _, line := range lines {
+			if line.Username == "" || excluded[line.Username] || queued[line.Username] {
+				continue
+			}
+
+			// The panel's expiry is checked again when the task runs, in case
+			// the line was renewed outside this server
+			req := TaskRequest{
+				Name:          "disable_account",
+				TargetWebsite: settings.WebsiteURL,
+				Username:      line.Username,
+				ExpiredBefore: &cutoff,
+			}
+			task, _, err := enqueueTask(db, settings, req, nil)
+			if err != nil {
+				log.Printf("Failed to enqueue disabling of line %s for user ID %d: %v", line.LineID, rule.UserID, err)
+				failed++
+				continue
+			}
+			queued[line.Username] = true
+
+			db.Model(&models.Line{}).Where("user_id = ? AND username = ? AND disable_queued_at IS NULL", rule.UserID, line.Username).
+				Update("disable_queued_at", &now)
+			db.Model(&models.ExpiryDisableRule{}).Where("id = ?", rule.ID).Update("last_run_at", &now)
+			log.Printf("Expiry rule of user ID %d queued task ID %d for line %s", rule.UserID, task.ID, line.LineID)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d line disablings could not be enqueued", failed)
+	}
+	return nil
+}
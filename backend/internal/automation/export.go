@@ -0,0 +1,126 @@
+package automation
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushEvery controls how many rows are written between flushes to the client
+const exportFlushEvery = 100
+
+// exportRow is the shape of a task in exports
+type exportRow struct {
+	ID            int             `json:"id"`
+	Name          string          `json:"name"`
+	TargetWebsite string          `json:"target_website"`
+	Status        string          `json:"status"`
+	Result        json.RawMessage `json:"result"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	CompletedAt   *time.Time      `json:"completed_at"`
+}
+
+// ExportTasks streams the current user's tasks as NDJSON or a JSON array without
+// loading the whole history into memory
+func ExportTasks(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "ndjson")
+	if format != "ndjson" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ndjson or json"})
+		return
+	}
+
+	db := database.GetReadDB()
+	rows, err := db.Raw(
+		"SELECT id, name, target_website, status, result, result_gzip, result_compressed, created_at, updated_at, completed_at FROM automation_tasks WHERE user_id = ? ORDER BY created_at, id",
+		u.ID,
+	).Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export tasks"})
+		return
+	}
+	defer rows.Close()
+
+	filename := "tasks-" + time.Now().Format("20060102-150405")
+	if format == "ndjson" {
+		c.Header("Content-Type", "application/x-ndjson")
+		filename += ".ndjson"
+	} else {
+		c.Header("Content-Type", "application/json")
+		filename += ".json"
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	w := bufio.NewWriter(c.Writer)
+	encoder := json.NewEncoder(w)
+
+	if format == "json" {
+		w.WriteString("[")
+	}
+
+	count := 0
+	for rows.Next() {
+		var row exportRow
+		var result sql.NullString
+		var resultGzip []byte
+		var compressed sql.NullBool
+		if err := rows.Scan(&row.ID, &row.Name, &row.TargetWebsite, &row.Status, &result, &resultGzip, &compressed,
+			&row.CreatedAt, &row.UpdatedAt, &row.CompletedAt); err != nil {
+			// Headers are already sent, so the export can only be cut short
+			log.Printf("Error scanning task during export for user ID %d: %v", u.ID, err)
+			break
+		}
+
+		if compressed.Bool {
+			if decompressed, err := models.DecompressResult(resultGzip); err == nil {
+				result = sql.NullString{String: string(decompressed), Valid: true}
+			}
+		}
+
+		row.Result = json.RawMessage("null")
+		if result.Valid && json.Valid([]byte(result.String)) {
+			row.Result = json.RawMessage(result.String)
+		}
+
+		if format == "json" && count > 0 {
+			w.WriteString(",")
+		}
+		// Encode appends a newline, which doubles as the NDJSON record separator
+		if err := encoder.Encode(row); err != nil {
+			log.Printf("Error writing task export for user ID %d: %v", u.ID, err)
+			return
+		}
+
+		count++
+		if count%exportFlushEvery == 0 {
+			w.Flush()
+			c.Writer.Flush()
+		}
+	}
+
+	if format == "json" {
+		w.WriteString("]")
+	}
+	w.Flush()
+	c.Writer.Flush()
+}
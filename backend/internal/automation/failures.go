@@ -0,0 +1,172 @@
+package automation
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultFailureSummaryDays = 7
+	maxFailureSummaryDays     = 90
+)
+
+// FailureGroup counts the failed tasks with one error code
+type FailureGroup struct {
+	Code         string    `json:"code"`
+	Count        int       `json:"count"`
+	LastFailedAt time.Time `json:"last_failed_at"`
+	// LastError is the message of the most recent failure, as shown to the user
+	LastError string `json:"last_error"`
+}
+
+// failureCode returns the error code of a stored failed task result. A
+// normalization entry matching the panel's text wins, so entries added later
+// apply to earlier failures too; results stored before codes were recorded
+// are classified by their panel error text.
+func failureCode(result models.JSON, entries []models.PanelErrorNormalization, provider string) (code, message string) {
+	var r struct {
+		Error     string `json:"error"`
+		ErrorCode string `json:"error_code"`
+		RawError  string `json:"raw_error"`
+	}
+	json.Unmarshal(result, &r)
+
+	raw := r.RawError
+	if raw == "" {
+		raw = r.Error
+	}
+	if code, ok := normalizePanelError(entries, provider, raw); ok {
+		return code, r.Error
+	}
+	if r.ErrorCode != "" {
+		return r.ErrorCode, r.Error
+	}
+	return classifyPanelError(0, raw), r.Error
+}
+
+// summarizeFailures groups the tasks that failed since the given time by error
+// code, most frequent first. A nil userID covers every user.
+func summarizeFailures(db *gorm.DB, userID *int, since time.Time) ([]FailureGroup, int, error) {
+	query := db.Model(&models.AutomationTask{}).
+		Select("id, target_website, result, result_gzip, result_compressed, updated_at, completed_at").
+		Where("status = ? AND updated_at >= ?", "failed", since)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	var tasks []models.AutomationTask
+	if err := query.Find(&tasks).Error; err != nil {
+		return nil, 0, err
+	}
+
+	entries := loadNormalizations(db)
+	groups := map[string]*FailureGroup{}
+	for _, task := range tasks {
+		code, message := failureCode(task.Result, entries, panelProvider(task.TargetWebsite))
+		failedAt := task.UpdatedAt
+		if task.CompletedAt != nil {
+			failedAt = *task.CompletedAt
+		}
+
+		g, ok := groups[code]
+		if !ok {
+			g = &FailureGroup{Code: code}
+			groups[code] = g
+		}
+		g.Count++
+		if failedAt.After(g.LastFailedAt) {
+			g.LastFailedAt = failedAt
+			g.LastError = message
+		}
+	}
+
+	summary := make([]FailureGroup, 0, len(groups))
+	for _, g := range groups {
+		summary = append(summary, *g)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Count != summary[j].Count {
+			return summary[i].Count > summary[j].Count
+		}
+		return summary[i].Code < summary[j].Code
+	})
+	return summary, len(tasks), nil
+}
+
+// parseFailureDays reads the days query parameter, responding on failure
+func parseFailureDays(c *gin.Context) (int, bool) {
+	days := defaultFailureSummaryDays
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxFailureSummaryDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 90"})
+			return 0, false
+		}
+		days = n
+	}
+	return days, true
+}
+
+// respondFailureSummary writes the failure summary for userID over the requested days
+func respondFailureSummary(c *gin.Context, userID *int) {
+	days, ok := parseFailureDays(c)
+	if !ok {
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	summary, total, err := summarizeFailures(database.GetReadDB(), userID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to retrieve tasks")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"since":  since,
+		"days":   days,
+		"total":  total,
+		"groups": summary,
+	})
+}
+
+// GetFailureSummary groups the current user's recent task failures by error code
+func GetFailureSummary(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	respondFailureSummary(c, &u.ID)
+}
+
+// GetAllFailureSummary groups recent task failures of every user, or of the
+// user given by user_id, by error code (admin only)
+func GetAllFailureSummary(c *gin.Context) {
+	var userID *int
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		userID = &id
+	}
+
+	respondFailureSummary(c, userID)
+}
@@ -1,8 +1,8 @@
 package automation
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -10,31 +10,53 @@ import (
 
 	"log"
 
+	"github.com/aliselcukkaya/account-editor/internal/audit"
 	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/middleware"
 	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/notify"
+	"github.com/aliselcukkaya/account-editor/internal/quota"
+	"github.com/aliselcukkaya/account-editor/internal/schemas"
+	"github.com/aliselcukkaya/account-editor/internal/slo"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// ErrCredentialsInvalid is returned for new tasks while the credential monitor
+// reports that the panel rejects the user's API key
+var ErrCredentialsInvalid = errors.New("panel credentials are invalid")
+
 type TaskRequest struct {
 	Name          string `json:"name" binding:"required"`
 	TargetWebsite string `json:"target_website" binding:"required"`
 	Username      string `json:"username,omitempty"`
 	Password      string `json:"password,omitempty"`
 	Package       int    `json:"package"`
+	// MaxConnections and Note apply to create_account
+	MaxConnections int    `json:"max_connections,omitempty"`
+	Note           string `json:"note,omitempty"`
+	// Tags label the task for notification filters
+	Tags []string `json:"tags,omitempty"`
+	// ExpiredBefore makes disable_account skip the line unless the panel still
+	// shows it expired before then; the expiry rule sets its grace cutoff
+	ExpiredBefore *time.Time `json:"expired_before,omitempty"`
 }
 
 type SettingsRequest struct {
 	WebsiteURL string `json:"website_url" binding:"required"`
 	APIKey     string `json:"api_key" binding:"required"`
 	AuthUser   string `json:"auth_user" binding:"required"`
+	// ExecutionWindow is optional; omitting it keeps the current window, "" removes it
+	ExecutionWindow *string `json:"execution_window"`
 }
 
 func CreateTask(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
 		return
 	}
 
@@ -60,44 +82,203 @@ func CreateTask(c *gin.Context) {
 		return
 	}
 
-	// Get settings from database
-	var settings models.UserSettings
+	applyPresets(u, &req)
+	if errs := validateTaskRequest(req); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  i18n.T(c, "Invalid task request"),
+			"errors": errs,
+		})
+		return
+	}
+
+	// Load the user's settings; nothing is stored until the panel is configured
 	db := database.GetDB()
-	if err := db.Where("user_id = ?", u.ID).First(&settings).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Settings not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	settings, ok := loadTaskSettings(c, db, u.ID)
+	if !ok {
+		return
+	}
+	task, heldUntil, err := enqueueTask(db, settings, req, middleware.SubAccountID(c))
+	if err == quota.ErrExceeded {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": i18n.T(c, "Daily task quota reached")})
+		return
+	}
+	if err == ErrCredentialsInvalid {
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "Your panel rejected the saved API key. Update your settings before creating tasks.")})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to create task")})
 		return
 	}
 
-	// Create API client
-	apiClient := NewAPIClient(settings.WebsiteURL, settings.APIKey, settings.AuthUser)
+	if task.Status == TaskStatusHeld {
+		c.Header("X-Held-Until", heldUntil.Format(time.RFC3339))
+	}
+	c.JSON(http.StatusCreated, task)
+}
+
+// validateTaskRequest checks a request against the published schema of its task type
+func validateTaskRequest(req TaskRequest) []string {
+	doc, err := schemas.Document(req)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return schemas.ValidateTaskRequest(doc)
+}
+
+// CodeSettingsMissing is the error code of task requests made before the panel
+// URL and API key are saved
+const CodeSettingsMissing = "SETTINGS_MISSING"
+
+// settingsPath is the endpoint that saves the panel settings
+const settingsPath = "/automation/settings"
+
+// missingSettings returns the panel settings tasks need that are not saved yet
+func missingSettings(settings models.UserSettings) []string {
+	missing := []string{}
+	if strings.TrimSpace(settings.WebsiteURL) == "" {
+		missing = append(missing, "website_url")
+	}
+	if strings.TrimSpace(settings.APIKey) == "" {
+		missing = append(missing, "api_key")
+	}
+	return missing
+}
+
+// loadTaskSettings returns the user's settings when tasks can run with them.
+// Otherwise it writes a 422 with the missing fields and where to save them.
+func loadTaskSettings(c *gin.Context, db *gorm.DB, userID int) (models.UserSettings, bool) {
+	var settings models.UserSettings
+	if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return settings, false
+	}
+
+	if missing := missingSettings(settings); len(missing) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":        i18n.T(c, "Save your panel URL and API key in the settings before creating tasks"),
+			"code":         CodeSettingsMissing,
+			"missing":      missing,
+			"settings_url": settingsPath,
+		})
+		return settings, false
+	}
+	return settings, true
+}
+
+// enqueueTask stores a task with its original request and starts it, or holds it
+// when the user's execution window is closed. createdBy is the sub-account
+// creating the task, if any. Returns when a held task will start.
+func enqueueTask(db *gorm.DB, settings models.UserSettings, req TaskRequest, createdBy *int) (models.AutomationTask, time.Time, error) {
+	user, open, opensAt, err := admitTask(db, settings)
+	if err != nil {
+		return models.AutomationTask{}, opensAt, err
+	}
+	status := "pending"
+	if !open {
+		status = TaskStatusHeld
+	}
+
+	// Keep the original request so the task can be requeued later
+	requestJSON, err := json.Marshal(req)
+	if err != nil {
+		return models.AutomationTask{}, opensAt, err
+	}
 
-	// Create task record
 	task := models.AutomationTask{
-		UserID:        u.ID,
+		UserID:        settings.UserID,
 		Name:          req.Name,
-		Status:        "pending",
+		Status:        status,
 		TargetWebsite: req.TargetWebsite,
+		Request:       json.RawMessage(requestJSON),
+		CreatedBy:     createdBy,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
 
 	if err := db.Create(&task).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
-		return
+		return task, opensAt, err
+	}
+	go notify.CheckQuota(user.ID)
+
+	if open {
+		apiClient := NewAPIClient(settings.WebsiteURL, settings.APIKey, settings.AuthUser)
+		go executeTask(task.ID, req, apiClient)
+	}
+	return task, opensAt, nil
+}
+
+// admitTask checks that the user may queue a task now: the credentials were not
+// rejected and the daily quota is not used up. It returns whether the user's
+// execution window is open, and when it opens otherwise.
+func admitTask(db *gorm.DB, settings models.UserSettings) (models.User, bool, time.Time, error) {
+	var user models.User
+	if settings.CredentialStatus == models.CredentialStatusInvalid {
+		return user, false, time.Time{}, ErrCredentialsInvalid
+	}
+	if err := db.First(&user, settings.UserID).Error; err != nil {
+		return user, false, time.Time{}, err
+	}
+	if err := quota.AllowTasks(db, user, 1); err != nil {
+		return user, false, time.Time{}, err
 	}
 
-	// Start task execution in background
-	go executeTask(task.ID, req, apiClient)
+	open, opensAt := executionWindowOpen(db, settings)
+	return user, open, opensAt, nil
+}
 
-	c.JSON(http.StatusCreated, task)
+// ErrTaskNotStuck is returned when a task to requeue finished or progressed
+// since it was found stuck
+var ErrTaskNotStuck = errors.New("task is no longer stuck")
+
+// requeueTask passes a stuck task through the same checks as a new task and
+// starts it again with its stored request, or holds it when the execution
+// window is closed. The task is claimed only if it is still pending or running
+// and was last updated before the cutoff, so a task that is being executed is
+// never started a second time.
+func requeueTask(db *gorm.DB, settings models.UserSettings, taskID int, req TaskRequest, cutoff time.Time) (string, time.Time, error) {
+	user, open, opensAt, err := admitTask(db, settings)
+	if err != nil {
+		return "", opensAt, err
+	}
+	status := "pending"
+	if !open {
+		status = TaskStatusHeld
+	}
+
+	claim := db.Model(&models.AutomationTask{}).
+		Where("id = ? AND status IN ? AND updated_at < ?", taskID, []string{"pending", "running"}, cutoff).
+		Updates(map[string]interface{}{"status": status, "result": nil, "completed_at": nil, "updated_at": time.Now()})
+	if claim.Error != nil {
+		return "", opensAt, claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return "", opensAt, ErrTaskNotStuck
+	}
+	go notify.CheckQuota(user.ID)
+
+	if open {
+		apiClient := NewAPIClient(settings.WebsiteURL, settings.APIKey, settings.AuthUser)
+		go executeTask(taskID, req, apiClient)
+	}
+	return status, opensAt, nil
+}
+
+// newClientForUser creates an API client from the user's stored settings
+func newClientForUser(db *gorm.DB, userID int) (*APIClient, error) {
+	var settings models.UserSettings
+	if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+		return nil, err
+	}
+
+	return NewAPIClient(settings.WebsiteURL, settings.APIKey, settings.AuthUser), nil
 }
 
 // executeTask executes the automation task
 func executeTask(taskID int, req TaskRequest, apiClient *APIClient) {
+	// Deferred first so it runs last, after a panicking task was marked failed
+	defer notifyTaskFinished(taskID)
+
 	// Recover from any panics
 	defer func() {
 		if r := recover(); r != nil {
@@ -138,6 +319,19 @@ func executeTask(taskID int, req TaskRequest, apiClient *APIClient) {
 		return
 	}
 
+	// Record the execution time per task type for the latency SLO report
+	start := time.Now()
+	defer func() { slo.RecordTask(task.Name, time.Since(start)) }()
+
+	// Once the legacy result is saved, compare it with a candidate run in shadow
+	defer func() { startShadow(db, task, req, apiClient) }()
+
+	// Mark the task as running so tasks orphaned mid-execution can be told apart
+	task.Status = "running"
+	if err := db.Model(&task).Update("status", task.Status).Error; err != nil {
+		log.Printf("Failed to mark task ID %d as running: %v", taskID, err)
+	}
+
 	// Generate RID
 	rid := uuid.New().String()
 
@@ -155,10 +349,12 @@ func executeTask(taskID int, req TaskRequest, apiClient *APIClient) {
 
 		// Prepare API request
 		apiReq := CreateAccountRequest{
-			Username: req.Username,
-			Password: req.Password,
-			Package:  req.Package,
-			RID:      rid,
+			Username:       req.Username,
+			Password:       req.Password,
+			Package:        req.Package,
+			MaxConnections: req.MaxConnections,
+			ResellerNotes:  req.Note,
+			RID:            rid,
 		}
 
 		// Execute API call (real or simulated)
@@ -176,11 +372,8 @@ func executeTask(taskID int, req TaskRequest, apiClient *APIClient) {
 			// Sanitize the error message in case it contains HTML
 			errorMessage := sanitizeErrorMessage(err.Error())
 
-			// Create error response using proper JSON marshaling
-			errorData := map[string]interface{}{
-				"success": false,
-				"error":   errorMessage,
-			}
+			// Explain known panel errors and create the error response using proper JSON marshaling
+			errorData := failureResult(db, errorMessage, panelErrorCode(err))
 			resultJSON, jsonErr := json.Marshal(errorData)
 			if jsonErr != nil {
 				log.Printf("Failed to marshal error data: %v", jsonErr)
@@ -228,6 +421,11 @@ func executeTask(taskID int, req TaskRequest, apiClient *APIClient) {
 			log.Printf("Failed to save task ID %d: %v", taskID, saveErr)
 		}
 
+		if task.Status == "completed" {
+			recordInventory(db, task, response.LineID, req.Username, req.Package, response.ExpireAt,
+				models.TransactionPurchase, response.TransactionAmount)
+		}
+
 	case "find_account":
 		var lines []Line
 		var err error
@@ -247,11 +445,8 @@ func executeTask(taskID int, req TaskRequest, apiClient *APIClient) {
 			// Sanitize the error message in case it contains HTML
 			errorMessage := sanitizeErrorMessage(err.Error())
 
-			// Create error response using proper JSON marshaling
-			errorData := map[string]interface{}{
-				"success": false,
-				"error":   errorMessage,
-			}
+			// Explain known panel errors and create the error response using proper JSON marshaling
+			errorData := failureResult(db, errorMessage, panelErrorCode(err))
 			resultJSON, jsonErr := json.Marshal(errorData)
 			if jsonErr != nil {
 				log.Printf("Failed to marshal error data: %v", jsonErr)
@@ -292,6 +487,12 @@ func executeTask(taskID int, req TaskRequest, apiClient *APIClient) {
 			log.Printf("Failed to save task ID %d: %v", taskID, saveErr)
 		}
 
+		if task.Status == "completed" {
+			for _, l := range lines {
+				recordLine(db, task, l.LineID, l.Username, l.PackageID, l.ExpireAt)
+			}
+		}
+
 	case "extend_package":
 		var lines []Line
 		var err error
@@ -312,11 +513,8 @@ func executeTask(taskID int, req TaskRequest, apiClient *APIClient) {
 			// Sanitize the error message in case it contains HTML
 			errorMessage := sanitizeErrorMessage(err.Error())
 
-			// Create error response using proper JSON marshaling
-			errorData := map[string]interface{}{
-				"success": false,
-				"error":   errorMessage,
-			}
+			// Explain known panel errors and create the error response using proper JSON marshaling
+			errorData := failureResult(db, errorMessage, panelErrorCode(err))
 			resultJSON, jsonErr := json.Marshal(errorData)
 			if jsonErr != nil {
 				log.Printf("Failed to marshal error data: %v", jsonErr)
@@ -339,11 +537,8 @@ func executeTask(taskID int, req TaskRequest, apiClient *APIClient) {
 			// Sanitize the error message in case it contains HTML
 			errorMessage := sanitizeErrorMessage("No accounts found with the provided username")
 
-			// Create error response using proper JSON marshaling
-			errorData := map[string]interface{}{
-				"success": false,
-				"error":   errorMessage,
-			}
+			// Explain known panel errors and create the error response using proper JSON marshaling
+			errorData := failureResult(db, errorMessage, PanelErrorNotFound)
 			resultJSON, _ := json.Marshal(errorData)
 			task.Result = models.JSON(resultJSON)
 			task.CompletedAt = &now
@@ -377,11 +572,8 @@ func executeTask(taskID int, req TaskRequest, apiClient *APIClient) {
 			// Sanitize the error message in case it contains HTML
 			errorMessage := sanitizeErrorMessage(err.Error())
 
-			// Create error response using proper JSON marshaling
-			errorData := map[string]interface{}{
-				"success": false,
-				"error":   errorMessage,
-			}
+			// Explain known panel errors and create the error response using proper JSON marshaling
+			errorData := failureResult(db, errorMessage, panelErrorCode(err))
 			resultJSON, jsonErr := json.Marshal(errorData)
 			if jsonErr != nil {
 				log.Printf("Failed to marshal error data: %v", jsonErr)
@@ -428,11 +620,158 @@ func executeTask(taskID int, req TaskRequest, apiClient *APIClient) {
 		if saveErr := db.Save(&task).Error; saveErr != nil {
 			log.Printf("Failed to save task ID %d: %v", taskID, saveErr)
 		}
+
+		if task.Status == "completed" {
+			recordInventory(db, task, response.LineID, line.Username, req.Package, response.ExpireAt,
+				models.TransactionRenewal, response.TransactionAmount)
+		}
+
+	case "disable_account":
+		var lines []Line
+		var err error
+		var response *DisableLineResponse
+
+		// Find the line to disable (real or simulated)
+		if isSimulation {
+			lines, err = apiClient.SimulateFindAccount(req.Username)
+		} else {
+			lines, err = apiClient.FindAccount(req.Username)
+		}
+		if err == nil && len(lines) == 0 {
+			err = newPanelError(http.StatusNotFound, "No accounts found with the provided username", "")
+		}
+
+		// A line renewed since the expiry rule queued the task keeps running
+		if err == nil && req.ExpiredBefore != nil && !lines[0].ExpireAt.Before(*req.ExpiredBefore) {
+			line := lines[0]
+			log.Printf("Task ID %d skipped disabling line %s, renewed until %s", taskID, line.LineID, line.ExpireAt.Format(time.RFC3339))
+			task.Status = "completed"
+			task.CompletedAt = &now
+
+			result := map[string]interface{}{
+				"success": true,
+				"data": map[string]interface{}{
+					"line_id":    line.LineID,
+					"username":   line.Username,
+					"expire_at":  line.ExpireAt,
+					"is_enabled": line.IsEnabled,
+					"skipped":    true,
+					"reason":     "The line was renewed after the expiry rule queued the task",
+				},
+			}
+			resultJSON, _ := json.Marshal(result)
+			task.Result = models.JSON(resultJSON)
+			if saveErr := db.Save(&task).Error; saveErr != nil {
+				log.Printf("Failed to save task ID %d: %v", taskID, saveErr)
+			}
+
+			recordLine(db, task, line.LineID, line.Username, 0, line.ExpireAt)
+			return
+		}
+
+		if err == nil {
+			if isSimulation {
+				response, err = apiClient.SimulateDisableLine(lines[0].LineID, DisableLineRequest{RID: rid})
+			} else {
+				response, err = apiClient.DisableLine(lines[0].LineID, DisableLineRequest{RID: rid})
+			}
+		}
+
+		if err != nil {
+			log.Printf("Task ID %d failed to disable account: %v", taskID, err)
+			task.Status = "failed"
+
+			// Explain known panel errors and create the error response using proper JSON marshaling
+			errorData := failureResult(db, sanitizeErrorMessage(err.Error()), panelErrorCode(err))
+			resultJSON, jsonErr := json.Marshal(errorData)
+			if jsonErr != nil {
+				log.Printf("Failed to marshal error data: %v", jsonErr)
+				resultJSON = []byte(`{"success":false,"error":"Failed to serialize error message"}`)
+			}
+
+			task.Result = models.JSON(resultJSON)
+			task.CompletedAt = &now
+			if saveErr := db.Save(&task).Error; saveErr != nil {
+				log.Printf("Failed to save task ID %d: %v", taskID, saveErr)
+			}
+			return
+		}
+
+		line := lines[0]
+		task.Status = "completed"
+		task.CompletedAt = &now
+
+		result := map[string]interface{}{
+			"success": true,
+			"data": map[string]interface{}{
+				"line_id":    response.LineID,
+				"username":   line.Username,
+				"expire_at":  line.ExpireAt,
+				"is_enabled": response.IsEnabled,
+				"rid":        response.RID,
+			},
+		}
+		resultJSON, _ := json.Marshal(result)
+		task.Result = models.JSON(resultJSON)
+		if saveErr := db.Save(&task).Error; saveErr != nil {
+			log.Printf("Failed to save task ID %d: %v", taskID, saveErr)
+		}
+
+		recordLine(db, task, response.LineID, line.Username, 0, line.ExpireAt)
+		db.Model(&models.Line{}).Where("user_id = ? AND line_id = ?", task.UserID, response.LineID).
+			Update("disabled_at", &now)
 	}
 
 	log.Printf("Task ID %d execution completed successfully", taskID)
 }
 
+// notifyTaskFinished notifies the owner of a finished task if they enabled
+// task_completed or task_failed in their notification defaults
+func notifyTaskFinished(taskID int) {
+	db := database.GetDB()
+
+	var task models.AutomationTask
+	if err := db.Preload("User").First(&task, taskID).Error; err != nil {
+		log.Printf("Failed to load task ID %d for notification: %v", taskID, err)
+		return
+	}
+
+	prefs := task.User.NotificationDefaults
+	data := map[string]interface{}{"task_id": task.ID, "name": task.Name, "status": task.Status}
+	if task.BatchID != nil {
+		data["batch_id"] = *task.BatchID
+	}
+	var req TaskRequest
+	if json.Unmarshal(task.Request, &req) == nil && len(req.Tags) > 0 {
+		data["tags"] = req.Tags
+	}
+
+	switch {
+	case task.Status == "completed" && prefs.TaskCompleted:
+		notify.Notify(task.UserID, notify.Event{
+			Kind:  notify.KindTaskCompleted,
+			Title: func(locale string) string { return i18n.Translate(locale, "Task completed") },
+			Body:  func(locale string) string { return i18n.Translate(locale, "Task %s #%d completed", task.Name, task.ID) },
+			Data:  data,
+		})
+	case task.Status == "failed" && prefs.TaskFailed:
+		var result struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(task.Result, &result)
+		data["error"] = result.Error
+
+		notify.Notify(task.UserID, notify.Event{
+			Kind:  notify.KindTaskFailed,
+			Title: func(locale string) string { return i18n.Translate(locale, "Task failed") },
+			Body: func(locale string) string {
+				return i18n.Translate(locale, "Task %s #%d failed: %s", task.Name, task.ID, result.Error)
+			},
+			Data: data,
+		})
+	}
+}
+
 // GetUserTasks returns all tasks for the current user
 func GetUserTasks(c *gin.Context) {
 	log.Printf("GetUserTasks called")
@@ -440,7 +779,7 @@ func GetUserTasks(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
 		log.Printf("User not found in context")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
 		return
 	}
 
@@ -453,17 +792,77 @@ func GetUserTasks(c *gin.Context) {
 
 	log.Printf("Fetching tasks for user ID: %d", u.ID)
 
-	var tasks []models.AutomationTask
+	tasks := []models.AutomationTask{}
 	db := database.GetDB()
 
-	if err := db.Where("user_id = ?", u.ID).Find(&tasks).Error; err != nil {
+	// Optional filters, served by the (user_id, status, created_at) and (user_id, created_at) indexes
+	query := db.Where("user_id = ?", u.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name = ?", name)
+	}
+	// created_by filters by the sub-account that created the task; "me" is the user's own tasks
+	if createdBy := c.Query("created_by"); createdBy == "me" {
+		query = query.Where("created_by IS NULL")
+	} else if createdBy != "" {
+		query = query.Where("created_by = ?", createdBy)
+	}
+	if since := c.Query("created_after"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "created_after must be an RFC3339 timestamp"})
+			return
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if until := c.Query("created_before"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "created_before must be an RFC3339 timestamp"})
+			return
+		}
+		query = query.Where("created_at < ?", t)
+	}
+
+	page, err := utils.ParsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Model(&models.AutomationTask{}).Count(&total).Error; err != nil {
+		log.Printf("Database error when counting tasks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to retrieve tasks")})
+		return
+	}
+
+	if page != nil {
+		query = page.Apply(query)
+	} else {
+		query = query.Order("created_at DESC")
+	}
+
+	if err := query.Find(&tasks).Error; err != nil {
 		log.Printf("Database error when fetching tasks: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tasks"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to retrieve tasks")})
 		return
 	}
 
+	next := ""
+	if page != nil {
+		var n int
+		n, next = page.NextCursor(len(tasks), func(i int) utils.Cursor {
+			return utils.Cursor{CreatedAt: tasks[i].CreatedAt, ID: tasks[i].ID}
+		})
+		tasks = tasks[:n]
+		c.Header("X-Next-Cursor", next)
+	}
+
 	log.Printf("Found %d tasks for user ID %d", len(tasks), u.ID)
-	c.JSON(http.StatusOK, tasks)
+	utils.RespondList(c, tasks, total, next)
 }
 
 // GetTask returns a specific task
@@ -472,7 +871,7 @@ func GetTask(c *gin.Context) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("PANIC in GetTask: %v", r)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Internal server error")})
 		}
 	}()
 
@@ -481,14 +880,14 @@ func GetTask(c *gin.Context) {
 
 	if id == "" {
 		log.Printf("Empty task ID provided")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Task ID is required"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Task ID is required")})
 		return
 	}
 
 	user, exists := c.Get("user")
 	if !exists {
 		log.Printf("User not found in context")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
 		return
 	}
 
@@ -537,7 +936,7 @@ func GetTask(c *gin.Context) {
 
 	if !rows.Next() {
 		log.Printf("Task ID %s not found for user ID %d", id, u.ID)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Task not found")})
 		return
 	}
 
@@ -561,21 +960,23 @@ func GetTask(c *gin.Context) {
 		return
 	}
 
-	// Get the result field separately and handle any errors
-	var resultString sql.NullString
-	resultErr := db.Raw("SELECT result FROM automation_tasks WHERE id = ? AND user_id = ?", id, u.ID).Scan(&resultString).Error
+	// Get the result fields separately and handle any errors; loading them
+	// through the model decompresses a gzipped result
+	var stored models.AutomationTask
+	resultErr := db.Select("id", "result", "result_gzip", "result_compressed").
+		Where("id = ? AND user_id = ?", id, u.ID).First(&stored).Error
 
 	if resultErr != nil {
 		log.Printf("Error fetching result field: %v", resultErr)
 		// Set a default value to avoid null result
 		task.Result = models.JSON([]byte(`{"success":false,"error":"Unable to read result data"}`))
-	} else if resultString.Valid {
+	} else if len(stored.Result) > 0 {
 		// Check if the JSON is valid
 		var js json.RawMessage
-		if json.Unmarshal([]byte(resultString.String), &js) == nil {
+		if json.Unmarshal(stored.Result, &js) == nil {
 			task.Result = models.JSON(js)
 		} else {
-			log.Printf("Invalid JSON in result field: %s", resultString.String)
+			log.Printf("Invalid JSON in result field: %s", stored.Result)
 			// Use a valid JSON if the stored JSON is invalid
 			task.Result = models.JSON([]byte(`{"success":false,"error":"Invalid result data format"}`))
 		}
@@ -632,6 +1033,15 @@ func UpdateSettings(c *gin.Context) {
 		return
 	}
 
+	if req.ExecutionWindow != nil && *req.ExecutionWindow != "" {
+		window, err := utils.ParseTimeWindow(*req.ExecutionWindow)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "execution_window: " + err.Error()})
+			return
+		}
+		*req.ExecutionWindow = window.String()
+	}
+
 	user, _ := c.Get("user")
 	u := user.(models.User)
 
@@ -641,6 +1051,18 @@ func UpdateSettings(c *gin.Context) {
 	var settings models.UserSettings
 	result := db.Where("user_id = ?", u.ID).First(&settings)
 
+	// Configuring a panel for the first time adds a profile to the plan's count
+	if req.WebsiteURL != "" && (result.Error != nil || settings.WebsiteURL == "") {
+		if err := quota.AllowPanelProfile(db, u); err != nil {
+			if err == quota.ErrExceeded {
+				c.JSON(http.StatusForbidden, gin.H{"error": i18n.T(c, "Your plan does not allow more panel profiles")})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+			return
+		}
+	}
+
 	if result.Error != nil {
 		// Create new settings
 		settings = models.UserSettings{
@@ -649,23 +1071,82 @@ func UpdateSettings(c *gin.Context) {
 			APIKey:     req.APIKey,
 			AuthUser:   req.AuthUser,
 		}
+		if req.ExecutionWindow != nil {
+			settings.ExecutionWindow = *req.ExecutionWindow
+		}
 		if err := db.Create(&settings).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create settings"})
 			return
 		}
 	} else {
+		// A different panel or credentials need a new connection test
+		if settings.WebsiteURL != req.WebsiteURL || settings.APIKey != req.APIKey || settings.AuthUser != req.AuthUser {
+			resetConnectionStatus(&settings)
+		}
+
 		// Update existing settings
 		settings.WebsiteURL = req.WebsiteURL
 		settings.APIKey = req.APIKey
 		settings.AuthUser = req.AuthUser
+		if req.ExecutionWindow != nil {
+			settings.ExecutionWindow = *req.ExecutionWindow
+		}
 
 		if err := db.Save(&settings).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update settings")})
 			return
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Settings updated successfully"})
+	audit.Record(c, audit.ActionSettingsSaved, "settings", settings.ID, map[string]interface{}{
+		"website_url": settings.WebsiteURL,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Settings updated successfully")})
+}
+
+// TestConnection checks that the user's panel is reachable with the saved settings
+func TestConnection(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	db := database.GetDB()
+	apiClient, err := newClientForUser(db, u.ID)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Settings not found")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	latency, err := apiClient.Ping()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"ok":    false,
+			"error": sanitizeErrorMessage(err.Error()),
+		})
+		return
+	}
+
+	now := time.Now()
+	db.Model(&models.UserSettings{}).Where("user_id = ?", u.ID).Update("connection_verified_at", &now)
+
+	c.JSON(http.StatusOK, gin.H{
+		"ok":         true,
+		"latency_ms": latency.Milliseconds(),
+		"simulation": apiClient.IsSimulationMode(),
+	})
 }
 
 // GetSettings returns the user's automation settings
@@ -682,6 +1163,8 @@ func GetSettings(c *gin.Context) {
 			"website_url": "",
 			"api_key":     "",
 			"auth_user":   "",
+
+			"execution_window": "",
 		}) // Return empty object if no settings found
 		return
 	}
@@ -692,17 +1175,80 @@ func GetSettings(c *gin.Context) {
 		"api_key":     settings.APIKey,
 		"auth_user":   settings.AuthUser,
 		"created_at":  settings.CreatedAt,
-		"updated_at":  settings.UpdatedAt,
+
+		"execution_window":      settings.ExecutionWindow,
+		"credential_status":     settings.CredentialStatus,
+		"credential_error":      settings.CredentialError,
+		"credential_checked_at": settings.CredentialCheckedAt,
+		"webhook_secret_set":    settings.WebhookSecret != "",
+		"updated_at":            settings.UpdatedAt,
 	})
 }
 
 // SetupRoutes configures the automation routes
 func SetupRoutes(router *gin.RouterGroup) {
 	router.POST("/tasks", CreateTask)
+	router.POST("/tasks/validate", ValidateTask)
+	router.POST("/tasks/bulk", CreateBulkTasks)
+	router.POST("/imports", CreateImport)
+	router.GET("/imports", GetImports)
+	router.GET("/imports/:id", GetImport)
+	router.GET("/imports/:id/errors.csv", GetImportErrors)
+	router.POST("/imports/:id/commit", CommitImport)
+	router.GET("/batches/:id", GetBatch)
 	router.GET("/tasks", GetUserTasks)
 	router.GET("/tasks/:id", GetTask)
+	router.GET("/tasks/:id/receipt", GetTaskReceipt)
+	router.GET("/tasks/:id/credential-card", GetTaskCredentialCard)
+	router.GET("/tasks/export", ExportTasks)
+	router.GET("/tasks/failures/summary", GetFailureSummary)
+	router.GET("/stats/heatmap", GetTaskHeatmap)
+	router.GET("/tasks/archive", GetArchivedTasks)
+	router.GET("/tasks/archive/:id", GetArchivedTask)
 	router.PUT("/settings", UpdateSettings)
 	router.GET("/settings", GetSettings)
+	router.GET("/presets", GetPresets)
+	router.PUT("/presets", UpdatePresets)
+	router.POST("/settings/test", TestConnection)
+	router.POST("/settings/webhook-secret", RotateWebhookSecret)
+	router.DELETE("/settings/webhook-secret", DeleteWebhookSecret)
+	router.GET("/lines", GetLines)
+	router.GET("/transactions", GetTransactions)
+	router.GET("/credits", GetCredits)
+	router.POST("/credits/topup", TopUpCredits)
+	router.GET("/renewals/rules", GetRenewalRules)
+	router.POST("/renewals/rules", CreateRenewalRule)
+	router.PUT("/renewals/rules/:id", UpdateRenewalRule)
+	router.DELETE("/renewals/rules/:id", DeleteRenewalRule)
+	router.GET("/expiry-disable", GetExpiryDisableRule)
+	router.PUT("/expiry-disable", UpdateExpiryDisableRule)
+	router.GET("/expiry-disable/review", GetExpiryReview)
+	router.GET("/expiry-disable/exclusions", GetExpiryExclusions)
+	router.POST("/expiry-disable/exclusions", CreateExpiryExclusion)
+	router.DELETE("/expiry-disable/exclusions/:id", DeleteExpiryExclusion)
+	router.GET("/renewals/plan", GetRenewalPlan)
+}
+
+// SetupAdminRoutes configures the automation routes for admins
+func SetupAdminRoutes(router *gin.RouterGroup) {
+	router.GET("/tasks/stuck", GetStuckTasks)
+	router.GET("/tasks/failures/summary", GetAllFailureSummary)
+	router.POST("/tasks/:id/force-fail", ForceFailTask)
+	router.POST("/tasks/:id/requeue", RequeueTask)
+	router.POST("/demo-users", ProvisionDemoUser)
+	router.POST("/settings/rollout", RolloutSettings)
+	router.GET("/panel-errors", GetPanelErrors)
+	router.POST("/panel-errors", CreatePanelError)
+	router.PUT("/panel-errors/:id", UpdatePanelError)
+	router.DELETE("/panel-errors/:id", DeletePanelError)
+	router.POST("/panel-errors/test", TestPanelError)
+	router.GET("/panel-errors/normalizations", GetNormalizations)
+	router.POST("/panel-errors/normalizations", CreateNormalization)
+	router.PUT("/panel-errors/normalizations/:id", UpdateNormalization)
+	router.DELETE("/panel-errors/normalizations/:id", DeleteNormalization)
+	setupBenchRoutes(router)
+	setupShadowRoutes(router)
+	setupPanelWebhookRoutes(router)
 }
 
 // Helper function to check if a string contains HTML
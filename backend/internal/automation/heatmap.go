@@ -0,0 +1,90 @@
+package automation
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/gin-gonic/gin"
+)
+
+// heatmapWeekdays names the rows of the heatmap, starting on Monday
+var heatmapWeekdays = [7]string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+// HeatmapPeak is the busiest weekday and hour of the heatmap
+type HeatmapPeak struct {
+	Weekday string `json:"weekday"`
+	Hour    int    `json:"hour"`
+	Count   int    `json:"count"`
+}
+
+// GetTaskHeatmap counts the current user's tasks of the last days by the
+// weekday and hour they were created, in the profile timezone, so peak demand
+// times can be avoided when scheduling bulk jobs (filters: name, status)
+func GetTaskHeatmap(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	days, ok := parseFailureDays(c)
+	if !ok {
+		return
+	}
+
+	loc := time.UTC
+	if u.Timezone != "" {
+		if l, err := time.LoadLocation(u.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	query := database.GetReadDB().Table("automation_tasks").
+		Where("user_id = ? AND created_at >= ?", u.ID, since)
+	if name := c.Query("name"); name != "" {
+		query = query.Where("name = ?", name)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var created []time.Time
+	if err := query.Pluck("created_at", &created).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to retrieve tasks")})
+		return
+	}
+
+	// Rows are weekdays from Monday, columns hours of the day
+	var counts [7][24]int
+	for _, t := range created {
+		t = t.In(loc)
+		counts[(int(t.Weekday())+6)%7][t.Hour()]++
+	}
+
+	var peak *HeatmapPeak
+	rows := make([]gin.H, 0, len(counts))
+	for day, hours := range counts {
+		total := 0
+		for hour, count := range hours {
+			total += count
+			if count > 0 && (peak == nil || count > peak.Count) {
+				peak = &HeatmapPeak{Weekday: heatmapWeekdays[day], Hour: hour, Count: count}
+			}
+		}
+		rows = append(rows, gin.H{
+			"weekday": heatmapWeekdays[day],
+			"hours":   hours,
+			"total":   total,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"since":    since,
+		"days":     days,
+		"timezone": loc.String(),
+		"total":    len(created),
+		"weekdays": rows,
+		"peak":     peak,
+	})
+}
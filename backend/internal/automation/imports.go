@@ -0,0 +1,680 @@
+package automation
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// importTTL is how long an uploaded spreadsheet waits to be committed
+	importTTL = 24 * time.Hour
+	// importRowsRetention is how long the rows of a committed import are kept
+	// for downloading the rows that failed
+	importRowsRetention = 7 * 24 * time.Hour
+	// importPurgeInterval is how often expired imports and rows are deleted
+	importPurgeInterval = time.Hour
+	// maxImportBytes limits the size of an uploaded spreadsheet
+	maxImportBytes = 10 << 20
+	// importSamples is the number of sample values shown per column
+	importSamples = 3
+)
+
+// Import statuses
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusCommitted = "committed"
+)
+
+// columnAliases are the headers, besides the field name itself, that are
+// recognized as a task field when a spreadsheet is uploaded
+var columnAliases = map[string][]string{
+	"name":            {"task", "type", "action", "task_type", "task_name"},
+	"target_website":  {"website", "url", "panel", "panel_url", "website_url"},
+	"username":        {"user", "login", "user_name", "customer", "account"},
+	"password":        {"pass", "pwd", "passwd"},
+	"package":         {"pkg", "package_id", "plan", "bouquet"},
+	"max_connections": {"connections", "max_conn", "conns", "devices"},
+	"note":            {"notes", "comment", "comments", "reseller_notes", "description"},
+}
+
+// ImportColumn describes a column of an uploaded spreadsheet
+type ImportColumn struct {
+	Index   int      `json:"index"`
+	Header  string   `json:"header"`
+	Samples []string `json:"samples"`
+	// Field is the task field the column was recognized as, if any
+	Field string `json:"field,omitempty"`
+}
+
+// ImportCommitRequest maps the columns of an import to task fields
+type ImportCommitRequest struct {
+	// Mapping maps task fields to column headers; empty uses the detected mapping
+	Mapping map[string]string `json:"mapping"`
+	// Name is the task type of rows without one, e.g. when no column is mapped to name
+	Name string `json:"name"`
+}
+
+// normalizeHeader lowercases a header and joins its words with underscores
+func normalizeHeader(header string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(header), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_' || r == '.'
+	}), "_")
+}
+
+// detectField returns the task field a header is recognized as, or ""
+func detectField(header string) string {
+	h := normalizeHeader(header)
+	for _, field := range csvColumns {
+		if h == field {
+			return field
+		}
+		for _, alias := range columnAliases[field] {
+			if h == alias {
+				return field
+			}
+		}
+	}
+	return ""
+}
+
+// detectDelimiter picks the most frequent of comma, semicolon and tab in the
+// first line, as spreadsheets export with any of them depending on the locale
+func detectDelimiter(data []byte) rune {
+	line := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line = data[:i]
+	}
+
+	delimiter, best := ',', bytes.Count(line, []byte{','})
+	for _, d := range []rune{';', '\t'} {
+		if n := bytes.Count(line, []byte(string(d))); n > best {
+			delimiter, best = d, n
+		}
+	}
+	return delimiter
+}
+
+// readImport parses an uploaded spreadsheet into its header and non-empty rows
+func readImport(data []byte) ([]string, [][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = detectDelimiter(data)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		rows = append(rows, record)
+	}
+	return header, rows, nil
+}
+
+// importColumns describes the columns of an import and the mapping detected
+// from their headers; the first column recognized as a field wins
+func importColumns(imp models.TaskImport) ([]ImportColumn, map[string]string) {
+	columns := make([]ImportColumn, len(imp.Header))
+	mapping := map[string]string{}
+	for i, header := range imp.Header {
+		col := ImportColumn{Index: i, Header: header, Samples: []string{}}
+		for _, row := range imp.Rows {
+			if len(col.Samples) == importSamples {
+				break
+			}
+			if i < len(row) && strings.TrimSpace(row[i]) != "" {
+				col.Samples = append(col.Samples, strings.TrimSpace(row[i]))
+			}
+		}
+		if field := detectField(header); field != "" {
+			if _, taken := mapping[field]; !taken {
+				col.Field = field
+				mapping[field] = header
+			}
+		}
+		columns[i] = col
+	}
+	return columns, mapping
+}
+
+func importResponse(imp models.TaskImport) gin.H {
+	columns, mapping := importColumns(imp)
+	return gin.H{
+		"id":             imp.ID,
+		"filename":       imp.Filename,
+		"status":         imp.Status,
+		"rows":           imp.Total,
+		"columns":        columns,
+		"mapping":        mapping,
+		"fields":         csvColumns,
+		"batch_id":       imp.BatchID,
+		"rejected":       imp.Rejected,
+		"expires_at":     imp.ExpiresAt,
+		"created_at":     imp.CreatedAt,
+		"committed_at":   imp.CommittedAt,
+		"rows_purged_at": imp.RowsPurgedAt,
+	}
+}
+
+// findImport loads one of the current user's imports by the :id parameter
+func findImport(c *gin.Context, db *gorm.DB, u models.User) (models.TaskImport, bool) {
+	var imp models.TaskImport
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), u.ID).First(&imp).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Import not found")})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		}
+		return imp, false
+	}
+	return imp, true
+}
+
+// CreateImport stores an uploaded spreadsheet (file field) and returns its
+// columns with sample values and the mapping detected from the headers
+func CreateImport(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file is required in the file field"})
+		return
+	}
+	if file.Size > maxImportBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": i18n.T(c, "The file may be at most %d MB", maxImportBytes>>20)})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxImportBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+
+	header, rows, err := readImport(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "The file is not a readable CSV file")})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "No tasks provided")})
+		return
+	}
+	if len(rows) > maxBulkTasks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "A batch may contain at most %d tasks", maxBulkTasks)})
+		return
+	}
+
+	db := database.GetDB()
+	// Imports that were never committed are dropped once they expire
+	db.Where("user_id = ? AND status = ? AND expires_at < ?", u.ID, ImportStatusPending, time.Now()).Delete(&models.TaskImport{})
+
+	imp := models.TaskImport{
+		UserID:    u.ID,
+		Filename:  file.Filename,
+		Header:    header,
+		Rows:      rows,
+		Total:     len(rows),
+		Status:    ImportStatusPending,
+		ExpiresAt: time.Now().Add(importTTL),
+	}
+	if err := db.Create(&imp).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusCreated, importResponse(imp))
+}
+
+// GetImport returns the columns and detected mapping of one of the current user's imports
+func GetImport(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	imp, ok := findImport(c, db, u)
+	if !ok {
+		return
+	}
+
+	tasks, err := importTasks(db, u.ID, imp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	rows := make([]gin.H, 0, len(imp.Outcomes))
+	for _, outcome := range imp.Outcomes {
+		status, reason := rowStatus(outcome, tasks)
+		rows = append(rows, gin.H{
+			"row":     outcome.Row,
+			"status":  status,
+			"task_id": outcome.TaskID,
+			"error":   reason,
+		})
+	}
+
+	resp := importResponse(imp)
+	resp["summary"] = importSummary(imp, tasks)
+	resp["outcomes"] = rows
+	c.JSON(http.StatusOK, resp)
+}
+
+// CommitImport turns the rows of an import into tasks with the given column
+// mapping and runs them as a batch, like a CSV upload to the bulk endpoint.
+// Rows that are not valid tasks are rejected and recorded with the reason.
+func CommitImport(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req ImportCommitRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	imp, ok := findImport(c, db, u)
+	if !ok {
+		return
+	}
+	switch {
+	case imp.Status == ImportStatusCommitted:
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "This import was already committed"), "batch_id": imp.BatchID})
+		return
+	case imp.ExpiresAt.Before(time.Now()):
+		c.JSON(http.StatusGone, gin.H{"error": i18n.T(c, "This import has expired; upload the file again")})
+		return
+	}
+
+	mapping := req.Mapping
+	if len(mapping) == 0 {
+		_, mapping = importColumns(imp)
+	}
+
+	headers := make(map[string]int, len(imp.Header))
+	for i := len(imp.Header) - 1; i >= 0; i-- {
+		headers[imp.Header[i]] = i
+	}
+	columns := make(map[string]int, len(mapping))
+	for field, header := range mapping {
+		if !slices.Contains(csvColumns, field) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Unknown task field: %s", field)})
+			return
+		}
+		i, ok := headers[header]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "The file has no column named %s", header)})
+			return
+		}
+		columns[field] = i
+	}
+	if _, ok := columns["name"]; !ok && req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Map a column to name or set the name of every task")})
+		return
+	}
+
+	settings, ok := loadTaskSettings(c, db, u.ID)
+	if !ok {
+		return
+	}
+
+	// Rows that cannot become a valid task are rejected; the others form the batch
+	outcomes := make([]models.ImportRowOutcome, len(imp.Rows))
+	parsed := make([]TaskRequest, len(imp.Rows))
+	for i, row := range imp.Rows {
+		outcomes[i].Row = i + 1
+		task, err := csvTask(row, columns, i+2)
+		if err != nil {
+			outcomes[i].Error = err.Error()
+			continue
+		}
+		if task.Name == "" {
+			task.Name = req.Name
+		}
+		parsed[i] = task
+	}
+	for _, rowErr := range validateBulkTasks(parsed, u, settings.WebsiteURL) {
+		if outcomes[rowErr.Row-1].Error == "" {
+			outcomes[rowErr.Row-1].Error = rowErr.Error
+		}
+	}
+
+	var requests []TaskRequest
+	var accepted []int
+	var rowErrors []BulkRowError
+	for i, outcome := range outcomes {
+		if outcome.Error != "" {
+			rowErrors = append(rowErrors, BulkRowError{Row: outcome.Row, Error: outcome.Error})
+			continue
+		}
+		requests = append(requests, parsed[i])
+		accepted = append(accepted, i)
+	}
+	if len(requests) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  i18n.T(c, "Some rows are invalid"),
+			"errors": rowErrors,
+		})
+		return
+	}
+
+	// Claim the import so a repeated commit cannot create the batch twice
+	claim := db.Model(&models.TaskImport{}).Where("id = ? AND status = ?", imp.ID, ImportStatusPending).
+		Update("status", ImportStatusCommitted)
+	if claim.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if claim.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "This import was already committed")})
+		return
+	}
+
+	batch, ok := createBatch(c, u, requests, "import", gin.H{
+		"import_id": imp.ID,
+		"rejected":  len(rowErrors),
+	})
+	if !ok {
+		db.Model(&models.TaskImport{}).Where("id = ?", imp.ID).Update("status", ImportStatusPending)
+		return
+	}
+
+	// Tasks are inserted in row order, so their IDs line up with the accepted rows
+	var taskIDs []int
+	if err := db.Model(&models.AutomationTask{}).Where("batch_id = ?", batch.ID).Order("id").
+		Pluck("id", &taskIDs).Error; err != nil {
+		log.Printf("Failed to load tasks of import ID %d: %v", imp.ID, err)
+	}
+	for j, i := range accepted {
+		if j < len(taskIDs) {
+			outcomes[i].TaskID = &taskIDs[j]
+		}
+	}
+
+	now := time.Now()
+	imp.BatchID = &batch.ID
+	imp.Outcomes = outcomes
+	imp.Rejected = len(rowErrors)
+	imp.CommittedAt = &now
+	if err := db.Model(&imp).Select("batch_id", "outcomes", "rejected", "committed_at").Updates(&imp).Error; err != nil {
+		log.Printf("Failed to save row outcomes of import ID %d: %v", imp.ID, err)
+	}
+}
+
+// importTask is the status of a task created by an import and, if it failed, why
+type importTask struct {
+	Status string
+	Error  string
+}
+
+// importTasks loads the tasks created by imports, archived ones included
+func importTasks(db *gorm.DB, userID int, imports ...models.TaskImport) (map[int]importTask, error) {
+	var ids []int
+	for _, imp := range imports {
+		for _, outcome := range imp.Outcomes {
+			if outcome.TaskID != nil {
+				ids = append(ids, *outcome.TaskID)
+			}
+		}
+	}
+
+	tasks := make(map[int]importTask, len(ids))
+	for start := 0; start < len(ids); start += maxBulkTasks {
+		chunk := ids[start:min(start+maxBulkTasks, len(ids))]
+
+		var live []models.AutomationTask
+		if err := db.Select("id, status, result, result_gzip, result_compressed").
+			Where("user_id = ? AND id IN ?", userID, chunk).Find(&live).Error; err != nil {
+			return nil, err
+		}
+		for _, task := range live {
+			tasks[task.ID] = taskOutcome(task.Status, task.Result)
+		}
+
+		var archived []models.AutomationTaskArchive
+		if err := db.Select("id, status, result, result_gzip, result_compressed").
+			Where("user_id = ? AND id IN ?", userID, chunk).Find(&archived).Error; err != nil {
+			return nil, err
+		}
+		for _, task := range archived {
+			tasks[task.ID] = taskOutcome(task.Status, models.JSON(task.Result))
+		}
+	}
+	return tasks, nil
+}
+
+func taskOutcome(status string, result models.JSON) importTask {
+	outcome := importTask{Status: status}
+	if status == "failed" {
+		_, outcome.Error = failureCode(result, nil, "")
+	}
+	return outcome
+}
+
+// rowStatus returns the status of an import row: rejected, or the status of its task
+func rowStatus(outcome models.ImportRowOutcome, tasks map[int]importTask) (status, reason string) {
+	if outcome.TaskID == nil {
+		return "rejected", outcome.Error
+	}
+	task, ok := tasks[*outcome.TaskID]
+	if !ok {
+		return "deleted", ""
+	}
+	return task.Status, task.Error
+}
+
+// importSummary counts the rows of an import by status
+func importSummary(imp models.TaskImport, tasks map[int]importTask) map[string]int {
+	summary := map[string]int{}
+	for _, outcome := range imp.Outcomes {
+		status, _ := rowStatus(outcome, tasks)
+		summary[status]++
+	}
+	return summary
+}
+
+// GetImports lists the current user's imports, newest first, with their rows
+// counted by outcome
+func GetImports(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	page, err := utils.ParsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page == nil {
+		page = &utils.Page{Limit: utils.DefaultPageSize}
+	}
+
+	db := database.GetDB()
+	query := db.Model(&models.TaskImport{}).Where("user_id = ?", u.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	// The rows themselves are only needed for a single import
+	var imports []models.TaskImport
+	if err := page.Apply(query.Omit("records")).Find(&imports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	n, next := page.NextCursor(len(imports), func(i int) utils.Cursor {
+		return utils.Cursor{CreatedAt: imports[i].CreatedAt, ID: imports[i].ID}
+	})
+	imports = imports[:n]
+	c.Header("X-Next-Cursor", next)
+
+	tasks, err := importTasks(db, u.ID, imports...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	items := make([]gin.H, 0, len(imports))
+	for _, imp := range imports {
+		items = append(items, gin.H{
+			"id":             imp.ID,
+			"filename":       imp.Filename,
+			"status":         imp.Status,
+			"batch_id":       imp.BatchID,
+			"rows":           imp.Total,
+			"rejected":       imp.Rejected,
+			"summary":        importSummary(imp, tasks),
+			"created_at":     imp.CreatedAt,
+			"committed_at":   imp.CommittedAt,
+			"expires_at":     imp.ExpiresAt,
+			"rows_purged_at": imp.RowsPurgedAt,
+		})
+	}
+
+	utils.RespondList(c, items, total, next)
+}
+
+// GetImportErrors downloads the rows of a committed import that were rejected
+// or whose task failed, with the original columns and a reason column, so the
+// file can be fixed and uploaded again
+func GetImportErrors(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	imp, ok := findImport(c, db, u)
+	if !ok {
+		return
+	}
+	if imp.Status != ImportStatusCommitted {
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "This import has not been committed yet")})
+		return
+	}
+	if imp.RowsPurgedAt != nil {
+		c.JSON(http.StatusGone, gin.H{"error": i18n.T(c, "The rows of this import have been deleted")})
+		return
+	}
+
+	tasks, err := importTasks(db, u.ID, imp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	filename := strings.TrimSuffix(imp.Filename, ".csv")
+	if filename == "" {
+		filename = "import-" + strconv.Itoa(imp.ID)
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(filename, `"`, "")+`-errors.csv"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(append(slices.Clone(imp.Header), "error"))
+	for _, outcome := range imp.Outcomes {
+		status, reason := rowStatus(outcome, tasks)
+		if status != "rejected" && status != "failed" {
+			continue
+		}
+		if outcome.Row < 1 || outcome.Row > len(imp.Rows) {
+			continue
+		}
+
+		record := make([]string, len(imp.Header))
+		copy(record, imp.Rows[outcome.Row-1])
+		w.Write(append(record, reason))
+	}
+	w.Flush()
+}
+
+// StartImportPurger deletes imports that expired without being committed and
+// the rows of imports committed more than importRowsRetention ago
+func StartImportPurger(db *gorm.DB) {
+	jobs.Register(jobs.Job{
+		Name:        "import_purge",
+		Description: "Delete expired imports and the rows of old committed imports",
+		Interval:    importPurgeInterval,
+		RunOnStart:  true,
+		Run: func() error {
+			return purgeImports(db)
+		},
+	})
+}
+
+// purgeImports deletes expired pending imports and the rows of committed
+// imports past the retention; outcomes and counts of committed imports stay
+func purgeImports(db *gorm.DB) error {
+	now := time.Now()
+	if err := db.Where("status = ? AND expires_at < ?", ImportStatusPending, now).
+		Delete(&models.TaskImport{}).Error; err != nil {
+		return fmt.Errorf("error deleting expired imports: %v", err)
+	}
+
+	result := db.Model(&models.TaskImport{}).
+		Where("status = ? AND committed_at < ? AND rows_purged_at IS NULL", ImportStatusCommitted, now.Add(-importRowsRetention)).
+		Updates(map[string]interface{}{"records": gorm.Expr("NULL"), "rows_purged_at": now})
+	if result.Error != nil {
+		return fmt.Errorf("error deleting rows of committed imports: %v", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Deleted the rows of %d committed imports", result.RowsAffected)
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package automation
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TopUpRequest struct {
+	Amount float64 `json:"amount" binding:"required"`
+	Note   string  `json:"note"`
+}
+
+// recordLine creates or updates a line from a task result. A new expiry date
+// clears any queued renewal or disable so the rules can act on the next expiry;
+// panels enable a line again when it is renewed. Changes the panel reported
+// itself come without a task (ID 0) and leave the line's last task as it is.
+func recordLine(db *gorm.DB, task models.AutomationTask, lineID, username string, packageID int, expireAt time.Time) {
+	if lineID == "" {
+		return
+	}
+
+	line := models.Line{
+		UserID:    task.UserID,
+		LineID:    lineID,
+		Username:  username,
+		PackageID: packageID,
+	}
+	columns := []string{"updated_at"}
+	if task.ID != 0 {
+		line.LastTaskID = &task.ID
+		columns = append(columns, "last_task_id")
+	}
+	if username != "" {
+		columns = append(columns, "username")
+	}
+	if packageID != 0 {
+		columns = append(columns, "package_id")
+	}
+	updates := clause.AssignmentColumns(columns)
+	if !expireAt.IsZero() {
+		line.ExpireAt = &expireAt
+		// Keep the queued renewal while the expiry is unchanged, e.g. on a repeated find
+		updates = append(clause.AssignmentColumns([]string{"expire_at"}), updates...)
+		for _, column := range []string{"renewal_queued_at", "disable_queued_at", "disabled_at"} {
+			updates = append(updates, clause.Assignment{
+				Column: clause.Column{Name: column},
+				Value:  gorm.Expr("CASE WHEN lines.expire_at = excluded.expire_at THEN lines." + column + " ELSE NULL END"),
+			})
+		}
+	}
+
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "line_id"}},
+		DoUpdates: updates,
+	}).Create(&line).Error; err != nil {
+		log.Printf("Failed to record line %s for task ID %d: %v", lineID, task.ID, err)
+	}
+}
+
+// recordInventory records the line and the credit spent by a create or extend task
+func recordInventory(db *gorm.DB, task models.AutomationTask, lineID, username string, packageID int,
+	expireAt time.Time, txType string, amount float64) {
+	recordLine(db, task, lineID, username, packageID, expireAt)
+
+	if amount == 0 {
+		return
+	}
+	transaction := models.Transaction{
+		UserID:    task.UserID,
+		TaskID:    &task.ID,
+		LineID:    lineID,
+		Type:      txType,
+		PackageID: packageID,
+		Amount:    -math.Abs(amount),
+	}
+	if err := db.Create(&transaction).Error; err != nil {
+		log.Printf("Failed to record transaction for task ID %d: %v", task.ID, err)
+	}
+}
+
+// creditBalance returns the sum of the user's transactions
+func creditBalance(db *gorm.DB, userID int) (float64, error) {
+	var balance float64
+	err := db.Model(&models.Transaction{}).
+		Where("user_id = ?", userID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+// GetLines lists the current user's known lines, soonest expiry first
+func GetLines(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	query := database.GetDB().Model(&models.Line{}).Where("user_id = ?", u.ID)
+	if v := c.Query("expiring_within_days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiring_within_days must be a non-negative integer"})
+			return
+		}
+		query = query.Where("expire_at IS NOT NULL AND expire_at < ?", time.Now().AddDate(0, 0, days))
+	}
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+
+	var lines []models.Line
+	if err := query.Order("expire_at IS NULL, expire_at ASC, id").Find(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	utils.RespondList(c, lines, int64(len(lines)), "")
+}
+
+// GetTransactions lists the current user's credit transactions, newest first
+func GetTransactions(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	page, err := utils.ParsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page == nil {
+		page = &utils.Page{Limit: utils.DefaultPageSize}
+	}
+
+	query := database.GetDB().Model(&models.Transaction{}).Where("user_id = ?", u.ID)
+	if txType := c.Query("type"); txType != "" {
+		query = query.Where("type = ?", txType)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var transactions []models.Transaction
+	if err := page.Apply(query).Find(&transactions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	n, next := page.NextCursor(len(transactions), func(i int) utils.Cursor {
+		return utils.Cursor{CreatedAt: transactions[i].CreatedAt, ID: transactions[i].ID}
+	})
+	transactions = transactions[:n]
+	c.Header("X-Next-Cursor", next)
+
+	utils.RespondList(c, transactions, total, next)
+}
+
+// GetCredits returns the current user's credit balance
+func GetCredits(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	balance, err := creditBalance(database.GetDB(), u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"balance": balance})
+}
+
+// TopUpCredits records credit bought from the panel provider. A negative amount
+// records a correction instead of a top-up.
+func TopUpCredits(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	var req TopUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	txType := models.TransactionTopUp
+	if req.Amount < 0 {
+		txType = models.TransactionAdjustment
+	}
+
+	db := database.GetDB()
+	transaction := models.Transaction{
+		UserID: u.ID,
+		Type:   txType,
+		Amount: req.Amount,
+		Note:   req.Note,
+	}
+	if err := db.Create(&transaction).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	balance, _ := creditBalance(db, u.ID)
+	c.JSON(http.StatusCreated, gin.H{
+		"transaction": transaction,
+		"balance":     balance,
+	})
+}
@@ -0,0 +1,255 @@
+package automation
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// normalizableCodes are the codes a normalization entry may assign
+var normalizableCodes = map[string]bool{
+	PanelErrorCredentials:  true,
+	PanelErrorCredit:       true,
+	PanelErrorConnectivity: true,
+	PanelErrorNotFound:     true,
+	PanelErrorRejected:     true,
+}
+
+// PanelErrorNormalizationRequest creates or replaces a normalization entry
+type PanelErrorNormalizationRequest struct {
+	Provider string `json:"provider"`
+	Pattern  string `json:"pattern" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// panelProvider returns the provider of a panel: the lowercased host of its
+// URL, without the port. A bare host is accepted as well.
+func panelProvider(panelURL string) string {
+	panelURL = strings.TrimSpace(panelURL)
+	if panelURL == "" {
+		return ""
+	}
+	if !strings.Contains(panelURL, "://") {
+		panelURL = "http://" + panelURL
+	}
+	u, err := url.Parse(panelURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// loadNormalizations returns the normalization table, or nothing when it
+// cannot be read, so errors are then classified by the built-in keywords
+func loadNormalizations(db *gorm.DB) []models.PanelErrorNormalization {
+	if db == nil {
+		return nil
+	}
+	var entries []models.PanelErrorNormalization
+	if err := db.Find(&entries).Error; err != nil {
+		log.Printf("Failed to load panel error normalizations: %v", err)
+		return nil
+	}
+	return entries
+}
+
+// normalizePanelError returns the code of the entry whose pattern occurs in
+// the message. Entries for the provider win over those for all panels, and
+// the longest pattern wins among them.
+func normalizePanelError(entries []models.PanelErrorNormalization, provider, message string) (string, bool) {
+	lower := strings.ToLower(message)
+
+	var best *models.PanelErrorNormalization
+	for i := range entries {
+		e := &entries[i]
+		if e.Provider != "" && e.Provider != provider {
+			continue
+		}
+		if e.Pattern == "" || !strings.Contains(lower, strings.ToLower(e.Pattern)) {
+			continue
+		}
+		if best == nil || outranks(e, best) {
+			best = e
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	return best.Code, true
+}
+
+// outranks reports whether entry a is preferred over b: entries for a provider
+// over those for all panels, then longer patterns
+func outranks(a, b *models.PanelErrorNormalization) bool {
+	if (a.Provider != "") != (b.Provider != "") {
+		return a.Provider != ""
+	}
+	return len(a.Pattern) > len(b.Pattern)
+}
+
+// providerPanelError returns a PanelError for a panel response, classified
+// with the normalization table before the built-in keywords
+func providerPanelError(resp *http.Response, message, rid string) *PanelError {
+	e := newPanelError(resp.StatusCode, message, rid)
+	if resp.Request == nil {
+		return e
+	}
+	if code, ok := normalizePanelError(loadNormalizations(database.GetDB()), panelProvider(resp.Request.URL.String()), message); ok {
+		e.Code = code
+	}
+	return e
+}
+
+// applyNormalizationRequest binds and validates an entry request into e, responding on failure
+func applyNormalizationRequest(c *gin.Context, e *models.PanelErrorNormalization) bool {
+	var req PanelErrorNormalizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	e.Provider = panelProvider(req.Provider)
+	e.Pattern = strings.TrimSpace(req.Pattern)
+	e.Code = strings.TrimSpace(req.Code)
+	if e.Pattern == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Pattern is required")})
+		return false
+	}
+	if !normalizableCodes[e.Code] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Code must be one of credentials, credit, connectivity, not_found or rejected")})
+		return false
+	}
+	if strings.TrimSpace(req.Provider) != "" && e.Provider == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Provider must be a panel host or URL")})
+		return false
+	}
+
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			e.UpdatedBy = &u.ID
+		}
+	}
+	return true
+}
+
+// saveNormalization stores an entry unless another one already uses its
+// provider and pattern, responding on failure
+func saveNormalization(c *gin.Context, db *gorm.DB, e *models.PanelErrorNormalization) bool {
+	var count int64
+	if err := db.Model(&models.PanelErrorNormalization{}).
+		Where("provider = ? AND LOWER(pattern) = LOWER(?) AND id <> ?", e.Provider, e.Pattern, e.ID).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return false
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "A normalization for this pattern already exists")})
+		return false
+	}
+
+	if err := db.Save(e).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return false
+	}
+	return true
+}
+
+// findNormalization loads an entry by URL ID, responding on failure
+func findNormalization(c *gin.Context, db *gorm.DB) (*models.PanelErrorNormalization, bool) {
+	var e models.PanelErrorNormalization
+	if err := db.First(&e, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Normalization not found")})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return nil, false
+	}
+	return &e, true
+}
+
+// GetNormalizations lists the normalization table, optionally of one provider (admin only)
+func GetNormalizations(c *gin.Context) {
+	query := database.GetDB().Order("provider, pattern")
+	if provider := c.Query("provider"); provider != "" {
+		query = query.Where("provider = ?", panelProvider(provider))
+	}
+
+	var entries []models.PanelErrorNormalization
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	utils.RespondList(c, entries, int64(len(entries)), "")
+}
+
+// CreateNormalization adds a normalization entry (admin only)
+func CreateNormalization(c *gin.Context) {
+	var e models.PanelErrorNormalization
+	if !applyNormalizationRequest(c, &e) {
+		return
+	}
+
+	if !saveNormalization(c, database.GetDB(), &e) {
+		return
+	}
+
+	audit.Record(c, audit.ActionPanelErrorNormalizationSaved, "panel_error_normalization", e.ID, map[string]interface{}{"normalization": e})
+
+	c.JSON(http.StatusCreated, e)
+}
+
+// UpdateNormalization replaces a normalization entry (admin only)
+func UpdateNormalization(c *gin.Context) {
+	db := database.GetDB()
+
+	e, ok := findNormalization(c, db)
+	if !ok {
+		return
+	}
+
+	if !applyNormalizationRequest(c, e) {
+		return
+	}
+
+	if !saveNormalization(c, db, e) {
+		return
+	}
+
+	audit.Record(c, audit.ActionPanelErrorNormalizationSaved, "panel_error_normalization", e.ID, map[string]interface{}{"normalization": e})
+
+	c.JSON(http.StatusOK, e)
+}
+
+// DeleteNormalization removes a normalization entry; tasks that already failed
+// keep their code (admin only)
+func DeleteNormalization(c *gin.Context) {
+	db := database.GetDB()
+
+	e, ok := findNormalization(c, db)
+	if !ok {
+		return
+	}
+
+	if err := db.Delete(e).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionPanelErrorNormalizationDeleted, "panel_error_normalization", e.ID, map[string]interface{}{
+		"provider": e.Provider,
+		"pattern":  e.Pattern,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Normalization deleted")})
+}
@@ -0,0 +1,305 @@
+package automation
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Normalized panel error codes
+const (
+	PanelErrorCredentials  = "credentials"
+	PanelErrorCredit       = "credit"
+	PanelErrorConnectivity = "connectivity"
+	PanelErrorNotFound     = "not_found"
+	PanelErrorRejected     = "rejected"
+	PanelErrorUnknown      = "unknown"
+)
+
+// panelErrorKeywords classify an error by its text, checked in order. Credit
+// keywords name what is missing, since "insufficient" alone also starts
+// permission errors such as "insufficient permissions".
+var panelErrorKeywords = []struct {
+	code     string
+	keywords []string
+}{
+	{PanelErrorCredit, []string{"credit", "balance", "funds"}},
+	{PanelErrorCredentials, []string{"api key", "api_key", "unauthorized", "forbidden", "credential", "permission", "auth"}},
+	{PanelErrorConnectivity, []string{"connection", "error making request", "timeout", "unavailable", "unreachable"}},
+	{PanelErrorNotFound, []string{"not found", "no accounts found", "does not exist"}},
+}
+
+// PanelError is an error returned by the panel or while reaching it
+type PanelError struct {
+	Code    string
+	Status  int // HTTP status, 0 if the panel was not reached
+	Message string
+	RID     string
+}
+
+func (e *PanelError) Error() string {
+	return e.Message
+}
+
+// newPanelError returns a PanelError classified from the status and message
+func newPanelError(status int, message, rid string) *PanelError {
+	return &PanelError{
+		Code:    classifyPanelError(status, message),
+		Status:  status,
+		Message: message,
+		RID:     rid,
+	}
+}
+
+// classifyPanelError returns the error code for a panel status and message.
+// The message wins over the status, since panels often answer 400 for everything.
+func classifyPanelError(status int, message string) string {
+	lower := strings.ToLower(message)
+	for _, k := range panelErrorKeywords {
+		for _, keyword := range k.keywords {
+			if strings.Contains(lower, keyword) {
+				return k.code
+			}
+		}
+	}
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return PanelErrorCredentials
+	case status == http.StatusPaymentRequired:
+		return PanelErrorCredit
+	case status == http.StatusNotFound:
+		return PanelErrorNotFound
+	case status >= 500:
+		return PanelErrorConnectivity
+	case status >= 400:
+		return PanelErrorRejected
+	}
+	return PanelErrorUnknown
+}
+
+// panelErrorCode returns the code of a PanelError, or classifies other errors by their text
+func panelErrorCode(err error) string {
+	var pe *PanelError
+	if errors.As(err, &pe) {
+		return pe.Code
+	}
+	return classifyPanelError(0, err.Error())
+}
+
+// PanelErrorRequest creates or replaces a panel error explanation
+type PanelErrorRequest struct {
+	Pattern      string `json:"pattern" binding:"required"`
+	Explanation  string `json:"explanation" binding:"required"`
+	SuggestedFix string `json:"suggested_fix"`
+}
+
+type PanelErrorTestRequest struct {
+	Error string `json:"error" binding:"required"`
+	// Provider is the panel host or URL whose normalization entries apply
+	Provider string `json:"provider"`
+}
+
+// matchPanelError returns the mapping whose pattern occurs in errMsg. The
+// longest pattern wins, so a specific mapping overrides a general one.
+func matchPanelError(mappings []models.PanelErrorMapping, errMsg string) *models.PanelErrorMapping {
+	lower := strings.ToLower(errMsg)
+
+	var best *models.PanelErrorMapping
+	for i := range mappings {
+		pattern := strings.ToLower(mappings[i].Pattern)
+		if pattern == "" || !strings.Contains(lower, pattern) {
+			continue
+		}
+		if best == nil || len(pattern) > len(best.Pattern) {
+			best = &mappings[i]
+		}
+	}
+	return best
+}
+
+// friendlyMessage is the explanation followed by the suggested fix, if any
+func friendlyMessage(m *models.PanelErrorMapping) string {
+	if m.SuggestedFix == "" {
+		return m.Explanation
+	}
+	return m.Explanation + " — " + m.SuggestedFix
+}
+
+// failureResult returns the result stored for a failed task with its error code.
+// When a mapping matches, error holds the friendly message and the panel's text
+// is kept in raw_error.
+func failureResult(db *gorm.DB, errMsg, code string) map[string]interface{} {
+	result := map[string]interface{}{
+		"success":    false,
+		"error":      errMsg,
+		"error_code": code,
+	}
+
+	var mappings []models.PanelErrorMapping
+	if err := db.Find(&mappings).Error; err != nil {
+		log.Printf("Failed to load panel error mappings: %v", err)
+		return result
+	}
+
+	if m := matchPanelError(mappings, errMsg); m != nil {
+		result["error"] = friendlyMessage(m)
+		result["explanation"] = m.Explanation
+		result["suggested_fix"] = m.SuggestedFix
+		result["raw_error"] = errMsg
+	}
+	return result
+}
+
+// applyPanelErrorRequest binds and validates a mapping request into m, responding on failure
+func applyPanelErrorRequest(c *gin.Context, m *models.PanelErrorMapping) bool {
+	var req PanelErrorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	m.Pattern = strings.TrimSpace(req.Pattern)
+	m.Explanation = strings.TrimSpace(req.Explanation)
+	m.SuggestedFix = strings.TrimSpace(req.SuggestedFix)
+	if m.Pattern == "" || m.Explanation == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Pattern and explanation are required")})
+		return false
+	}
+
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			m.UpdatedBy = &u.ID
+		}
+	}
+	return true
+}
+
+// savePanelError stores a mapping unless another one already uses its pattern, responding on failure
+func savePanelError(c *gin.Context, db *gorm.DB, m *models.PanelErrorMapping) bool {
+	var count int64
+	if err := db.Model(&models.PanelErrorMapping{}).
+		Where("LOWER(pattern) = LOWER(?) AND id <> ?", m.Pattern, m.ID).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return false
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "A mapping for this pattern already exists")})
+		return false
+	}
+
+	if err := db.Save(m).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return false
+	}
+	return true
+}
+
+// findPanelError loads a mapping by URL ID, responding on failure
+func findPanelError(c *gin.Context, db *gorm.DB) (*models.PanelErrorMapping, bool) {
+	var m models.PanelErrorMapping
+	if err := db.First(&m, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Panel error mapping not found")})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return nil, false
+	}
+	return &m, true
+}
+
+// GetPanelErrors lists the panel error mappings (admin only)
+func GetPanelErrors(c *gin.Context) {
+	var mappings []models.PanelErrorMapping
+	if err := database.GetDB().Order("pattern").Find(&mappings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	utils.RespondList(c, mappings, int64(len(mappings)), "")
+}
+
+// CreatePanelError adds a panel error mapping (admin only)
+func CreatePanelError(c *gin.Context) {
+	var m models.PanelErrorMapping
+	if !applyPanelErrorRequest(c, &m) {
+		return
+	}
+
+	if !savePanelError(c, database.GetDB(), &m) {
+		return
+	}
+
+	audit.Record(c, audit.ActionPanelErrorSaved, "panel_error", m.ID, map[string]interface{}{"mapping": m})
+
+	c.JSON(http.StatusCreated, m)
+}
+
+// UpdatePanelError replaces a panel error mapping (admin only)
+func UpdatePanelError(c *gin.Context) {
+	db := database.GetDB()
+
+	m, ok := findPanelError(c, db)
+	if !ok {
+		return
+	}
+
+	if !applyPanelErrorRequest(c, m) {
+		return
+	}
+
+	if !savePanelError(c, db, m) {
+		return
+	}
+
+	audit.Record(c, audit.ActionPanelErrorSaved, "panel_error", m.ID, map[string]interface{}{"mapping": m})
+
+	c.JSON(http.StatusOK, m)
+}
+
+// DeletePanelError removes a panel error mapping; tasks that already failed keep their message (admin only)
+func DeletePanelError(c *gin.Context) {
+	db := database.GetDB()
+
+	m, ok := findPanelError(c, db)
+	if !ok {
+		return
+	}
+
+	if err := db.Delete(m).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionPanelErrorDeleted, "panel_error", m.ID, map[string]interface{}{"pattern": m.Pattern})
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Panel error mapping deleted")})
+}
+
+// TestPanelError shows what a task failing with the given error would store (admin only)
+func TestPanelError(c *gin.Context) {
+	var req PanelErrorTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	code := classifyPanelError(0, req.Error)
+	if normalized, ok := normalizePanelError(loadNormalizations(db), panelProvider(req.Provider), req.Error); ok {
+		code = normalized
+	}
+
+	c.JSON(http.StatusOK, failureResult(db, sanitizeErrorMessage(req.Error), code))
+}
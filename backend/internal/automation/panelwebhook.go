@@ -0,0 +1,490 @@
+package automation
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/middleware"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// PanelWebhookPrefix is the public path panels deliver their webhooks to,
+	// followed by the user ID of the profile
+	PanelWebhookPrefix = "/panel-webhooks"
+
+	// Headers of a signed panel webhook delivery
+	panelTimestampHeader = "X-Panel-Timestamp"
+	panelNonceHeader     = "X-Panel-Nonce"
+	panelSignatureHeader = "X-Panel-Signature"
+
+	// panelWebhookTolerance is how far a delivery's timestamp may be from now
+	panelWebhookTolerance = 5 * time.Minute
+	// maxPanelWebhookBytes caps the size of a delivery
+	maxPanelWebhookBytes = 64 << 10
+	// panelWebhookRetention is how long rejected deliveries are kept
+	panelWebhookRetention = 30 * 24 * time.Hour
+)
+
+// Reasons a panel webhook delivery is rejected
+const (
+	RejectNotConfigured    = "not_configured"
+	RejectMissingSignature = "missing_signature"
+	RejectStaleTimestamp   = "stale_timestamp"
+	RejectBadSignature     = "bad_signature"
+	RejectReplayed         = "replayed"
+	RejectMalformed        = "malformed"
+)
+
+// panelWebhookLimiter bounds deliveries per IP, which also bounds how fast
+// rejections can be written
+var panelWebhookLimiter = middleware.NewIPRateLimiter(rate.Every(20*time.Millisecond), 100)
+
+// claimPanelNonce records the nonce of an authentic delivery and reports
+// whether it was new. A nonce is kept until the delivery's timestamp falls out
+// of the tolerance, after which a replay is refused as stale instead; the
+// unique index makes the claim atomic across instances.
+func claimPanelNonce(db *gorm.DB, userID int, nonce string, now time.Time) (bool, error) {
+	result := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "nonce"}},
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Lt{Column: clause.Column{Table: "panel_webhook_nonces", Name: "expires_at"}, Value: now},
+		}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+	}).Create(&models.PanelWebhookNonce{
+		UserID:    userID,
+		Nonce:     nonce,
+		ExpiresAt: now.Add(2 * panelWebhookTolerance),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// signPanelWebhook returns the hex HMAC-SHA256 of a delivery: the timestamp,
+// the nonce and the body joined by dots
+func signPanelWebhook(secret, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + nonce + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyPanelWebhook checks the signature headers of a delivery and returns the
+// reason it is rejected, or "" when it is authentic and new
+func verifyPanelWebhook(db *gorm.DB, userID int, secret string, header http.Header, body []byte, now time.Time) (string, error) {
+	timestamp := header.Get(panelTimestampHeader)
+	nonce := header.Get(panelNonceHeader)
+	signature := strings.TrimPrefix(header.Get(panelSignatureHeader), "sha256=")
+	if timestamp == "" || signature == "" || len(nonce) < 8 || len(nonce) > 128 {
+		return RejectMissingSignature, nil
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return RejectMissingSignature, nil
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > panelWebhookTolerance || age < -panelWebhookTolerance {
+		return RejectStaleTimestamp, nil
+	}
+
+	decoded, err := hex.DecodeString(signature)
+	expected, _ := hex.DecodeString(signPanelWebhook(secret, timestamp, nonce, body))
+	if err != nil || !hmac.Equal(decoded, expected) {
+		return RejectBadSignature, nil
+	}
+
+	// Only authentic deliveries claim their nonce, so forged ones cannot burn it
+	claimed, err := claimPanelNonce(db, userID, nonce, now)
+	if err != nil {
+		return "", err
+	}
+	if !claimed {
+		return RejectReplayed, nil
+	}
+	return "", nil
+}
+
+var (
+	rejectionPruneMu   sync.Mutex
+	lastRejectionPrune time.Time
+)
+
+// countedRejections are the reasons of deliveries without a valid signature.
+// Anyone can send those, so they are counted per user, reason and day instead
+// of being stored one by one.
+var countedRejections = map[string]bool{
+	RejectNotConfigured:    true,
+	RejectMissingSignature: true,
+	RejectStaleTimestamp:   true,
+	RejectBadSignature:     true,
+}
+
+// prunePanelWebhooks deletes rejections older than the retention and expired
+// nonces, at most once an hour
+func prunePanelWebhooks(db *gorm.DB) {
+	rejectionPruneMu.Lock()
+	prune := time.Since(lastRejectionPrune) > time.Hour
+	if prune {
+		lastRejectionPrune = time.Now()
+	}
+	rejectionPruneMu.Unlock()
+	if !prune {
+		return
+	}
+
+	cutoff := time.Now().Add(-panelWebhookRetention)
+	db.Where("created_at < ?", cutoff).Delete(&models.PanelWebhookRejection{})
+	db.Where("day < ?", cutoff.UTC().Format("2006-01-02")).Delete(&models.PanelWebhookRejectionCount{})
+	db.Where("expires_at < ?", time.Now()).Delete(&models.PanelWebhookNonce{})
+}
+
+// recordRejection stores a refused delivery for the admin report, or counts it
+// when it was not signed
+func recordRejection(c *gin.Context, userID int, reason, event string) {
+	db := database.GetDB()
+	var err error
+	if countedRejections[reason] {
+		err = db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}, {Name: "reason"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"count":          gorm.Expr("panel_webhook_rejection_counts.count + 1"),
+				"last_remote_ip": c.ClientIP(),
+				"updated_at":     time.Now(),
+			}),
+		}).Create(&models.PanelWebhookRejectionCount{
+			UserID:       userID,
+			Reason:       reason,
+			Day:          time.Now().UTC().Format("2006-01-02"),
+			Count:        1,
+			LastRemoteIP: c.ClientIP(),
+		}).Error
+	} else {
+		rejection := models.PanelWebhookRejection{
+			UserID:    userID,
+			Reason:    reason,
+			Event:     event,
+			Nonce:     c.GetHeader(panelNonceHeader),
+			RemoteIP:  c.ClientIP(),
+			Timestamp: c.GetHeader(panelTimestampHeader),
+		}
+		if len(rejection.Nonce) > 128 {
+			rejection.Nonce = rejection.Nonce[:128]
+		}
+		if len(rejection.Timestamp) > 32 {
+			rejection.Timestamp = rejection.Timestamp[:32]
+		}
+		err = db.Create(&rejection).Error
+	}
+	if err != nil {
+		log.Printf("Failed to record panel webhook rejection for user ID %d: %v", userID, err)
+	}
+}
+
+// PanelWebhookEvent is a change of a line the panel reports
+type PanelWebhookEvent struct {
+	// Event is line.updated, line.disabled or line.deleted
+	Event string `json:"event"`
+	Line  struct {
+		LineID    string     `json:"line_id"`
+		Username  string     `json:"username"`
+		Package   int        `json:"package"`
+		ExpireAt  *time.Time `json:"expire_at"`
+		IsEnabled *bool      `json:"is_enabled"`
+	} `json:"line"`
+}
+
+// applyPanelEvent brings the user's line inventory up to date with the event.
+// It returns false for events it does not know.
+func applyPanelEvent(db *gorm.DB, userID int, event PanelWebhookEvent) (bool, error) {
+	l := event.Line
+	switch event.Event {
+	case "line.updated", "line.disabled":
+		var expireAt time.Time
+		if l.ExpireAt != nil {
+			expireAt = *l.ExpireAt
+		}
+		recordLine(db, models.AutomationTask{UserID: userID}, l.LineID, l.Username, l.Package, expireAt)
+
+		disabled := event.Event == "line.disabled" || (l.IsEnabled != nil && !*l.IsEnabled)
+		query := db.Model(&models.Line{}).Where("user_id = ? AND line_id = ?", userID, l.LineID)
+		if disabled {
+			return true, query.Where("disabled_at IS NULL").Update("disabled_at", time.Now()).Error
+		}
+		if l.IsEnabled != nil {
+			return true, query.Update("disabled_at", nil).Error
+		}
+		return true, nil
+	case "line.deleted":
+		return true, db.Where("user_id = ? AND line_id = ?", userID, l.LineID).Delete(&models.Line{}).Error
+	default:
+		return false, nil
+	}
+}
+
+// PanelWebhook receives line changes from a user's panel. Requests are
+// authenticated by the signature made with the profile's webhook secret, not
+// by a user token.
+func PanelWebhook(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil || userID <= 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not configured"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPanelWebhookBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read payload"})
+		return
+	}
+	prunePanelWebhooks(database.GetDB())
+
+	db := database.ForUser(userID)
+	var settings models.UserSettings
+	if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil || settings.WebhookSecret == "" {
+		if err != nil && err != gorm.ErrRecordNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		// Deliveries to users without a profile are counted together, so
+		// probing user IDs does not add a counter per ID
+		if err == gorm.ErrRecordNotFound {
+			userID = 0
+		}
+		recordRejection(c, userID, RejectNotConfigured, "")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not configured"})
+		return
+	}
+
+	reason, err := verifyPanelWebhook(database.GetDB(), userID, settings.WebhookSecret, c.Request.Header, body, time.Now())
+	if err != nil {
+		log.Printf("Failed to claim panel webhook nonce for user ID %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if reason != "" {
+		recordRejection(c, userID, reason, "")
+		if reason == RejectReplayed {
+			c.JSON(http.StatusConflict, gin.H{"error": "Delivery already received"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature", "reason": reason})
+		return
+	}
+
+	var event PanelWebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.Event == "" || event.Line.LineID == "" {
+		recordRejection(c, userID, RejectMalformed, event.Event)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	known, err := applyPanelEvent(db, userID, event)
+	if err != nil {
+		log.Printf("Failed to apply panel webhook %s for user ID %d: %v", event.Event, userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"received": true, "ignored": !known})
+}
+
+// RotateWebhookSecret creates a new secret for the user's panel webhooks,
+// replacing the previous one, and returns it once
+func RotateWebhookSecret(c *gin.Context) {
+	user, _ := c.Get("user")
+	u := user.(models.User)
+
+	db := database.GetDB()
+	var settings models.UserSettings
+	if err := db.Where("user_id = ?", u.ID).First(&settings).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Settings not found")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update settings")})
+		return
+	}
+	settings.WebhookSecret = hex.EncodeToString(raw)
+	if err := db.Model(&settings).Select("webhook_secret").Updates(&settings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update settings")})
+		return
+	}
+
+	audit.Record(c, audit.ActionWebhookSecretRotated, "settings", settings.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhook_url":    utils.BaseURL(c) + PanelWebhookPrefix + "/" + strconv.Itoa(u.ID),
+		"webhook_secret": settings.WebhookSecret,
+	})
+}
+
+// DeleteWebhookSecret removes the user's webhook secret, so the panel's
+// deliveries are refused
+func DeleteWebhookSecret(c *gin.Context) {
+	user, _ := c.Get("user")
+	u := user.(models.User)
+
+	db := database.GetDB()
+	var settings models.UserSettings
+	if err := db.Where("user_id = ?", u.ID).First(&settings).Error; err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if settings.WebhookSecret == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Webhook secret not set")})
+		return
+	}
+	if err := db.Model(&settings).Update("webhook_secret", "").Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionWebhookSecretRemoved, "settings", settings.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Webhook secret removed")})
+}
+
+// GetPanelWebhookRejections lists rejected panel webhook deliveries, newest first
+func GetPanelWebhookRejections(c *gin.Context) {
+	page, err := utils.ParsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page == nil {
+		page = &utils.Page{Limit: utils.DefaultPageSize}
+	}
+
+	query := database.GetDB().Model(&models.PanelWebhookRejection{})
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var rejections []models.PanelWebhookRejection
+	if err := page.Apply(query).Find(&rejections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	n, next := page.NextCursor(len(rejections), func(i int) utils.Cursor {
+		return utils.Cursor{CreatedAt: rejections[i].CreatedAt, ID: rejections[i].ID}
+	})
+	rejections = rejections[:n]
+	c.Header("X-Next-Cursor", next)
+
+	utils.RespondList(c, rejections, total, next)
+}
+
+// rejectionSummaryRow counts the rejections of one user for one reason
+type rejectionSummaryRow struct {
+	UserID int    `json:"user_id"`
+	Reason string `json:"reason"`
+	Count  int64  `json:"count"`
+}
+
+// GetPanelWebhookRejectionSummary counts rejected deliveries by user and reason
+// over the last days (7 by default)
+func GetPanelWebhookRejectionSummary(c *gin.Context) {
+	days := 7
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive integer"})
+			return
+		}
+		days = n
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	db := database.GetReadDB()
+	var stored, counted []rejectionSummaryRow
+	if err := db.Model(&models.PanelWebhookRejection{}).
+		Select("user_id, reason, COUNT(*) AS count").
+		Where("created_at >= ?", since).
+		Group("user_id, reason").
+		Scan(&stored).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if err := db.Model(&models.PanelWebhookRejectionCount{}).
+		Select("user_id, reason, SUM(count) AS count").
+		Where("day >= ?", since.UTC().Format("2006-01-02")).
+		Group("user_id, reason").
+		Scan(&counted).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	// Stored and counted reasons never overlap, so the rows can be joined
+	rows := append(stored, counted...)
+	if rows == nil {
+		rows = []rejectionSummaryRow{}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].UserID < rows[j].UserID
+	})
+
+	reasons := map[string]int64{}
+	var total int64
+	for _, r := range rows {
+		reasons[r.Reason] += r.Count
+		total += r.Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"days":    days,
+		"since":   since,
+		"total":   total,
+		"reasons": reasons,
+		"users":   rows,
+	})
+}
+
+// SetupWebhookRoutes configures the public, signature-protected panel webhook route
+func SetupWebhookRoutes(router *gin.RouterGroup) {
+	router.POST("/:user_id", middleware.RateLimiterMiddleware(panelWebhookLimiter), PanelWebhook)
+}
+
+// setupPanelWebhookRoutes configures the panel webhook report for admins
+func setupPanelWebhookRoutes(router *gin.RouterGroup) {
+	router.GET("/panel-webhooks/rejections", GetPanelWebhookRejections)
+	router.GET("/panel-webhooks/rejections/summary", GetPanelWebhookRejectionSummary)
+}
@@ -0,0 +1,108 @@
+package automation
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxPresetConnections bounds the max connections preset
+	maxPresetConnections = 100
+	// maxNoteTemplateLength bounds the note template preset
+	maxNoteTemplateLength = 500
+)
+
+// renderNote fills in the placeholders of a note template; the date is in the
+// user's timezone
+func renderNote(u models.User, template string, req TaskRequest) string {
+	loc := time.UTC
+	if u.Timezone != "" {
+		if l, err := time.LoadLocation(u.Timezone); err == nil {
+			loc = l
+		}
+	}
+
+	return strings.NewReplacer(
+		"{username}", req.Username,
+		"{package}", strconv.Itoa(req.Package),
+		"{date}", time.Now().In(loc).Format("2006-01-02"),
+	).Replace(template)
+}
+
+// applyPresets fills the fields a task request leaves empty from the user's
+// presets. It runs before validation, so presets satisfy required fields.
+func applyPresets(u models.User, req *TaskRequest) {
+	presets := u.TaskPresets
+	switch req.Name {
+	case "create_account":
+		if req.Package == 0 {
+			req.Package = presets.Package
+		}
+		if req.MaxConnections == 0 {
+			req.MaxConnections = presets.MaxConnections
+		}
+		if req.Note == "" && presets.NoteTemplate != "" {
+			req.Note = renderNote(u, presets.NoteTemplate, *req)
+		}
+	case "extend_package":
+		if req.Package == 0 {
+			req.Package = presets.Package
+		}
+	}
+}
+
+// GetPresets returns the current user's task form presets
+func GetPresets(c *gin.Context) {
+	user, _ := c.Get("user")
+	u := user.(models.User)
+
+	c.JSON(http.StatusOK, u.TaskPresets)
+}
+
+// UpdatePresets replaces the current user's task form presets
+func UpdatePresets(c *gin.Context) {
+	user, _ := c.Get("user")
+	u := user.(models.User)
+
+	var req models.TaskPresets
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.NoteTemplate = strings.TrimSpace(req.NoteTemplate)
+
+	if req.Package < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "package must be a positive number")})
+		return
+	}
+	if req.MaxConnections < 0 || req.MaxConnections > maxPresetConnections {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "max_connections must be between 1 and %d", maxPresetConnections)})
+		return
+	}
+	if len(req.NoteTemplate) > maxNoteTemplateLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "note_template must be at most %d characters", maxNoteTemplateLength)})
+		return
+	}
+
+	// Serialized fields go through Select so the JSON serializer is applied
+	u.TaskPresets = req
+	if err := database.GetDB().Model(&u).Select("task_presets").Updates(&u).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionPresetsSaved, "user", u.ID, map[string]interface{}{
+		"package":         req.Package,
+		"max_connections": req.MaxConnections,
+	})
+
+	c.JSON(http.StatusOK, u.TaskPresets)
+}
@@ -0,0 +1,425 @@
+package automation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/heartbeat"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/quota"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// renewalCheckInterval is how often renewal rules are evaluated
+const renewalCheckInterval = time.Hour
+
+// maxDaysBeforeExpiry limits how early a rule may renew a line
+const maxDaysBeforeExpiry = 60
+
+type RenewalRuleRequest struct {
+	Name             string `json:"name"`
+	LineID           string `json:"line_id"`
+	Package          int    `json:"package" binding:"required"`
+	DaysBeforeExpiry int    `json:"days_before_expiry"`
+	Enabled          *bool  `json:"enabled"`
+}
+
+// currentUser returns the authenticated user or writes an error response
+func currentUser(c *gin.Context) (models.User, bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return models.User{}, false
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return models.User{}, false
+	}
+	return u, true
+}
+
+// applyRuleRequest validates a rule request and copies it onto the rule
+func applyRuleRequest(c *gin.Context, rule *models.RenewalRule) bool {
+	var req RenewalRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if req.DaysBeforeExpiry < 0 || req.DaysBeforeExpiry > maxDaysBeforeExpiry {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days_before_expiry must be between 0 and 60"})
+		return false
+	}
+
+	rule.Name = req.Name
+	rule.LineID = req.LineID
+	rule.Package = req.Package
+	rule.DaysBeforeExpiry = req.DaysBeforeExpiry
+	rule.Enabled = req.Enabled == nil || *req.Enabled
+	return true
+}
+
+// GetRenewalRules lists the current user's renewal rules
+func GetRenewalRules(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var rules []models.RenewalRule
+	if err := database.GetDB().Where("user_id = ?", u.ID).Order("id").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	utils.RespondList(c, rules, int64(len(rules)), "")
+}
+
+// CreateRenewalRule adds a renewal rule for the current user
+func CreateRenewalRule(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	if err := quota.AllowRenewalRule(db, u); err != nil {
+		if err == quota.ErrExceeded {
+			c.JSON(http.StatusForbidden, gin.H{"error": i18n.T(c, "Your plan does not allow more renewal rules")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	rule := models.RenewalRule{UserID: u.ID}
+	if !applyRuleRequest(c, &rule) {
+		return
+	}
+
+	if err := db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRenewalRule replaces one of the current user's renewal rules
+func UpdateRenewalRule(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var rule models.RenewalRule
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), u.ID).First(&rule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Renewal rule not found")})
+		return
+	}
+
+	if !applyRuleRequest(c, &rule) {
+		return
+	}
+
+	if err := db.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRenewalRule removes one of the current user's renewal rules
+func DeleteRenewalRule(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	result := database.GetDB().Where("id = ? AND user_id = ?", c.Param("id"), u.ID).Delete(&models.RenewalRule{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Renewal rule not found")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Renewal rule deleted")})
+}
+
+// ruleForLine picks the rule that applies to a line; a rule for the specific line
+// takes precedence over a rule for all lines
+func ruleForLine(rules []models.RenewalRule, line models.Line) *models.RenewalRule {
+	var general *models.RenewalRule
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.Enabled {
+			continue
+		}
+		if rule.LineID == line.LineID {
+			return rule
+		}
+		if rule.LineID == "" && general == nil {
+			general = rule
+		}
+	}
+	return general
+}
+
+// StartRenewalScheduler evaluates renewal rules hourly and enqueues extend
+// tasks, then disable tasks for lines past the grace period of expiry rules,
+// when RENEWAL_SCHEDULER_ENABLED is set
+func StartRenewalScheduler(db *gorm.DB) {
+	if !config.Get().RenewalSchedulerEnabled {
+		return
+	}
+
+	jobs.Register(jobs.Job{
+		Name:        heartbeat.JobRenewals,
+		Description: "Enqueue extend tasks of renewal rules and disable tasks of the expiry rule",
+		Interval:    renewalCheckInterval,
+		RunOnStart:  true,
+		Run: func() error {
+			err := runRenewalRules(db)
+			if expiryErr := runExpiryDisabling(db); expiryErr != nil {
+				err = errors.Join(err, expiryErr)
+			}
+			return err
+		},
+	})
+}
+
+// runRenewalRules creates an extend_package task for every line that a rule
+// says is due. It fails when the rules cannot be loaded or a renewal could not
+// be enqueued.
+func runRenewalRules(db *gorm.DB) error {
+	var userIDs []int
+	if err := db.Model(&models.RenewalRule{}).Where("enabled = ?", true).
+		Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		log.Printf("Failed to load renewal rules: %v", err)
+		return fmt.Errorf("error loading renewal rules: %v", err)
+	}
+
+	failed := 0
+	now := time.Now()
+	for _, userID := range userIDs {
+		var settings models.UserSettings
+		if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+			continue
+		}
+		// Renewals would only fail until the user fixes the API key
+		if settings.CredentialStatus == models.CredentialStatusInvalid {
+			continue
+		}
+
+		var rules []models.RenewalRule
+		if err := db.Where("user_id = ? AND enabled = ?", userID, true).Order("id").Find(&rules).Error; err != nil {
+			continue
+		}
+
+		maxDays := 0
+		for _, rule := range rules {
+			if rule.DaysBeforeExpiry > maxDays {
+				maxDays = rule.DaysBeforeExpiry
+			}
+		}
+
+		var lines []models.Line
+		if err := db.Where("user_id = ? AND renewal_queued_at IS NULL AND expire_at IS NOT NULL AND expire_at < ?",
+			userID, now.AddDate(0, 0, maxDays+1)).Find(&lines).Error; err != nil {
+			continue
+		}
+
+		for _, line := range lines {
+			rule := ruleForLine(rules, line)
+			if rule == nil || line.Username == "" || line.ExpireAt.After(now.AddDate(0, 0, rule.DaysBeforeExpiry)) {
+				continue
+			}
+
+			req := TaskRequest{
+				Name:          "extend_package",
+				TargetWebsite: settings.WebsiteURL,
+				Username:      line.Username,
+				Package:       rule.Package,
+			}
+			task, _, err := enqueueTask(db, settings, req, nil)
+			if err != nil {
+				log.Printf("Failed to enqueue renewal of line %s for user ID %d: %v", line.LineID, userID, err)
+				failed++
+				continue
+			}
+
+			db.Model(&models.Line{}).Where("id = ?", line.ID).Update("renewal_queued_at", &now)
+			db.Model(&models.RenewalRule{}).Where("id = ?", rule.ID).Update("last_run_at", &now)
+			log.Printf("Renewal rule ID %d queued task ID %d for line %s", rule.ID, task.ID, line.LineID)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d renewals could not be enqueued", failed)
+	}
+	return nil
+}
+
+// packageCosts returns the average credit spent per package from the user's history
+func packageCosts(db *gorm.DB, userID int) (map[int]float64, error) {
+	var rows []struct {
+		PackageID int
+		Cost      float64
+	}
+	err := db.Model(&models.Transaction{}).
+		Select("package_id, AVG(-amount) AS cost").
+		Where("user_id = ? AND type IN ? AND package_id <> 0", userID,
+			[]string{models.TransactionPurchase, models.TransactionRenewal}).
+		Group("package_id").
+		Scan(&rows).Error
+
+	costs := make(map[int]float64, len(rows))
+	for _, row := range rows {
+		costs[row.PackageID] = row.Cost
+	}
+	return costs, err
+}
+
+// GetRenewalPlan projects renewals and spend for a month from expiring lines,
+// renewal rules and the credit balance
+func GetRenewalPlan(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetReadDB()
+	loc := userLocation(db, u.ID)
+
+	month := time.Now().In(loc)
+	if v := c.Query("month"); v != "" {
+		parsed, err := time.ParseInLocation("2006-01", v, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "month must look like 2006-01"})
+			return
+		}
+		month = parsed
+	}
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 1, 0)
+
+	var lines []models.Line
+	if err := db.Where("user_id = ? AND expire_at >= ? AND expire_at < ?", u.ID, from, to).
+		Order("expire_at, id").
+		Find(&lines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var rules []models.RenewalRule
+	if err := db.Where("user_id = ? AND enabled = ?", u.ID, true).Order("id").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	costs, err := packageCosts(db, u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	balance, err := creditBalance(db, u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	type dayPlan struct {
+		Date           string  `json:"date"`
+		Expiring       int     `json:"expiring"`
+		AutoRenewals   int     `json:"auto_renewals"`
+		ProjectedSpend float64 `json:"projected_spend"`
+	}
+
+	var (
+		days           []dayPlan
+		items          = make([]gin.H, 0, len(lines))
+		autoRenewals   int
+		unpriced       int
+		projectedSpend float64
+		potentialSpend float64
+	)
+	dayIndex := make(map[string]int)
+
+	for _, line := range lines {
+		rule := ruleForLine(rules, line)
+
+		packageID := line.PackageID
+		if rule != nil {
+			packageID = rule.Package
+		}
+		var estimate *float64
+		if cost, ok := costs[packageID]; ok {
+			cost = math.Round(cost*100) / 100
+			estimate = &cost
+			potentialSpend += cost
+		} else {
+			unpriced++
+		}
+
+		date := line.ExpireAt.In(loc).Format("2006-01-02")
+		i, seen := dayIndex[date]
+		if !seen {
+			i = len(days)
+			dayIndex[date] = i
+			days = append(days, dayPlan{Date: date})
+		}
+		days[i].Expiring++
+
+		item := gin.H{
+			"line_id":        line.LineID,
+			"username":       line.Username,
+			"expire_at":      line.ExpireAt,
+			"package_id":     packageID,
+			"rule_id":        nil,
+			"estimated_cost": estimate,
+		}
+		if rule != nil {
+			item["rule_id"] = rule.ID
+			autoRenewals++
+			days[i].AutoRenewals++
+			if estimate != nil {
+				projectedSpend += *estimate
+				days[i].ProjectedSpend += *estimate
+			}
+		}
+		items = append(items, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"month":           from.Format("2006-01"),
+		"from":            from,
+		"to":              to,
+		"expiring_lines":  len(lines),
+		"auto_renewals":   autoRenewals,
+		"manual_renewals": len(lines) - autoRenewals,
+		"unpriced_lines":  unpriced,
+		"projected_spend": projectedSpend,
+		"potential_spend": potentialSpend,
+		"credit_balance":  balance,
+		"shortfall":       math.Max(0, projectedSpend-balance),
+		"days":            days,
+		"lines":           items,
+	})
+}
@@ -0,0 +1,280 @@
+package automation
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Outcomes of a profile in a settings rollout
+const (
+	RolloutUpdated   = "updated"
+	RolloutUnchanged = "unchanged"
+	RolloutSkipped   = "skipped"
+)
+
+// SettingsRolloutRequest changes the panel profile of many users at once
+type SettingsRolloutRequest struct {
+	// UserIDs selects users by ID
+	UserIDs []int `json:"user_ids"`
+	// FromURL selects the profiles whose panel URL has the same host; with
+	// UserIDs, listed users on another host are skipped
+	FromURL string `json:"from_url"`
+
+	// WebsiteURL, APIKey and AuthUser replace the profile's values when set
+	WebsiteURL string `json:"website_url"`
+	APIKey     string `json:"api_key"`
+	AuthUser   string `json:"auth_user"`
+	// APIKeys rotates keys per user, by user ID, and takes precedence over APIKey
+	APIKeys map[string]string `json:"api_keys"`
+
+	DryRun bool `json:"dry_run"`
+}
+
+// RolloutChange is a setting that changes, with API keys masked
+type RolloutChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// RolloutProfile is the outcome of a rollout for one user
+type RolloutProfile struct {
+	UserID   int                      `json:"user_id"`
+	Username string                   `json:"username"`
+	Status   string                   `json:"status"`
+	Reason   string                   `json:"reason,omitempty"`
+	Changes  map[string]RolloutChange `json:"changes,omitempty"`
+}
+
+// maskKey keeps the last 4 characters of an API key for the preview
+func maskKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
+
+// parsePanelURL checks a panel URL is an absolute http(s) URL
+func parsePanelURL(raw string) (*url.URL, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, false
+	}
+	return u, true
+}
+
+// onHost reports whether a panel URL is on the host
+func onHost(raw, host string) bool {
+	u, ok := parsePanelURL(raw)
+	return ok && strings.EqualFold(u.Host, host)
+}
+
+// resetConnectionStatus forgets the connection test and credential check of a
+// profile whose panel or credentials change
+func resetConnectionStatus(settings *models.UserSettings) {
+	settings.ConnectionVerifiedAt = nil
+	settings.CredentialStatus = models.CredentialStatusUnknown
+	settings.CredentialError = ""
+	settings.CredentialCheckedAt = nil
+}
+
+// rolloutTargets returns the selected profiles by user ID and the requested
+// users without one. Without user IDs, the profiles on the from_url host are
+// selected; with them, the host is checked per profile by the caller.
+func rolloutTargets(req SettingsRolloutRequest, fromHost string) (map[int]models.UserSettings, []int, error) {
+	selected := map[int]bool{}
+	for _, id := range req.UserIDs {
+		selected[id] = true
+	}
+
+	query := database.GetDB().Model(&models.UserSettings{})
+	if len(req.UserIDs) > 0 {
+		query = query.Where("user_id IN ?", req.UserIDs)
+	}
+	var rows []models.UserSettings
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	profiles := map[int]models.UserSettings{}
+	for _, row := range rows {
+		if len(req.UserIDs) == 0 && !onHost(row.WebsiteURL, fromHost) {
+			continue
+		}
+		profiles[row.UserID] = row
+	}
+
+	var missing []int
+	for id := range selected {
+		if _, ok := profiles[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	sort.Ints(missing)
+	return profiles, missing, nil
+}
+
+// RolloutSettings changes the panel profile of many users at once, e.g. when
+// an upstream panel moves to a new domain. Profiles are selected by user ID,
+// by the host of their panel URL, or both. A dry run returns the same preview
+// without saving anything.
+func RolloutSettings(c *gin.Context) {
+	var req SettingsRolloutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.UserIDs) == 0 && req.FromURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_ids or from_url is required"})
+		return
+	}
+	if req.WebsiteURL == "" && req.APIKey == "" && req.AuthUser == "" && len(req.APIKeys) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to change: set website_url, api_key, api_keys or auth_user"})
+		return
+	}
+	var fromHost string
+	if req.FromURL != "" {
+		u, ok := parsePanelURL(req.FromURL)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from_url must be an http or https URL"})
+			return
+		}
+		fromHost = u.Host
+	}
+	if req.WebsiteURL != "" {
+		u, ok := parsePanelURL(req.WebsiteURL)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "website_url must be an http or https URL"})
+			return
+		}
+		req.WebsiteURL = u.String()
+	}
+	keys := map[int]string{}
+	for id, key := range req.APIKeys {
+		userID, err := strconv.Atoi(id)
+		if err != nil || key == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "api_keys must map user IDs to keys"})
+			return
+		}
+		keys[userID] = key
+	}
+
+	profiles, missing, err := rolloutTargets(req, fromHost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	ids := make([]int, 0, len(profiles)+len(missing))
+	for id := range profiles {
+		ids = append(ids, id)
+	}
+	ids = append(ids, missing...)
+	sort.Ints(ids)
+
+	var users []models.User
+	if len(ids) > 0 {
+		if err := database.GetDB().Select("id", "username").Where("id IN ?", ids).Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+			return
+		}
+	}
+	usernames := map[int]string{}
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	results := make([]RolloutProfile, 0, len(ids))
+	counts := map[string]int{RolloutUpdated: 0, RolloutUnchanged: 0, RolloutSkipped: 0}
+	var updated []int
+	for _, id := range ids {
+		result := RolloutProfile{UserID: id, Username: usernames[id]}
+		settings, ok := profiles[id]
+		switch {
+		case result.Username == "":
+			result.Status, result.Reason = RolloutSkipped, "user not found"
+		case !ok:
+			result.Status, result.Reason = RolloutSkipped, "no panel settings"
+		case fromHost != "" && !onHost(settings.WebsiteURL, fromHost):
+			result.Status, result.Reason = RolloutSkipped, "panel URL is not on the from_url host"
+		default:
+			next := settings
+			if req.WebsiteURL != "" {
+				next.WebsiteURL = req.WebsiteURL
+			}
+			if req.APIKey != "" {
+				next.APIKey = req.APIKey
+			}
+			if key, ok := keys[id]; ok {
+				next.APIKey = key
+			}
+			if req.AuthUser != "" {
+				next.AuthUser = req.AuthUser
+			}
+
+			result.Changes = map[string]RolloutChange{}
+			if next.WebsiteURL != settings.WebsiteURL {
+				result.Changes["website_url"] = RolloutChange{From: settings.WebsiteURL, To: next.WebsiteURL}
+			}
+			if next.APIKey != settings.APIKey {
+				result.Changes["api_key"] = RolloutChange{From: maskKey(settings.APIKey), To: maskKey(next.APIKey)}
+			}
+			if next.AuthUser != settings.AuthUser {
+				result.Changes["auth_user"] = RolloutChange{From: settings.AuthUser, To: next.AuthUser}
+			}
+
+			if len(result.Changes) == 0 {
+				result.Status = RolloutUnchanged
+				break
+			}
+			result.Status = RolloutUpdated
+			if !req.DryRun {
+				resetConnectionStatus(&next)
+				if err := database.GetDB().Select("website_url", "api_key", "auth_user", "connection_verified_at",
+					"credential_status", "credential_error", "credential_checked_at").Updates(&next).Error; err != nil {
+					result.Status, result.Reason = RolloutSkipped, "failed to save: "+err.Error()
+					break
+				}
+				updated = append(updated, id)
+			}
+		}
+		counts[result.Status]++
+		results = append(results, result)
+	}
+
+	if !req.DryRun && len(updated) > 0 {
+		changed := []string{}
+		if req.WebsiteURL != "" {
+			changed = append(changed, "website_url")
+		}
+		if req.APIKey != "" || len(req.APIKeys) > 0 {
+			changed = append(changed, "api_key")
+		}
+		if req.AuthUser != "" {
+			changed = append(changed, "auth_user")
+		}
+		audit.Record(c, audit.ActionSettingsRollout, "settings", nil, map[string]interface{}{
+			"user_ids":    updated,
+			"from_url":    req.FromURL,
+			"website_url": req.WebsiteURL,
+			"changed":     changed,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dry_run":  req.DryRun,
+		"counts":   counts,
+		"profiles": results,
+	})
+}
@@ -0,0 +1,558 @@
+package automation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/settings"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxShadowRuns caps the shadow runs in flight; tasks finishing while it is
+// reached are not shadowed, so shadowing never queues up behind the panel
+const maxShadowRuns = 4
+
+// PanelOperations is the panel API a task implementation works against
+type PanelOperations interface {
+	FindAccount(username string) ([]Line, error)
+	CreateAccount(req CreateAccountRequest) (*CreateAccountResponse, error)
+	ExtendPackage(lineID string, req ExtendPackageRequest) (*ExtendPackageResponse, error)
+	DisableLine(lineID string, req DisableLineRequest) (*DisableLineResponse, error)
+}
+
+// ShadowImplementation is a candidate implementation of a task type. It
+// returns the "data" of a successful result, like the legacy path stores it.
+type ShadowImplementation func(panel PanelOperations, req TaskRequest, rid string) (interface{}, error)
+
+// ShadowConfig selects the task types whose candidate implementation runs in
+// shadow, and the share of their tasks that is shadowed
+type ShadowConfig struct {
+	TaskTypes     []string `json:"task_types"`
+	SamplePercent int      `json:"sample_percent"`
+}
+
+// ShadowDifference is a field whose value differs between the two paths
+type ShadowDifference struct {
+	Field  string      `json:"field"`
+	Legacy interface{} `json:"legacy"`
+	Shadow interface{} `json:"shadow"`
+}
+
+// DefaultShadowConfig shadows nothing
+var DefaultShadowConfig = ShadowConfig{TaskTypes: []string{}, SamplePercent: 100}
+
+var (
+	shadowMu              sync.RWMutex
+	shadowImplementations = map[string]ShadowImplementation{}
+
+	// shadowSlots limits the shadow runs in flight to maxShadowRuns
+	shadowSlots = make(chan struct{}, maxShadowRuns)
+)
+
+// shadowFields are the fields of a successful result compared per task type.
+// Fields made up by simulated writes, such as expiry dates and amounts, are
+// left out; a nil list compares the whole data.
+var shadowFields = map[string][]string{
+	"create_account":  {"username", "password"},
+	"find_account":    nil,
+	"extend_package":  {"line_id", "username", "password"},
+	"disable_account": {"line_id", "username", "is_enabled"},
+}
+
+// RegisterShadow sets the candidate implementation of a task type that runs in
+// shadow when the type is enabled in the shadow mode settings
+func RegisterShadow(taskType string, impl ShadowImplementation) {
+	shadowMu.Lock()
+	defer shadowMu.Unlock()
+	shadowImplementations[taskType] = impl
+}
+
+// shadowImplementation returns the candidate implementation of a task type
+func shadowImplementation(taskType string) (ShadowImplementation, bool) {
+	shadowMu.RLock()
+	defer shadowMu.RUnlock()
+	impl, ok := shadowImplementations[taskType]
+	return impl, ok
+}
+
+// ShadowTaskTypes lists the task types with a candidate implementation
+func ShadowTaskTypes() []string {
+	shadowMu.RLock()
+	defer shadowMu.RUnlock()
+	types := make([]string, 0, len(shadowImplementations))
+	for t := range shadowImplementations {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// LoadShadowConfig returns the stored shadow mode settings, or the defaults
+func LoadShadowConfig(db *gorm.DB) (ShadowConfig, error) {
+	value, err := settings.Get(db, settings.KeyShadowMode)
+	if err != nil || value == "" {
+		return DefaultShadowConfig, err
+	}
+
+	var cfg ShadowConfig
+	if err := json.Unmarshal([]byte(value), &cfg); err != nil {
+		return DefaultShadowConfig, fmt.Errorf("error decoding shadow mode settings: %v", err)
+	}
+	if cfg.TaskTypes == nil {
+		cfg.TaskTypes = []string{}
+	}
+	return cfg, nil
+}
+
+// SaveShadowConfig stores the shadow mode settings
+func SaveShadowConfig(db *gorm.DB, cfg ShadowConfig, updatedBy *int) error {
+	value, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return settings.Set(db, settings.KeyShadowMode, string(value), updatedBy)
+}
+
+// Validate checks that every task type has a candidate implementation and the
+// sample is a percentage
+func (cfg *ShadowConfig) Validate() error {
+	if cfg.TaskTypes == nil {
+		cfg.TaskTypes = []string{}
+	}
+	for _, t := range cfg.TaskTypes {
+		if _, ok := shadowImplementation(t); !ok {
+			return fmt.Errorf("task type %q has no shadow implementation", t)
+		}
+	}
+	if cfg.SamplePercent < 1 || cfg.SamplePercent > 100 {
+		return errors.New("sample_percent must be between 1 and 100")
+	}
+	return nil
+}
+
+// shadows tells whether a task of the type is picked for a shadow run
+func (cfg ShadowConfig) shadows(taskType string) bool {
+	for _, t := range cfg.TaskTypes {
+		if t == taskType {
+			return cfg.SamplePercent >= 100 || rand.Intn(100) < cfg.SamplePercent
+		}
+	}
+	return false
+}
+
+// shadowPanel runs reads against the real panel and simulates every write, so
+// a candidate implementation sees production data without changing it
+type shadowPanel struct {
+	client *APIClient
+}
+
+func (p shadowPanel) FindAccount(username string) ([]Line, error) {
+	if p.client.IsSimulationMode() {
+		return p.client.SimulateFindAccount(username)
+	}
+	return p.client.FindAccount(username)
+}
+
+func (p shadowPanel) CreateAccount(req CreateAccountRequest) (*CreateAccountResponse, error) {
+	return p.client.SimulateCreateAccount(req)
+}
+
+func (p shadowPanel) ExtendPackage(lineID string, req ExtendPackageRequest) (*ExtendPackageResponse, error) {
+	return p.client.SimulateExtendPackage(lineID, req)
+}
+
+func (p shadowPanel) DisableLine(lineID string, req DisableLineRequest) (*DisableLineResponse, error) {
+	return p.client.SimulateDisableLine(lineID, req)
+}
+
+// startShadow runs the candidate implementation of a finished task in the
+// background when its type is shadowed. It never changes the task.
+func startShadow(db *gorm.DB, task models.AutomationTask, req TaskRequest, apiClient *APIClient) {
+	// Panicked or unsaved tasks have no legacy result to compare with
+	if task.Status != "completed" && task.Status != "failed" {
+		return
+	}
+	impl, ok := shadowImplementation(task.Name)
+	if !ok {
+		return
+	}
+	cfg, err := LoadShadowConfig(db)
+	if err != nil {
+		log.Printf("Failed to load shadow mode settings: %v", err)
+		return
+	}
+	if !cfg.shadows(task.Name) {
+		return
+	}
+
+	select {
+	case shadowSlots <- struct{}{}:
+	default:
+		log.Printf("Skipping shadow run of task ID %d: %d runs in flight", task.ID, maxShadowRuns)
+		return
+	}
+
+	legacy := append(models.JSON(nil), task.Result...)
+	go func() {
+		defer func() { <-shadowSlots }()
+		if err := runShadow(db, task, legacy, req, impl, apiClient); err != nil {
+			log.Printf("Shadow run of task ID %d failed: %v", task.ID, err)
+		}
+	}()
+}
+
+// runShadow runs a candidate implementation and stores its result next to the
+// legacy one
+func runShadow(db *gorm.DB, task models.AutomationTask, legacy models.JSON, req TaskRequest, impl ShadowImplementation, apiClient *APIClient) error {
+	start := time.Now()
+
+	var result map[string]interface{}
+	func() {
+		// A broken candidate is a mismatch, not a crash of the server
+		defer func() {
+			if r := recover(); r != nil {
+				result = map[string]interface{}{"success": false, "error": fmt.Sprintf("panic: %v", r), "error_code": "panic"}
+			}
+		}()
+		data, runErr := impl(shadowPanel{client: apiClient}, req, uuid.New().String())
+		if runErr != nil {
+			result = map[string]interface{}{
+				"success":    false,
+				"error":      sanitizeErrorMessage(runErr.Error()),
+				"error_code": panelErrorCode(runErr),
+			}
+			return
+		}
+		result = map[string]interface{}{"success": true, "data": data}
+	}()
+
+	shadowJSON, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("error encoding the shadow result: %v", err)
+	}
+	differences, err := compareShadowResults(task.Name, legacy, shadowJSON)
+	if err != nil {
+		return err
+	}
+	differencesJSON, _ := json.Marshal(differences)
+
+	return db.Create(&models.TaskShadowResult{
+		TaskID:       task.ID,
+		UserID:       task.UserID,
+		TaskType:     task.Name,
+		LegacyResult: legacy,
+		ShadowResult: models.JSON(shadowJSON),
+		Match:        len(differences) == 0,
+		Differences:  models.JSON(differencesJSON),
+		DurationMS:   int(time.Since(start).Milliseconds()),
+	}).Error
+}
+
+// compareShadowResults lists the differences between a legacy and a shadow
+// result. Outcomes are compared first, then error codes of failures and the
+// compared fields of successes; messages are free to differ.
+func compareShadowResults(taskType string, legacyJSON, shadowJSON []byte) ([]ShadowDifference, error) {
+	var legacy, shadow map[string]interface{}
+	if err := json.Unmarshal(legacyJSON, &legacy); err != nil {
+		return nil, fmt.Errorf("error decoding the legacy result: %v", err)
+	}
+	if err := json.Unmarshal(shadowJSON, &shadow); err != nil {
+		return nil, fmt.Errorf("error decoding the shadow result: %v", err)
+	}
+
+	differences := []ShadowDifference{}
+	if legacy["success"] != shadow["success"] {
+		return append(differences, ShadowDifference{Field: "success", Legacy: legacy["success"], Shadow: shadow["success"]}), nil
+	}
+	if legacy["success"] != true {
+		if legacy["error_code"] != shadow["error_code"] {
+			differences = append(differences, ShadowDifference{Field: "error_code", Legacy: legacy["error_code"], Shadow: shadow["error_code"]})
+		}
+		return differences, nil
+	}
+
+	fields := shadowFields[taskType]
+	if fields == nil {
+		if !reflect.DeepEqual(legacy["data"], shadow["data"]) {
+			differences = append(differences, ShadowDifference{Field: "data", Legacy: legacy["data"], Shadow: shadow["data"]})
+		}
+		return differences, nil
+	}
+
+	legacyData, _ := legacy["data"].(map[string]interface{})
+	shadowData, _ := shadow["data"].(map[string]interface{})
+	for _, field := range fields {
+		if !reflect.DeepEqual(legacyData[field], shadowData[field]) {
+			differences = append(differences, ShadowDifference{Field: "data." + field, Legacy: legacyData[field], Shadow: shadowData[field]})
+		}
+	}
+	return differences, nil
+}
+
+// findFirstLine returns the first line of a username, or a not found panel error
+func findFirstLine(panel PanelOperations, username string) (Line, error) {
+	lines, err := panel.FindAccount(username)
+	if err != nil {
+		return Line{}, err
+	}
+	if len(lines) == 0 {
+		return Line{}, newPanelError(http.StatusNotFound, "No accounts found with the provided username", "")
+	}
+	return lines[0], nil
+}
+
+// The candidate implementations of the built-in task types work through
+// PanelOperations alone, so a new panel provider can be validated against
+// production traffic before it replaces the legacy path
+func init() {
+	RegisterShadow("create_account", func(panel PanelOperations, req TaskRequest, rid string) (interface{}, error) {
+		response, err := panel.CreateAccount(CreateAccountRequest{
+			Username:       req.Username,
+			Password:       req.Password,
+			Package:        req.Package,
+			MaxConnections: req.MaxConnections,
+			ResellerNotes:  req.Note,
+			RID:            rid,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"line_id":            response.LineID,
+			"username":           req.Username,
+			"password":           req.Password,
+			"expire_at":          response.ExpireAt,
+			"transaction_amount": response.TransactionAmount,
+			"rid":                response.RID,
+		}, nil
+	})
+
+	RegisterShadow("find_account", func(panel PanelOperations, req TaskRequest, rid string) (interface{}, error) {
+		return panel.FindAccount(req.Username)
+	})
+
+	RegisterShadow("extend_package", func(panel PanelOperations, req TaskRequest, rid string) (interface{}, error) {
+		line, err := findFirstLine(panel, req.Username)
+		if err != nil {
+			return nil, err
+		}
+		response, err := panel.ExtendPackage(line.LineID, ExtendPackageRequest{Package: req.Package, RID: rid})
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"line_id":            response.LineID,
+			"username":           line.Username,
+			"password":           line.Password,
+			"expire_at":          response.ExpireAt,
+			"transaction_amount": response.TransactionAmount,
+			"rid":                response.RID,
+		}, nil
+	})
+
+	RegisterShadow("disable_account", func(panel PanelOperations, req TaskRequest, rid string) (interface{}, error) {
+		line, err := findFirstLine(panel, req.Username)
+		if err != nil {
+			return nil, err
+		}
+		response, err := panel.DisableLine(line.LineID, DisableLineRequest{RID: rid})
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"line_id":    response.LineID,
+			"username":   line.Username,
+			"expire_at":  line.ExpireAt,
+			"is_enabled": response.IsEnabled,
+			"rid":        response.RID,
+		}, nil
+	})
+}
+
+// shadowSettingsResponse is the shadow mode configuration with the task types
+// that may be shadowed
+func shadowSettingsResponse(cfg ShadowConfig) gin.H {
+	return gin.H{
+		"task_types":     cfg.TaskTypes,
+		"sample_percent": cfg.SamplePercent,
+		"available":      ShadowTaskTypes(),
+	}
+}
+
+// GetShadowSettings returns the shadow mode configuration (admin only)
+func GetShadowSettings(c *gin.Context) {
+	cfg, err := LoadShadowConfig(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to load settings")})
+		return
+	}
+
+	c.JSON(http.StatusOK, shadowSettingsResponse(cfg))
+}
+
+// UpdateShadowSettings replaces the shadow mode configuration (admin only)
+func UpdateShadowSettings(c *gin.Context) {
+	var req ShadowConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var updatedBy *int
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			updatedBy = &u.ID
+		}
+	}
+
+	if err := SaveShadowConfig(database.GetDB(), req, updatedBy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update settings")})
+		return
+	}
+
+	audit.Record(c, audit.ActionShadowModeUpdated, "settings", "shadow_mode", map[string]interface{}{
+		"task_types":     req.TaskTypes,
+		"sample_percent": req.SamplePercent,
+	})
+
+	c.JSON(http.StatusOK, shadowSettingsResponse(req))
+}
+
+// GetShadowResults lists shadow runs, newest first, optionally filtered by
+// ?task_type=, ?user_id= and ?match=true|false (admin only)
+func GetShadowResults(c *gin.Context) {
+	page, err := utils.ParsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page == nil {
+		page = &utils.Page{Limit: utils.DefaultPageSize}
+	}
+
+	query := database.GetReadDB().Model(&models.TaskShadowResult{})
+	if taskType := c.Query("task_type"); taskType != "" {
+		query = query.Where("task_type = ?", taskType)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if match := c.Query("match"); match != "" {
+		matched, err := strconv.ParseBool(match)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "match must be true or false"})
+			return
+		}
+		query = query.Where("matched = ?", matched)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var results []models.TaskShadowResult
+	if err := page.Apply(query).Find(&results).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	n, next := page.NextCursor(len(results), func(i int) utils.Cursor {
+		return utils.Cursor{CreatedAt: results[i].CreatedAt, ID: results[i].ID}
+	})
+	results = results[:n]
+	c.Header("X-Next-Cursor", next)
+
+	response := make([]gin.H, 0, len(results))
+	for _, r := range results {
+		response = append(response, gin.H{
+			"id":            r.ID,
+			"task_id":       r.TaskID,
+			"user_id":       r.UserID,
+			"task_type":     r.TaskType,
+			"match":         r.Match,
+			"differences":   json.RawMessage(r.Differences),
+			"legacy_result": json.RawMessage(r.LegacyResult),
+			"shadow_result": json.RawMessage(r.ShadowResult),
+			"duration_ms":   r.DurationMS,
+			"created_at":    r.CreatedAt,
+		})
+	}
+
+	utils.RespondList(c, response, total, next)
+}
+
+// shadowSummaryRow counts the shadow runs of a task type
+type shadowSummaryRow struct {
+	TaskType      string  `json:"task_type"`
+	Runs          int64   `json:"runs"`
+	Matches       int64   `json:"matches"`
+	MatchRate     float64 `json:"match_rate"`
+	AvgDurationMS float64 `json:"avg_duration_ms"`
+}
+
+// GetShadowSummary reports the match rate of every shadowed task type over the
+// last ?days= days, 7 by default (admin only)
+func GetShadowSummary(c *gin.Context) {
+	days := 7
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive integer"})
+			return
+		}
+		days = n
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	rows := []shadowSummaryRow{}
+	if err := database.GetReadDB().Model(&models.TaskShadowResult{}).
+		Select("task_type, COUNT(*) AS runs, SUM(CASE WHEN matched THEN 1 ELSE 0 END) AS matches, AVG(duration_ms) AS avg_duration_ms").
+		Where("created_at >= ?", since).
+		Group("task_type").
+		Order("task_type").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	for i := range rows {
+		if rows[i].Runs > 0 {
+			rows[i].MatchRate = float64(rows[i].Matches) / float64(rows[i].Runs)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"days":       days,
+		"since":      since,
+		"task_types": rows,
+	})
+}
+
+// setupShadowRoutes configures the shadow mode routes for admins
+func setupShadowRoutes(router *gin.RouterGroup) {
+	router.GET("/settings/shadow", GetShadowSettings)
+	router.PUT("/settings/shadow", UpdateShadowSettings)
+	router.GET("/shadow/results", GetShadowResults)
+	router.GET("/shadow/summary", GetShadowSummary)
+}
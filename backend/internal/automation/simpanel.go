@@ -0,0 +1,368 @@
+package automation
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Failures the simulated panel answers failed requests with
+const (
+	// PanelFaultUnavailable answers 503, classified as a connectivity error
+	PanelFaultUnavailable = "unavailable"
+	// PanelFaultCredentials answers 401 as for a revoked API key
+	PanelFaultCredentials = "credentials"
+	// PanelFaultCredit answers 402 as for a reseller out of credits
+	PanelFaultCredit = "credit"
+	// PanelFaultRejected answers 400 as for a request the panel refuses
+	PanelFaultRejected = "rejected"
+	// PanelFaultHTML answers 502 with an HTML page, as a proxy in front of a down panel does
+	PanelFaultHTML = "html"
+	// PanelFaultMalformed answers 200 with a truncated JSON body
+	PanelFaultMalformed = "malformed"
+	// PanelFaultTimeout never answers, so the client's timeout is hit
+	PanelFaultTimeout = "timeout"
+)
+
+// PanelFaults lists every failure the simulated panel can answer with
+var PanelFaults = []string{PanelFaultUnavailable, PanelFaultCredentials, PanelFaultCredit, PanelFaultRejected, PanelFaultHTML, PanelFaultMalformed, PanelFaultTimeout}
+
+// SimulatedPanelProfile tunes the simulated panel
+type SimulatedPanelProfile struct {
+	// LatencyMS is added to every response
+	LatencyMS int `json:"latency_ms"`
+	// JitterMS adds a random delay of up to this many milliseconds
+	JitterMS int `json:"jitter_ms"`
+	// ErrorRate is the share of requests (0-1) that fail
+	ErrorRate float64 `json:"error_rate"`
+	// ErrorMode is how failed requests are answered; unavailable when empty
+	ErrorMode string `json:"error_mode,omitempty"`
+}
+
+// Validate checks the profile's ranges
+func (p SimulatedPanelProfile) Validate() error {
+	if p.LatencyMS < 0 || p.LatencyMS > 60000 || p.JitterMS < 0 || p.JitterMS > 60000 {
+		return fmt.Errorf("latency_ms and jitter_ms must be between 0 and 60000")
+	}
+	if p.ErrorRate < 0 || p.ErrorRate > 1 {
+		return fmt.Errorf("error_rate must be between 0 and 1")
+	}
+	if p.ErrorMode != "" && !isPanelFault(p.ErrorMode) {
+		return fmt.Errorf("error_mode must be one of %v", PanelFaults)
+	}
+	return nil
+}
+
+func isPanelFault(mode string) bool {
+	for _, f := range PanelFaults {
+		if f == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// SimulatedPanelStats counts the requests served by the simulated panel
+type SimulatedPanelStats struct {
+	Requests int64 `json:"requests"`
+	Errors   int64 `json:"errors"`
+	Lines    int   `json:"lines"`
+}
+
+// SimulatedPanel is an in-memory panel implementing the endpoints APIClient
+// uses. It serves bench mode and the fakepanel command.
+type SimulatedPanel struct {
+	apiKey   string
+	authUser string
+
+	mu      sync.Mutex
+	profile SimulatedPanelProfile
+	lines   map[string]*Line // by line ID
+	byUser  map[string][]*Line
+
+	requests atomic.Int64
+	errors   atomic.Int64
+}
+
+// NewSimulatedPanel returns an empty panel accepting only the given credentials
+func NewSimulatedPanel(apiKey, authUser string, profile SimulatedPanelProfile) *SimulatedPanel {
+	return &SimulatedPanel{
+		apiKey:   apiKey,
+		authUser: authUser,
+		profile:  profile,
+		lines:    map[string]*Line{},
+		byUser:   map[string][]*Line{},
+	}
+}
+
+// addLine stores a line on the simulated panel, with a random password when
+// none is given
+func (p *SimulatedPanel) addLine(username, password string, pkg int, expireAt time.Time, trial bool) *Line {
+	if password == "" {
+		password = fmt.Sprintf("Sim%04d!", rand.Intn(10000))
+	}
+	line := &Line{
+		LineID:    "sim-" + uuid.New().String(),
+		Username:  username,
+		Password:  password,
+		Owner:     p.authUser,
+		Type:      "line",
+		ExpireAt:  expireAt,
+		IsEnabled: true,
+		IsTrial:   trial,
+		PackageID: pkg,
+		Bouquets:  []int{},
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lines[line.LineID] = line
+	p.byUser[username] = append(p.byUser[username], line)
+	return line
+}
+
+// Profile returns the current latency and failure profile
+func (p *SimulatedPanel) Profile() SimulatedPanelProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.profile
+}
+
+// SetProfile changes the latency and failure profile, also while serving
+func (p *SimulatedPanel) SetProfile(profile SimulatedPanelProfile) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.profile = profile
+}
+
+// Stats returns the request counts and the number of stored lines
+func (p *SimulatedPanel) Stats() SimulatedPanelStats {
+	p.mu.Lock()
+	lines := len(p.lines)
+	p.mu.Unlock()
+
+	return SimulatedPanelStats{
+		Requests: p.requests.Load(),
+		Errors:   p.errors.Load(),
+		Lines:    lines,
+	}
+}
+
+// Reset removes every line and zeroes the counters
+func (p *SimulatedPanel) Reset() {
+	p.mu.Lock()
+	p.lines = map[string]*Line{}
+	p.byUser = map[string][]*Line{}
+	p.mu.Unlock()
+
+	p.requests.Store(0)
+	p.errors.Store(0)
+}
+
+// Register adds the panel API to a router
+func (p *SimulatedPanel) Register(r gin.IRoutes) {
+	r.GET("/", func(c *gin.Context) {
+		p.requests.Add(1)
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	r.POST("/ext/line/create", p.createLine)
+	r.GET("/ext/lines", p.findLines)
+	r.POST("/ext/line/:id/renew", p.renewLine)
+	r.POST("/ext/line/:id/disable", p.disableLine)
+	r.POST("/ext/line/:id/delete", p.deleteLine)
+}
+
+// simulate delays the response and checks the credentials, responding with an
+// error when the request fails
+func (p *SimulatedPanel) simulate(c *gin.Context) bool {
+	p.requests.Add(1)
+	profile := p.Profile()
+
+	delay := time.Duration(profile.LatencyMS) * time.Millisecond
+	if profile.JitterMS > 0 {
+		delay += time.Duration(rand.Intn(profile.JitterMS+1)) * time.Millisecond
+	}
+	time.Sleep(delay)
+
+	if c.GetHeader("X-Api-Key") != p.apiKey || c.GetHeader("X-Auth-User") != p.authUser {
+		p.errors.Add(1)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key", "rid": uuid.New().String()})
+		return false
+	}
+	if profile.ErrorRate > 0 && rand.Float64() < profile.ErrorRate {
+		p.errors.Add(1)
+		fail(c, profile.ErrorMode)
+		return false
+	}
+	return true
+}
+
+// fail answers a request the way a panel failing with the mode would
+func fail(c *gin.Context, mode string) {
+	rid := uuid.New().String()
+	switch mode {
+	case PanelFaultCredentials:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key", "rid": rid})
+	case PanelFaultCredit:
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": "Insufficient credits", "rid": rid})
+	case PanelFaultRejected:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request rejected by the panel", "rid": rid})
+	case PanelFaultHTML:
+		c.Data(http.StatusBadGateway, "text/html; charset=utf-8",
+			[]byte("<!DOCTYPE html><html><head><title>502 Bad Gateway</title></head><body><h1>502 Bad Gateway</h1></body></html>"))
+	case PanelFaultMalformed:
+		c.Data(http.StatusOK, "application/json", []byte(`{"line_id": "`))
+	case PanelFaultTimeout:
+		// Hold the request until the client gives up. The server only notices
+		// a closed connection once the body was read.
+		io.Copy(io.Discard, c.Request.Body)
+		<-c.Request.Context().Done()
+		c.Abort()
+	default:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable", "rid": rid})
+	}
+}
+
+func (p *SimulatedPanel) createLine(c *gin.Context) {
+	if !p.simulate(c) {
+		return
+	}
+
+	var req CreateAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expireAt := time.Now().AddDate(0, req.Package%100, 0)
+	if req.IsTrial {
+		expireAt = time.Now().Add(24 * time.Hour)
+	}
+	line := p.addLine(req.Username, req.Password, req.Package, expireAt, req.IsTrial)
+	c.JSON(http.StatusOK, CreateAccountResponse{
+		LineID:            line.LineID,
+		ExpireAt:          line.ExpireAt,
+		TransactionAmount: simulatedPrice(req.Package),
+		RID:               req.RID,
+	})
+}
+
+func (p *SimulatedPanel) findLines(c *gin.Context) {
+	if !p.simulate(c) {
+		return
+	}
+
+	p.mu.Lock()
+	lines := make([]Line, 0, len(p.byUser[c.Query("username")]))
+	for _, line := range p.byUser[c.Query("username")] {
+		lines = append(lines, *line)
+	}
+	p.mu.Unlock()
+
+	c.JSON(http.StatusOK, lines)
+}
+
+func (p *SimulatedPanel) renewLine(c *gin.Context) {
+	if !p.simulate(c) {
+		return
+	}
+
+	var req ExtendPackageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	p.mu.Lock()
+	line, ok := p.lines[c.Param("id")]
+	var expireAt time.Time
+	if ok {
+		base := line.ExpireAt
+		if base.Before(time.Now()) {
+			base = time.Now()
+		}
+		line.ExpireAt = base.AddDate(0, req.Package%100, 0)
+		line.PackageID = req.Package
+		line.IsEnabled = true
+		expireAt = line.ExpireAt
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Line not found", "rid": req.RID})
+		return
+	}
+
+	c.JSON(http.StatusOK, ExtendPackageResponse{
+		LineID:            c.Param("id"),
+		ExpireAt:          expireAt,
+		TransactionAmount: simulatedPrice(req.Package),
+		RID:               req.RID,
+	})
+}
+
+func (p *SimulatedPanel) disableLine(c *gin.Context) {
+	if !p.simulate(c) {
+		return
+	}
+
+	var req DisableLineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	p.mu.Lock()
+	line, ok := p.lines[c.Param("id")]
+	if ok {
+		line.IsEnabled = false
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Line not found", "rid": req.RID})
+		return
+	}
+
+	c.JSON(http.StatusOK, DisableLineResponse{LineID: c.Param("id"), IsEnabled: false, RID: req.RID})
+}
+
+func (p *SimulatedPanel) deleteLine(c *gin.Context) {
+	if !p.simulate(c) {
+		return
+	}
+
+	var req DeleteLineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	p.mu.Lock()
+	line, ok := p.lines[c.Param("id")]
+	if ok {
+		delete(p.lines, line.LineID)
+		remaining := p.byUser[line.Username][:0]
+		for _, l := range p.byUser[line.Username] {
+			if l.LineID != line.LineID {
+				remaining = append(remaining, l)
+			}
+		}
+		p.byUser[line.Username] = remaining
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Line not found", "rid": req.RID})
+		return
+	}
+
+	c.JSON(http.StatusOK, DeleteLineResponse{LineID: c.Param("id"), RID: req.RID})
+}
@@ -0,0 +1,139 @@
+package automation
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestPanel serves a simulated panel and returns a client with its credentials
+func newTestPanel(t *testing.T, profile SimulatedPanelProfile) (*SimulatedPanel, *APIClient) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	panel := NewSimulatedPanel("key", "reseller", profile)
+	r := gin.New()
+	panel.Register(r)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return panel, NewAPIClient(srv.URL, "key", "reseller")
+}
+
+func TestSimulatedPanelLineLifecycle(t *testing.T) {
+	panel, client := newTestPanel(t, SimulatedPanelProfile{})
+
+	if _, err := client.Ping(); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	if err := client.CheckCredentials(); err != nil {
+		t.Fatalf("CheckCredentials() error = %v", err)
+	}
+
+	created, err := client.CreateAccount(CreateAccountRequest{Username: "alice", Password: "secret", Package: 101, RID: "r1"})
+	if err != nil {
+		t.Fatalf("CreateAccount() error = %v", err)
+	}
+	if created.LineID == "" || created.RID != "r1" || !created.ExpireAt.After(time.Now()) {
+		t.Errorf("CreateAccount() = %+v", created)
+	}
+
+	lines, err := client.FindAccount("alice")
+	if err != nil {
+		t.Fatalf("FindAccount() error = %v", err)
+	}
+	if len(lines) != 1 || lines[0].LineID != created.LineID || lines[0].Password != "secret" || !lines[0].IsEnabled {
+		t.Fatalf("FindAccount() = %+v", lines)
+	}
+
+	extended, err := client.ExtendPackage(created.LineID, ExtendPackageRequest{Package: 103, RID: "r2"})
+	if err != nil {
+		t.Fatalf("ExtendPackage() error = %v", err)
+	}
+	if !extended.ExpireAt.After(created.ExpireAt) || extended.TransactionAmount != simulatedPrice(103) {
+		t.Errorf("ExtendPackage() = %+v, created %+v", extended, created)
+	}
+
+	disabled, err := client.DisableLine(created.LineID, DisableLineRequest{RID: "r3"})
+	if err != nil {
+		t.Fatalf("DisableLine() error = %v", err)
+	}
+	if disabled.IsEnabled {
+		t.Errorf("DisableLine() = %+v, want is_enabled false", disabled)
+	}
+	if lines, _ := client.FindAccount("alice"); len(lines) != 1 || lines[0].IsEnabled {
+		t.Errorf("FindAccount() after disabling = %+v", lines)
+	}
+
+	if _, err := client.DeleteLine(created.LineID, DeleteLineRequest{RID: "r4"}); err != nil {
+		t.Fatalf("DeleteLine() error = %v", err)
+	}
+	if lines, _ := client.FindAccount("alice"); len(lines) != 0 {
+		t.Errorf("FindAccount() after deleting = %+v", lines)
+	}
+
+	var panelErr *PanelError
+	_, err = client.DeleteLine(created.LineID, DeleteLineRequest{RID: "r5"})
+	if !errors.As(err, &panelErr) || panelErr.Code != PanelErrorNotFound {
+		t.Errorf("DeleteLine() of a deleted line error = %v, want %s", err, PanelErrorNotFound)
+	}
+
+	if stats := panel.Stats(); stats.Lines != 0 || stats.Errors != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestSimulatedPanelRejectsWrongKey(t *testing.T) {
+	_, client := newTestPanel(t, SimulatedPanelProfile{})
+	client.APIKey = "wrong"
+
+	var panelErr *PanelError
+	err := client.CheckCredentials()
+	if !errors.As(err, &panelErr) || panelErr.Code != PanelErrorCredentials {
+		t.Errorf("CheckCredentials() error = %v, want %s", err, PanelErrorCredentials)
+	}
+}
+
+func TestSimulatedPanelFaults(t *testing.T) {
+	tests := []struct {
+		mode string
+		// code is the PanelError code the client reports, empty for errors
+		// that are not a PanelError
+		code string
+	}{
+		{PanelFaultUnavailable, PanelErrorConnectivity},
+		{PanelFaultCredentials, PanelErrorCredentials},
+		{PanelFaultCredit, PanelErrorCredit},
+		{PanelFaultRejected, PanelErrorRejected},
+		{PanelFaultHTML, PanelErrorConnectivity},
+		{PanelFaultMalformed, ""},
+		{PanelFaultTimeout, PanelErrorConnectivity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			panel, client := newTestPanel(t, SimulatedPanelProfile{ErrorRate: 1, ErrorMode: tt.mode})
+			client.HTTPClient.Timeout = 200 * time.Millisecond
+
+			_, err := client.CreateAccount(CreateAccountRequest{Username: "bob", Package: 101, RID: "r1"})
+			if err == nil {
+				t.Fatal("CreateAccount() error = nil")
+			}
+
+			var panelErr *PanelError
+			switch {
+			case tt.code == "" && errors.As(err, &panelErr):
+				t.Errorf("CreateAccount() error = %v (%s), want a decoding error", err, panelErr.Code)
+			case tt.code != "" && (!errors.As(err, &panelErr) || panelErr.Code != tt.code):
+				t.Errorf("CreateAccount() error = %v, want %s", err, tt.code)
+			}
+
+			if stats := panel.Stats(); stats.Errors != 1 || stats.Lines != 0 {
+				t.Errorf("Stats() = %+v", stats)
+			}
+		})
+	}
+}
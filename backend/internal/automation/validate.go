@@ -0,0 +1,109 @@
+package automation
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/quota"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TaskIssue is a problem found when validating a task request. Field names the
+// request field it concerns, if any.
+type TaskIssue struct {
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// checkTask runs the checks of CreateTask without creating the task. Errors
+// would make creation fail; warnings are likely panel refusals the server does
+// not enforce, since its view of lines and credit may be incomplete.
+func checkTask(c *gin.Context, db *gorm.DB, u models.User, req TaskRequest) (errs, warnings []TaskIssue, heldUntil *time.Time, err error) {
+	for _, msg := range validateTaskRequest(req) {
+		errs = append(errs, TaskIssue{Code: "invalid_request", Message: msg})
+	}
+
+	var settings models.UserSettings
+	if err := db.Where("user_id = ?", u.ID).First(&settings).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return nil, nil, nil, err
+	}
+	if len(missingSettings(settings)) > 0 {
+		errs = append(errs, TaskIssue{Code: CodeSettingsMissing,
+			Message: i18n.T(c, "Save your panel URL and API key in the settings before creating tasks")})
+	} else {
+		if settings.CredentialStatus == models.CredentialStatusInvalid {
+			errs = append(errs, TaskIssue{Code: "credentials_invalid",
+				Message: i18n.T(c, "Your panel rejected the saved API key. Update your settings before creating tasks.")})
+		}
+		if open, opensAt := executionWindowOpen(db, settings); !open {
+			heldUntil = &opensAt
+		}
+	}
+
+	if err := quota.AllowTasks(db, u, 1); err == quota.ErrExceeded {
+		errs = append(errs, TaskIssue{Code: "quota_exceeded", Message: i18n.T(c, "Daily task quota reached")})
+	} else if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if req.Name == "create_account" && req.Username != "" {
+		var count int64
+		db.Model(&models.Line{}).Where("user_id = ? AND username = ?", u.ID, req.Username).Count(&count)
+		if count > 0 {
+			warnings = append(warnings, TaskIssue{Field: "username", Code: "username_taken",
+				Message: i18n.T(c, "You already have a line named %s", req.Username)})
+		}
+	}
+
+	// The cost of a package is only known from earlier purchases and renewals
+	if req.Name == "create_account" || req.Name == "extend_package" {
+		costs, err := packageCosts(db, u.ID)
+		if cost, ok := costs[req.Package]; err == nil && ok {
+			if balance, err := creditBalance(db, u.ID); err == nil && balance < cost {
+				warnings = append(warnings, TaskIssue{Field: "package", Code: "insufficient_balance",
+					Message: i18n.T(c, "Package %d usually costs %.2f credits but your balance is %.2f", req.Package, cost, balance)})
+			}
+		}
+	}
+
+	return errs, warnings, heldUntil, nil
+}
+
+// ValidateTask checks a task request the way CreateTask would, without
+// creating it, so forms can show problems before they are submitted
+func ValidateTask(c *gin.Context) {
+	user, _ := c.Get("user")
+	u := user.(models.User)
+
+	var req TaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Invalid request format")})
+		return
+	}
+	applyPresets(u, &req)
+
+	errs, warnings, heldUntil, err := checkTask(c, database.GetDB(), u, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if errs == nil {
+		errs = []TaskIssue{}
+	}
+	if warnings == nil {
+		warnings = []TaskIssue{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":      len(errs) == 0,
+		"errors":     errs,
+		"warnings":   warnings,
+		"held_until": heldUntil,
+		"request":    req,
+	})
+}
@@ -0,0 +1,164 @@
+package automation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/heartbeat"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// Tasks and batches created outside the user's execution window wait in the held status
+const (
+	TaskStatusHeld  = "held"
+	BatchStatusHeld = "held"
+)
+
+// heldDispatchInterval is how often held work is checked against execution windows
+const heldDispatchInterval = time.Minute
+
+// userLocation returns the user's timezone, falling back to the server's local time
+func userLocation(db *gorm.DB, userID int) *time.Location {
+	var user models.User
+	if err := db.Select("timezone").First(&user, userID).Error; err == nil && user.Timezone != "" {
+		if loc, err := time.LoadLocation(user.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// executionWindowOpen reports whether the user's tasks may run now and, if not,
+// when the window opens next
+func executionWindowOpen(db *gorm.DB, settings models.UserSettings) (bool, time.Time) {
+	now := time.Now()
+	if settings.ExecutionWindow == "" {
+		return true, now
+	}
+
+	window, err := utils.ParseTimeWindow(settings.ExecutionWindow)
+	if err != nil {
+		// An invalid stored window must not block tasks forever
+		return true, now
+	}
+
+	local := now.In(userLocation(db, settings.UserID))
+	if window.Contains(local) {
+		return true, now
+	}
+	return false, window.NextOpen(local)
+}
+
+// StartHeldTaskDispatcher releases held tasks and batches once their execution
+// window opens, and batches waiting on an unreachable panel once it answers
+func StartHeldTaskDispatcher(db *gorm.DB) {
+	jobs.Register(jobs.Job{
+		Name:        heartbeat.JobHeldTasks,
+		Description: "Start held tasks and batches once their execution window opens or their panel recovers",
+		Interval:    heldDispatchInterval,
+		RunOnStart:  true,
+		Run: func() error {
+			return errors.Join(releaseHeldWork(db), resumeWaitingWork(db))
+		},
+	})
+}
+
+// resumeWaitingBatchesLimit bounds the panels pinged per run
+const resumeWaitingBatchesLimit = 100
+
+// resumeWaitingWork pings the panels of users with batches waiting on an
+// unreachable panel and starts the batches of those that answer. The uptime
+// monitor resumes them sooner when it is enabled.
+func resumeWaitingWork(db *gorm.DB) error {
+	var userIDs []int
+	if err := db.Model(&models.TaskBatch{}).Where("status = ?", BatchStatusWaitingOnPanel).
+		Distinct().Limit(resumeWaitingBatchesLimit).Pluck("user_id", &userIDs).Error; err != nil {
+		log.Printf("Failed to load batches waiting on the panel: %v", err)
+		return fmt.Errorf("error loading batches waiting on the panel: %v", err)
+	}
+
+	for _, userID := range userIDs {
+		apiClient, err := newClientForUser(db, userID)
+		if err != nil {
+			continue
+		}
+		if _, err := apiClient.Ping(); err != nil {
+			continue
+		}
+		resumeBatches(db, userID, BatchStatusWaitingOnPanel, apiClient)
+	}
+	return nil
+}
+
+// releaseHeldWork starts held tasks and batches of users whose window is open
+func releaseHeldWork(db *gorm.DB) error {
+	var userIDs []int
+	if err := db.Model(&models.AutomationTask{}).Where("status = ?", TaskStatusHeld).
+		Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		log.Printf("Failed to load held tasks: %v", err)
+		return fmt.Errorf("error loading held tasks: %v", err)
+	}
+	var batchUserIDs []int
+	if err := db.Model(&models.TaskBatch{}).Where("status = ?", BatchStatusHeld).
+		Distinct().Pluck("user_id", &batchUserIDs).Error; err != nil {
+		log.Printf("Failed to load held batches: %v", err)
+		return fmt.Errorf("error loading held batches: %v", err)
+	}
+
+	seen := make(map[int]bool)
+	for _, userID := range append(userIDs, batchUserIDs...) {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+
+		var settings models.UserSettings
+		if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+			continue
+		}
+		if open, _ := executionWindowOpen(db, settings); !open {
+			continue
+		}
+
+		apiClient := NewAPIClient(settings.WebsiteURL, settings.APIKey, settings.AuthUser)
+		releaseHeldTasks(db, userID, apiClient)
+		resumeBatches(db, userID, BatchStatusHeld, apiClient)
+	}
+	return nil
+}
+
+// releaseHeldTasks starts the user's individually created held tasks
+func releaseHeldTasks(db *gorm.DB, userID int, apiClient *APIClient) {
+	var tasks []models.AutomationTask
+	if err := db.Where("user_id = ? AND status = ? AND batch_id IS NULL", userID, TaskStatusHeld).
+		Order("id").
+		Find(&tasks).Error; err != nil {
+		log.Printf("Failed to load held tasks for user ID %d: %v", userID, err)
+		return
+	}
+
+	for _, task := range tasks {
+		var req TaskRequest
+		if err := json.Unmarshal(task.Request, &req); err != nil {
+			log.Printf("Invalid stored request for held task ID %d: %v", task.ID, err)
+			continue
+		}
+
+		// Claim the task so a concurrent dispatch cannot start it twice
+		result := db.Model(&models.AutomationTask{}).
+			Where("id = ? AND status = ?", task.ID, TaskStatusHeld).
+			Update("status", "pending")
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		log.Printf("Execution window open, starting held task ID %d", task.ID)
+		go executeTask(task.ID, req, apiClient)
+	}
+}
@@ -0,0 +1,384 @@
+package billing
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/middleware"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+// redeemLimiter slows down guessing of codes: a burst of 5, then one attempt every 6 seconds per IP
+var redeemLimiter = middleware.NewIPRateLimiter(rate.Every(6*time.Second), 5)
+
+// couponCodePattern restricts codes to what is easy to type and share
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{4,40}$`)
+
+// Redemption failures shown to the user
+var (
+	errCouponInvalid  = errors.New("Invalid or expired code")
+	errCouponUsedUp   = errors.New("This code has reached its redemption limit")
+	errCouponRedeemed = errors.New("You have already redeemed this code")
+	errCouponHasPlan  = errors.New("Your current plan cannot be replaced by this code")
+)
+
+// couponErrorMessage translates a redemption error for the request
+func couponErrorMessage(c *gin.Context, err error) string {
+	switch err {
+	case errCouponUsedUp:
+		return i18n.T(c, "This code has reached its redemption limit")
+	case errCouponRedeemed:
+		return i18n.T(c, "You have already redeemed this code")
+	case errCouponHasPlan:
+		return i18n.T(c, "Your current plan cannot be replaced by this code")
+	default:
+		return i18n.T(c, "Invalid or expired code")
+	}
+}
+
+// CouponRequest creates or replaces a coupon
+type CouponRequest struct {
+	Code           string     `json:"code" binding:"required"`
+	Description    string     `json:"description"`
+	Kind           string     `json:"kind" binding:"required"`
+	PlanID         *int       `json:"plan_id"`
+	PlanDays       int        `json:"plan_days"`
+	CreditAmount   float64    `json:"credit_amount"`
+	MaxRedemptions int        `json:"max_redemptions"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	Active         *bool      `json:"active"`
+}
+
+type RedeemRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// normalizeCode makes codes case-insensitive
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// applyCouponRequest binds and validates a coupon request into coupon, responding on failure
+func applyCouponRequest(c *gin.Context, db *gorm.DB, coupon *models.Coupon) bool {
+	var req CouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	code := normalizeCode(req.Code)
+	if !couponCodePattern.MatchString(code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Code must be 4-40 letters, digits, dashes or underscores")})
+		return false
+	}
+	if req.MaxRedemptions < 0 || req.PlanDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Limits cannot be negative")})
+		return false
+	}
+
+	switch req.Kind {
+	case models.CouponKindPlan:
+		if req.PlanID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Plan coupons need a plan_id")})
+			return false
+		}
+		if err := db.First(&models.Plan{}, *req.PlanID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Plan not found")})
+			return false
+		}
+		req.CreditAmount = 0
+	case models.CouponKindCredit:
+		if req.CreditAmount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Credit coupons need a positive credit_amount")})
+			return false
+		}
+		req.PlanID = nil
+		req.PlanDays = 0
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Kind must be plan or credit")})
+		return false
+	}
+
+	var count int64
+	if err := db.Model(&models.Coupon{}).Where("code = ? AND id <> ?", code, coupon.ID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return false
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "A coupon with this code already exists")})
+		return false
+	}
+
+	coupon.Code = code
+	coupon.Description = req.Description
+	coupon.Kind = req.Kind
+	coupon.PlanID = req.PlanID
+	coupon.PlanDays = req.PlanDays
+	coupon.CreditAmount = req.CreditAmount
+	coupon.MaxRedemptions = req.MaxRedemptions
+	coupon.ExpiresAt = req.ExpiresAt
+	coupon.Active = req.Active == nil || *req.Active
+	return true
+}
+
+// findCoupon loads a coupon by the :id URL parameter, responding on failure
+func findCoupon(c *gin.Context, db *gorm.DB) (models.Coupon, bool) {
+	var coupon models.Coupon
+	if err := db.First(&coupon, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Coupon not found")})
+			return coupon, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return coupon, false
+	}
+	return coupon, true
+}
+
+// GetCoupons lists all coupons, newest first (admin only)
+func GetCoupons(c *gin.Context) {
+	var coupons []models.Coupon
+	if err := database.GetDB().Order("id DESC").Find(&coupons).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	utils.RespondList(c, coupons, int64(len(coupons)), "")
+}
+
+// CreateCoupon creates a coupon (admin only)
+func CreateCoupon(c *gin.Context) {
+	db := database.GetDB()
+
+	coupon := models.Coupon{}
+	if !applyCouponRequest(c, db, &coupon) {
+		return
+	}
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			coupon.CreatedBy = &u.ID
+		}
+	}
+
+	if err := db.Create(&coupon).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionCouponCreated, "coupon", coupon.ID, map[string]interface{}{"coupon": coupon})
+
+	c.JSON(http.StatusCreated, coupon)
+}
+
+// UpdateCoupon replaces a coupon; redemptions made so far are kept (admin only)
+func UpdateCoupon(c *gin.Context) {
+	db := database.GetDB()
+
+	coupon, ok := findCoupon(c, db)
+	if !ok {
+		return
+	}
+	if !applyCouponRequest(c, db, &coupon) {
+		return
+	}
+
+	if err := db.Save(&coupon).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionCouponUpdated, "coupon", coupon.ID, map[string]interface{}{"coupon": coupon})
+
+	c.JSON(http.StatusOK, coupon)
+}
+
+// GetCouponRedemptions lists who redeemed a coupon and what they received (admin only)
+func GetCouponRedemptions(c *gin.Context) {
+	db := database.GetDB()
+
+	coupon, ok := findCoupon(c, db)
+	if !ok {
+		return
+	}
+
+	var redemptions []struct {
+		models.CouponRedemption
+		Username string `json:"username"`
+	}
+	if err := db.Model(&models.CouponRedemption{}).
+		Select("coupon_redemptions.*, users.username").
+		Joins("LEFT JOIN users ON users.id = coupon_redemptions.user_id").
+		Where("coupon_redemptions.coupon_id = ?", coupon.ID).
+		Order("coupon_redemptions.id DESC").
+		Scan(&redemptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	utils.RespondList(c, redemptions, int64(len(redemptions)), "")
+}
+
+// redeem applies the coupon to the user inside tx and returns the stored redemption
+func redeem(tx *gorm.DB, coupon models.Coupon, user models.User, ip string) (models.CouponRedemption, error) {
+	redemption := models.CouponRedemption{
+		CouponID:  coupon.ID,
+		UserID:    user.ID,
+		IPAddress: ip,
+	}
+
+	var count int64
+	if err := tx.Model(&models.CouponRedemption{}).
+		Where("coupon_id = ? AND user_id = ?", coupon.ID, user.ID).
+		Count(&count).Error; err != nil {
+		return redemption, err
+	}
+	if count > 0 {
+		return redemption, errCouponRedeemed
+	}
+
+	// Claim a redemption slot atomically so concurrent redemptions cannot exceed the limit
+	result := tx.Model(&models.Coupon{}).
+		Where("id = ? AND (max_redemptions = 0 OR redemptions < max_redemptions)", coupon.ID).
+		Update("redemptions", gorm.Expr("redemptions + 1"))
+	if result.Error != nil {
+		return redemption, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return redemption, errCouponUsedUp
+	}
+
+	switch coupon.Kind {
+	case models.CouponKindPlan:
+		now := time.Now()
+		hasPlan := user.PlanID != nil && (user.PlanExpiresAt == nil || user.PlanExpiresAt.After(now))
+		samePlan := hasPlan && *user.PlanID == *coupon.PlanID
+
+		var expiresAt *time.Time
+		switch {
+		case coupon.PlanDays == 0:
+			// A permanent grant replaces any plan
+		case samePlan && user.PlanExpiresAt == nil:
+			// The user already has this plan permanently
+			return redemption, errCouponHasPlan
+		case samePlan:
+			// Extend the running grant of the same plan
+			end := user.PlanExpiresAt.AddDate(0, 0, coupon.PlanDays)
+			expiresAt = &end
+		case hasPlan:
+			// A trial must not cut short a plan the user already has
+			return redemption, errCouponHasPlan
+		default:
+			end := now.AddDate(0, 0, coupon.PlanDays)
+			expiresAt = &end
+		}
+
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"plan_id":         *coupon.PlanID,
+			"plan_expires_at": expiresAt,
+		}).Error; err != nil {
+			return redemption, err
+		}
+		redemption.PlanID = coupon.PlanID
+		redemption.PlanExpiresAt = expiresAt
+
+	case models.CouponKindCredit:
+		if err := tx.Create(&models.Transaction{
+			UserID: user.ID,
+			Type:   models.TransactionTopUp,
+			Amount: coupon.CreditAmount,
+			Note:   "Coupon " + coupon.Code,
+		}).Error; err != nil {
+			return redemption, err
+		}
+		redemption.CreditAmount = coupon.CreditAmount
+	}
+
+	return redemption, tx.Create(&redemption).Error
+}
+
+// RedeemCoupon redeems a code for the current user
+func RedeemCoupon(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	var req RedeemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var coupon models.Coupon
+	err := db.Where("code = ? AND active = ?", normalizeCode(req.Code), true).First(&coupon).Error
+	if err == nil && coupon.ExpiresAt != nil && coupon.ExpiresAt.Before(time.Now()) {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			audit.Record(c, audit.ActionCouponRejected, "coupon", nil, map[string]interface{}{
+				"code":   normalizeCode(req.Code),
+				"reason": errCouponInvalid.Error(),
+			})
+			c.JSON(http.StatusBadRequest, gin.H{"error": couponErrorMessage(c, errCouponInvalid)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var redemption models.CouponRedemption
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		redemption, err = redeem(tx, coupon, u, c.ClientIP())
+		return err
+	})
+	if err != nil {
+		switch err {
+		case errCouponUsedUp, errCouponRedeemed, errCouponHasPlan:
+			audit.Record(c, audit.ActionCouponRejected, "coupon", coupon.ID, map[string]interface{}{
+				"code":   coupon.Code,
+				"reason": err.Error(),
+			})
+			c.JSON(http.StatusConflict, gin.H{"error": couponErrorMessage(c, err)})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		}
+		return
+	}
+
+	audit.Record(c, audit.ActionCouponRedeemed, "coupon", coupon.ID, map[string]interface{}{
+		"code":            coupon.Code,
+		"kind":            coupon.Kind,
+		"plan_id":         redemption.PlanID,
+		"plan_expires_at": redemption.PlanExpiresAt,
+		"credit_amount":   redemption.CreditAmount,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"kind":            coupon.Kind,
+		"plan_id":         redemption.PlanID,
+		"plan_expires_at": redemption.PlanExpiresAt,
+		"credit_amount":   redemption.CreditAmount,
+		"message":         i18n.T(c, "Code redeemed"),
+	})
+}
@@ -0,0 +1,224 @@
+package billing
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxOverviewDays limits the period of the revenue overview
+const maxOverviewDays = 366
+
+// monthlyFactor converts a price per billing interval to a price per month
+var monthlyFactor = map[string]float64{
+	"day":   365.0 / 12,
+	"week":  52.0 / 12,
+	"month": 1,
+	"year":  1.0 / 12,
+}
+
+// planShare is one plan's slice of the user base and recurring revenue
+type planShare struct {
+	PlanID *int   `json:"plan_id"`
+	Name   string `json:"name"`
+	// Users counts users the plan applies to, by assignment or as the default
+	Users         int64            `json:"users"`
+	Subscriptions int64            `json:"subscriptions"`
+	MRRCents      map[string]int64 `json:"mrr_cents"`
+}
+
+// mrrCents returns the monthly recurring revenue of a subscription in cents
+func mrrCents(sub models.Subscription) int64 {
+	factor, ok := monthlyFactor[sub.Interval]
+	if !ok {
+		return 0
+	}
+	return int64(math.Round(float64(sub.AmountCents) * factor))
+}
+
+// planDistribution counts the users each plan applies to. Users without an
+// assigned plan, or whose time-limited plan expired, fall to the default plan.
+func planDistribution(db *gorm.DB, plans []models.Plan) (map[int]int64, int64, error) {
+	var rows []struct {
+		PlanID int
+		Users  int64
+	}
+	if err := db.Model(&models.User{}).
+		Select("plan_id, COUNT(*) AS users").
+		Where("plan_id IS NOT NULL AND (plan_expires_at IS NULL OR plan_expires_at > ?)", time.Now()).
+		Group("plan_id").
+		Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := db.Model(&models.User{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var defaultID *int
+	for i := range plans {
+		if plans[i].IsDefault {
+			defaultID = &plans[i].ID
+		}
+	}
+
+	users := make(map[int]int64, len(rows)+1)
+	unplanned := total
+	for _, row := range rows {
+		users[row.PlanID] += row.Users
+		unplanned -= row.Users
+	}
+	if defaultID != nil {
+		users[*defaultID] += unplanned
+		unplanned = 0
+	}
+	return users, unplanned, nil
+}
+
+// GetBillingOverview aggregates subscriptions, recurring revenue, plan
+// distribution and credit top-ups (admin only)
+func GetBillingOverview(c *gin.Context) {
+	days := 30
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxOverviewDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 366"})
+			return
+		}
+		days = n
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	db := database.GetReadDB()
+
+	var plans []models.Plan
+	if err := db.Order("id").Find(&plans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	users, unplanned, err := planDistribution(db, plans)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var subscriptions []models.Subscription
+	if err := db.Find(&subscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	shares := make(map[int]*planShare, len(plans))
+	distribution := make([]*planShare, 0, len(plans)+1)
+	for i := range plans {
+		share := &planShare{
+			PlanID:   &plans[i].ID,
+			Name:     plans[i].Name,
+			Users:    users[plans[i].ID],
+			MRRCents: map[string]int64{},
+		}
+		shares[plans[i].ID] = share
+		distribution = append(distribution, share)
+	}
+	if unplanned > 0 {
+		distribution = append(distribution, &planShare{Users: unplanned, MRRCents: map[string]int64{}})
+	}
+
+	var (
+		active      int64
+		newInPeriod int64
+		churned     int64
+		mrr         = map[string]int64{}
+		byStatus    = map[string]int64{}
+		cancelAtEnd int64
+	)
+	for _, sub := range subscriptions {
+		byStatus[sub.Status]++
+		if sub.CreatedAt.After(since) {
+			newInPeriod++
+		}
+		if !planKeepingStatuses[sub.Status] {
+			if sub.UpdatedAt.After(since) {
+				churned++
+			}
+			continue
+		}
+
+		active++
+		if sub.CancelAtPeriodEnd {
+			cancelAtEnd++
+		}
+		amount := mrrCents(sub)
+		mrr[sub.Currency] += amount
+		if sub.PlanID != nil {
+			if share, ok := shares[*sub.PlanID]; ok {
+				share.Subscriptions++
+				share.MRRCents[sub.Currency] += amount
+			}
+		}
+	}
+
+	var topups struct {
+		Count int64
+		Total float64
+	}
+	if err := db.Model(&models.Transaction{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS total").
+		Where("type = ? AND created_at >= ?", models.TransactionTopUp, since).
+		Scan(&topups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var fromCoupons float64
+	if err := db.Model(&models.CouponRedemption{}).
+		Select("COALESCE(SUM(credit_amount), 0)").
+		Where("created_at >= ?", since).
+		Scan(&fromCoupons).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var topupUsers int64
+	if err := db.Model(&models.Transaction{}).
+		Where("type = ? AND created_at >= ?", models.TransactionTopUp, since).
+		Distinct("user_id").
+		Count(&topupUsers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	sort.SliceStable(distribution, func(i, j int) bool {
+		return distribution[i].Users > distribution[j].Users
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"days":              days,
+		"stripe_enabled":    Enabled(),
+		"mrr_cents":         mrr,
+		"plan_distribution": distribution,
+		"subscriptions": gin.H{
+			"active":               active,
+			"cancel_at_period_end": cancelAtEnd,
+			"new":                  newInPeriod,
+			"churned":              churned,
+			"by_status":            byStatus,
+		},
+		"topups": gin.H{
+			"count":        topups.Count,
+			"users":        topupUsers,
+			"total":        topups.Total,
+			"from_coupons": fromCoupons,
+		},
+	})
+}
@@ -0,0 +1,301 @@
+package billing
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PlanRequest creates or replaces a plan; limits of 0 mean unlimited
+type PlanRequest struct {
+	Name                    string `json:"name" binding:"required"`
+	Description             string `json:"description"`
+	IsDefault               bool   `json:"is_default"`
+	StripePriceID           string `json:"stripe_price_id"`
+	TasksPerDay             int    `json:"tasks_per_day"`
+	MaxBatchSize            int    `json:"max_batch_size"`
+	MaxRenewalRules         int    `json:"max_renewal_rules"`
+	ResultStorageMB         int    `json:"result_storage_mb"`
+	WebhookDeliveriesPerDay int    `json:"webhook_deliveries_per_day"`
+	MaxPanelProfiles        int    `json:"max_panel_profiles"`
+}
+
+type AssignPlanRequest struct {
+	// PlanID is the plan to assign; null returns the user to the default plan
+	PlanID *int `json:"plan_id"`
+}
+
+// applyPlanRequest binds and validates a plan request into plan, responding on failure
+func applyPlanRequest(c *gin.Context, plan *models.Plan) bool {
+	var req PlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Plan name is required")})
+		return false
+	}
+	if req.TasksPerDay < 0 || req.MaxBatchSize < 0 || req.MaxRenewalRules < 0 ||
+		req.ResultStorageMB < 0 || req.WebhookDeliveriesPerDay < 0 || req.MaxPanelProfiles < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Plan limits cannot be negative")})
+		return false
+	}
+
+	plan.Name = req.Name
+	plan.Description = req.Description
+	plan.IsDefault = req.IsDefault
+	plan.StripePriceID = strings.TrimSpace(req.StripePriceID)
+	plan.TasksPerDay = req.TasksPerDay
+	plan.MaxBatchSize = req.MaxBatchSize
+	plan.MaxRenewalRules = req.MaxRenewalRules
+	plan.ResultStorageMB = req.ResultStorageMB
+	plan.WebhookDeliveriesPerDay = req.WebhookDeliveriesPerDay
+	plan.MaxPanelProfiles = req.MaxPanelProfiles
+	return true
+}
+
+// savePlan stores the plan, making it the only default plan if requested
+func savePlan(db *gorm.DB, plan *models.Plan) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if plan.IsDefault {
+			if err := tx.Model(&models.Plan{}).Where("id <> ?", plan.ID).Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(plan).Error
+	})
+}
+
+// planNameTaken reports whether another plan already uses the name
+func planNameTaken(db *gorm.DB, name string, exceptID int) (bool, error) {
+	var count int64
+	err := db.Model(&models.Plan{}).Where("name = ? AND id <> ?", name, exceptID).Count(&count).Error
+	return count > 0, err
+}
+
+// GetPlans lists all plans with the number of users assigned to each (admin only)
+func GetPlans(c *gin.Context) {
+	db := database.GetDB()
+
+	var plans []models.Plan
+	if err := db.Order("id").Find(&plans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var counts []struct {
+		PlanID int
+		Users  int64
+	}
+	if err := db.Model(&models.User{}).
+		Select("plan_id, COUNT(*) AS users").
+		Where("plan_id IS NOT NULL").
+		Group("plan_id").
+		Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	users := make(map[int]int64, len(counts))
+	for _, row := range counts {
+		users[row.PlanID] = row.Users
+	}
+
+	response := []gin.H{}
+	for _, plan := range plans {
+		response = append(response, gin.H{
+			"plan":  plan,
+			"users": users[plan.ID],
+		})
+	}
+
+	utils.RespondList(c, response, int64(len(response)), "")
+}
+
+// CreatePlan creates a plan (admin only)
+func CreatePlan(c *gin.Context) {
+	var plan models.Plan
+	if !applyPlanRequest(c, &plan) {
+		return
+	}
+
+	db := database.GetDB()
+	if taken, err := planNameTaken(db, plan.Name, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	} else if taken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "A plan with this name already exists")})
+		return
+	}
+
+	if err := savePlan(db, &plan); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionPlanCreated, "plan", plan.ID, map[string]interface{}{"plan": plan})
+
+	c.JSON(http.StatusCreated, plan)
+}
+
+// UpdatePlan replaces a plan's name and limits (admin only)
+func UpdatePlan(c *gin.Context) {
+	db := database.GetDB()
+
+	var plan models.Plan
+	if err := db.First(&plan, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Plan not found")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	if !applyPlanRequest(c, &plan) {
+		return
+	}
+
+	if taken, err := planNameTaken(db, plan.Name, plan.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	} else if taken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "A plan with this name already exists")})
+		return
+	}
+
+	if err := savePlan(db, &plan); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionPlanUpdated, "plan", plan.ID, map[string]interface{}{"plan": plan})
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// DeletePlan deletes a plan that no user is assigned to (admin only)
+func DeletePlan(c *gin.Context) {
+	db := database.GetDB()
+
+	var plan models.Plan
+	if err := db.First(&plan, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Plan not found")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var assigned int64
+	if err := db.Model(&models.User{}).Where("plan_id = ?", plan.ID).Count(&assigned).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if assigned > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": i18n.T(c, "Plan is assigned to %d users", assigned),
+			"users": assigned,
+		})
+		return
+	}
+
+	if err := db.Delete(&plan).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionPlanDeleted, "plan", plan.ID, map[string]interface{}{"name": plan.Name})
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Plan deleted")})
+}
+
+// AssignPlan assigns a plan to a user and their sub-accounts, or clears it so
+// the default plan applies (admin only)
+func AssignPlan(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req AssignPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "User not found")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if user.ParentID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Sub-accounts use the plan of their reseller account")})
+		return
+	}
+
+	planName := ""
+	if req.PlanID != nil {
+		var plan models.Plan
+		if err := db.First(&plan, *req.PlanID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Plan not found")})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+			return
+		}
+		planName = plan.Name
+	}
+
+	if err := db.Model(&user).Updates(map[string]interface{}{
+		"plan_id":         req.PlanID,
+		"plan_expires_at": nil,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	audit.Record(c, audit.ActionPlanAssigned, "user", user.ID, map[string]interface{}{
+		"plan_id": req.PlanID,
+		"plan":    planName,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      user.ID,
+		"plan_id": req.PlanID,
+		"message": i18n.T(c, "User updated successfully"),
+	})
+}
+
+// SetupAdminRoutes sets up the billing routes for admins
+func SetupAdminRoutes(router *gin.RouterGroup) {
+	router.GET("/plans", GetPlans)
+	router.POST("/plans", CreatePlan)
+	router.PUT("/plans/:id", UpdatePlan)
+	router.DELETE("/plans/:id", DeletePlan)
+	router.PUT("/users/:id/plan", AssignPlan)
+	router.GET("/coupons", GetCoupons)
+	router.POST("/coupons", CreateCoupon)
+	router.PUT("/coupons/:id", UpdateCoupon)
+	router.GET("/coupons/:id/redemptions", GetCouponRedemptions)
+	router.GET("/billing/overview", GetBillingOverview)
+}
@@ -0,0 +1,178 @@
+package billing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+)
+
+const (
+	// stripeAPIBase is the Stripe REST API
+	stripeAPIBase = "https://api.stripe.com/v1"
+	// stripeSignatureTolerance is how old a signed webhook may be before it is rejected as a replay
+	stripeSignatureTolerance = 5 * time.Minute
+)
+
+var stripeClient = &http.Client{Timeout: 15 * time.Second}
+
+// Enabled reports whether Stripe billing is configured
+func Enabled() bool {
+	return config.Get().StripeSecretKey != ""
+}
+
+// stripeEvent is the envelope of a Stripe webhook event
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Created is when the event happened, in Unix seconds; Stripe does not
+	// deliver events in order
+	Created int64 `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// checkoutSession is the part of a Stripe Checkout Session used here
+type checkoutSession struct {
+	ID                string            `json:"id"`
+	URL               string            `json:"url"`
+	ClientReferenceID string            `json:"client_reference_id"`
+	Customer          string            `json:"customer"`
+	Subscription      string            `json:"subscription"`
+	PaymentStatus     string            `json:"payment_status"`
+	Metadata          map[string]string `json:"metadata"`
+}
+
+// stripePrice is the part of a Stripe price used here
+type stripePrice struct {
+	ID         string `json:"id"`
+	UnitAmount int64  `json:"unit_amount"`
+	Currency   string `json:"currency"`
+	Recurring  struct {
+		Interval string `json:"interval"`
+	} `json:"recurring"`
+}
+
+// stripeSubscription is the part of a Stripe subscription used here
+type stripeSubscription struct {
+	ID                string            `json:"id"`
+	Customer          string            `json:"customer"`
+	Status            string            `json:"status"`
+	CancelAtPeriodEnd bool              `json:"cancel_at_period_end"`
+	CurrentPeriodEnd  int64             `json:"current_period_end"`
+	Metadata          map[string]string `json:"metadata"`
+	Items             struct {
+		Data []struct {
+			// Newer API versions report the period per item
+			CurrentPeriodEnd int64       `json:"current_period_end"`
+			Price            stripePrice `json:"price"`
+		} `json:"data"`
+	} `json:"items"`
+}
+
+// price returns the subscription's first price
+func (s stripeSubscription) price() stripePrice {
+	if len(s.Items.Data) == 0 {
+		return stripePrice{}
+	}
+	return s.Items.Data[0].Price
+}
+
+// periodEnd returns when the current paid period ends, or nil if unknown
+func (s stripeSubscription) periodEnd() *time.Time {
+	end := s.CurrentPeriodEnd
+	if end == 0 && len(s.Items.Data) > 0 {
+		end = s.Items.Data[0].CurrentPeriodEnd
+	}
+	if end == 0 {
+		return nil
+	}
+	t := time.Unix(end, 0)
+	return &t
+}
+
+// stripePost sends a form-encoded POST to the Stripe API and decodes the response into out
+func stripePost(path string, form url.Values, out interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, stripeAPIBase+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(config.Get().StripeSecretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := stripeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("stripe: %s", apiErr.Error.Message)
+		}
+		return fmt.Errorf("stripe: unexpected status %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// verifyStripeSignature checks the Stripe-Signature header of a webhook payload
+func verifyStripeSignature(payload []byte, header, secret string) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return errors.New("malformed signature header")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("malformed signature timestamp")
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > stripeSignatureTolerance || age < -stripeSignatureTolerance {
+		return errors.New("signature timestamp outside tolerance")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	for _, sig := range signatures {
+		decoded, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return errors.New("signature mismatch")
+}
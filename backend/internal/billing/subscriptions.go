@@ -0,0 +1,459 @@
+package billing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/heartbeat"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/middleware"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/notify"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxWebhookBytes limits the size of a Stripe webhook payload
+const maxWebhookBytes = 1 << 20
+
+// planKeepingStatuses are subscription statuses under which the user keeps the paid
+// plan. past_due keeps it while Stripe retries the payment.
+var planKeepingStatuses = map[string]bool{
+	models.SubscriptionActive:   true,
+	models.SubscriptionTrialing: true,
+	models.SubscriptionPastDue:  true,
+}
+
+type CheckoutRequest struct {
+	PlanID int `json:"plan_id" binding:"required"`
+}
+
+// CreateCheckoutSession starts a Stripe Checkout for subscribing the current user to a plan
+func CreateCheckoutSession(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	cfg := config.Get()
+	if !Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Billing is not enabled")})
+		return
+	}
+	if cfg.StripeSuccessURL == "" || cfg.StripeCancelURL == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": i18n.T(c, "Billing is not configured")})
+		return
+	}
+
+	var req CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+
+	var plan models.Plan
+	if err := db.First(&plan, req.PlanID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Plan not found")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if plan.StripePriceID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "This plan cannot be purchased")})
+		return
+	}
+
+	userID := strconv.Itoa(u.ID)
+	planID := strconv.Itoa(plan.ID)
+	form := url.Values{
+		"mode":                                 {"subscription"},
+		"line_items[0][price]":                 {plan.StripePriceID},
+		"line_items[0][quantity]":              {"1"},
+		"success_url":                          {cfg.StripeSuccessURL},
+		"cancel_url":                           {cfg.StripeCancelURL},
+		"client_reference_id":                  {userID},
+		"metadata[user_id]":                    {userID},
+		"metadata[plan_id]":                    {planID},
+		"subscription_data[metadata][user_id]": {userID},
+		"subscription_data[metadata][plan_id]": {planID},
+	}
+
+	// Reuse the Stripe customer of an earlier subscription
+	var existing models.Subscription
+	if err := db.Where("user_id = ?", u.ID).First(&existing).Error; err == nil && existing.StripeCustomerID != "" {
+		form.Set("customer", existing.StripeCustomerID)
+	}
+
+	var session checkoutSession
+	if err := stripePost("/checkout/sessions", form, &session); err != nil {
+		log.Printf("Failed to create Stripe checkout session for user ID %d: %v", u.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": i18n.T(c, "Failed to start checkout")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":  session.ID,
+		"url": session.URL,
+	})
+}
+
+// GetSubscription returns the current user's subscription, if any
+func GetSubscription(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	var subscription models.Subscription
+	if err := database.GetDB().Where("user_id = ?", u.ID).First(&subscription).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusOK, gin.H{"subscription": nil, "billing_enabled": Enabled()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"subscription": subscription, "billing_enabled": Enabled()})
+}
+
+// StripeWebhook receives subscription events from Stripe. Requests are
+// authenticated by the Stripe-Signature header, not by a user token.
+func StripeWebhook(c *gin.Context) {
+	secret := config.Get().StripeWebhookSecret
+	if !Enabled() || secret == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Billing is not enabled"})
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read payload"})
+		return
+	}
+	if err := verifyStripeSignature(payload, c.GetHeader("Stripe-Signature"), secret); err != nil {
+		log.Printf("Rejected Stripe webhook: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	var event stripeEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+		return
+	}
+
+	db := database.GetDB()
+	eventAt := time.Unix(event.Created, 0)
+	switch event.Type {
+	case "checkout.session.completed":
+		var session checkoutSession
+		if err := json.Unmarshal(event.Data.Object, &session); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+			return
+		}
+		err = handleCheckoutCompleted(db, session, eventAt)
+
+	case "customer.subscription.created", "customer.subscription.updated", "customer.subscription.deleted":
+		var sub stripeSubscription
+		if err := json.Unmarshal(event.Data.Object, &sub); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload"})
+			return
+		}
+		if event.Type == "customer.subscription.deleted" {
+			sub.Status = models.SubscriptionCanceled
+		}
+		err = handleSubscriptionChange(db, sub, eventAt)
+
+	default:
+		// Other events are acknowledged so Stripe does not retry them
+	}
+
+	if err != nil {
+		// A non-2xx response makes Stripe retry the event later
+		log.Printf("Failed to process Stripe event %s (%s): %v", event.ID, event.Type, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process event"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
+
+// metadataInt reads an integer from Stripe metadata
+func metadataInt(metadata map[string]string, key string) (int, bool) {
+	n, err := strconv.Atoi(metadata[key])
+	return n, err == nil && n > 0
+}
+
+// staleEvent reports whether an event is older than the newest one applied to
+// the subscription, or concerns the subscription after it was canceled, which
+// is final in Stripe. Such events must not grant the plan again.
+func staleEvent(subscription models.Subscription, stripeSubscriptionID string, eventAt time.Time) bool {
+	if subscription.StripeEventAt != nil && eventAt.Before(*subscription.StripeEventAt) {
+		return true
+	}
+	return subscription.Status == models.SubscriptionCanceled && subscription.StripeSubscriptionID == stripeSubscriptionID
+}
+
+// handleCheckoutCompleted links the paid checkout to the user and grants the plan
+func handleCheckoutCompleted(db *gorm.DB, session checkoutSession, eventAt time.Time) error {
+	userID, ok := metadataInt(session.Metadata, "user_id")
+	if !ok {
+		if id, err := strconv.Atoi(session.ClientReferenceID); err == nil && id > 0 {
+			userID, ok = id, true
+		}
+	}
+	planID, hasPlan := metadataInt(session.Metadata, "plan_id")
+	if !ok || !hasPlan {
+		log.Printf("Ignoring Stripe checkout session %s without user or plan metadata", session.ID)
+		return nil
+	}
+	// Unpaid checkouts, e.g. with a delayed payment method, are granted by the
+	// subscription's events once it becomes active
+	if session.PaymentStatus != "paid" {
+		log.Printf("Ignoring Stripe checkout session %s with payment status %q", session.ID, session.PaymentStatus)
+		return nil
+	}
+
+	subscription, err := subscriptionForUser(db, userID)
+	if err != nil {
+		return err
+	}
+	if staleEvent(subscription, session.Subscription, eventAt) {
+		log.Printf("Ignoring outdated Stripe checkout session %s for user ID %d", session.ID, userID)
+		return nil
+	}
+	subscription.PlanID = &planID
+	subscription.StripeCustomerID = session.Customer
+	subscription.StripeSubscriptionID = session.Subscription
+	subscription.Status = models.SubscriptionActive
+	subscription.StripeEventAt = &eventAt
+	if err := db.Save(&subscription).Error; err != nil {
+		return err
+	}
+
+	return applyPlan(db, subscription)
+}
+
+// handleSubscriptionChange stores the subscription's new state and grants or removes the plan
+func handleSubscriptionChange(db *gorm.DB, sub stripeSubscription, eventAt time.Time) error {
+	var subscription models.Subscription
+	err := db.Where("stripe_subscription_id = ?", sub.ID).First(&subscription).Error
+	if err == gorm.ErrRecordNotFound {
+		userID, ok := metadataInt(sub.Metadata, "user_id")
+		if !ok {
+			log.Printf("Ignoring Stripe subscription %s without a known user", sub.ID)
+			return nil
+		}
+		subscription, err = subscriptionForUser(db, userID)
+	}
+	if err != nil {
+		return err
+	}
+	if staleEvent(subscription, sub.ID, eventAt) {
+		log.Printf("Ignoring outdated Stripe event for subscription %s (%s)", sub.ID, sub.Status)
+		return nil
+	}
+
+	price := sub.price()
+	subscription.StripeSubscriptionID = sub.ID
+	subscription.StripeCustomerID = sub.Customer
+	subscription.Status = sub.Status
+	subscription.CancelAtPeriodEnd = sub.CancelAtPeriodEnd
+	subscription.CurrentPeriodEnd = sub.periodEnd()
+	subscription.StripeEventAt = &eventAt
+	if price.ID != "" {
+		subscription.StripePriceID = price.ID
+		subscription.AmountCents = price.UnitAmount
+		subscription.Currency = price.Currency
+		subscription.Interval = price.Recurring.Interval
+
+		// The price decides the plan, so plan changes made in Stripe are followed
+		var plan models.Plan
+		if err := db.Where("stripe_price_id = ?", price.ID).First(&plan).Error; err == nil {
+			subscription.PlanID = &plan.ID
+		}
+	}
+
+	if err := db.Save(&subscription).Error; err != nil {
+		return err
+	}
+	return applyPlan(db, subscription)
+}
+
+// subscriptionForUser returns the user's subscription row, unsaved if it does not exist yet
+func subscriptionForUser(db *gorm.DB, userID int) (models.Subscription, error) {
+	subscription := models.Subscription{UserID: userID}
+	err := db.Where("user_id = ?", userID).First(&subscription).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return subscription, err
+	}
+	return subscription, nil
+}
+
+// applyPlan grants the subscription's plan while it is paid for and downgrades the
+// user to the default plan otherwise
+func applyPlan(db *gorm.DB, subscription models.Subscription) error {
+	if subscription.PlanID == nil {
+		return nil
+	}
+
+	var user models.User
+	if err := db.First(&user, subscription.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if planKeepingStatuses[subscription.Status] {
+		if user.PlanID != nil && *user.PlanID == *subscription.PlanID && user.PlanExpiresAt == nil {
+			return nil
+		}
+		if err := db.Model(&user).Updates(map[string]interface{}{
+			"plan_id":         *subscription.PlanID,
+			"plan_expires_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		audit.RecordSystem(audit.ActionPlanAssigned, "user", user.ID, map[string]interface{}{
+			"plan_id": *subscription.PlanID,
+			"source":  "stripe",
+			"status":  subscription.Status,
+		})
+		return nil
+	}
+
+	return downgrade(db, user, subscription)
+}
+
+// downgrade moves the user back to the default plan, unless an admin has meanwhile
+// assigned a plan other than the subscribed one
+func downgrade(db *gorm.DB, user models.User, subscription models.Subscription) error {
+	if user.PlanID == nil || subscription.PlanID == nil || *user.PlanID != *subscription.PlanID {
+		return nil
+	}
+
+	if err := db.Model(&user).Update("plan_id", nil).Error; err != nil {
+		return err
+	}
+	audit.RecordSystem(audit.ActionPlanAssigned, "user", user.ID, map[string]interface{}{
+		"plan_id": nil,
+		"source":  "stripe",
+		"status":  subscription.Status,
+	})
+
+	var plan models.Plan
+	planName := ""
+	if err := db.First(&plan, *subscription.PlanID).Error; err == nil {
+		planName = plan.Name
+	}
+	go notify.Notify(user.ID, notify.Event{
+		Kind:  notify.KindPlanDowngraded,
+		Title: func(locale string) string { return i18n.Translate(locale, "Plan downgraded") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "Your %s subscription has ended and your account was moved to the default plan", planName)
+		},
+		Data: map[string]interface{}{
+			"plan_id": *subscription.PlanID,
+			"status":  subscription.Status,
+		},
+	})
+	return nil
+}
+
+// sweepLapsedSubscriptions downgrades users whose paid period ended more than the
+// grace period ago, in case the Stripe events announcing it were missed
+func sweepLapsedSubscriptions(db *gorm.DB) error {
+	cutoff := time.Now().AddDate(0, 0, -config.Get().StripeGraceDays)
+
+	keeping := make([]string, 0, len(planKeepingStatuses))
+	for status := range planKeepingStatuses {
+		keeping = append(keeping, status)
+	}
+
+	var subscriptions []models.Subscription
+	if err := db.Where("status IN ? AND current_period_end IS NOT NULL AND current_period_end < ?",
+		keeping, cutoff).Find(&subscriptions).Error; err != nil {
+		log.Printf("Subscription sweep: failed to load subscriptions: %v", err)
+		return fmt.Errorf("error loading subscriptions: %v", err)
+	}
+
+	failed := 0
+	for _, subscription := range subscriptions {
+		subscription.Status = models.SubscriptionLapsed
+		if err := db.Model(&subscription).Update("status", subscription.Status).Error; err != nil {
+			log.Printf("Subscription sweep: failed to update subscription ID %d: %v", subscription.ID, err)
+			failed++
+			continue
+		}
+		if err := applyPlan(db, subscription); err != nil {
+			log.Printf("Subscription sweep: failed to downgrade user ID %d: %v", subscription.UserID, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d lapsed subscriptions could not be downgraded", failed)
+	}
+	return nil
+}
+
+// StartSubscriptionSweeper checks for lapsed subscriptions every hour when Stripe billing is enabled
+func StartSubscriptionSweeper(db *gorm.DB) {
+	if !Enabled() {
+		return
+	}
+
+	jobs.Register(jobs.Job{
+		Name:        heartbeat.JobSubscriptions,
+		Description: "Downgrade users whose subscription lapsed past the grace period",
+		Interval:    time.Hour,
+		RunOnStart:  true,
+		Run: func() error {
+			return sweepLapsedSubscriptions(db)
+		},
+	})
+
+	log.Println("Stripe subscription sweeper started")
+}
+
+// SetupRoutes sets up the public billing routes
+func SetupRoutes(router *gin.RouterGroup) {
+	router.POST("/stripe/webhook", StripeWebhook)
+}
+
+// SetupProtectedRoutes sets up the billing routes for authenticated users
+func SetupProtectedRoutes(router *gin.RouterGroup) {
+	router.GET("/subscription", GetSubscription)
+	router.POST("/checkout", CreateCheckoutSession)
+	router.POST("/redeem", middleware.RateLimiterMiddleware(redeemLimiter), RedeemCoupon)
+}
@@ -0,0 +1,97 @@
+package branding
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/settings"
+	"gorm.io/gorm"
+)
+
+// accentColorPattern accepts hex colors like #1a73e8
+var accentColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Branding is the white-label configuration used by the UI and in generated
+// receipts, emails and credential cards
+type Branding struct {
+	ProductName  string `json:"product_name"`
+	LogoURL      string `json:"logo_url"`
+	AccentColor  string `json:"accent_color"`
+	SupportEmail string `json:"support_email"`
+	SupportURL   string `json:"support_url"`
+}
+
+// Default is the branding used until an admin configures one
+var Default = Branding{
+	ProductName: "Account Editor",
+	AccentColor: "#1976d2",
+}
+
+// Load returns the configured branding with defaults filled in for empty fields
+func Load(db *gorm.DB) (Branding, error) {
+	b := Branding{}
+	value, err := settings.Get(db, settings.KeyBranding)
+	if err != nil {
+		return Default, err
+	}
+	if value != "" {
+		if err := json.Unmarshal([]byte(value), &b); err != nil {
+			return Default, fmt.Errorf("error decoding branding: %v", err)
+		}
+	}
+
+	if b.ProductName == "" {
+		b.ProductName = Default.ProductName
+	}
+	if b.AccentColor == "" {
+		b.AccentColor = Default.AccentColor
+	}
+	return b, nil
+}
+
+// Save validates and stores the branding
+func Save(db *gorm.DB, b Branding, updatedBy *int) error {
+	value, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return settings.Set(db, settings.KeyBranding, string(value), updatedBy)
+}
+
+// Validate trims the fields and checks their format
+func (b *Branding) Validate() error {
+	b.ProductName = strings.TrimSpace(b.ProductName)
+	b.LogoURL = strings.TrimSpace(b.LogoURL)
+	b.AccentColor = strings.TrimSpace(b.AccentColor)
+	b.SupportEmail = strings.TrimSpace(b.SupportEmail)
+	b.SupportURL = strings.TrimSpace(b.SupportURL)
+
+	if len(b.ProductName) > 100 {
+		return fmt.Errorf("product_name must be at most 100 characters")
+	}
+	if b.LogoURL != "" && !isHTTPURL(b.LogoURL) {
+		return fmt.Errorf("logo_url must be an http or https URL")
+	}
+	if b.AccentColor != "" && !accentColorPattern.MatchString(b.AccentColor) {
+		return fmt.Errorf("accent_color must be a hex color like #1976d2")
+	}
+	if b.SupportEmail != "" {
+		if _, err := mail.ParseAddress(b.SupportEmail); err != nil {
+			return fmt.Errorf("support_email must be a valid email address")
+		}
+	}
+	if b.SupportURL != "" && !isHTTPURL(b.SupportURL) {
+		return fmt.Errorf("support_url must be an http or https URL")
+	}
+	return nil
+}
+
+// isHTTPURL reports whether s is an absolute http(s) URL
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
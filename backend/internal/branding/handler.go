@@ -0,0 +1,68 @@
+package branding
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetBranding returns the public branding configuration
+func GetBranding(c *gin.Context) {
+	b, err := Load(database.GetDB())
+	if err != nil {
+		// Branding is cosmetic, so serve the defaults rather than failing the page
+		log.Printf("Failed to load branding: %v", err)
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, b)
+}
+
+// UpdateBranding replaces the branding configuration (admin only)
+func UpdateBranding(c *gin.Context) {
+	var req Branding
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var updatedBy *int
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			updatedBy = &u.ID
+		}
+	}
+
+	db := database.GetDB()
+	if err := Save(db, req, updatedBy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update settings")})
+		return
+	}
+
+	audit.Record(c, audit.ActionBrandingUpdated, "settings", "branding", map[string]interface{}{
+		"product_name": req.ProductName,
+	})
+
+	b, _ := Load(db)
+	c.JSON(http.StatusOK, b)
+}
+
+// SetupRoutes sets up the public branding routes
+func SetupRoutes(router *gin.RouterGroup) {
+	router.GET("", GetBranding)
+}
+
+// SetupAdminRoutes sets up the admin branding routes
+func SetupAdminRoutes(router *gin.RouterGroup) {
+	router.GET("/settings/branding", GetBranding)
+	router.PUT("/settings/branding", UpdateBranding)
+}
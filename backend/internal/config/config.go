@@ -0,0 +1,303 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Authentication modes supported by the API
+const (
+	// AuthModeHeader expects a bearer token in the Authorization header
+	AuthModeHeader = "header"
+	// AuthModeCookie stores the token in an HttpOnly session cookie
+	AuthModeCookie = "cookie"
+)
+
+// Config holds the runtime configuration read from the environment
+type Config struct {
+	Port      string
+	DBPath    string
+	JWTSecret string
+	// AuditHMACKey signs the audit log chain; empty derives it from JWTSecret
+	AuditHMACKey string
+	// FieldEncryptionKey is the base64 AES-256 key encrypting secrets stored in
+	// the database; empty stores new secrets in clear
+	FieldEncryptionKey string
+	// FieldEncryptionPreviousKeys lists retired keys, separated by commas, that
+	// still decrypt values until they are re-encrypted
+	FieldEncryptionPreviousKeys string
+
+	// DBReadDSN is an optional read-only SQLite DSN used for reports and exports
+	DBReadDSN string
+	// DBExplainSlowQueries logs EXPLAIN QUERY PLAN output for slow SELECTs
+	DBExplainSlowQueries bool
+	// DBSlowQueryMS is the threshold above which a query counts as slow
+	DBSlowQueryMS int
+
+	// LegacyListResponses returns bare arrays from list endpoints instead of the envelope
+	LegacyListResponses bool
+
+	// AuthMode selects how the access token is transported (header or cookie)
+	AuthMode string
+	// CookieDomain is the domain attribute for session cookies (empty for host-only)
+	CookieDomain string
+	// CookieSecure marks session cookies as Secure (HTTPS only)
+	CookieSecure bool
+
+	// ArtifactsDir is where generated downloadable files are written
+	ArtifactsDir string
+	// SignedURLTTLMinutes is how long a signed download URL stays valid
+	SignedURLTTLMinutes int
+
+	// StorageBackend selects where artifacts are stored (local or s3)
+	StorageBackend string
+	S3Endpoint     string
+	S3Region       string
+	S3Bucket       string
+	S3AccessKey    string
+	S3SecretKey    string
+
+	// ArtifactRetentionHours is how long exports, receipts and debug bundles are kept
+	ArtifactRetentionHours int
+	// BackupRetentionDays is how long backups are kept
+	BackupRetentionDays int
+	// TenantExportRecipients are the age public keys, separated by commas,
+	// tenant exports are encrypted to unless a request names its own
+	TenantExportRecipients string
+
+	// DBMaintenanceEnabled turns the scheduled SQLite maintenance job on or off
+	DBMaintenanceEnabled bool
+	// DBMaintenanceWindow is the local time range (HH:MM-HH:MM) in which VACUUM may run
+	DBMaintenanceWindow string
+	// DBIntegrityCheckHours is the interval between PRAGMA integrity_check runs
+	DBIntegrityCheckHours int
+	// DBVacuumIntervalHours is the minimum interval between VACUUM/ANALYZE runs
+	DBVacuumIntervalHours int
+
+	// TaskRetentionDays is the age after which finished tasks are archived (0 disables)
+	TaskRetentionDays int
+	// ResultCompressThresholdBytes is the size above which task results are stored gzipped (0 disables)
+	ResultCompressThresholdBytes int
+
+	// StatusPanelCheckSeconds is how long the public status page caches panel probes
+	StatusPanelCheckSeconds int
+	// StatusQueueDelayWarnSeconds is the oldest pending task age at which the queue counts as degraded
+	StatusQueueDelayWarnSeconds int
+
+	// RenewalSchedulerEnabled turns on the hourly job that enqueues extend tasks
+	// of renewal rules and disable tasks of expiry rules
+	RenewalSchedulerEnabled bool
+
+	// UptimeMonitorEnabled turns on periodic health probes of every configured panel
+	UptimeMonitorEnabled bool
+	// UptimeProbeIntervalSeconds is the time between probe rounds
+	UptimeProbeIntervalSeconds int
+	// UptimeFailureThreshold is how many consecutive failed probes mark a panel as down
+	UptimeFailureThreshold int
+	// UptimeHistoryDays is how long individual probe results are kept
+	UptimeHistoryDays int
+
+	// CredentialCheckEnabled turns on periodic validation of every stored panel API key
+	CredentialCheckEnabled bool
+	// CredentialCheckIntervalMinutes is the time between credential check rounds
+	CredentialCheckIntervalMinutes int
+
+	// SMTP server used for email notifications (empty host disables email)
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// TelegramBotToken is the bot used for Telegram notifications (empty disables Telegram)
+	TelegramBotToken string
+	// WebhookMaxAttempts is how often a webhook notification is sent before it is given up
+	WebhookMaxAttempts int
+
+	// Per-user quotas (0 means unlimited)
+	QuotaTasksPerDay             int
+	QuotaResultStorageMB         int
+	QuotaWebhookDeliveriesPerDay int
+	// QuotaWarnPercent is the share of a quota at which users are warned
+	QuotaWarnPercent int
+
+	// Stripe subscription billing (empty secret key disables it)
+	StripeSecretKey     string
+	StripeWebhookSecret string
+	StripeSuccessURL    string
+	StripeCancelURL     string
+	// StripeGraceDays is how long after the paid period ends a user keeps their plan
+	StripeGraceDays int
+
+	// PublicBaseURL is the externally visible base URL of the API used for
+	// generated links; empty derives it from the request
+	PublicBaseURL string
+	// TrustProxyHeaders honors X-Forwarded-Proto/Host/Prefix from a reverse proxy
+	TrustProxyHeaders bool
+
+	// SCIMToken is the bearer token identity providers use for SCIM provisioning (empty disables SCIM)
+	SCIMToken string
+
+	// IntrospectionTokens are the bearer tokens of sibling services allowed to
+	// introspect access tokens, as name=token pairs separated by commas (empty
+	// disables introspection)
+	IntrospectionTokens string
+
+	// GraphQLEnabled serves the read-only GraphQL endpoint at /graphql
+	GraphQLEnabled bool
+
+	// SignupEnabled allows self-registration at /auth/signup; it needs SMTP for verification emails
+	SignupEnabled bool
+	// SignupRequireApproval holds verified signups for an admin to approve
+	SignupRequireApproval bool
+
+	// BenchMode enables the load-test seeding endpoint and the simulated panel.
+	// It creates synthetic users and must only be used with a disposable database.
+	BenchMode bool
+	// BenchPanelAddr is the listen address of the simulated panel
+	BenchPanelAddr string
+}
+
+var cfg *Config
+
+// Load reads the configuration from environment variables
+func Load() *Config {
+	cfg = &Config{
+		Port:      getEnv("PORT", "8080"),
+		DBPath:    getEnv("DB_PATH", "sql_app.db"),
+		JWTSecret: getEnv("JWT_SECRET", ""),
+		DBReadDSN: getEnv("DB_READ_DSN", ""),
+
+		AuditHMACKey:                getEnv("AUDIT_HMAC_KEY", ""),
+		FieldEncryptionKey:          getEnv("FIELD_ENCRYPTION_KEY", ""),
+		FieldEncryptionPreviousKeys: getEnv("FIELD_ENCRYPTION_PREVIOUS_KEYS", ""),
+
+		DBExplainSlowQueries: getEnvBool("DB_EXPLAIN_SLOW_QUERIES", false),
+		DBSlowQueryMS:        getEnvInt("DB_SLOW_QUERY_MS", 200),
+		AuthMode:             strings.ToLower(getEnv("AUTH_MODE", AuthModeHeader)),
+		CookieDomain:         getEnv("COOKIE_DOMAIN", ""),
+		CookieSecure:         getEnvBool("COOKIE_SECURE", false),
+		LegacyListResponses:  getEnvBool("LEGACY_LIST_RESPONSES", false),
+
+		ArtifactsDir:        getEnv("ARTIFACTS_DIR", "artifacts"),
+		SignedURLTTLMinutes: getEnvInt("SIGNED_URL_TTL_MINUTES", 5),
+
+		StorageBackend: strings.ToLower(getEnv("STORAGE_BACKEND", "local")),
+		S3Endpoint:     getEnv("S3_ENDPOINT", ""),
+		S3Region:       getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:       getEnv("S3_BUCKET", ""),
+		S3AccessKey:    getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:    getEnv("S3_SECRET_KEY", ""),
+
+		ArtifactRetentionHours: getEnvInt("ARTIFACT_RETENTION_HOURS", 72),
+		BackupRetentionDays:    getEnvInt("BACKUP_RETENTION_DAYS", 30),
+		TenantExportRecipients: getEnv("TENANT_EXPORT_RECIPIENTS", ""),
+
+		DBMaintenanceEnabled:  getEnvBool("DB_MAINTENANCE_ENABLED", true),
+		DBMaintenanceWindow:   getEnv("DB_MAINTENANCE_WINDOW", "03:00-05:00"),
+		DBIntegrityCheckHours: getEnvInt("DB_INTEGRITY_CHECK_HOURS", 24),
+		DBVacuumIntervalHours: getEnvInt("DB_VACUUM_INTERVAL_HOURS", 168),
+
+		TaskRetentionDays:            getEnvInt("TASK_RETENTION_DAYS", 90),
+		ResultCompressThresholdBytes: getEnvInt("RESULT_COMPRESS_THRESHOLD_BYTES", 16384),
+
+		StatusPanelCheckSeconds:     getEnvInt("STATUS_PANEL_CHECK_SECONDS", 60),
+		StatusQueueDelayWarnSeconds: getEnvInt("STATUS_QUEUE_DELAY_WARN_SECONDS", 300),
+
+		RenewalSchedulerEnabled: getEnvBool("RENEWAL_SCHEDULER_ENABLED", false),
+
+		UptimeMonitorEnabled:       getEnvBool("UPTIME_MONITOR_ENABLED", false),
+		UptimeProbeIntervalSeconds: getEnvInt("UPTIME_PROBE_INTERVAL_SECONDS", 60),
+		UptimeFailureThreshold:     getEnvInt("UPTIME_FAILURE_THRESHOLD", 2),
+		UptimeHistoryDays:          getEnvInt("UPTIME_HISTORY_DAYS", 7),
+
+		CredentialCheckEnabled:         getEnvBool("CREDENTIAL_CHECK_ENABLED", false),
+		CredentialCheckIntervalMinutes: getEnvInt("CREDENTIAL_CHECK_INTERVAL_MINUTES", 60),
+
+		SMTPHost:         getEnv("SMTP_HOST", ""),
+		SMTPPort:         getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:     getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:     getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:         getEnv("SMTP_FROM", ""),
+		TelegramBotToken: getEnv("TELEGRAM_BOT_TOKEN", ""),
+
+		WebhookMaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+
+		QuotaTasksPerDay:             getEnvInt("QUOTA_TASKS_PER_DAY", 0),
+		QuotaResultStorageMB:         getEnvInt("QUOTA_RESULT_STORAGE_MB", 0),
+		QuotaWebhookDeliveriesPerDay: getEnvInt("QUOTA_WEBHOOK_DELIVERIES_PER_DAY", 0),
+		QuotaWarnPercent:             getEnvInt("QUOTA_WARN_PERCENT", 80),
+
+		StripeSecretKey:     getEnv("STRIPE_SECRET_KEY", ""),
+		StripeWebhookSecret: getEnv("STRIPE_WEBHOOK_SECRET", ""),
+		StripeSuccessURL:    getEnv("STRIPE_SUCCESS_URL", ""),
+		StripeCancelURL:     getEnv("STRIPE_CANCEL_URL", ""),
+		StripeGraceDays:     getEnvInt("STRIPE_GRACE_DAYS", 3),
+
+		PublicBaseURL:     getEnv("PUBLIC_BASE_URL", ""),
+		TrustProxyHeaders: getEnvBool("TRUST_PROXY_HEADERS", false),
+
+		SCIMToken: getEnv("SCIM_TOKEN", ""),
+
+		IntrospectionTokens: getEnv("INTROSPECTION_TOKENS", ""),
+
+		GraphQLEnabled: getEnvBool("GRAPHQL_ENABLED", false),
+
+		SignupEnabled:         getEnvBool("SIGNUP_ENABLED", false),
+		SignupRequireApproval: getEnvBool("SIGNUP_REQUIRE_APPROVAL", true),
+
+		BenchMode:      getEnvBool("BENCH_MODE", false),
+		BenchPanelAddr: getEnv("BENCH_PANEL_ADDR", "127.0.0.1:8099"),
+	}
+
+	if cfg.AuthMode != AuthModeCookie {
+		cfg.AuthMode = AuthModeHeader
+	}
+
+	return cfg
+}
+
+// Get returns the loaded configuration, loading it on first use
+func Get() *Config {
+	if cfg == nil {
+		return Load()
+	}
+	return cfg
+}
+
+// CookieAuthEnabled reports whether the cookie-based session mode is active
+func (c *Config) CookieAuthEnabled() bool {
+	return c.AuthMode == AuthModeCookie
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := lookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, ok := lookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, ok := lookupEnv(key)
+	if !ok || value == "" {
+		return fallback
+	}
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	}
+	return fallback
+}
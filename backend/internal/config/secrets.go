@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// ageValuePrefix marks an environment value holding base64 encoded age ciphertext
+const ageValuePrefix = "age:"
+
+// ageIdentities are the keys encrypted values are decrypted with, loaded on first use
+var ageIdentities []age.Identity
+
+// lookupEnv returns a configuration value. KEY_FILE takes precedence over KEY
+// and names a file holding the value, as with Docker and Kubernetes secrets.
+// Files in age format and values prefixed with "age:" are decrypted with the
+// identities from CONFIG_AGE_IDENTITY_FILE (or SOPS_AGE_KEY_FILE). A value
+// that cannot be read or decrypted stops the server, since running with a
+// missing secret would fail later in less obvious ways.
+func lookupEnv(key string) (string, bool) {
+	if path := os.Getenv(key + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Cannot read %s_FILE: %v", key, err)
+		}
+		if isAgeFile(data) {
+			plain, err := decryptAge(data)
+			if err != nil {
+				log.Fatalf("Cannot decrypt %s_FILE: %v", key, err)
+			}
+			data = plain
+		}
+		return strings.TrimRight(string(data), "\r\n"), true
+	}
+
+	value, ok := os.LookupEnv(key)
+	if !ok || !strings.HasPrefix(value, ageValuePrefix) {
+		return value, ok
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, ageValuePrefix))
+	if err != nil {
+		log.Fatalf("Cannot decode %s: %v", key, err)
+	}
+	plain, err := decryptAge(ciphertext)
+	if err != nil {
+		log.Fatalf("Cannot decrypt %s: %v", key, err)
+	}
+	return strings.TrimRight(string(plain), "\r\n"), true
+}
+
+// isAgeFile reports whether data is an age encrypted file, binary or armored
+func isAgeFile(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("age-encryption.org/")) ||
+		bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header))
+}
+
+// decryptAge decrypts binary or armored age ciphertext
+func decryptAge(data []byte) ([]byte, error) {
+	identities, err := loadAgeIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+
+	r, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// loadAgeIdentities reads the age identity file once
+func loadAgeIdentities() ([]age.Identity, error) {
+	if ageIdentities != nil {
+		return ageIdentities, nil
+	}
+
+	path := os.Getenv("CONFIG_AGE_IDENTITY_FILE")
+	if path == "" {
+		path = os.Getenv("SOPS_AGE_KEY_FILE")
+	}
+	if path == "" {
+		return nil, fmt.Errorf("encrypted value found but CONFIG_AGE_IDENTITY_FILE is not set")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	ageIdentities = identities
+	return identities, nil
+}
@@ -0,0 +1,378 @@
+package dashboard
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxWidgets limits the widgets of a user
+	maxWidgets = 50
+	// maxRows limits the rows a widget aggregates; larger results are truncated
+	maxRows = 100000
+	// maxGroups limits the groups a widget returns
+	maxGroups = 400
+	// maxDays limits the window of a widget
+	maxDays = 366
+)
+
+// Aggregates
+const (
+	AggregateCount = "count"
+	AggregateSum   = "sum"
+	AggregateAvg   = "avg"
+	AggregateMin   = "min"
+	AggregateMax   = "max"
+)
+
+// Time buckets usable as group_by
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// field is a numeric value of a row, computed from the columns it reads
+type field struct {
+	columns []string
+	value   func(row map[string]interface{}) (float64, bool)
+}
+
+// source describes what a widget may query. Filters and groups map spec names
+// to columns; only these are ever put into SQL.
+type source struct {
+	table   string
+	filters map[string]string
+	groups  map[string]string
+	fields  map[string]field
+}
+
+var sources = map[string]source{
+	"tasks": {
+		table:   "automation_tasks",
+		filters: map[string]string{"name": "name", "status": "status", "target_website": "target_website"},
+		groups:  map[string]string{"name": "name", "status": "status", "target_website": "target_website"},
+		fields: map[string]field{
+			// duration_seconds is the time from creation to completion of finished tasks
+			"duration_seconds": {columns: []string{"completed_at"}, value: func(row map[string]interface{}) (float64, bool) {
+				created, ok1 := row["created_at"].(time.Time)
+				completed, ok2 := row["completed_at"].(time.Time)
+				if !ok1 || !ok2 {
+					return 0, false
+				}
+				return completed.Sub(created).Seconds(), true
+			}},
+		},
+	},
+	"transactions": {
+		table:   "transactions",
+		filters: map[string]string{"type": "type", "line_id": "line_id", "package_id": "package_id"},
+		groups:  map[string]string{"type": "type", "line_id": "line_id", "package_id": "package_id"},
+		fields: map[string]field{
+			"amount": {columns: []string{"amount"}, value: numberColumn("amount")},
+		},
+	},
+	"lines": {
+		table:   "lines",
+		filters: map[string]string{"username": "username", "package_id": "package_id"},
+		groups:  map[string]string{"package_id": "package_id"},
+		fields: map[string]field{
+			// days_until_expiry is negative for expired lines
+			"days_until_expiry": {columns: []string{"expire_at"}, value: func(row map[string]interface{}) (float64, bool) {
+				expireAt, ok := row["expire_at"].(time.Time)
+				if !ok {
+					return 0, false
+				}
+				return time.Until(expireAt).Hours() / 24, true
+			}},
+		},
+	},
+}
+
+func numberColumn(column string) func(row map[string]interface{}) (float64, bool) {
+	return func(row map[string]interface{}) (float64, bool) {
+		switch v := row[column].(type) {
+		case float64:
+			return v, true
+		case int64:
+			return float64(v), true
+		}
+		return 0, false
+	}
+}
+
+func isBucket(groupBy string) bool {
+	return groupBy == BucketDay || groupBy == BucketWeek || groupBy == BucketMonth
+}
+
+func names(m interface{}) string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]field:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
+
+// Validate checks a widget spec against its source
+func Validate(spec *models.WidgetSpec) error {
+	src, ok := sources[spec.Source]
+	if !ok {
+		return fmt.Errorf("source must be tasks, transactions or lines")
+	}
+
+	for name := range spec.Filters {
+		if _, ok := src.filters[name]; !ok {
+			return fmt.Errorf("cannot filter %s by %q; use %s", spec.Source, name, names(src.filters))
+		}
+	}
+
+	if spec.Aggregate == "" {
+		spec.Aggregate = AggregateCount
+	}
+	switch spec.Aggregate {
+	case AggregateCount:
+		spec.Field = ""
+	case AggregateSum, AggregateAvg, AggregateMin, AggregateMax:
+		if _, ok := src.fields[spec.Field]; !ok {
+			return fmt.Errorf("%s of %s needs a field: %s", spec.Aggregate, spec.Source, names(src.fields))
+		}
+	default:
+		return fmt.Errorf("aggregate must be count, sum, avg, min or max")
+	}
+
+	if spec.GroupBy != "" && !isBucket(spec.GroupBy) {
+		if _, ok := src.groups[spec.GroupBy]; !ok {
+			return fmt.Errorf("cannot group %s by %q; use day, week, month or %s", spec.Source, spec.GroupBy, names(src.groups))
+		}
+	}
+
+	if spec.Days < 0 || spec.Days > maxDays {
+		return fmt.Errorf("days must be between 0 and %d", maxDays)
+	}
+	return nil
+}
+
+// Group is the aggregate of the rows sharing a group key
+type Group struct {
+	Key   string  `json:"key"`
+	Value float64 `json:"value"`
+	Count int     `json:"count"`
+}
+
+// Data is an evaluated widget. Value is set for ungrouped widgets and Groups
+// for grouped ones; Value is null when no row has the aggregated field.
+type Data struct {
+	Value  *float64 `json:"value"`
+	Groups []Group  `json:"groups,omitempty"`
+	// Rows is the number of rows aggregated
+	Rows int `json:"rows"`
+	// Truncated is set when more rows matched than are aggregated
+	Truncated bool `json:"truncated"`
+}
+
+// accumulator reduces the values of a group
+type accumulator struct {
+	count int
+	n     int
+	sum   float64
+	min   float64
+	max   float64
+}
+
+func (a *accumulator) add(v float64) {
+	if a.n == 0 || v < a.min {
+		a.min = v
+	}
+	if a.n == 0 || v > a.max {
+		a.max = v
+	}
+	a.n++
+	a.sum += v
+}
+
+func (a *accumulator) result(aggregate string) (float64, bool) {
+	if aggregate == AggregateCount {
+		return float64(a.count), true
+	}
+	if a.n == 0 {
+		return 0, aggregate == AggregateSum
+	}
+	var v float64
+	switch aggregate {
+	case AggregateSum:
+		v = a.sum
+	case AggregateAvg:
+		v = a.sum / float64(a.n)
+	case AggregateMin:
+		v = a.min
+	case AggregateMax:
+		v = a.max
+	}
+	return math.Round(v*100) / 100, true
+}
+
+// bucketKey returns the time bucket of t in the user's timezone: 2006-01-02
+// for days, the Monday of the week for weeks and 2006-01 for months
+func bucketKey(bucket string, t time.Time, loc *time.Location) string {
+	t = t.In(loc)
+	switch bucket {
+	case BucketWeek:
+		offset := (int(t.Weekday()) + 6) % 7
+		return t.AddDate(0, 0, -offset).Format("2006-01-02")
+	case BucketMonth:
+		return t.Format("2006-01")
+	}
+	return t.Format("2006-01-02")
+}
+
+// emptyBuckets returns every bucket key of the last days, so a time series
+// has no gaps
+func emptyBuckets(bucket string, days int, loc *time.Location) []string {
+	var keys []string
+	seen := map[string]bool{}
+	now := time.Now()
+	for d := days - 1; d >= 0; d-- {
+		key := bucketKey(bucket, now.AddDate(0, 0, -d), loc)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// location returns the user's timezone, or UTC
+func location(u models.User) *time.Location {
+	if u.Timezone != "" {
+		if loc, err := time.LoadLocation(u.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// Evaluate runs a validated widget spec over the user's rows
+func Evaluate(db *gorm.DB, u models.User, spec models.WidgetSpec) (Data, error) {
+	src := sources[spec.Source]
+
+	columns := []string{"created_at"}
+	if f, ok := src.fields[spec.Field]; ok {
+		columns = append(columns, f.columns...)
+	}
+	groupColumn := ""
+	if spec.GroupBy != "" && !isBucket(spec.GroupBy) {
+		groupColumn = src.groups[spec.GroupBy]
+		columns = append(columns, groupColumn)
+	}
+
+	query := db.Table(src.table).Select(columns).Where("user_id = ?", u.ID)
+	for name, value := range spec.Filters {
+		query = query.Where(src.filters[name]+" = ?", value)
+	}
+	if spec.Days > 0 {
+		query = query.Where("created_at >= ?", time.Now().AddDate(0, 0, -spec.Days))
+	}
+
+	// Past the row limit, the newest rows are the ones that count
+	var rows []map[string]interface{}
+	if err := query.Order("id DESC").Limit(maxRows + 1).Find(&rows).Error; err != nil {
+		return Data{}, err
+	}
+
+	data := Data{}
+	if len(rows) > maxRows {
+		rows = rows[:maxRows]
+		data.Truncated = true
+	}
+	data.Rows = len(rows)
+
+	loc := location(u)
+	groups := map[string]*accumulator{}
+	var order []string
+	if isBucket(spec.GroupBy) && spec.Days > 0 {
+		order = emptyBuckets(spec.GroupBy, spec.Days, loc)
+		for _, key := range order {
+			groups[key] = &accumulator{}
+		}
+	}
+
+	value := src.fields[spec.Field].value
+	for _, row := range rows {
+		key := ""
+		switch {
+		case isBucket(spec.GroupBy):
+			if t, ok := row["created_at"].(time.Time); ok {
+				key = bucketKey(spec.GroupBy, t, loc)
+			}
+		case groupColumn != "":
+			if v := row[groupColumn]; v != nil {
+				key = fmt.Sprint(v)
+			}
+		}
+
+		acc, ok := groups[key]
+		if !ok {
+			acc = &accumulator{}
+			groups[key] = acc
+			order = append(order, key)
+		}
+		acc.count++
+		if value != nil {
+			if v, ok := value(row); ok {
+				acc.add(v)
+			}
+		}
+	}
+
+	if spec.GroupBy == "" {
+		acc, ok := groups[""]
+		if !ok {
+			acc = &accumulator{}
+		}
+		if v, ok := acc.result(spec.Aggregate); ok {
+			data.Value = &v
+		}
+		return data, nil
+	}
+
+	data.Groups = []Group{}
+	for _, key := range order {
+		acc := groups[key]
+		v, ok := acc.result(spec.Aggregate)
+		if !ok {
+			continue
+		}
+		data.Groups = append(data.Groups, Group{Key: key, Value: v, Count: acc.count})
+	}
+
+	// Time series in time order, everything else largest first
+	if isBucket(spec.GroupBy) {
+		sort.SliceStable(data.Groups, func(i, j int) bool { return data.Groups[i].Key < data.Groups[j].Key })
+	} else {
+		sort.SliceStable(data.Groups, func(i, j int) bool {
+			if data.Groups[i].Value != data.Groups[j].Value {
+				return data.Groups[i].Value > data.Groups[j].Value
+			}
+			return data.Groups[i].Key < data.Groups[j].Key
+		})
+	}
+	if len(data.Groups) > maxGroups {
+		data.Groups = data.Groups[:maxGroups]
+		data.Truncated = true
+	}
+	return data, nil
+}
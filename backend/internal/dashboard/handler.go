@@ -0,0 +1,247 @@
+package dashboard
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type WidgetRequest struct {
+	Name     string            `json:"name" binding:"required"`
+	Position int               `json:"position"`
+	Spec     models.WidgetSpec `json:"spec"`
+}
+
+// WidgetData is a widget with its evaluated data
+type WidgetData struct {
+	models.DashboardWidget
+	Data  *Data  `json:"data"`
+	Error string `json:"error,omitempty"`
+}
+
+// currentUser returns the authenticated user or writes an error response
+func currentUser(c *gin.Context) (models.User, bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return models.User{}, false
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return models.User{}, false
+	}
+	return u, true
+}
+
+// applyWidgetRequest validates a widget request and copies it onto the widget
+func applyWidgetRequest(c *gin.Context, w *models.DashboardWidget) bool {
+	var req WidgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be between 1 and 100 characters"})
+		return false
+	}
+	if err := Validate(&req.Spec); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	w.Name = req.Name
+	w.Position = req.Position
+	w.Spec = req.Spec
+	return true
+}
+
+// GetWidgets lists the current user's widgets in dashboard order
+func GetWidgets(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var widgets []models.DashboardWidget
+	if err := database.GetDB().Where("user_id = ?", u.ID).Order("position, id").Find(&widgets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	utils.RespondList(c, widgets, int64(len(widgets)), "")
+}
+
+// CreateWidget adds a widget for the current user
+func CreateWidget(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var count int64
+	if err := db.Model(&models.DashboardWidget{}).Where("user_id = ?", u.ID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if count >= maxWidgets {
+		c.JSON(http.StatusForbidden, gin.H{"error": i18n.T(c, "You can have at most %d widgets", maxWidgets)})
+		return
+	}
+
+	widget := models.DashboardWidget{UserID: u.ID}
+	if !applyWidgetRequest(c, &widget) {
+		return
+	}
+
+	if err := db.Create(&widget).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusCreated, widget)
+}
+
+// UpdateWidget replaces one of the current user's widgets
+func UpdateWidget(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	var widget models.DashboardWidget
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), u.ID).First(&widget).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Widget not found")})
+		return
+	}
+
+	if !applyWidgetRequest(c, &widget) {
+		return
+	}
+
+	if err := db.Save(&widget).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, widget)
+}
+
+// DeleteWidget removes one of the current user's widgets
+func DeleteWidget(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	result := database.GetDB().Where("id = ? AND user_id = ?", c.Param("id"), u.ID).Delete(&models.DashboardWidget{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Widget not found")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Widget deleted")})
+}
+
+// GetWidgetData evaluates one of the current user's widgets
+func GetWidgetData(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var widget models.DashboardWidget
+	if err := database.GetDB().Where("id = ? AND user_id = ?", c.Param("id"), u.ID).First(&widget).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Widget not found")})
+		return
+	}
+
+	data, err := Evaluate(database.GetReadDB(), u, widget.Spec)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, data)
+}
+
+// PreviewWidget evaluates a widget spec without saving it
+func PreviewWidget(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var spec models.WidgetSpec
+	if err := c.ShouldBindJSON(&spec); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := Validate(&spec); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	data, err := Evaluate(database.GetReadDB(), u, spec)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, data)
+}
+
+// GetDashboard returns every widget of the current user with its data, so the
+// dashboard loads in one request. A widget that fails to evaluate carries the
+// error instead of failing the dashboard.
+func GetDashboard(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var widgets []models.DashboardWidget
+	if err := database.GetDB().Where("user_id = ?", u.ID).Order("position, id").Find(&widgets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	items := make([]WidgetData, len(widgets))
+	for i, w := range widgets {
+		items[i].DashboardWidget = w
+		data, err := Evaluate(database.GetReadDB(), u, w.Spec)
+		if err != nil {
+			log.Printf("Failed to evaluate widget ID %d: %v", w.ID, err)
+			items[i].Error = i18n.T(c, "Database error")
+			continue
+		}
+		items[i].Data = &data
+	}
+
+	c.JSON(http.StatusOK, gin.H{"widgets": items})
+}
+
+// SetupRoutes sets up the dashboard routes
+func SetupRoutes(router *gin.RouterGroup) {
+	router.GET("", GetDashboard)
+	router.GET("/widgets", GetWidgets)
+	router.POST("/widgets", CreateWidget)
+	router.POST("/widgets/preview", PreviewWidget)
+	router.PUT("/widgets/:id", UpdateWidget)
+	router.DELETE("/widgets/:id", DeleteWidget)
+	router.GET("/widgets/:id/data", GetWidgetData)
+}
@@ -3,8 +3,9 @@ package database
 import (
 	"log"
 	"os"
+	"time"
 
-	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/config"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -13,6 +14,9 @@ import (
 var (
 	// DB is the global database connection
 	DB *gorm.DB
+
+	// ReadDB is the optional read-only connection for heavy report queries
+	ReadDB *gorm.DB
 )
 
 // Initialize sets up the database connection and creates tables
@@ -20,15 +24,22 @@ func Initialize() {
 	var err error
 
 	// Configure GORM logger
-	newLogger := logger.New(
+	var newLogger logger.Interface = logger.New(
 		log.New(os.Stdout, "\r\n", log.LstdFlags),
 		logger.Config{
 			LogLevel: logger.Info,
 		},
 	)
 
+	// Optionally log query plans for slow queries
+	cfg := config.Get()
+	if cfg.DBExplainSlowQueries {
+		threshold := time.Duration(cfg.DBSlowQueryMS) * time.Millisecond
+		newLogger = newExplainLogger(newLogger, threshold, GetDB)
+	}
+
 	// Connect to SQLite database
-	DB, err = gorm.Open(sqlite.Open("sql_app.db"), &gorm.Config{
+	DB, err = gorm.Open(sqlite.Open(cfg.DBPath), &gorm.Config{
 		Logger: newLogger,
 	})
 	if err != nil {
@@ -36,15 +47,24 @@ func Initialize() {
 	}
 
 	// Auto-migrate the schema
-	err = DB.AutoMigrate(
-		&models.User{},
-		&models.AutomationTask{},
-		&models.UserSettings{},
-	)
-	if err != nil {
+	if err := DB.AutoMigrate(append(globalModels, tenantModels...)...); err != nil {
 		log.Fatal("Failed to auto-migrate schema:", err)
 	}
 
+	// Connect to the read-only reporting replica if one is configured
+	if dsn := cfg.DBReadDSN; dsn != "" {
+		ReadDB, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{
+			Logger: newLogger,
+		})
+		if err != nil {
+			log.Fatal("Failed to connect to read replica:", err)
+		}
+		if err := ReadDB.Exec("PRAGMA query_only = ON").Error; err != nil {
+			log.Fatal("Failed to make read replica connection read-only:", err)
+		}
+		log.Println("Read-only reporting replica connected")
+	}
+
 	log.Println("Database initialized successfully")
 }
 
@@ -52,3 +72,12 @@ func Initialize() {
 func GetDB() *gorm.DB {
 	return DB
 }
+
+// GetReadDB returns the reporting replica connection, or the primary connection
+// if no replica is configured. Use it for analytics, report and export queries only.
+func GetReadDB() *gorm.DB {
+	if ReadDB != nil {
+		return ReadDB
+	}
+	return DB
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// explainLogger wraps a GORM logger and logs the query plan of slow SELECT statements
+type explainLogger struct {
+	logger.Interface
+	threshold time.Duration
+	db        func() *gorm.DB
+}
+
+// newExplainLogger returns a logger that explains SELECTs slower than threshold using the
+// connection returned by db
+func newExplainLogger(base logger.Interface, threshold time.Duration, db func() *gorm.DB) logger.Interface {
+	return &explainLogger{Interface: base, threshold: threshold, db: db}
+}
+
+// LogMode keeps the explain wrapper when the log level is changed
+func (l *explainLogger) LogMode(level logger.LogLevel) logger.Interface {
+	return &explainLogger{Interface: l.Interface.LogMode(level), threshold: l.threshold, db: l.db}
+}
+
+// Trace logs the statement and, for slow SELECTs, its EXPLAIN QUERY PLAN output
+func (l *explainLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	l.Interface.Trace(ctx, begin, fc, err)
+
+	elapsed := time.Since(begin)
+	if err != nil || elapsed < l.threshold {
+		return
+	}
+
+	sql, _ := fc()
+	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(sql)), "SELECT") {
+		return
+	}
+
+	db := l.db()
+	if db == nil {
+		return
+	}
+
+	// Run outside the caller's goroutine so the original query is never delayed
+	go func() {
+		var plan []struct {
+			ID     int
+			Parent int
+			Detail string
+		}
+		if err := db.Session(&gorm.Session{Logger: logger.Discard}).Raw("EXPLAIN QUERY PLAN " + sql).Scan(&plan).Error; err != nil {
+			log.Printf("[slow query %s] EXPLAIN failed: %v", elapsed, err)
+			return
+		}
+
+		lines := make([]string, 0, len(plan))
+		for _, row := range plan {
+			lines = append(lines, row.Detail)
+		}
+		log.Printf("[slow query %s] %s\n  plan: %s", elapsed, sql, strings.Join(lines, " | "))
+	}()
+}
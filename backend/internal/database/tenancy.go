@@ -0,0 +1,85 @@
+package database
+
+import (
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+)
+
+// globalModels are the tables that only live in the primary database:
+// accounts, billing, configuration and the audit log
+var globalModels = []interface{}{
+	&models.User{},
+	&models.AuditLog{},
+	&models.SystemSetting{},
+	&models.UsageCounter{},
+	&models.QuotaAlert{},
+	&models.Plan{},
+	&models.Subscription{},
+	&models.Coupon{},
+	&models.CouponRedemption{},
+	&models.RecoveryToken{},
+	&models.ServiceClient{},
+	&models.SignupRequest{},
+	&models.NotificationTemplate{},
+	&models.PanelErrorMapping{},
+	&models.PanelErrorNormalization{},
+	&models.LatencyRollup{},
+	&models.PanelWebhookRejection{},
+	&models.PanelWebhookRejectionCount{},
+	&models.PanelWebhookNonce{},
+}
+
+// tenantModels are the tables holding users' own data. They exist in the
+// primary and in every database a resolver routes users to.
+var tenantModels = []interface{}{
+	&models.AutomationTask{},
+	&models.UserSettings{},
+	&models.TaskResultQuarantine{},
+	&models.AutomationTaskArchive{},
+	&models.TaskBatch{},
+	&models.TaskImport{},
+	&models.Notification{},
+	&models.PanelProbe{},
+	&models.PanelHealth{},
+	&models.Line{},
+	&models.Transaction{},
+	&models.RenewalRule{},
+	&models.ExpiryDisableRule{},
+	&models.ExpiryDisableExclusion{},
+	&models.WebhookDelivery{},
+	&models.NotificationDigestItem{},
+	&models.DashboardWidget{},
+	&models.TaskShadowResult{},
+}
+
+// Resolver returns the connection holding a user's data, or nil for the
+// primary. The database it returns must hold the tables of TenantModels.
+type Resolver func(userID int) *gorm.DB
+
+// resolver maps users to their databases; without one every user is on the primary
+var resolver Resolver
+
+// SetResolver sets how users are mapped to databases, e.g. by region or
+// organization, for operators embedding the server. The server sets none:
+// until the handlers and background jobs look up every user's data through
+// ForUser, routing users elsewhere would split their data between databases.
+func SetResolver(r Resolver) {
+	resolver = r
+}
+
+// ForUser returns the connection holding the user's own data: the one the
+// resolver returns, or the primary for users without one
+func ForUser(userID int) *gorm.DB {
+	if resolver == nil {
+		return DB
+	}
+	if db := resolver(userID); db != nil {
+		return db
+	}
+	return DB
+}
+
+// TenantModels returns the models of the tables holding users' own data
+func TenantModels() []interface{} {
+	return append([]interface{}(nil), tenantModels...)
+}
@@ -0,0 +1,252 @@
+package erasure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/artifacts"
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/heartbeat"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/notify"
+	"github.com/aliselcukkaya/account-editor/internal/storage"
+	"gorm.io/gorm"
+)
+
+// GracePeriod is how long a user can cancel a requested deletion
+const GracePeriod = 7 * 24 * time.Hour
+
+var (
+	// ErrAlreadyScheduled is returned when the user's deletion is already pending
+	ErrAlreadyScheduled = errors.New("account deletion is already scheduled")
+	// ErrNotScheduled is returned when canceling a deletion that is not pending
+	ErrNotScheduled = errors.New("account deletion is not scheduled")
+)
+
+// ownedData lists the tables whose rows of a user are deleted on erasure, in
+// the database holding the user's data. Credit transactions are kept as
+// billing records.
+var ownedData = []interface{}{
+	&models.AutomationTaskArchive{},
+	&models.TaskBatch{},
+	&models.TaskImport{},
+	&models.Line{},
+	&models.RenewalRule{},
+	&models.ExpiryDisableRule{},
+	&models.ExpiryDisableExclusion{},
+	&models.UserSettings{},
+	&models.Notification{},
+	&models.NotificationDigestItem{},
+	&models.WebhookDelivery{},
+	&models.DashboardWidget{},
+	&models.PanelProbe{},
+	&models.PanelHealth{},
+	&models.TaskShadowResult{},
+}
+
+// ownedAccountData lists the tables of the primary database whose rows of a
+// user are deleted on erasure. Subscriptions and coupon redemptions are kept as
+// billing records, and audit entries keep the user ID with the name removed.
+var ownedAccountData = []interface{}{
+	&models.UsageCounter{},
+	&models.QuotaAlert{},
+	&models.RecoveryToken{},
+	&models.ServiceClient{},
+	&models.PanelWebhookRejection{},
+	&models.PanelWebhookRejectionCount{},
+	&models.PanelWebhookNonce{},
+}
+
+// erasedUsername is the placeholder username of an erased user; it keeps the
+// unique username free and cannot be signed up for since it contains a colon
+func erasedUsername(userID int) string {
+	return "deleted:" + strconv.Itoa(userID)
+}
+
+// Schedule marks the user for deletion after the grace period and notifies
+// every superadmin. It returns when the account will be erased.
+func Schedule(db *gorm.DB, u models.User) (time.Time, error) {
+	now := time.Now()
+	scheduledAt := now.Add(GracePeriod)
+
+	result := db.Model(&models.User{}).
+		Where("id = ? AND deletion_scheduled_at IS NULL", u.ID).
+		Updates(map[string]interface{}{"deletion_requested_at": now, "deletion_scheduled_at": scheduledAt})
+	if result.Error != nil {
+		return time.Time{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return time.Time{}, ErrAlreadyScheduled
+	}
+
+	notify.NotifySuperadmins(db, notify.Event{
+		Kind:  notify.KindDeletionRequested,
+		Title: func(locale string) string { return i18n.Translate(locale, "Account deletion requested") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "%s requested deletion of their account. It will be erased on %s unless they cancel.", u.Username, scheduledAt.UTC().Format("2006-01-02"))
+		},
+		Data: map[string]interface{}{
+			"user_id":      u.ID,
+			"username":     u.Username,
+			"scheduled_at": scheduledAt,
+		},
+	})
+
+	return scheduledAt, nil
+}
+
+// Cancel withdraws the user's pending deletion
+func Cancel(db *gorm.DB, u models.User) error {
+	result := db.Model(&models.User{}).
+		Where("id = ? AND deletion_scheduled_at IS NOT NULL AND erased_at IS NULL", u.ID).
+		Updates(map[string]interface{}{"deletion_requested_at": nil, "deletion_scheduled_at": nil})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotScheduled
+	}
+	return nil
+}
+
+// eraseOwnedData deletes the user's tasks, lines, settings and other own data
+// in a transaction
+func eraseOwnedData(db *gorm.DB, userID int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		taskIDs := tx.Model(&models.AutomationTask{}).Select("id").Where("user_id = ?", userID)
+		if err := tx.Where("task_id IN (?)", taskIDs).Delete(&models.TaskResultQuarantine{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.AutomationTask{}).Error; err != nil {
+			return err
+		}
+		for _, table := range ownedData {
+			if err := tx.Where("user_id = ?", userID).Delete(table).Error; err != nil {
+				return err
+			}
+		}
+
+		// Notes of credit transactions may name lines
+		return tx.Model(&models.Transaction{}).Where("user_id = ?", userID).Update("note", "").Error
+	})
+}
+
+// Erase anonymizes a user: their tasks, lines, settings, notifications and
+// other owned data are deleted, and the user row is kept as an inactive
+// placeholder without name, password, profile or contact details so billing
+// records and audit entries still resolve.
+func Erase(db *gorm.DB, userID int) error {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.ErasedAt != nil {
+		return nil
+	}
+
+	placeholder := erasedUsername(user.ID)
+	now := time.Now()
+
+	// The user's own data is erased first; a failure later on is retried with
+	// the next run
+	if err := eraseOwnedData(database.ForUser(user.ID), user.ID); err != nil {
+		return fmt.Errorf("error erasing data of user ID %d: %v", user.ID, err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range ownedAccountData {
+			if err := tx.Where("user_id = ?", user.ID).Delete(table).Error; err != nil {
+				return err
+			}
+		}
+		// Only the user's own entries carry their username: signups and failed
+		// logins are recorded by signup and user ID
+		if err := tx.Model(&models.AuditLog{}).Where("actor_id = ?", user.ID).Update("actor_username", placeholder).Error; err != nil {
+			return err
+		}
+		// Sub-accounts cannot work without their parent
+		if err := tx.Model(&models.User{}).Where("parent_id = ?", user.ID).Update("is_active", false).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.SignupRequest{}).Where("user_id = ?", user.ID).
+			Updates(map[string]interface{}{"username": placeholder, "email": "", "hashed_password": "", "ip_address": ""}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&user).
+			Select("username", "hashed_password", "is_active", "external_id", "display_name",
+				"avatar_key", "timezone", "locale", "notification_defaults", "erased_at").
+			Updates(models.User{Username: placeholder, ErasedAt: &now}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("error erasing user ID %d: %v", user.ID, err)
+	}
+
+	// Stored files go last; anything left behind expires with the artifact lifecycle rules
+	ctx := context.Background()
+	for _, kind := range []string{artifacts.KindAvatar, artifacts.KindExport, artifacts.KindReceipt, artifacts.KindDebugBundle} {
+		objects, err := storage.Get().List(ctx, path.Join(kind, strconv.Itoa(user.ID))+"/")
+		if err != nil {
+			log.Printf("Failed to list %s of erased user ID %d: %v", kind, user.ID, err)
+			continue
+		}
+		for _, obj := range objects {
+			if err := storage.Get().Delete(ctx, obj.Key); err != nil {
+				log.Printf("Failed to delete %s of erased user ID %d: %v", obj.Key, user.ID, err)
+			}
+		}
+	}
+
+	audit.RecordSystem(audit.ActionUserErased, "user", user.ID, map[string]interface{}{
+		"requested_at": user.DeletionRequestedAt,
+	})
+	return nil
+}
+
+// eraseDue erases every user whose grace period has ended. It fails when the
+// due deletions cannot be loaded or an account could not be erased.
+func eraseDue(db *gorm.DB) error {
+	var ids []int
+	if err := db.Model(&models.User{}).
+		Where("deletion_scheduled_at <= ? AND erased_at IS NULL", time.Now()).
+		Pluck("id", &ids).Error; err != nil {
+		log.Printf("Account erasure: failed to load due deletions: %v", err)
+		return fmt.Errorf("error loading due deletions: %v", err)
+	}
+
+	failed := 0
+	for _, id := range ids {
+		if err := Erase(db, id); err != nil {
+			log.Printf("Account erasure: %v", err)
+			failed++
+			continue
+		}
+		log.Printf("Erased user ID %d after its deletion grace period", id)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d due accounts could not be erased", failed, len(ids))
+	}
+	return nil
+}
+
+// StartEraser erases accounts whose deletion grace period has ended, hourly
+func StartEraser(db *gorm.DB) {
+	jobs.Register(jobs.Job{
+		Name:        heartbeat.JobErasure,
+		Description: "Erase accounts whose deletion grace period has ended",
+		Interval:    time.Hour,
+		RunOnStart:  true,
+		Run: func() error {
+			return eraseDue(db)
+		},
+	})
+}
@@ -0,0 +1,148 @@
+package erasure
+
+import (
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type DeletionRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// currentUser returns the authenticated user or writes an error response
+func currentUser(c *gin.Context) (models.User, bool) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return models.User{}, false
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return models.User{}, false
+	}
+	return u, true
+}
+
+// GetDeletion returns whether the current user's account is scheduled for deletion
+func GetDeletion(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"scheduled":    u.DeletionScheduledAt != nil,
+		"requested_at": u.DeletionRequestedAt,
+		"scheduled_at": u.DeletionScheduledAt,
+	})
+}
+
+// RequestDeletion schedules the current user's account for erasure after the
+// grace period; the password confirms the request
+func RequestDeletion(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req DeletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !utils.CheckPasswordHash(req.Password, u.HashedPassword) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Current password is incorrect")})
+		return
+	}
+
+	// Admin accounts are removed by another admin, so the last one cannot lock everyone out
+	if u.IsAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": i18n.T(c, "Admin accounts cannot be deleted by their owner")})
+		return
+	}
+
+	scheduledAt, err := Schedule(database.GetDB(), u)
+	if err != nil {
+		if err == ErrAlreadyScheduled {
+			c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "Account deletion is already scheduled")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionDeletionRequested, "user", u.ID, map[string]interface{}{
+		"scheduled_at": scheduledAt,
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"scheduled":    true,
+		"scheduled_at": scheduledAt,
+		"message":      i18n.T(c, "Your account will be deleted on %s unless you cancel", scheduledAt.UTC().Format("2006-01-02")),
+	})
+}
+
+// CancelDeletion withdraws the current user's pending account deletion
+func CancelDeletion(c *gin.Context) {
+	u, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := Cancel(database.GetDB(), u); err != nil {
+		if err == ErrNotScheduled {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Account deletion is not scheduled")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionDeletionCanceled, "user", u.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"scheduled": false, "message": i18n.T(c, "Account deletion canceled")})
+}
+
+// GetPendingDeletions lists the accounts scheduled for deletion, soonest first (admin only)
+func GetPendingDeletions(c *gin.Context) {
+	var users []models.User
+	if err := database.GetDB().
+		Where("deletion_scheduled_at IS NOT NULL AND erased_at IS NULL").
+		Order("deletion_scheduled_at").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	items := []gin.H{}
+	for _, u := range users {
+		items = append(items, gin.H{
+			"user_id":      u.ID,
+			"username":     u.Username,
+			"display_name": u.DisplayName,
+			"requested_at": u.DeletionRequestedAt,
+			"scheduled_at": u.DeletionScheduledAt,
+		})
+	}
+
+	utils.RespondList(c, items, int64(len(items)), "")
+}
+
+// SetupRoutes sets up the account deletion routes of the current user
+func SetupRoutes(router *gin.RouterGroup) {
+	router.GET("/me/deletion", GetDeletion)
+	router.POST("/me/deletion", RequestDeletion)
+	router.DELETE("/me/deletion", CancelDeletion)
+}
+
+// SetupAdminRoutes sets up the admin account deletion routes
+func SetupAdminRoutes(router *gin.RouterGroup) {
+	router.GET("/deletions", GetPendingDeletions)
+}
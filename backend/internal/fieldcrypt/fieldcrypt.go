@@ -0,0 +1,192 @@
+// Package fieldcrypt encrypts secrets stored in database columns and settings
+// with AES-256-GCM. Values are stored as "enc:v1:<key id>:<base64 nonce and
+// ciphertext>", so rows written with a previous key stay readable after a
+// rotation and plaintext rows from before encryption was enabled still load.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// prefix marks an encrypted value and its format version
+const prefix = "enc:v1:"
+
+// ErrNoKey is returned when an encrypted value is read without a key configured
+var ErrNoKey = errors.New("encrypted value found but FIELD_ENCRYPTION_KEY is not set")
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+var (
+	mu sync.RWMutex
+	// current encrypts new values; nil stores them in clear
+	current *key
+	// keys decrypts values by key ID, the current and previous keys
+	keys = map[string]*key{}
+)
+
+// parseKey reads a base64 encoded 32 byte key. Its ID is a short hash, so the
+// key used for a value can be found without trying them all.
+func parseKey(encoded string) (*key, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64: %v", err)
+	}
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(raw)
+	return &key{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// SetKeys configures the key new values are encrypted with and the previous
+// keys still accepted for reading. An empty current key stores new values in
+// clear.
+func SetKeys(currentKey string, previous []string) error {
+	parsed := map[string]*key{}
+	var cur *key
+	if strings.TrimSpace(currentKey) != "" {
+		k, err := parseKey(currentKey)
+		if err != nil {
+			return fmt.Errorf("FIELD_ENCRYPTION_KEY: %v", err)
+		}
+		cur = k
+		parsed[k.id] = k
+	}
+	for _, encoded := range previous {
+		if strings.TrimSpace(encoded) == "" {
+			continue
+		}
+		k, err := parseKey(encoded)
+		if err != nil {
+			return fmt.Errorf("FIELD_ENCRYPTION_PREVIOUS_KEYS: %v", err)
+		}
+		if _, ok := parsed[k.id]; !ok {
+			parsed[k.id] = k
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	current = cur
+	keys = parsed
+	return nil
+}
+
+// Enabled reports whether new values are encrypted
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current != nil
+}
+
+// CurrentKeyID returns the ID of the key new values are encrypted with, or ""
+func CurrentKeyID() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return ""
+	}
+	return current.id
+}
+
+// IsEncrypted reports whether a stored value is encrypted
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyID returns the ID of the key a stored value was encrypted with, or "" for
+// a value in clear
+func KeyID(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return id
+}
+
+// Encrypt encrypts plain for storage. The context, such as "user_settings.api_key",
+// is authenticated with the value, so it cannot be copied into another column.
+// Without a key the value is returned in clear.
+func Encrypt(plain []byte, context string) (string, error) {
+	mu.RLock()
+	k := current
+	mu.RUnlock()
+	if k == nil {
+		return string(plain), nil
+	}
+
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := k.aead.Seal(nonce, nonce, plain, []byte(context))
+	return prefix + k.id + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt. Values in clear are returned as they are.
+func Decrypt(value string, context string) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return []byte(value), nil
+	}
+
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return nil, errors.New("malformed encrypted value")
+	}
+	mu.RLock()
+	k, found := keys[id]
+	empty := len(keys) == 0
+	mu.RUnlock()
+	if empty {
+		return nil, ErrNoKey
+	}
+	if !found {
+		return nil, fmt.Errorf("value was encrypted with unknown key %s", id)
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < k.aead.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plain, err := k.aead.Open(nil, nonce, ciphertext, []byte(context))
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting value with key %s: %v", id, err)
+	}
+	return plain, nil
+}
+
+// EncryptString encrypts a string for storage; empty strings stay empty
+func EncryptString(plain, context string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	return Encrypt([]byte(plain), context)
+}
+
+// DecryptString reverses EncryptString
+func DecryptString(value, context string) (string, error) {
+	plain, err := Decrypt(value, context)
+	return string(plain), err
+}
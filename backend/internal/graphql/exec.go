@@ -0,0 +1,546 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxDepth limits how deeply selections may be nested
+	maxDepth = 8
+	// maxResolvedValues limits the number of fields resolved for one request,
+	// since nested lists multiply
+	maxResolvedValues = 20000
+)
+
+// errForbidden is returned for fields the viewer may not see; the field is null
+var errForbidden = errors.New("not authorized to access this field")
+
+// errTooLarge aborts a request that resolves more than maxResolvedValues fields
+var errTooLarge = fmt.Errorf("query resolves more than %d fields; narrow it or lower the limits", maxResolvedValues)
+
+// ResolveContext is passed to every resolver
+type ResolveContext struct {
+	db     *gorm.DB
+	viewer models.User
+
+	resolved int
+	errors   []Error
+}
+
+// Object is a GraphQL object type
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+// Field is a field of an object type. Resolve receives the parent value (nil
+// on Query) and the coerced arguments. Allow, when set, is checked before
+// Resolve; a denied field is null with an error.
+type Field struct {
+	Name        string
+	Description string
+	// Type is the GraphQL type, e.g. "[Task!]!"
+	Type    string
+	Args    []Arg
+	Allow   func(rc *ResolveContext, parent interface{}) bool
+	Resolve func(rc *ResolveContext, parent interface{}, args map[string]interface{}) (interface{}, error)
+}
+
+// Arg is an argument of a field
+type Arg struct {
+	Name        string
+	Type        string
+	Default     interface{}
+	Description string
+}
+
+// Error is a GraphQL error in the response
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Schema holds the object types, Query being the root
+type Schema struct {
+	Query   *Object
+	types   []*Object
+	objects map[string]*Object
+}
+
+// NewSchema builds a schema from the root and every other object type
+func NewSchema(query *Object, others ...*Object) *Schema {
+	s := &Schema{Query: query, objects: map[string]*Object{}}
+	for _, o := range append([]*Object{query}, others...) {
+		s.types = append(s.types, o)
+		s.objects[o.Name] = o
+	}
+	return s
+}
+
+func (o *Object) field(name string) *Field {
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// namedType strips list and non-null markers: "[Task!]!" is "Task"
+func namedType(t string) string {
+	return strings.Trim(t, "[]!")
+}
+
+// orderedMap is a response object; its keys keep the order of the query
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: map[string]interface{}{}}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Response is the result of a request. Data is absent when the request could
+// not be executed at all.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+// requestError fails a request before execution
+func requestError(err error) (*Response, bool) {
+	return &Response{Errors: []Error{{Message: err.Error()}}}, false
+}
+
+// Execute parses, validates and runs a query for the viewer. It returns false
+// when the request failed before execution.
+func (s *Schema) Execute(db *gorm.DB, viewer models.User, req Request) (*Response, bool) {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return requestError(err)
+	}
+
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return requestError(err)
+	}
+	if op.kind != "query" {
+		return requestError(fmt.Errorf("only queries are supported"))
+	}
+
+	vars, err := coerceVariables(op, req.Variables)
+	if err != nil {
+		return requestError(err)
+	}
+
+	v := &validator{schema: s, doc: doc, vars: vars, visiting: map[string]bool{}}
+	v.selections(s.Query, op.selections, 1)
+	if len(v.errors) > 0 {
+		return &Response{Errors: v.errors}, false
+	}
+
+	rc := &ResolveContext{db: db, viewer: viewer}
+	e := &executor{schema: s, doc: doc, vars: vars}
+	data := e.object(rc, s.Query, nil, op.selections, nil)
+
+	resp := &Response{Data: data, Errors: rc.errors}
+	if rc.resolved > maxResolvedValues {
+		resp.Data = nil
+		resp.Errors = []Error{{Message: errTooLarge.Error()}}
+	}
+	return resp, true
+}
+
+// operation picks the operation to run
+func (d *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document has several operations")
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("operation %q not found", name)
+}
+
+// coerceVariables applies defaults and checks the variables against their declared types
+func coerceVariables(op *operation, given map[string]interface{}) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for _, def := range op.variables {
+		value, ok := given[def.name]
+		if !ok && def.hasDefault {
+			value, ok = def.def, true
+		}
+		if !ok || value == nil {
+			if strings.HasSuffix(def.typ, "!") {
+				return nil, fmt.Errorf("variable $%s of type %s is required", def.name, def.typ)
+			}
+			continue
+		}
+
+		coerced, err := coerceInput(def.typ, value)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %v", def.name, err)
+		}
+		vars[def.name] = coerced
+	}
+	return vars, nil
+}
+
+// coerceInput converts an input value (from JSON or the document) to the Go
+// value of a scalar type: int, float64, string or bool
+func coerceInput(typ string, value interface{}) (interface{}, error) {
+	if value == nil {
+		if strings.HasSuffix(typ, "!") {
+			return nil, fmt.Errorf("must not be null")
+		}
+		return nil, nil
+	}
+
+	typ = strings.TrimSuffix(typ, "!")
+	if strings.HasPrefix(typ, "[") {
+		return nil, fmt.Errorf("list arguments are not supported")
+	}
+
+	switch typ {
+	case "Int":
+		var f float64
+		switch n := value.(type) {
+		case int:
+			f = float64(n)
+		case int64:
+			f = float64(n)
+		case float64:
+			f = n
+		default:
+			return nil, fmt.Errorf("expected an Int")
+		}
+		if f != math.Trunc(f) || f > math.MaxInt32 || f < math.MinInt32 {
+			return nil, fmt.Errorf("expected an Int")
+		}
+		return int(f), nil
+	case "Float":
+		switch n := value.(type) {
+		case int:
+			return float64(n), nil
+		case int64:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+		return nil, fmt.Errorf("expected a Float")
+	case "String", "DateTime":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("expected a String")
+	case "Boolean":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected a Boolean")
+	}
+	return nil, fmt.Errorf("unknown input type %s", typ)
+}
+
+// validator checks a query against the schema before it runs
+type validator struct {
+	schema   *Schema
+	doc      *document
+	vars     map[string]interface{}
+	visiting map[string]bool
+	errors   []Error
+}
+
+func (v *validator) errorf(format string, args ...interface{}) {
+	v.errors = append(v.errors, Error{Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) selections(obj *Object, sels []*selection, depth int) {
+	if depth > maxDepth {
+		v.errorf("query is nested more than %d levels deep", maxDepth)
+		return
+	}
+
+	for _, sel := range sels {
+		switch {
+		case sel.spread != "":
+			f, ok := v.doc.fragments[sel.spread]
+			if !ok {
+				v.errorf("unknown fragment %q", sel.spread)
+				continue
+			}
+			if f.typeCond != obj.Name {
+				v.errorf("fragment %q on %s cannot be spread on %s", f.name, f.typeCond, obj.Name)
+				continue
+			}
+			if v.visiting[f.name] {
+				v.errorf("fragment %q spreads itself", f.name)
+				continue
+			}
+			v.visiting[f.name] = true
+			v.selections(obj, f.selections, depth)
+			delete(v.visiting, f.name)
+
+		case sel.inline:
+			if sel.typeCond != "" && sel.typeCond != obj.Name {
+				v.errorf("inline fragment on %s cannot be used on %s", sel.typeCond, obj.Name)
+				continue
+			}
+			v.selections(obj, sel.selections, depth)
+
+		case sel.name == "__typename":
+			if len(sel.selections) > 0 {
+				v.errorf("field __typename cannot have a selection")
+			}
+
+		default:
+			f := obj.field(sel.name)
+			if f == nil {
+				v.errorf("cannot query field %q on type %s", sel.name, obj.Name)
+				continue
+			}
+			for _, arg := range sel.args {
+				if !hasArg(f, arg.name) {
+					v.errorf("unknown argument %q on field %s.%s", arg.name, obj.Name, f.Name)
+				}
+			}
+			if _, err := v.argsOf(f, sel); err != nil {
+				v.errorf("field %s.%s: %v", obj.Name, f.Name, err)
+			}
+
+			child := v.schema.objects[namedType(f.Type)]
+			switch {
+			case child == nil && len(sel.selections) > 0:
+				v.errorf("field %s.%s of type %s cannot have a selection", obj.Name, f.Name, f.Type)
+			case child != nil && len(sel.selections) == 0:
+				v.errorf("field %s.%s of type %s must have a selection", obj.Name, f.Name, f.Type)
+			case child != nil:
+				v.selections(child, sel.selections, depth+1)
+			}
+		}
+	}
+}
+
+func hasArg(f *Field, name string) bool {
+	for _, a := range f.Args {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) argsOf(f *Field, sel *selection) (map[string]interface{}, error) {
+	return fieldArgs(f, sel.args, v.vars)
+}
+
+// fieldArgs resolves variables, applies defaults and coerces the arguments of a field
+func fieldArgs(f *Field, given []argument, vars map[string]interface{}) (map[string]interface{}, error) {
+	args := map[string]interface{}{}
+	for _, def := range f.Args {
+		var value interface{} = def.Default
+		for _, a := range given {
+			if a.name != def.Name {
+				continue
+			}
+			value = a.value
+			if ref, ok := a.value.(variableRef); ok {
+				value = vars[string(ref)]
+				if value == nil {
+					value = def.Default
+				}
+			}
+		}
+		if e, ok := value.(enumValue); ok {
+			value = string(e)
+		}
+
+		coerced, err := coerceInput(def.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %v", def.Name, err)
+		}
+		if coerced != nil {
+			args[def.Name] = coerced
+		}
+	}
+	return args, nil
+}
+
+// included evaluates @include and @skip
+func included(dirs []directive, vars map[string]interface{}) bool {
+	for _, d := range dirs {
+		if d.name != "include" && d.name != "skip" {
+			continue
+		}
+		cond := false
+		for _, a := range d.args {
+			if a.name != "if" {
+				continue
+			}
+			value := a.value
+			if ref, ok := value.(variableRef); ok {
+				value = vars[string(ref)]
+			}
+			cond, _ = value.(bool)
+		}
+		if (d.name == "include") != cond {
+			return false
+		}
+	}
+	return true
+}
+
+type executor struct {
+	schema *Schema
+	doc    *document
+	vars   map[string]interface{}
+}
+
+// collect flattens fragments into the fields to resolve, in query order
+func (e *executor) collect(sels []*selection, out []*selection) []*selection {
+	for _, sel := range sels {
+		if !included(sel.directives, e.vars) {
+			continue
+		}
+		switch {
+		case sel.spread != "":
+			out = e.collect(e.doc.fragments[sel.spread].selections, out)
+		case sel.inline:
+			out = e.collect(sel.selections, out)
+		default:
+			out = append(out, sel)
+		}
+	}
+	return out
+}
+
+// object resolves the selected fields of an object value
+func (e *executor) object(rc *ResolveContext, obj *Object, value interface{}, sels []*selection, path []interface{}) interface{} {
+	result := newOrderedMap()
+	merged := map[string]*selection{}
+
+	for _, sel := range e.collect(sels, nil) {
+		key := sel.responseKey()
+		if prev, ok := merged[key]; ok {
+			// Same field selected twice (e.g. from two fragments): merge the sub-selections
+			prev.selections = append(append([]*selection{}, prev.selections...), sel.selections...)
+			continue
+		}
+		merged[key] = &selection{name: sel.name, alias: sel.alias, args: sel.args, selections: sel.selections}
+		result.set(key, nil)
+	}
+
+	for _, key := range result.keys {
+		if rc.resolved > maxResolvedValues {
+			break
+		}
+		rc.resolved++
+
+		sel := merged[key]
+		fieldPath := append(append([]interface{}{}, path...), key)
+		if sel.name == "__typename" {
+			result.set(key, obj.Name)
+			continue
+		}
+
+		f := obj.field(sel.name)
+		if f.Allow != nil && !f.Allow(rc, value) {
+			rc.errors = append(rc.errors, Error{Message: errForbidden.Error(), Path: fieldPath})
+			continue
+		}
+
+		args, _ := fieldArgs(f, sel.args, e.vars)
+		resolved, err := f.Resolve(rc, value, args)
+		if err != nil {
+			rc.errors = append(rc.errors, Error{Message: err.Error(), Path: fieldPath})
+			continue
+		}
+		result.set(key, e.complete(rc, f.Type, resolved, sel.selections, fieldPath))
+	}
+	return result
+}
+
+// complete turns a resolved value into its response form, resolving the
+// sub-selections of objects and lists of objects
+func (e *executor) complete(rc *ResolveContext, typ string, value interface{}, sels []*selection, path []interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+	}
+
+	typ = strings.TrimSuffix(typ, "!")
+	if strings.HasPrefix(typ, "[") {
+		inner := strings.TrimSuffix(strings.TrimPrefix(typ, "["), "]")
+		if rv.Kind() != reflect.Slice {
+			return nil
+		}
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = e.complete(rc, inner, rv.Index(i).Interface(), sels, append(append([]interface{}{}, path...), i))
+		}
+		return list
+	}
+
+	if obj, ok := e.schema.objects[typ]; ok {
+		if rv.Kind() == reflect.Ptr {
+			value = rv.Elem().Interface()
+		}
+		return e.object(rc, obj, value, sels, path)
+	}
+	return value
+}
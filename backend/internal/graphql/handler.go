@@ -0,0 +1,97 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// SDL returns the schema in the GraphQL schema definition language
+func (s *Schema) SDL() string {
+	var b strings.Builder
+	b.WriteString("\"\"\"RFC 3339 timestamp\"\"\"\nscalar DateTime\n\n")
+	b.WriteString("\"\"\"Arbitrary JSON value\"\"\"\nscalar JSON\n")
+
+	for _, o := range s.types {
+		b.WriteString("\n")
+		if o.Description != "" {
+			fmt.Fprintf(&b, "\"\"\"%s\"\"\"\n", o.Description)
+		}
+		fmt.Fprintf(&b, "type %s {\n", o.Name)
+		for _, f := range o.Fields {
+			if f.Description != "" {
+				fmt.Fprintf(&b, "  %q\n", f.Description)
+			}
+			b.WriteString("  " + f.Name)
+			if len(f.Args) > 0 {
+				args := make([]string, len(f.Args))
+				for i, a := range f.Args {
+					args[i] = a.Name + ": " + a.Type
+					if a.Default != nil {
+						args[i] += fmt.Sprintf(" = %v", a.Default)
+					}
+				}
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			b.WriteString(": " + f.Type + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+// Query runs a GraphQL query for the current user, sent as a JSON body
+// ({"query", "operationName", "variables"}) or in the query string
+func Query(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	var req Request
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if v := c.Query("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				c.JSON(http.StatusBadRequest, Response{Errors: []Error{{Message: "variables must be a JSON object"}}})
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Errors: []Error{{Message: err.Error()}}})
+		return
+	}
+
+	resp, executed := schema.Execute(database.GetReadDB(), u, req)
+	if !executed {
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetSDL returns the schema definition
+func GetSDL(c *gin.Context) {
+	c.String(http.StatusOK, schema.SDL())
+}
+
+// SetupRoutes sets up the GraphQL routes
+func SetupRoutes(router *gin.RouterGroup) {
+	router.GET("", Query)
+	router.POST("", Query)
+	router.GET("/schema", GetSDL)
+}
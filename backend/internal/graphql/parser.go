@@ -0,0 +1,695 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxQueryLength limits the size of a query document
+const maxQueryLength = 32 << 10
+
+// document is a parsed executable GraphQL document
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string // query, mutation or subscription
+	name       string
+	variables  []variableDef
+	selections []*selection
+}
+
+type variableDef struct {
+	name       string
+	typ        string
+	def        interface{}
+	hasDefault bool
+}
+
+type fragment struct {
+	name       string
+	typeCond   string
+	selections []*selection
+}
+
+// selection is a field, a fragment spread (spread set) or an inline fragment
+// (inline set, typeCond optional)
+type selection struct {
+	alias      string
+	name       string
+	args       []argument
+	directives []directive
+	selections []*selection
+
+	spread   string
+	inline   bool
+	typeCond string
+}
+
+// responseKey is the name of a field in the response
+func (s *selection) responseKey() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+type argument struct {
+	name  string
+	value interface{}
+}
+
+type directive struct {
+	name string
+	args []argument
+}
+
+// Values in the document: variables, enums and objects get their own types,
+// everything else is the equivalent Go value
+type (
+	variableRef string
+	enumValue   string
+	objectValue map[string]interface{}
+)
+
+// Token kinds
+const (
+	tokEOF = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind  int
+	value string
+	pos   int
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+// parse parses an executable document
+func parse(src string) (*document, error) {
+	if len(src) > maxQueryLength {
+		return nil, fmt.Errorf("query must be at most %d bytes", maxQueryLength)
+	}
+
+	p := &parser{src: src}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: map[string]*fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peek(tokPunct, "{"):
+			sels, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: sels})
+
+		case p.peek(tokName, "query"), p.peek(tokName, "mutation"), p.peek(tokName, "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+
+		case p.peek(tokName, "fragment"):
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := doc.fragments[f.name]; exists {
+				return nil, fmt.Errorf("fragment %q is defined more than once", f.name)
+			}
+			doc.fragments[f.name] = f
+
+		default:
+			return nil, p.unexpected()
+		}
+	}
+
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("document has no operation")
+	}
+	return doc, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	line := 1 + strings.Count(p.src[:p.tok.pos], "\n")
+	col := p.tok.pos - strings.LastIndex(p.src[:p.tok.pos], "\n")
+	return fmt.Errorf("syntax error at %d:%d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokEOF {
+		return p.errorf("unexpected end of document")
+	}
+	return p.errorf("unexpected %q", p.tok.value)
+}
+
+// next reads the next token, skipping whitespace, commas and comments
+func (p *parser) next() error {
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		if ch == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		if ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',' {
+			p.pos++
+			continue
+		}
+		// Byte order mark
+		if strings.HasPrefix(p.src[p.pos:], "\uFEFF") {
+			p.pos += len("\uFEFF")
+			continue
+		}
+		break
+	}
+
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return nil
+	}
+
+	ch := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = token{kind: tokPunct, value: "...", pos: start}
+
+	case strings.ContainsRune("!$&():=@[]{|}", rune(ch)):
+		p.pos++
+		p.tok = token{kind: tokPunct, value: string(ch), pos: start}
+
+	case ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z'):
+		for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok = token{kind: tokName, value: p.src[start:p.pos], pos: start}
+
+	case ch == '-' || (ch >= '0' && ch <= '9'):
+		return p.number()
+
+	case ch == '"':
+		return p.string()
+
+	default:
+		p.tok = token{pos: start}
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		return p.errorf("unexpected character %q", r)
+	}
+	return nil
+}
+
+func isNameChar(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+func (p *parser) number() error {
+	start := p.pos
+	kind := tokInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+		}
+	}
+	digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		kind = tokFloat
+		p.pos++
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		kind = tokFloat
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+
+	p.tok = token{kind: kind, value: p.src[start:p.pos], pos: start}
+	if p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+		return p.errorf("invalid number %q", p.src[start:p.pos+1])
+	}
+	return nil
+}
+
+func (p *parser) string() error {
+	start := p.pos
+	p.tok = token{pos: start}
+
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			return p.errorf("unterminated block string")
+		}
+		value := p.src[p.pos+3 : p.pos+3+end]
+		p.pos += end + 6
+		p.tok = token{kind: tokString, value: strings.TrimSpace(value), pos: start}
+		return nil
+	}
+
+	var b strings.Builder
+	p.pos++
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			return p.errorf("unterminated string")
+		}
+		ch := p.src[p.pos]
+		if ch == '"' {
+			p.pos++
+			break
+		}
+		if ch != '\\' {
+			b.WriteByte(ch)
+			p.pos++
+			continue
+		}
+
+		if p.pos+1 >= len(p.src) {
+			return p.errorf("unterminated string")
+		}
+		esc := p.src[p.pos+1]
+		p.pos += 2
+		switch esc {
+		case '"', '\\', '/':
+			b.WriteByte(esc)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.src) {
+				return p.errorf("invalid unicode escape")
+			}
+			n, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				return p.errorf("invalid unicode escape")
+			}
+			b.WriteRune(rune(n))
+			p.pos += 4
+		default:
+			return p.errorf("invalid escape \\%c", esc)
+		}
+	}
+
+	p.tok = token{kind: tokString, value: b.String(), pos: start}
+	return nil
+}
+
+// peek reports whether the current token is the given one
+func (p *parser) peek(kind int, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+// expect consumes the given punctuator
+func (p *parser) expect(value string) error {
+	if !p.peek(tokPunct, value) {
+		return p.errorf("expected %q", value)
+	}
+	return p.next()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.errorf("expected a name")
+	}
+	name := p.tok.value
+	return name, p.next()
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: p.tok.value}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind == tokName {
+		op.name = p.tok.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek(tokPunct, "(") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for !p.peek(tokPunct, ")") {
+			v, err := p.variableDef()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, v)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	sels, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = sels
+	return op, nil
+}
+
+func (p *parser) variableDef() (variableDef, error) {
+	var v variableDef
+	if err := p.expect("$"); err != nil {
+		return v, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return v, err
+	}
+	v.name = name
+
+	if err := p.expect(":"); err != nil {
+		return v, err
+	}
+	if v.typ, err = p.typeRef(); err != nil {
+		return v, err
+	}
+
+	if p.peek(tokPunct, "=") {
+		if err := p.next(); err != nil {
+			return v, err
+		}
+		if v.def, err = p.value(true); err != nil {
+			return v, err
+		}
+		v.hasDefault = true
+	}
+
+	_, err = p.directives()
+	return v, err
+}
+
+// typeRef reads a type such as [Int!]! and returns it as written
+func (p *parser) typeRef() (string, error) {
+	var t string
+	if p.peek(tokPunct, "[") {
+		if err := p.next(); err != nil {
+			return "", err
+		}
+		inner, err := p.typeRef()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		t = "[" + inner + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		t = name
+	}
+
+	if p.peek(tokPunct, "!") {
+		t += "!"
+		if err := p.next(); err != nil {
+			return "", err
+		}
+	}
+	return t, nil
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, p.errorf("fragment cannot be named \"on\"")
+	}
+	if !p.peek(tokName, "on") {
+		return nil, p.errorf("expected a type condition")
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	typeCond, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+
+	sels, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &fragment{name: name, typeCond: typeCond, selections: sels}, nil
+}
+
+func (p *parser) selectionSet() ([]*selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	var sels []*selection
+	for !p.peek(tokPunct, "}") {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+	}
+	if len(sels) == 0 {
+		return nil, p.errorf("selection set must not be empty")
+	}
+	return sels, p.next()
+}
+
+func (p *parser) selection() (*selection, error) {
+	sel := &selection{}
+	var err error
+
+	if p.peek(tokPunct, "...") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		if p.tok.kind == tokName && p.tok.value != "on" {
+			sel.spread = p.tok.value
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			sel.directives, err = p.directives()
+			return sel, err
+		}
+
+		sel.inline = true
+		if p.peek(tokName, "on") {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			if sel.typeCond, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if sel.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		sel.selections, err = p.selectionSet()
+		return sel, err
+	}
+
+	if sel.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if p.peek(tokPunct, ":") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		sel.alias = sel.name
+		if sel.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if sel.args, err = p.arguments(); err != nil {
+		return nil, err
+	}
+	if sel.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek(tokPunct, "{") {
+		if sel.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return sel, nil
+}
+
+func (p *parser) arguments() ([]argument, error) {
+	if !p.peek(tokPunct, "(") {
+		return nil, nil
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	var args []argument
+	for !p.peek(tokPunct, ")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.value(false)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, argument{name: name, value: value})
+	}
+	if len(args) == 0 {
+		return nil, p.errorf("argument list must not be empty")
+	}
+	return args, p.next()
+}
+
+func (p *parser) directives() ([]directive, error) {
+	var dirs []directive
+	for p.peek(tokPunct, "@") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments()
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, directive{name: name, args: args})
+	}
+	return dirs, nil
+}
+
+// value reads an input value; constant values may not contain variables
+func (p *parser) value(constant bool) (interface{}, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokInt:
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			return nil, p.errorf("invalid integer %s", tok.value)
+		}
+		return n, p.next()
+
+	case tokFloat:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", tok.value)
+		}
+		return f, p.next()
+
+	case tokString:
+		return tok.value, p.next()
+
+	case tokName:
+		var v interface{}
+		switch tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = enumValue(tok.value)
+		}
+		return v, p.next()
+	}
+
+	switch {
+	case p.peek(tokPunct, "$"):
+		if constant {
+			return nil, p.errorf("variables are not allowed here")
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return variableRef(name), err
+
+	case p.peek(tokPunct, "["):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		list := []interface{}{}
+		for !p.peek(tokPunct, "]") {
+			v, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.next()
+
+	case p.peek(tokPunct, "{"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		obj := objectValue{}
+		for !p.peek(tokPunct, "}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[name], err = p.value(constant); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.next()
+	}
+
+	return nil, p.unexpected()
+}
@@ -0,0 +1,424 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/middleware"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// Authorization rules. Users always see their own data; admins see other users
+// according to their role's permissions. Lines, transactions, the credit balance
+// and task payloads (which contain line passwords) of other users are for
+// superadmins only.
+
+// canSeeUser reports whether the viewer may see the profile of a user
+func canSeeUser(rc *ResolveContext, userID int) bool {
+	return rc.viewer.ID == userID || middleware.HasPermission(rc.viewer, middleware.PermissionViewUsers)
+}
+
+// canSeeTasks reports whether the viewer may see the tasks of a user
+func canSeeTasks(rc *ResolveContext, userID int) bool {
+	return rc.viewer.ID == userID || middleware.HasPermission(rc.viewer, middleware.PermissionViewTasks)
+}
+
+// canSeeAccount reports whether the viewer may see the lines, transactions and
+// task payloads of a user
+func canSeeAccount(rc *ResolveContext, userID int) bool {
+	return rc.viewer.ID == userID || rc.viewer.Role() == models.AdminRoleSuperadmin
+}
+
+// ownerID returns the user a value belongs to
+func ownerID(v interface{}) int {
+	switch v := v.(type) {
+	case models.User:
+		return v.ID
+	case models.AutomationTask:
+		return v.UserID
+	case models.Line:
+		return v.UserID
+	case models.Transaction:
+		return v.UserID
+	}
+	return 0
+}
+
+func allowUser(rc *ResolveContext, parent interface{}) bool {
+	return canSeeUser(rc, ownerID(parent))
+}
+
+func allowTasks(rc *ResolveContext, parent interface{}) bool {
+	return canSeeTasks(rc, ownerID(parent))
+}
+
+func allowAccount(rc *ResolveContext, parent interface{}) bool {
+	return canSeeAccount(rc, ownerID(parent))
+}
+
+// pageArgs are the arguments of list fields
+var pageArgs = []Arg{
+	{Name: "limit", Type: "Int", Default: defaultLimit, Description: fmt.Sprintf("At most %d", maxLimit)},
+	{Name: "offset", Type: "Int", Default: 0},
+}
+
+func withPage(args ...Arg) []Arg {
+	return append(args, pageArgs...)
+}
+
+// page applies the limit and offset arguments
+func page(query *gorm.DB, args map[string]interface{}) (*gorm.DB, error) {
+	limit, _ := args["limit"].(int)
+	offset, _ := args["offset"].(int)
+	if limit < 1 || limit > maxLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+	if offset < 0 {
+		return nil, fmt.Errorf("offset must not be negative")
+	}
+	return query.Limit(limit).Offset(offset), nil
+}
+
+// prop returns a resolver reading a value of the parent
+func prop[T any](get func(T) interface{}) func(*ResolveContext, interface{}, map[string]interface{}) (interface{}, error) {
+	return func(_ *ResolveContext, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+		return get(parent.(T)), nil
+	}
+}
+
+// jsonValue decodes a stored JSON document, or returns nil when it is empty
+func jsonValue(data models.JSON) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return string(data)
+	}
+	return v
+}
+
+// resultField returns a field of a failed task's result
+func resultField(task models.AutomationTask, key string) interface{} {
+	result, ok := jsonValue(task.Result).(map[string]interface{})
+	if !ok || result["success"] != false {
+		return nil
+	}
+	return result[key]
+}
+
+func findTasks(rc *ResolveContext, userID int, args map[string]interface{}) (interface{}, error) {
+	query := rc.db.Where("user_id = ?", userID)
+	if status, ok := args["status"].(string); ok {
+		query = query.Where("status = ?", status)
+	}
+	if name, ok := args["name"].(string); ok {
+		query = query.Where("name = ?", name)
+	}
+	query, err := page(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []models.AutomationTask
+	err = query.Order("created_at DESC, id DESC").Find(&tasks).Error
+	return tasks, err
+}
+
+func findLines(rc *ResolveContext, userID int, args map[string]interface{}) (interface{}, error) {
+	query := rc.db.Where("user_id = ?", userID)
+	if username, ok := args["username"].(string); ok {
+		query = query.Where("username = ?", username)
+	}
+	if days, ok := args["expiring_within_days"].(int); ok {
+		if days < 0 {
+			return nil, fmt.Errorf("expiring_within_days must not be negative")
+		}
+		query = query.Where("expire_at IS NOT NULL AND expire_at < ?", time.Now().AddDate(0, 0, days))
+	}
+	query, err := page(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []models.Line
+	err = query.Order("expire_at IS NULL, expire_at ASC, id").Find(&lines).Error
+	return lines, err
+}
+
+func findTransactions(rc *ResolveContext, userID int, args map[string]interface{}) (interface{}, error) {
+	query := rc.db.Where("user_id = ?", userID)
+	if txType, ok := args["type"].(string); ok {
+		query = query.Where("type = ?", txType)
+	}
+	if lineID, ok := args["line_id"].(string); ok {
+		query = query.Where("line_id = ?", lineID)
+	}
+	query, err := page(query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []models.Transaction
+	err = query.Order("created_at DESC, id DESC").Find(&transactions).Error
+	return transactions, err
+}
+
+func balance(rc *ResolveContext, userID int) (interface{}, error) {
+	var total float64
+	err := rc.db.Model(&models.Transaction{}).
+		Where("user_id = ?", userID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// findOne loads a record of the user by ID, or returns nil when there is none
+func findOne[T any](rc *ResolveContext, userID, id int) (interface{}, error) {
+	var v T
+	err := rc.db.Where("id = ? AND user_id = ?", id, userID).First(&v).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// findUser loads a user by ID, or returns nil when there is none
+func findUser(rc *ResolveContext, id int) (interface{}, error) {
+	var u models.User
+	err := rc.db.First(&u, id).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+var taskFilterArgs = []Arg{
+	{Name: "status", Type: "String", Description: "pending, held, running, completed or failed"},
+	{Name: "name", Type: "String", Description: "Task type"},
+}
+
+var lineFilterArgs = []Arg{
+	{Name: "username", Type: "String"},
+	{Name: "expiring_within_days", Type: "Int"},
+}
+
+var transactionFilterArgs = []Arg{
+	{Name: "type", Type: "String", Description: "purchase, renewal, topup or adjustment"},
+	{Name: "line_id", Type: "String"},
+}
+
+var (
+	userType = &Object{
+		Name:        "User",
+		Description: "An account of this service",
+	}
+	taskType = &Object{
+		Name:        "Task",
+		Description: "An automation task",
+	}
+	lineType = &Object{
+		Name:        "Line",
+		Description: "A panel line known from the user's task results",
+	}
+	transactionType = &Object{
+		Name:        "Transaction",
+		Description: "A change of the user's panel credit; spending is negative",
+	}
+	queryType = &Object{
+		Name: "Query",
+	}
+)
+
+func init() {
+	userType.Fields = []*Field{
+		{Name: "id", Type: "Int!", Resolve: prop(func(u models.User) interface{} { return u.ID })},
+		{Name: "username", Type: "String!", Resolve: prop(func(u models.User) interface{} { return u.Username })},
+		{Name: "display_name", Type: "String!", Resolve: prop(func(u models.User) interface{} { return u.DisplayName })},
+		{Name: "timezone", Type: "String!", Resolve: prop(func(u models.User) interface{} { return u.Timezone })},
+		{Name: "locale", Type: "String!", Resolve: prop(func(u models.User) interface{} { return u.Locale })},
+		{Name: "is_active", Type: "Boolean!", Resolve: prop(func(u models.User) interface{} { return u.IsActive })},
+		{Name: "is_admin", Type: "Boolean!", Resolve: prop(func(u models.User) interface{} { return u.IsAdmin })},
+		{Name: "admin_role", Type: "String", Description: "superadmin or support; null for users that are not admins",
+			Resolve: prop(func(u models.User) interface{} {
+				if role := u.Role(); role != "" {
+					return role
+				}
+				return nil
+			})},
+		{Name: "created_at", Type: "DateTime!", Resolve: prop(func(u models.User) interface{} { return u.CreatedAt })},
+		{Name: "last_login_at", Type: "DateTime", Resolve: prop(func(u models.User) interface{} { return u.LastLoginAt })},
+		{Name: "tasks", Type: "[Task!]", Description: "Newest first", Args: withPage(taskFilterArgs...), Allow: allowTasks,
+			Resolve: func(rc *ResolveContext, parent interface{}, args map[string]interface{}) (interface{}, error) {
+				return findTasks(rc, ownerID(parent), args)
+			}},
+		{Name: "lines", Type: "[Line!]", Description: "Soonest expiry first", Args: withPage(lineFilterArgs...), Allow: allowAccount,
+			Resolve: func(rc *ResolveContext, parent interface{}, args map[string]interface{}) (interface{}, error) {
+				return findLines(rc, ownerID(parent), args)
+			}},
+		{Name: "transactions", Type: "[Transaction!]", Description: "Newest first", Args: withPage(transactionFilterArgs...), Allow: allowAccount,
+			Resolve: func(rc *ResolveContext, parent interface{}, args map[string]interface{}) (interface{}, error) {
+				return findTransactions(rc, ownerID(parent), args)
+			}},
+		{Name: "balance", Type: "Float", Description: "Sum of all transactions", Allow: allowAccount,
+			Resolve: func(rc *ResolveContext, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+				return balance(rc, ownerID(parent))
+			}},
+	}
+
+	taskType.Fields = []*Field{
+		{Name: "id", Type: "Int!", Resolve: prop(func(t models.AutomationTask) interface{} { return t.ID })},
+		{Name: "name", Type: "String!", Resolve: prop(func(t models.AutomationTask) interface{} { return t.Name })},
+		{Name: "status", Type: "String!", Resolve: prop(func(t models.AutomationTask) interface{} { return t.Status })},
+		{Name: "target_website", Type: "String!", Resolve: prop(func(t models.AutomationTask) interface{} { return t.TargetWebsite })},
+		{Name: "batch_id", Type: "Int", Resolve: prop(func(t models.AutomationTask) interface{} { return t.BatchID })},
+		{Name: "created_at", Type: "DateTime!", Resolve: prop(func(t models.AutomationTask) interface{} { return t.CreatedAt })},
+		{Name: "updated_at", Type: "DateTime!", Resolve: prop(func(t models.AutomationTask) interface{} { return t.UpdatedAt })},
+		{Name: "completed_at", Type: "DateTime", Resolve: prop(func(t models.AutomationTask) interface{} { return t.CompletedAt })},
+		{Name: "error", Type: "String", Description: "Error of a failed task",
+			Resolve: prop(func(t models.AutomationTask) interface{} { return resultField(t, "error") })},
+		{Name: "error_code", Type: "String", Description: "Error code of a failed task",
+			Resolve: prop(func(t models.AutomationTask) interface{} { return resultField(t, "error_code") })},
+		{Name: "result", Type: "JSON", Description: "Task result as published at /schemas/task-result/:name", Allow: allowAccount,
+			Resolve: prop(func(t models.AutomationTask) interface{} { return jsonValue(t.Result) })},
+		{Name: "request", Type: "JSON", Description: "Original task request", Allow: allowAccount,
+			Resolve: prop(func(t models.AutomationTask) interface{} { return jsonValue(models.JSON(t.Request)) })},
+		{Name: "user", Type: "User", Allow: allowUser,
+			Resolve: func(rc *ResolveContext, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+				return findUser(rc, ownerID(parent))
+			}},
+	}
+
+	lineType.Fields = []*Field{
+		{Name: "id", Type: "Int!", Resolve: prop(func(l models.Line) interface{} { return l.ID })},
+		{Name: "line_id", Type: "String!", Resolve: prop(func(l models.Line) interface{} { return l.LineID })},
+		{Name: "username", Type: "String!", Resolve: prop(func(l models.Line) interface{} { return l.Username })},
+		{Name: "package_id", Type: "Int!", Resolve: prop(func(l models.Line) interface{} { return l.PackageID })},
+		{Name: "expire_at", Type: "DateTime", Resolve: prop(func(l models.Line) interface{} { return l.ExpireAt })},
+		{Name: "renewal_queued_at", Type: "DateTime", Resolve: prop(func(l models.Line) interface{} { return l.RenewalQueuedAt })},
+		{Name: "created_at", Type: "DateTime!", Resolve: prop(func(l models.Line) interface{} { return l.CreatedAt })},
+		{Name: "updated_at", Type: "DateTime!", Resolve: prop(func(l models.Line) interface{} { return l.UpdatedAt })},
+		{Name: "last_task", Type: "Task", Allow: allowTasks,
+			Resolve: func(rc *ResolveContext, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+				l := parent.(models.Line)
+				if l.LastTaskID == nil {
+					return nil, nil
+				}
+				return findOne[models.AutomationTask](rc, l.UserID, *l.LastTaskID)
+			}},
+		{Name: "transactions", Type: "[Transaction!]", Description: "Newest first", Args: withPage(), Allow: allowAccount,
+			Resolve: func(rc *ResolveContext, parent interface{}, args map[string]interface{}) (interface{}, error) {
+				l := parent.(models.Line)
+				args["line_id"] = l.LineID
+				return findTransactions(rc, l.UserID, args)
+			}},
+	}
+
+	transactionType.Fields = []*Field{
+		{Name: "id", Type: "Int!", Resolve: prop(func(t models.Transaction) interface{} { return t.ID })},
+		{Name: "type", Type: "String!", Resolve: prop(func(t models.Transaction) interface{} { return t.Type })},
+		{Name: "amount", Type: "Float!", Resolve: prop(func(t models.Transaction) interface{} { return t.Amount })},
+		{Name: "package_id", Type: "Int!", Resolve: prop(func(t models.Transaction) interface{} { return t.PackageID })},
+		{Name: "line_id", Type: "String!", Resolve: prop(func(t models.Transaction) interface{} { return t.LineID })},
+		{Name: "note", Type: "String!", Resolve: prop(func(t models.Transaction) interface{} { return t.Note })},
+		{Name: "created_at", Type: "DateTime!", Resolve: prop(func(t models.Transaction) interface{} { return t.CreatedAt })},
+		{Name: "task", Type: "Task", Allow: allowTasks,
+			Resolve: func(rc *ResolveContext, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+				t := parent.(models.Transaction)
+				if t.TaskID == nil {
+					return nil, nil
+				}
+				return findOne[models.AutomationTask](rc, t.UserID, *t.TaskID)
+			}},
+		{Name: "line", Type: "Line", Allow: allowAccount,
+			Resolve: func(rc *ResolveContext, parent interface{}, _ map[string]interface{}) (interface{}, error) {
+				t := parent.(models.Transaction)
+				if t.LineID == "" {
+					return nil, nil
+				}
+				var l models.Line
+				err := rc.db.Where("user_id = ? AND line_id = ?", t.UserID, t.LineID).First(&l).Error
+				if err == gorm.ErrRecordNotFound {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				return l, nil
+			}},
+	}
+
+	queryType.Fields = []*Field{
+		{Name: "me", Type: "User!", Description: "The current user",
+			Resolve: func(rc *ResolveContext, _ interface{}, _ map[string]interface{}) (interface{}, error) {
+				return rc.viewer, nil
+			}},
+		{Name: "user", Type: "User", Description: "A user by ID; other users need the users.view admin permission",
+			Args: []Arg{{Name: "id", Type: "Int!"}},
+			Resolve: func(rc *ResolveContext, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				id := args["id"].(int)
+				if !canSeeUser(rc, id) {
+					return nil, errForbidden
+				}
+				return findUser(rc, id)
+			}},
+		{Name: "users", Type: "[User!]", Description: "All users by ID (users.view admin permission)",
+			Args: withPage(Arg{Name: "search", Type: "String", Description: "Part of the username or display name"}),
+			Allow: func(rc *ResolveContext, _ interface{}) bool {
+				return middleware.HasPermission(rc.viewer, middleware.PermissionViewUsers)
+			},
+			Resolve: func(rc *ResolveContext, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				query := rc.db.Model(&models.User{})
+				if search, ok := args["search"].(string); ok && search != "" {
+					like := "%" + search + "%"
+					query = query.Where("username LIKE ? OR display_name LIKE ?", like, like)
+				}
+				query, err := page(query, args)
+				if err != nil {
+					return nil, err
+				}
+				var users []models.User
+				err = query.Order("id").Find(&users).Error
+				return users, err
+			}},
+		{Name: "task", Type: "Task", Description: "One of the current user's tasks",
+			Args: []Arg{{Name: "id", Type: "Int!"}},
+			Resolve: func(rc *ResolveContext, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				return findOne[models.AutomationTask](rc, rc.viewer.ID, args["id"].(int))
+			}},
+		{Name: "tasks", Type: "[Task!]!", Description: "The current user's tasks, newest first", Args: withPage(taskFilterArgs...),
+			Resolve: func(rc *ResolveContext, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				return findTasks(rc, rc.viewer.ID, args)
+			}},
+		{Name: "line", Type: "Line", Description: "One of the current user's lines",
+			Args: []Arg{{Name: "id", Type: "Int!"}},
+			Resolve: func(rc *ResolveContext, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				return findOne[models.Line](rc, rc.viewer.ID, args["id"].(int))
+			}},
+		{Name: "lines", Type: "[Line!]!", Description: "The current user's lines, soonest expiry first", Args: withPage(lineFilterArgs...),
+			Resolve: func(rc *ResolveContext, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				return findLines(rc, rc.viewer.ID, args)
+			}},
+		{Name: "transactions", Type: "[Transaction!]!", Description: "The current user's transactions, newest first",
+			Args: withPage(transactionFilterArgs...),
+			Resolve: func(rc *ResolveContext, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				return findTransactions(rc, rc.viewer.ID, args)
+			}},
+	}
+}
+
+// schema is the API's GraphQL schema
+var schema = NewSchema(queryType, userType, taskType, lineType, transactionType)
@@ -0,0 +1,135 @@
+package heartbeat
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeatsResponse is the heartbeat configuration with the auth header masked
+func heartbeatsResponse(cfg Config) gin.H {
+	authHeader := ""
+	if cfg.AuthHeader != "" {
+		authHeader = maskedSecret
+	}
+	if cfg.URLs == nil {
+		cfg.URLs = map[string]string{}
+	}
+	return gin.H{
+		"enabled":     cfg.Enabled,
+		"base_url":    cfg.BaseURL,
+		"urls":        cfg.URLs,
+		"auth_header": authHeader,
+		"jobs":        Jobs,
+		"monitored":   cfg.monitoredJobs(),
+		"status":      GetStatus(),
+	}
+}
+
+// bindConfig binds and validates a heartbeat configuration, keeping the stored
+// auth header when the masked value is sent back. It responds on failure.
+func bindConfig(c *gin.Context) (Config, bool) {
+	var req Config
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+
+	if req.AuthHeader == maskedSecret {
+		current, err := LoadConfig(database.GetDB())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to load settings")})
+			return req, false
+		}
+		req.AuthHeader = current.AuthHeader
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	return req, true
+}
+
+// GetHeartbeats returns the heartbeat configuration and the state of every job (admin only)
+func GetHeartbeats(c *gin.Context) {
+	cfg, err := LoadConfig(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to load settings")})
+		return
+	}
+
+	c.JSON(http.StatusOK, heartbeatsResponse(cfg))
+}
+
+// UpdateHeartbeats replaces the heartbeat configuration (admin only)
+func UpdateHeartbeats(c *gin.Context) {
+	req, ok := bindConfig(c)
+	if !ok {
+		return
+	}
+
+	var updatedBy *int
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			updatedBy = &u.ID
+		}
+	}
+
+	if err := SaveConfig(database.GetDB(), req, updatedBy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update settings")})
+		return
+	}
+
+	audit.Record(c, audit.ActionHeartbeatsUpdated, "settings", "heartbeats", map[string]interface{}{
+		"enabled":  req.Enabled,
+		"base_url": req.BaseURL,
+		"urls":     req.URLs,
+	})
+
+	c.JSON(http.StatusOK, heartbeatsResponse(req))
+}
+
+// TestHeartbeat sends a success ping, or a failure ping with ?fail=true, for
+// the job in ?job= with the submitted configuration (admin only)
+func TestHeartbeat(c *gin.Context) {
+	req, ok := bindConfig(c)
+	if !ok {
+		return
+	}
+	job := c.Query("job")
+	if !knownJob(job) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "Unknown job")})
+		return
+	}
+	if req.URL(job) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c, "No heartbeat URL is configured for this job")})
+		return
+	}
+
+	var runErr error
+	if c.Query("fail") == "true" {
+		runErr = errors.New("heartbeat test failure")
+	}
+	if err := Send(req, job, runErr); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   i18n.T(c, "Failed to deliver the heartbeat"),
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Heartbeat delivered")})
+}
+
+// SetupAdminRoutes configures the heartbeat settings routes for admins
+func SetupAdminRoutes(router *gin.RouterGroup) {
+	router.GET("/settings/heartbeats", GetHeartbeats)
+	router.PUT("/settings/heartbeats", UpdateHeartbeats)
+	router.POST("/settings/heartbeats/test", TestHeartbeat)
+}
@@ -0,0 +1,310 @@
+package heartbeat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/fieldcrypt"
+	"github.com/aliselcukkaya/account-editor/internal/settings"
+	"gorm.io/gorm"
+)
+
+// Background jobs that send heartbeats
+const (
+	JobRenewals          = "renewals"
+	JobHeldTasks         = "held_tasks"
+	JobTaskArchive       = "task_archive"
+	JobSQLiteMaintenance = "sqlite_maintenance"
+	JobErasure           = "erasure"
+	JobSubscriptions     = "subscriptions"
+	// JobBackup is pinged by backup scripts through the heartbeat command, since
+	// backups run outside the server
+	JobBackup = "backup"
+)
+
+// Jobs lists every job a heartbeat URL can be configured for; registered
+// background jobs are added while the server starts
+var Jobs = []string{JobRenewals, JobHeldTasks, JobTaskArchive, JobSQLiteMaintenance, JobErasure, JobSubscriptions, JobBackup}
+
+// AddJob adds a background job to the jobs heartbeats can be configured for
+func AddJob(job string) {
+	if !knownJob(job) {
+		Jobs = append(Jobs, job)
+	}
+}
+
+const (
+	// pingTimeout bounds a single ping
+	pingTimeout = 10 * time.Second
+	// minSuccessInterval throttles success pings of jobs that run every minute;
+	// failures are always sent
+	minSuccessInterval = 5 * time.Minute
+	// maxFailureBody limits the error sent with a failure ping
+	maxFailureBody = 10 << 10
+	// maskedSecret replaces the auth header in responses; sending it back keeps the stored value
+	maskedSecret = "********"
+	// authHeaderContext binds the encrypted auth header to this setting
+	authHeaderContext = "system_settings.heartbeats.auth_header"
+)
+
+// Config configures heartbeat pings to an external uptime service in the style
+// of healthchecks.io: a run pings the job URL on success and the URL with
+// "/fail" appended on failure, with the error as body
+type Config struct {
+	Enabled bool `json:"enabled"`
+	// BaseURL is joined with the job name when the job has no URL of its own,
+	// e.g. https://hc-ping.com/<ping key> for slug-based checks
+	BaseURL string `json:"base_url"`
+	// URLs are the ping URLs of single jobs
+	URLs map[string]string `json:"urls"`
+	// AuthHeader is sent as the Authorization header of pings
+	AuthHeader string `json:"auth_header,omitempty"`
+}
+
+// JobStatus is the in-memory state of a job's heartbeats
+type JobStatus struct {
+	LastRunAt           *time.Time `json:"last_run_at"`
+	LastPingAt          *time.Time `json:"last_ping_at"`
+	LastOK              bool       `json:"last_ok"`
+	LastError           string     `json:"last_error,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	// PingError is why the last ping could not be delivered
+	PingError string `json:"ping_error,omitempty"`
+}
+
+var (
+	statusMu sync.Mutex
+	statuses = map[string]*JobStatus{}
+
+	client = &http.Client{Timeout: pingTimeout}
+)
+
+// LoadConfig returns the stored heartbeat configuration
+func LoadConfig(db *gorm.DB) (Config, error) {
+	var cfg Config
+	value, err := settings.Get(db, settings.KeyHeartbeats)
+	if err != nil || value == "" {
+		return cfg, err
+	}
+	if err := json.Unmarshal([]byte(value), &cfg); err != nil {
+		return cfg, fmt.Errorf("error decoding heartbeats: %v", err)
+	}
+	if cfg.AuthHeader, err = fieldcrypt.DecryptString(cfg.AuthHeader, authHeaderContext); err != nil {
+		return cfg, fmt.Errorf("error decrypting the auth header: %v", err)
+	}
+	return cfg, nil
+}
+
+// SaveConfig stores the heartbeat configuration
+func SaveConfig(db *gorm.DB, cfg Config, updatedBy *int) error {
+	// The auth header is a secret, so it is stored encrypted
+	authHeader, err := fieldcrypt.EncryptString(cfg.AuthHeader, authHeaderContext)
+	if err != nil {
+		return err
+	}
+	cfg.AuthHeader = authHeader
+
+	value, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return settings.Set(db, settings.KeyHeartbeats, string(value), updatedBy)
+}
+
+// Validate trims the URLs and checks that they are http(s) URLs of known jobs
+func (c *Config) Validate() error {
+	c.BaseURL = strings.TrimRight(strings.TrimSpace(c.BaseURL), "/")
+	c.AuthHeader = strings.TrimSpace(c.AuthHeader)
+
+	if c.BaseURL != "" {
+		if err := validateURL(c.BaseURL); err != nil {
+			return fmt.Errorf("base_url %v", err)
+		}
+	}
+
+	urls := make(map[string]string, len(c.URLs))
+	for job, raw := range c.URLs {
+		if !knownJob(job) {
+			return fmt.Errorf("unknown job %q; jobs are %s", job, strings.Join(Jobs, ", "))
+		}
+		raw = strings.TrimRight(strings.TrimSpace(raw), "/")
+		if raw == "" {
+			continue
+		}
+		if err := validateURL(raw); err != nil {
+			return fmt.Errorf("urls.%s %v", job, err)
+		}
+		urls[job] = raw
+	}
+	c.URLs = urls
+
+	if c.Enabled && c.BaseURL == "" && len(c.URLs) == 0 {
+		return fmt.Errorf("base_url or urls is required")
+	}
+	return nil
+}
+
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("must be an absolute http or https URL")
+	}
+	return nil
+}
+
+func knownJob(job string) bool {
+	for _, j := range Jobs {
+		if j == job {
+			return true
+		}
+	}
+	return false
+}
+
+// URL returns the ping URL of a job, or "" when it is not monitored
+func (c Config) URL(job string) string {
+	if u := c.URLs[job]; u != "" {
+		return u
+	}
+	if c.BaseURL != "" {
+		return c.BaseURL + "/" + job
+	}
+	return ""
+}
+
+// Ping reports a run of a background job, failed when err is set. The ping is
+// sent in the background, so a slow uptime service never delays the job.
+func Ping(db *gorm.DB, job string, runErr error) {
+	cfg, err := LoadConfig(db)
+	if err != nil {
+		log.Printf("Failed to load heartbeat configuration: %v", err)
+		return
+	}
+
+	if !record(job, runErr, cfg.Enabled && cfg.URL(job) != "") {
+		return
+	}
+	go func() {
+		deliver(job, Send(cfg, job, runErr))
+	}()
+}
+
+// Send pings the job URL with the given configuration and waits for the response
+func Send(cfg Config, job string, runErr error) error {
+	target := cfg.URL(job)
+	if target == "" {
+		return fmt.Errorf("no URL is configured for job %s", job)
+	}
+
+	var body io.Reader
+	if runErr != nil {
+		target += "/fail"
+		msg := runErr.Error()
+		if len(msg) > maxFailureBody {
+			msg = msg[:maxFailureBody]
+		}
+		body = strings.NewReader(msg)
+	}
+
+	req, err := http.NewRequest("POST", target, body)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "account-editor-heartbeat")
+	if cfg.AuthHeader != "" {
+		req.Header.Set("Authorization", cfg.AuthHeader)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// record updates the job status after a run and reports whether a ping should
+// be sent: always after a failure or a recovery, otherwise at most every few minutes
+func record(job string, runErr error, monitored bool) bool {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	s, ok := statuses[job]
+	if !ok {
+		s = &JobStatus{}
+		statuses[job] = s
+	}
+
+	now := time.Now()
+	recovered := s.ConsecutiveFailures > 0
+	s.LastRunAt = &now
+	s.LastOK = runErr == nil
+	if runErr != nil {
+		s.ConsecutiveFailures++
+		s.LastError = runErr.Error()
+	} else {
+		s.ConsecutiveFailures = 0
+		s.LastError = ""
+	}
+
+	if !monitored {
+		return false
+	}
+	if runErr == nil && !recovered && s.LastPingAt != nil && now.Sub(*s.LastPingAt) < minSuccessInterval {
+		return false
+	}
+	s.LastPingAt = &now
+	return true
+}
+
+// deliver records the outcome of a ping
+func deliver(job string, err error) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	s := statuses[job]
+	if err != nil {
+		s.PingError = err.Error()
+		// Retry with the next run instead of waiting out the throttle
+		s.LastPingAt = nil
+		log.Printf("Failed to send heartbeat of job %s: %v", job, err)
+		return
+	}
+	s.PingError = ""
+}
+
+// GetStatus returns the heartbeat state of every job that ran since the server started
+func GetStatus() map[string]JobStatus {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	result := make(map[string]JobStatus, len(statuses))
+	for job, s := range statuses {
+		result[job] = *s
+	}
+	return result
+}
+
+// monitoredJobs returns the jobs the configuration sends heartbeats for
+func (c Config) monitoredJobs() []string {
+	jobs := []string{}
+	for _, job := range Jobs {
+		if c.URL(job) != "" {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
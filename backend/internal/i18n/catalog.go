@@ -0,0 +1,252 @@
+package i18n
+
+// catalogs maps a locale to translations keyed by the English message.
+// English needs no entries since the keys are the English text.
+var catalogs = map[string]map[string]string{
+	"en": {},
+	"tr": {
+		// Authentication
+		"Authorization header is required":                   "Authorization başlığı gerekli",
+		"Authorization header format must be Bearer {token}": "Authorization başlığı Bearer {token} biçiminde olmalı",
+		"Invalid or expired token":                           "Geçersiz veya süresi dolmuş token",
+		"Invalid or expired session":                         "Geçersiz veya süresi dolmuş oturum",
+		"User not authenticated":                             "Kullanıcı doğrulanmadı",
+		"User not found":                                     "Kullanıcı bulunamadı",
+		"User is inactive":                                   "Kullanıcı pasif durumda",
+		"Admin access required":                              "Yönetici yetkisi gerekli",
+		"Your admin role does not allow this action":         "Yönetici rolün bu işleme izin vermiyor",
+		"Admin role must be superadmin or support":           "Yönetici rolü superadmin veya support olmalı",
+		"Invalid username or password":                       "Geçersiz kullanıcı adı veya şifre",
+		"Account is inactive. Please contact administrator.": "Hesap pasif durumda. Lütfen yöneticiyle iletişime geçin.",
+		"Logged out successfully":                            "Başarıyla çıkış yapıldı",
+		"Username already registered":                        "Kullanıcı adı zaten kayıtlı",
+		"User created successfully":                          "Kullanıcı başarıyla oluşturuldu",
+		"User updated successfully":                          "Kullanıcı başarıyla güncellendi",
+		"User deleted successfully":                          "Kullanıcı başarıyla silindi",
+		"Profile updated successfully":                       "Profil başarıyla güncellendi",
+		"Avatar updated successfully":                        "Profil resmi başarıyla güncellendi",
+		"Avatar removed":                                     "Profil resmi kaldırıldı",
+		"Avatar must be a PNG, JPEG or GIF image":            "Profil resmi PNG, JPEG veya GIF olmalı",
+		"Avatar must be at most 2 MB":                        "Profil resmi en fazla 2 MB olabilir",
+		"Avatar must be at most 2048x2048 pixels":            "Profil resmi en fazla 2048x2048 piksel olabilir",
+		"Image is required in the avatar field":              "avatar alanında bir resim gerekli",
+		"Failed to read uploaded file":                       "Yüklenen dosya okunamadı",
+		"Failed to store avatar":                             "Profil resmi kaydedilemedi",
+		"Failed to hash password":                            "Şifre işlenemedi",
+		"Failed to create user":                              "Kullanıcı oluşturulamadı",
+		"Unknown notification channel: %s":                   "Bilinmeyen bildirim kanalı: %s",
+		"Invalid or missing CSRF token":                      "Geçersiz veya eksik CSRF token",
+		"Current password is incorrect":                      "Mevcut şifre yanlış",
+		"Password must be at least 8 characters":             "Şifre en az 8 karakter olmalı",
+		"New password must differ from the current password": "Yeni şifre mevcut şifreden farklı olmalı",
+		"Password changed successfully":                      "Şifre başarıyla değiştirildi",
+		"Daily task quota reached":                           "Günlük görev kotası doldu",
+		"Quota warning":                                      "Kota uyarısı",
+		"Quota reached":                                      "Kota doldu",
+		"You have created %s of your %s tasks for today":     "Bugünkü %[2]s görevinizin %[1]s tanesini oluşturdunuz",
+		"You have used all %[2]s tasks for today; new tasks are rejected until tomorrow":                         "Bugünkü %[2]s görevin tamamını kullandınız; yarına kadar yeni görevler reddedilecek",
+		"Task results use %s of your %s storage":                                                                 "Görev sonuçları %[2]s depolama alanınızın %[1]s kadarını kullanıyor",
+		"Task results use %s of your %s storage; archive or delete old tasks":                                    "Görev sonuçları %[2]s depolama alanınızın %[1]s kadarını kullanıyor; eski görevleri arşivleyin veya silin",
+		"%s of your %s webhook deliveries for today have been sent":                                              "Bugünkü %[2]s webhook gönderiminizin %[1]s tanesi yapıldı",
+		"You have used all %[2]s webhook deliveries for today; webhook notifications are skipped until tomorrow": "Bugünkü %[2]s webhook gönderiminin tamamını kullandınız; yarına kadar webhook bildirimleri atlanacak",
+		"The batch is larger than your plan allows":                                                              "Toplu iş planınızın izin verdiğinden büyük",
+		"Your plan does not allow more renewal rules":                                                            "Planınız daha fazla yenileme kuralına izin vermiyor",
+		"Your plan does not allow more panel profiles":                                                           "Planınız daha fazla panel profiline izin vermiyor",
+		"Sub-accounts use the plan of their reseller account":                                                    "Alt hesaplar bayi hesaplarının planını kullanır",
+		"Plan name is required":                                                                                  "Plan adı gerekli",
+		"Plan limits cannot be negative":                                                                         "Plan limitleri negatif olamaz",
+		"A plan with this name already exists":                                                                   "Bu adla bir plan zaten var",
+		"Plan not found":                                                                                         "Plan bulunamadı",
+		"Plan is assigned to %d users":                                                                           "Plan %d kullanıcıya atanmış",
+		"Plan deleted":                                                                                           "Plan silindi",
+		"Billing is not enabled":                                                                                 "Faturalandırma etkin değil",
+		"Billing is not configured":                                                                              "Faturalandırma yapılandırılmamış",
+		"This plan cannot be purchased":                                                                          "Bu plan satın alınamaz",
+		"Failed to start checkout":                                                                               "Ödeme başlatılamadı",
+		"Plan downgraded":                                                                                        "Plan düşürüldü",
+		"Your %s subscription has ended and your account was moved to the default plan":                          "%s aboneliğiniz sona erdi ve hesabınız varsayılan plana taşındı",
+		"Code must be 4-40 letters, digits, dashes or underscores":                                               "Kod 4-40 harf, rakam, tire veya alt çizgiden oluşmalı",
+		"Limits cannot be negative":                                                                              "Limitler negatif olamaz",
+		"Plan coupons need a plan_id":                                                                            "Plan kuponları için plan_id gerekli",
+		"Credit coupons need a positive credit_amount":                                                           "Kredi kuponları için pozitif credit_amount gerekli",
+		"Kind must be plan or credit":                                                                            "Tür plan veya credit olmalı",
+		"A coupon with this code already exists":                                                                 "Bu kodla bir kupon zaten var",
+		"Coupon not found":                                                                                       "Kupon bulunamadı",
+		"Invalid or expired code":                                                                                "Geçersiz veya süresi dolmuş kod",
+		"This code has reached its redemption limit":                                                             "Bu kod kullanım sınırına ulaştı",
+		"You have already redeemed this code":                                                                    "Bu kodu zaten kullandınız",
+		"Your current plan cannot be replaced by this code":                                                      "Mevcut planınız bu kodla değiştirilemez",
+		"Code redeemed":                                                                                          "Kod kullanıldı",
+		"Invalid or expired recovery token":                                                                      "Geçersiz veya süresi dolmuş kurtarma anahtarı",
+		"No failed webhook delivery with this ID":                                                                "Bu kimliğe sahip başarısız bir webhook teslimatı yok",
+		"Webhook delivery queued":                                                                                "Webhook teslimatı kuyruğa alındı",
+		"Failed to save template":                                                                                "Şablon kaydedilemedi",
+		"Template not found":                                                                                     "Şablon bulunamadı",
+		"Template deleted":                                                                                       "Şablon silindi",
+		"Pattern and explanation are required":                                                                   "Desen ve açıklama gerekli",
+		"A mapping for this pattern already exists":                                                              "Bu desen için bir eşleme zaten var",
+		"Panel error mapping not found":                                                                          "Panel hatası eşlemesi bulunamadı",
+		"Panel error mapping deleted":                                                                            "Panel hatası eşlemesi silindi",
+		"Task completed":                                                                                         "Görev tamamlandı",
+		"Task failed":                                                                                            "Görev başarısız oldu",
+		"Task %s #%d completed":                                                                                  "%s görevi #%d tamamlandı",
+		"Task %s #%d failed: %s":                                                                                 "%s görevi #%d başarısız oldu: %s",
+		"%d tasks completed":                                                                                     "%d görev tamamlandı",
+		"%d tasks failed":                                                                                        "%d görev başarısız oldu",
+		"%d notifications":                                                                                       "%d bildirim",
+		"and %d more":                                                                                            "ve %d tane daha",
+		"Unknown timezone":                                                                                       "Bilinmeyen saat dilimi",
+		"Display name must be at most 100 characters":                                                            "Görünen ad en fazla 100 karakter olabilir",
+		"Failed to update profile":                                                                               "Profil güncellenemedi",
+		"Locale must look like en or en-US":                                                                      "Dil en veya en-US biçiminde olmalı",
+
+		"Terms of service acceptance required":                        "Kullanım koşullarının kabul edilmesi gerekiyor",
+		"Terms of service accepted":                                   "Kullanım koşulları kabul edildi",
+		"Terms of service version does not match the current version": "Kullanım koşulları sürümü güncel sürümle eşleşmiyor",
+
+		"Username must be 3-50 letters, digits, dots, dashes or underscores": "Kullanıcı adı 3-50 harf, rakam, nokta, tire veya alt çizgiden oluşmalı",
+		"Email must be a plain email address":                                "E-posta düz bir e-posta adresi olmalı",
+		"A signup with this email address is already pending":                "Bu e-posta adresiyle bekleyen bir kayıt zaten var",
+		"Verification email could not be sent":                               "Doğrulama e-postası gönderilemedi",
+		"Check your email to verify your address":                            "Adresinizi doğrulamak için e-postanızı kontrol edin",
+		"Verify your email address for %s":                                   "%s için e-posta adresinizi doğrulayın",
+		"Open this link within 24 hours to verify your email address:\n\n%s\n\nIf you did not sign up, ignore this email.": "E-posta adresinizi doğrulamak için bu bağlantıyı 24 saat içinde açın:\n\n%s\n\nKayıt olmadıysanız bu e-postayı yok sayın.",
+		"Invalid or expired verification link":                      "Geçersiz veya süresi dolmuş doğrulama bağlantısı",
+		"Email verified. An administrator will review your signup.": "E-posta doğrulandı. Bir yönetici kaydınızı inceleyecek.",
+		"Email verified. You can now log in.":                       "E-posta doğrulandı. Artık giriş yapabilirsiniz.",
+		"This signup was rejected":                                  "Bu kayıt reddedildi",
+		"Your %s account is ready":                                  "%s hesabınız hazır",
+		"Your signup was approved. You can now log in as %s.":       "Kaydınız onaylandı. Artık %s olarak giriş yapabilirsiniz.",
+		"Your %s signup":                                            "%s kaydınız",
+		"Your signup was not approved.":                             "Kaydınız onaylanmadı.",
+		"Your signup was not approved: %s":                          "Kaydınız onaylanmadı: %s",
+		"Signup not found":                                          "Kayıt bulunamadı",
+		"Signup is not awaiting approval":                           "Kayıt onay beklemiyor",
+		"Signup approved":                                           "Kayıt onaylandı",
+		"Signup rejected":                                           "Kayıt reddedildi",
+
+		"Admin accounts cannot be deleted by their owner":                                     "Yönetici hesapları sahipleri tarafından silinemez",
+		"Account deletion is already scheduled":                                               "Hesap silme zaten planlandı",
+		"Account deletion is not scheduled":                                                   "Hesap silme planlanmadı",
+		"Account deletion canceled":                                                           "Hesap silme iptal edildi",
+		"Your account will be deleted on %s unless you cancel":                                "İptal etmezseniz hesabınız %s tarihinde silinecek",
+		"Account deletion requested":                                                          "Hesap silme talep edildi",
+		"%s requested deletion of their account. It will be erased on %s unless they cancel.": "%s hesabının silinmesini talep etti. İptal etmezse hesap %s tarihinde silinecek.",
+
+		// Background jobs
+		"Background job %s is failing":                         "%s arka plan işi başarısız oluyor",
+		"The %s job failed %d times in a row: %s":              "%s işi art arda %d kez başarısız oldu: %s",
+		"Background job %s recovered":                          "%s arka plan işi düzeldi",
+		"The %s job ran successfully again after %d failures.": "%s işi %d başarısız denemeden sonra yeniden başarıyla çalıştı.",
+		"A run of this job is already queued":                  "Bu işin bir çalıştırması zaten sırada",
+		"Database integrity check failed":                      "Veritabanı bütünlük denetimi başarısız oldu",
+		"The SQLite integrity check found %d problems: %s":     "SQLite bütünlük denetimi %d sorun buldu: %s",
+
+		// Task presets
+		"package must be a positive number":                                            "package pozitif bir sayı olmalıdır",
+		"max_connections must be between 1 and %d":                                     "max_connections 1 ile %d arasında olmalıdır",
+		"Save your panel URL and API key in the settings before creating tasks":        "Görev oluşturmadan önce panel URL'nizi ve API anahtarınızı ayarlara kaydedin",
+		"Sub-accounts cannot use this endpoint":                                        "Alt hesaplar bu uç noktayı kullanamaz",
+		"The reseller account of this sub-account is inactive":                         "Bu alt hesabın bayi hesabı etkin değil",
+		"Sub-account not found":                                                        "Alt hesap bulunamadı",
+		"Sub-account revoked":                                                          "Alt hesap iptal edildi",
+		"You can have at most %d sub-accounts":                                         "En fazla %d alt hesabınız olabilir",
+		"Exclusion not found":                                                          "İstisna bulunamadı",
+		"Exclusion deleted":                                                            "İstisna silindi",
+		"Invalid request format":                                                       "Geçersiz istek biçimi",
+		"You already have a line named %s":                                             "%s adında bir hattınız zaten var",
+		"Package %d usually costs %.2f credits but your balance is %.2f":               "%d paketi genellikle %.2f krediye mal oluyor ancak bakiyeniz %.2f",
+		"note_template must be at most %d characters":                                  "note_template en fazla %d karakter olabilir",
+		"Import not found":                                                             "İçe aktarma bulunamadı",
+		"The file may be at most %d MB":                                                "Dosya en fazla %d MB olabilir",
+		"The file is not a readable CSV file":                                          "Dosya okunabilir bir CSV dosyası değil",
+		"A batch may contain at most %d tasks":                                         "Bir toplu işlem en fazla %d görev içerebilir",
+		"This import was already committed":                                            "Bu içe aktarma zaten onaylandı",
+		"This import has expired; upload the file again":                               "Bu içe aktarmanın süresi doldu; dosyayı yeniden yükleyin",
+		"Unknown task field: %s":                                                       "Bilinmeyen görev alanı: %s",
+		"The file has no column named %s":                                              "Dosyada %s adında bir sütun yok",
+		"Map a column to name or set the name of every task":                           "name alanına bir sütun eşleyin veya tüm görevlerin adını belirtin",
+		"The rows of this import have been deleted":                                    "Bu içe aktarmanın satırları silindi",
+		"This import has not been committed yet":                                       "Bu içe aktarma henüz onaylanmadı",
+		"Pattern is required":                                                          "Kalıp gerekli",
+		"Code must be one of credentials, credit, connectivity, not_found or rejected": "Kod credentials, credit, connectivity, not_found veya rejected olmalıdır",
+		"Provider must be a panel host or URL":                                         "Sağlayıcı bir panel ana bilgisayarı veya URL olmalıdır",
+		"A normalization for this pattern already exists":                              "Bu kalıp için bir normalleştirme zaten var",
+		"Normalization not found":                                                      "Normalleştirme bulunamadı",
+		"Normalization deleted":                                                        "Normalleştirme silindi",
+
+		// Tasks and settings
+		"Settings not found":                             "Ayarlar bulunamadı",
+		"Settings updated successfully":                  "Ayarlar başarıyla güncellendi",
+		"Failed to update settings":                      "Ayarlar güncellenemedi",
+		"Failed to load settings":                        "Ayarlar yüklenemedi",
+		"Webhook secret not set":                         "Webhook anahtarı ayarlanmamış",
+		"Webhook secret removed":                         "Webhook anahtarı kaldırıldı",
+		"Task not found":                                 "Görev bulunamadı",
+		"Task ID is required":                            "Görev kimliği gerekli",
+		"Failed to create task":                          "Görev oluşturulamadı",
+		"Failed to retrieve tasks":                       "Görevler alınamadı",
+		"Tasks created successfully":                     "Görevler başarıyla oluşturuldu",
+		"Invalid task ID":                                "Geçersiz görev kimliği",
+		"Failed to update task":                          "Görev güncellenemedi",
+		"older_than_minutes must be a positive integer":  "older_than_minutes pozitif bir tam sayı olmalı",
+		"Only pending or running tasks can be changed":   "Yalnızca bekleyen veya çalışan görevler değiştirilebilir",
+		"The task is still progressing and is not stuck": "Görev hâlâ ilerliyor, takılı değil",
+		"Task marked as failed":                          "Görev başarısız olarak işaretlendi",
+		"Task was stuck and has been marked as failed by an administrator":      "Görev takılı kaldı ve bir yönetici tarafından başarısız olarak işaretlendi",
+		"Task was created before requests were recorded and cannot be requeued": "Görev, istekler kaydedilmeden önce oluşturuldu ve yeniden kuyruğa alınamaz",
+		"Stored task request is invalid":                                        "Kayıtlı görev isteği geçersiz",
+		"Task owner has no settings configured":                                 "Görev sahibinin ayarları yapılandırılmamış",
+		"The task owner's panel rejected the saved API key":                     "Görev sahibinin paneli kayıtlı API anahtarını reddetti",
+		"Task requeued": "Görev yeniden kuyruğa alındı",
+		"Panel is unreachable; the batch will start when it recovers":  "Panele ulaşılamıyor; toplu işlem panel düzeldiğinde başlayacak",
+		"Outside the execution window; tasks will start when it opens": "Çalışma zaman aralığı dışında; görevler aralık açıldığında başlayacak",
+		"Failed to deliver the test event":                             "Test olayı iletilemedi",
+		"Test event delivered":                                         "Test olayı iletildi",
+		"Unknown job":                                                  "Bilinmeyen görev",
+		"No heartbeat URL is configured for this job":                  "Bu görev için heartbeat URL'si yapılandırılmamış",
+		"Failed to deliver the heartbeat":                              "Heartbeat iletilemedi",
+		"Heartbeat delivered":                                          "Heartbeat iletildi",
+		"Failed to export tenant data":                                 "Kiracı verileri dışa aktarılamadı",
+		"Client not found":                                             "İstemci bulunamadı",
+		"Client deleted":                                               "İstemci silindi",
+		"The client is not allowed to use this route":                  "İstemcinin bu işlemi kullanma izni yok",
+		"Widget not found":                                             "Widget bulunamadı",
+		"Widget deleted":                                               "Widget silindi",
+		"You can have at most %d widgets":                              "En fazla %d widget oluşturabilirsiniz",
+		"Renewal rule not found":                                       "Yenileme kuralı bulunamadı",
+		"Renewal rule deleted":                                         "Yenileme kuralı silindi",
+		"No tasks provided":                                            "Görev belirtilmedi",
+		"Invalid task request":                                         "Geçersiz görev isteği",
+		"Some rows are invalid":                                        "Bazı satırlar geçersiz",
+		"Database error":                                               "Veritabanı hatası",
+		"Internal server error":                                        "Sunucu hatası",
+
+		// Notifications and receipts
+		"Task %s completed":             "%s görevi tamamlandı",
+		"Task %s failed":                "%s görevi başarısız oldu",
+		"Your panel is unreachable":     "Paneline ulaşılamıyor",
+		"Your panel is reachable again": "Paneline yeniden ulaşılabiliyor",
+		"The panel at %s did not respond to health checks: %s. Tasks will fail until it recovers.":                     "%s adresindeki panel sağlık kontrollerine yanıt vermedi: %s. Panel düzelene kadar görevler başarısız olacak.",
+		"The panel at %s is responding again.":                                                                         "%s adresindeki panel yeniden yanıt veriyor.",
+		"Your panel API key was rejected":                                                                              "Panel API anahtarın reddedildi",
+		"Your panel API key works again":                                                                               "Panel API anahtarın yeniden çalışıyor",
+		"The panel at %s rejected your API key: %s. New tasks and renewals are paused until you update your settings.": "%s adresindeki panel API anahtarını reddetti: %s. Ayarlarını güncelleyene kadar yeni görevler ve yenilemeler duraklatıldı.",
+		"The panel at %s accepts your API key again. Tasks and renewals have resumed.":                                 "%s adresindeki panel API anahtarını yeniden kabul ediyor. Görevler ve yenilemeler devam ediyor.",
+		"Your panel rejected the saved API key. Update your settings before creating tasks.":                           "Panel kayıtlı API anahtarını reddetti. Görev oluşturmadan önce ayarlarını güncelle.",
+		"Receipt": "Makbuz",
+		"Documents are only available for completed create and extend tasks": "Belgeler yalnızca tamamlanmış oluşturma ve uzatma görevleri için kullanılabilir",
+		"Questions? Contact support:":                                        "Sorunuz mu var? Destekle iletişime geçin:",
+		"New line":                                                           "Yeni hat",
+		"Extension":                                                          "Uzatma",
+		"Task":                                                               "Görev",
+		"Date":                                                               "Tarih",
+		"Operation":                                                          "İşlem",
+		"Username":                                                           "Kullanıcı adı",
+		"Password":                                                           "Şifre",
+		"Line ID":                                                            "Hat kimliği",
+		"Valid until":                                                        "Geçerlilik sonu",
+		"Amount":                                                             "Tutar",
+		"Your login details":                                                 "Giriş bilgileriniz",
+	},
+}
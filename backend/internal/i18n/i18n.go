@@ -0,0 +1,109 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultLocale is used when neither the user nor the request asks for a supported locale
+const DefaultLocale = "en"
+
+// Supported returns the locales that have a message catalog
+func Supported() []string {
+	locales := make([]string, 0, len(catalogs))
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// match returns the supported locale for a language tag like "tr" or "tr-TR", or ""
+func match(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return ""
+	}
+	if _, ok := catalogs[tag]; ok {
+		return tag
+	}
+	if base, _, found := strings.Cut(tag, "-"); found {
+		if _, ok := catalogs[base]; ok {
+			return base
+		}
+	}
+	return ""
+}
+
+// ParseAcceptLanguage returns the best supported locale from an Accept-Language header, or ""
+func ParseAcceptLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if locale := match(tag); locale != "" && q > bestQ {
+			best, bestQ = locale, q
+		}
+	}
+	return best
+}
+
+// Resolve picks the locale from the user's stored preference, falling back to the
+// Accept-Language header and finally DefaultLocale
+func Resolve(preferred, acceptLanguage string) string {
+	if locale := match(preferred); locale != "" {
+		return locale
+	}
+	if locale := ParseAcceptLanguage(acceptLanguage); locale != "" {
+		return locale
+	}
+	return DefaultLocale
+}
+
+// ForUser returns the locale used for messages sent to a user outside of a request,
+// e.g. notification emails and receipts
+func ForUser(u models.User) string {
+	return Resolve(u.Locale, "")
+}
+
+// FromContext returns the locale for the current request
+func FromContext(c *gin.Context) string {
+	preferred := ""
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			preferred = u.Locale
+		}
+	}
+	return Resolve(preferred, c.GetHeader("Accept-Language"))
+}
+
+// Translate returns the message in the given locale. Messages are keyed by their
+// English text, so untranslated messages fall back to English unchanged.
+func Translate(locale, message string, args ...interface{}) string {
+	if translated, ok := catalogs[locale][message]; ok {
+		message = translated
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// T translates a message for the current request and sets the Content-Language header
+func T(c *gin.Context, message string, args ...interface{}) string {
+	locale := FromContext(c)
+	c.Header("Content-Language", locale)
+	return Translate(locale, message, args...)
+}
@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/settings"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultAlertAfter is how many consecutive failures alert admins when not configured
+	defaultAlertAfter = 3
+	// configTTL is how long the stored configuration is cached, so changes made
+	// on another instance apply within it
+	configTTL = time.Minute
+)
+
+// Schedule overrides the schedule of one job
+type Schedule struct {
+	// Interval is a duration such as "30m"; empty keeps the default
+	Interval string `json:"interval,omitempty"`
+	// Paused stops scheduled runs; manual runs still work
+	Paused bool `json:"paused,omitempty"`
+}
+
+// Config holds the schedule overrides and the alerting threshold
+type Config struct {
+	Schedules map[string]Schedule `json:"schedules"`
+	// AlertAfterFailures is how many consecutive failures of a job alert the
+	// superadmins; 3 when 0
+	AlertAfterFailures int `json:"alert_after_failures"`
+}
+
+// LoadConfig returns the stored job configuration
+func LoadConfig(db *gorm.DB) (Config, error) {
+	cfg := Config{Schedules: map[string]Schedule{}}
+	value, err := settings.Get(db, settings.KeyJobs)
+	if err != nil || value == "" {
+		return cfg, err
+	}
+	if err := json.Unmarshal([]byte(value), &cfg); err != nil {
+		return cfg, fmt.Errorf("error decoding job schedules: %v", err)
+	}
+	if cfg.Schedules == nil {
+		cfg.Schedules = map[string]Schedule{}
+	}
+	return cfg, nil
+}
+
+// SaveConfig stores the job configuration and applies it to the running jobs
+func SaveConfig(db *gorm.DB, cfg Config, updatedBy *int) error {
+	value, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := settings.Set(db, settings.KeyJobs, string(value), updatedBy); err != nil {
+		return err
+	}
+
+	cacheMu.Lock()
+	cached, cachedAt = cfg, time.Now()
+	cacheMu.Unlock()
+	Reschedule()
+	return nil
+}
+
+// Validate checks that the schedules are for registered jobs with intervals
+// they accept
+func (c *Config) Validate() error {
+	if c.AlertAfterFailures < 0 {
+		return fmt.Errorf("alert_after_failures must not be negative")
+	}
+	if c.Schedules == nil {
+		c.Schedules = map[string]Schedule{}
+	}
+	for name, s := range c.Schedules {
+		r, ok := lookup(name)
+		if !ok {
+			return fmt.Errorf("unknown job %q", name)
+		}
+		if s.Interval == "" {
+			continue
+		}
+		interval, err := time.ParseDuration(s.Interval)
+		if err != nil {
+			return fmt.Errorf("schedules.%s.interval: %v", name, err)
+		}
+		if interval < r.job.MinInterval {
+			return fmt.Errorf("schedules.%s.interval must be at least %s", name, r.job.MinInterval)
+		}
+		s.Interval = interval.String()
+		c.Schedules[name] = s
+	}
+	return nil
+}
+
+var (
+	cacheMu  sync.Mutex
+	cached   Config
+	cachedAt time.Time
+)
+
+// currentConfig returns the job configuration, cached for a minute
+func currentConfig() Config {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	if time.Since(cachedAt) < configTTL {
+		return cached
+	}
+	cfg, err := LoadConfig(database.GetDB())
+	if err != nil {
+		// Keep the previous configuration until the next attempt
+		log.Printf("Failed to load job schedules: %v", err)
+	} else {
+		cached = cfg
+	}
+	cachedAt = time.Now()
+	return cached
+}
+
+// effectiveSchedule is the schedule a job runs on
+type effectiveSchedule struct {
+	interval time.Duration
+	paused   bool
+}
+
+// scheduleOf returns the job's schedule with the stored overrides applied
+func scheduleOf(job Job) effectiveSchedule {
+	schedule := effectiveSchedule{interval: job.Interval}
+	override, ok := currentConfig().Schedules[job.Name]
+	if !ok {
+		return schedule
+	}
+	schedule.paused = override.Paused
+	if interval, err := time.ParseDuration(override.Interval); err == nil && interval >= job.MinInterval {
+		schedule.interval = interval
+	}
+	return schedule
+}
+
+// alertThreshold returns how many consecutive failures alert the superadmins
+func alertThreshold() int {
+	if n := currentConfig().AlertAfterFailures; n > 0 {
+		return n
+	}
+	return defaultAlertAfter
+}
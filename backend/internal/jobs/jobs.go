@@ -0,0 +1,322 @@
+// Package jobs runs the server's periodic background work. Packages register
+// their jobs with a default interval; the registry runs each job in its own
+// goroutine, never overlapping with itself, and records the outcome of every
+// run, which is reported as a heartbeat and alerts admins when a job keeps
+// failing. Admins can override intervals, pause jobs and trigger runs.
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownJob is returned for a job name that is not registered
+var ErrUnknownJob = errors.New("unknown job")
+
+// ErrAlreadyQueued is returned when a manual run is already waiting to start
+var ErrAlreadyQueued = errors.New("a run of this job is already queued")
+
+// Job is a unit of periodic work
+type Job struct {
+	// Name identifies the job in the API, schedules and heartbeats
+	Name        string
+	Description string
+	// Interval is the default time between the start of two runs
+	Interval time.Duration
+	// MinInterval bounds the interval an admin can set; 10 seconds when 0
+	MinInterval time.Duration
+	// RunOnStart runs the job when it is registered instead of after the first interval
+	RunOnStart bool
+	// Wake, when set, runs the job early, e.g. when new work is queued
+	Wake <-chan struct{}
+	// Run does the work; an error marks the run as failed
+	Run func() error
+}
+
+// Status is the state of a job since the server started
+type Status struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Interval is the effective interval and DefaultInterval the one of the code
+	Interval        string `json:"interval"`
+	DefaultInterval string `json:"default_interval"`
+	Paused          bool   `json:"paused"`
+	Running         bool   `json:"running"`
+	Queued          bool   `json:"queued"`
+
+	Runs                int        `json:"runs"`
+	Failures            int        `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastStartedAt       *time.Time `json:"last_started_at"`
+	LastFinishedAt      *time.Time `json:"last_finished_at"`
+	LastDurationMS      int64      `json:"last_duration_ms"`
+	LastOK              bool       `json:"last_ok"`
+	LastError           string     `json:"last_error,omitempty"`
+	NextRunAt           *time.Time `json:"next_run_at"`
+}
+
+// Alert is sent when a job reached the failure threshold, and once more when
+// it recovered
+type Alert struct {
+	Job                 string
+	Recovered           bool
+	ConsecutiveFailures int
+	Error               string
+}
+
+type registered struct {
+	job Job
+	// trigger queues a manual run, reschedule recomputes the next run
+	trigger    chan struct{}
+	reschedule chan struct{}
+
+	mu     sync.Mutex
+	status Status
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*registered{}
+
+	hooksMu     sync.RWMutex
+	heartbeatFn func(job string, err error)
+	alertFn     func(Alert)
+)
+
+// SetHeartbeatFunc sets how the outcome of every run is reported to the
+// external uptime service
+func SetHeartbeatFunc(fn func(job string, err error)) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	heartbeatFn = fn
+}
+
+// SetAlertFunc sets how admins are alerted of failing jobs
+func SetAlertFunc(fn func(Alert)) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	alertFn = fn
+}
+
+// Register adds a job and starts running it. Job names must be unique.
+func Register(job Job) {
+	if job.MinInterval <= 0 {
+		job.MinInterval = 10 * time.Second
+	}
+
+	r := &registered{
+		job:        job,
+		trigger:    make(chan struct{}, 1),
+		reschedule: make(chan struct{}, 1),
+		status: Status{
+			Name:            job.Name,
+			Description:     job.Description,
+			DefaultInterval: job.Interval.String(),
+		},
+	}
+
+	registryMu.Lock()
+	if _, exists := registry[job.Name]; exists {
+		registryMu.Unlock()
+		panic(fmt.Sprintf("jobs: job %q registered twice", job.Name))
+	}
+	registry[job.Name] = r
+	registryMu.Unlock()
+
+	go r.loop()
+}
+
+// lookup returns a registered job
+func lookup(name string) (*registered, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	r, ok := registry[name]
+	return r, ok
+}
+
+// all returns the registered jobs by name
+func all() []*registered {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	jobs := make([]*registered, 0, len(registry))
+	for _, r := range registry {
+		jobs = append(jobs, r)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].job.Name < jobs[j].job.Name })
+	return jobs
+}
+
+// Names returns the names of the registered jobs
+func Names() []string {
+	names := []string{}
+	for _, r := range all() {
+		names = append(names, r.job.Name)
+	}
+	return names
+}
+
+// Statuses returns the state of every registered job, by name
+func Statuses() []Status {
+	statuses := []Status{}
+	for _, r := range all() {
+		statuses = append(statuses, r.snapshot())
+	}
+	return statuses
+}
+
+// Trigger queues a run of the job outside its schedule, also while it is
+// paused. The run starts once a running one finished.
+func Trigger(name string) error {
+	r, ok := lookup(name)
+	if !ok {
+		return ErrUnknownJob
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status.Queued {
+		return ErrAlreadyQueued
+	}
+	select {
+	case r.trigger <- struct{}{}:
+		r.status.Queued = true
+		return nil
+	default:
+		return ErrAlreadyQueued
+	}
+}
+
+// Reschedule makes every job pick up changed schedules
+func Reschedule() {
+	for _, r := range all() {
+		select {
+		case r.reschedule <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (r *registered) snapshot() Status {
+	schedule := scheduleOf(r.job)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.status
+	s.Interval = schedule.interval.String()
+	s.Paused = schedule.paused
+	if s.Paused {
+		s.NextRunAt = nil
+	}
+	return s
+}
+
+// loop runs the job on its schedule until the server stops
+func (r *registered) loop() {
+	last := time.Now()
+	if r.job.RunOnStart {
+		r.run()
+		last = time.Now()
+	}
+
+	for {
+		schedule := scheduleOf(r.job)
+		next := last.Add(schedule.interval)
+		r.mu.Lock()
+		r.status.NextRunAt = &next
+		r.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		var due bool
+		select {
+		case <-timer.C:
+			due = !schedule.paused
+			last = time.Now()
+		case <-r.job.Wake:
+			due = !schedule.paused
+		case <-r.trigger:
+			due = true
+		case <-r.reschedule:
+		}
+		timer.Stop()
+
+		if due {
+			r.run()
+			last = time.Now()
+		}
+	}
+}
+
+// run runs the job once and records the outcome
+func (r *registered) run() {
+	start := time.Now()
+	r.mu.Lock()
+	r.status.Running = true
+	r.status.Queued = false
+	r.status.LastStartedAt = &start
+	r.mu.Unlock()
+
+	err := r.safeRun()
+
+	finished := time.Now()
+	r.mu.Lock()
+	previousFailures := r.status.ConsecutiveFailures
+	r.status.Running = false
+	r.status.Runs++
+	r.status.LastFinishedAt = &finished
+	r.status.LastDurationMS = finished.Sub(start).Milliseconds()
+	r.status.LastOK = err == nil
+	if err != nil {
+		r.status.Failures++
+		r.status.ConsecutiveFailures++
+		r.status.LastError = err.Error()
+	} else {
+		r.status.ConsecutiveFailures = 0
+		r.status.LastError = ""
+	}
+	failures := r.status.ConsecutiveFailures
+	r.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Job %s failed: %v", r.job.Name, err)
+	}
+	hooksMu.RLock()
+	ping := heartbeatFn
+	hooksMu.RUnlock()
+	if ping != nil {
+		ping(r.job.Name, err)
+	}
+
+	threshold := alertThreshold()
+	switch {
+	case err != nil && failures == threshold:
+		alert(Alert{Job: r.job.Name, ConsecutiveFailures: failures, Error: err.Error()})
+	case err == nil && previousFailures >= threshold:
+		alert(Alert{Job: r.job.Name, Recovered: true, ConsecutiveFailures: previousFailures})
+	}
+}
+
+// safeRun runs the job, turning a panic into a failed run
+func (r *registered) safeRun() (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Job %s panicked: %v\n%s", r.job.Name, p, debug.Stack())
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return r.job.Run()
+}
+
+func alert(a Alert) {
+	hooksMu.RLock()
+	fn := alertFn
+	hooksMu.RUnlock()
+	if fn != nil {
+		go fn(a)
+	}
+}
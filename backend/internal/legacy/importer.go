@@ -0,0 +1,279 @@
+package legacy
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Options controls how the legacy database is imported
+type Options struct {
+	// SourcePath is the path to the legacy FastAPI/SQLAlchemy SQLite database
+	SourcePath string
+	// DryRun reports what would be imported without writing anything
+	DryRun bool
+	// SkipIncompatible skips users whose password hash cannot be verified by this backend.
+	// When false they are imported as inactive so an admin can reset their password.
+	SkipIncompatible bool
+}
+
+// Report summarizes an import run
+type Report struct {
+	UsersImported       int
+	UsersSkipped        int
+	IncompatibleHashes  []string
+	TasksImported       int
+	TasksSkipped        int
+	SettingsImported    int
+	SettingsSkipped     int
+	DeactivatedUsers    []string
+	SkippedUsernames    []string
+	SourceTablesMissing []string
+}
+
+type legacyUser struct {
+	ID             int
+	Username       string
+	HashedPassword string
+	IsActive       sql.NullBool
+	IsAdmin        sql.NullBool
+	CreatedAt      sql.NullTime
+}
+
+type legacyTask struct {
+	ID            int
+	UserID        int
+	Name          string
+	TargetWebsite sql.NullString
+	Status        sql.NullString
+	Result        sql.NullString
+	CreatedAt     sql.NullTime
+	UpdatedAt     sql.NullTime
+	CompletedAt   sql.NullTime
+}
+
+type legacySettings struct {
+	UserID     int
+	WebsiteURL sql.NullString
+	APIKey     sql.NullString
+	AuthUser   sql.NullString
+}
+
+// IsCompatibleHash reports whether a stored password hash can be verified with bcrypt.
+// passlib's default bcrypt scheme produces $2b$ hashes; other schemes (pbkdf2, argon2,
+// sha256_crypt) are not supported by this backend.
+func IsCompatibleHash(hash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Import copies users, tasks and settings from the legacy database into db
+func Import(db *gorm.DB, opts Options) (*Report, error) {
+	source, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=ro", opts.SourcePath)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error opening legacy database: %v", err)
+	}
+
+	report := &Report{}
+	migrator := source.Migrator()
+	for _, table := range []string{"users", "automation_tasks", "user_settings"} {
+		if !migrator.HasTable(table) {
+			report.SourceTablesMissing = append(report.SourceTablesMissing, table)
+		}
+	}
+	if !migrator.HasTable("users") {
+		return report, fmt.Errorf("legacy database has no users table")
+	}
+
+	var users []legacyUser
+	if err := source.Raw(selectColumns(source, "users", []string{
+		"id", "username", "hashed_password", "is_active", "is_admin", "created_at",
+	})).Scan(&users).Error; err != nil {
+		return nil, fmt.Errorf("error reading legacy users: %v", err)
+	}
+
+	var tasks []legacyTask
+	if migrator.HasTable("automation_tasks") {
+		if err := source.Raw(selectColumns(source, "automation_tasks", []string{
+			"id", "user_id", "name", "target_website", "status", "result", "created_at", "updated_at", "completed_at",
+		})).Scan(&tasks).Error; err != nil {
+			return nil, fmt.Errorf("error reading legacy tasks: %v", err)
+		}
+	}
+
+	var settings []legacySettings
+	if migrator.HasTable("user_settings") {
+		if err := source.Raw(selectColumns(source, "user_settings", []string{
+			"user_id", "website_url", "api_key", "auth_user",
+		})).Scan(&settings).Error; err != nil {
+			return nil, fmt.Errorf("error reading legacy settings: %v", err)
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Maps legacy user IDs to the IDs assigned in the current schema
+		userIDs := make(map[int]int)
+
+		for _, lu := range users {
+			var existing models.User
+			if err := tx.Where("username = ?", lu.Username).First(&existing).Error; err == nil {
+				report.UsersSkipped++
+				report.SkippedUsernames = append(report.SkippedUsernames, lu.Username)
+				continue
+			} else if err != gorm.ErrRecordNotFound {
+				return err
+			}
+
+			isActive := !lu.IsActive.Valid || lu.IsActive.Bool
+			if !IsCompatibleHash(lu.HashedPassword) {
+				report.IncompatibleHashes = append(report.IncompatibleHashes, lu.Username)
+				if opts.SkipIncompatible {
+					report.UsersSkipped++
+					continue
+				}
+				if isActive {
+					report.DeactivatedUsers = append(report.DeactivatedUsers, lu.Username)
+				}
+				isActive = false
+			}
+
+			user := models.User{
+				Username:       lu.Username,
+				HashedPassword: lu.HashedPassword,
+				IsActive:       isActive,
+				IsAdmin:        lu.IsAdmin.Valid && lu.IsAdmin.Bool,
+			}
+			if lu.CreatedAt.Valid {
+				user.CreatedAt = lu.CreatedAt.Time
+			}
+
+			if err := tx.Create(&user).Error; err != nil {
+				return fmt.Errorf("error importing user %s: %v", lu.Username, err)
+			}
+			// GORM skips false values for columns with a default, so set them explicitly
+			if !isActive {
+				if err := tx.Model(&user).Update("is_active", false).Error; err != nil {
+					return fmt.Errorf("error deactivating user %s: %v", lu.Username, err)
+				}
+			}
+			userIDs[lu.ID] = user.ID
+			report.UsersImported++
+		}
+
+		for _, lt := range tasks {
+			userID, ok := userIDs[lt.UserID]
+			if !ok {
+				report.TasksSkipped++
+				continue
+			}
+
+			task := models.AutomationTask{
+				UserID:        userID,
+				Name:          lt.Name,
+				TargetWebsite: lt.TargetWebsite.String,
+				Status:        lt.Status.String,
+			}
+			if lt.Result.Valid {
+				task.Result = models.JSON(lt.Result.String)
+			}
+			if lt.CreatedAt.Valid {
+				task.CreatedAt = lt.CreatedAt.Time
+			}
+			if lt.UpdatedAt.Valid {
+				task.UpdatedAt = lt.UpdatedAt.Time
+			}
+			if lt.CompletedAt.Valid {
+				completedAt := lt.CompletedAt.Time
+				task.CompletedAt = &completedAt
+			}
+
+			if err := tx.Create(&task).Error; err != nil {
+				return fmt.Errorf("error importing task %d: %v", lt.ID, err)
+			}
+			report.TasksImported++
+		}
+
+		for _, ls := range settings {
+			userID, ok := userIDs[ls.UserID]
+			if !ok {
+				report.SettingsSkipped++
+				continue
+			}
+
+			s := models.UserSettings{
+				UserID:     userID,
+				WebsiteURL: ls.WebsiteURL.String,
+				APIKey:     ls.APIKey.String,
+				AuthUser:   ls.AuthUser.String,
+			}
+			if err := tx.Create(&s).Error; err != nil {
+				return fmt.Errorf("error importing settings for legacy user %d: %v", ls.UserID, err)
+			}
+			report.SettingsImported++
+		}
+
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+
+	if err != nil && err != errDryRun {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+var errDryRun = fmt.Errorf("dry run")
+
+// selectColumns builds a SELECT for the wanted columns, substituting NULL for
+// columns that older legacy schema versions did not have
+func selectColumns(db *gorm.DB, table string, columns []string) string {
+	migrator := db.Migrator()
+	parts := make([]string, len(columns))
+	for i, col := range columns {
+		if migrator.HasColumn(table, col) {
+			parts[i] = col
+		} else {
+			parts[i] = "NULL AS " + col
+		}
+	}
+	return fmt.Sprintf("SELECT %s FROM %s", strings.Join(parts, ", "), table)
+}
+
+// LogReport prints a human readable summary of the import
+func (r *Report) LogReport(dryRun bool) {
+	prefix := ""
+	if dryRun {
+		prefix = "[dry run] "
+	}
+
+	for _, table := range r.SourceTablesMissing {
+		log.Printf("%sLegacy table %s not found, skipped", prefix, table)
+	}
+	log.Printf("%sUsers: %d imported, %d skipped", prefix, r.UsersImported, r.UsersSkipped)
+	if len(r.SkippedUsernames) > 0 {
+		log.Printf("%sUsernames already present: %s", prefix, strings.Join(r.SkippedUsernames, ", "))
+	}
+	if len(r.IncompatibleHashes) > 0 {
+		log.Printf("%sUsers with unsupported password hashes: %s", prefix, strings.Join(r.IncompatibleHashes, ", "))
+	}
+	if len(r.DeactivatedUsers) > 0 {
+		log.Printf("%sImported as inactive (password reset required): %s", prefix, strings.Join(r.DeactivatedUsers, ", "))
+	}
+	log.Printf("%sTasks: %d imported, %d skipped", prefix, r.TasksImported, r.TasksSkipped)
+	log.Printf("%sSettings: %d imported, %d skipped", prefix, r.SettingsImported, r.SettingsSkipped)
+}
@@ -0,0 +1,83 @@
+package maintenance
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/heartbeat"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"gorm.io/gorm"
+)
+
+// archiveBatchSize limits how many tasks are moved per transaction
+const archiveBatchSize = 500
+
+// ArchiveTasks moves finished tasks created before cutoff into automation_tasks_archive
+// and returns how many were moved
+func ArchiveTasks(db *gorm.DB, cutoff time.Time) (int, error) {
+	total := 0
+
+	for {
+		var ids []int
+		if err := db.Raw(
+			"SELECT id FROM automation_tasks WHERE status IN ('completed', 'failed') AND created_at < ? ORDER BY id LIMIT ?",
+			cutoff, archiveBatchSize,
+		).Scan(&ids).Error; err != nil {
+			return total, fmt.Errorf("error selecting tasks to archive: %v", err)
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// The request is left behind: it holds the line password and
+			// finished tasks are never requeued
+			if err := tx.Exec(`INSERT INTO automation_tasks_archive
+				(id, user_id, name, target_website, status, result, result_gzip, result_compressed, created_at, updated_at, completed_at, archived_at)
+				SELECT id, user_id, name, target_website, status, result, result_gzip, result_compressed, created_at, updated_at, completed_at, ?
+				FROM automation_tasks WHERE id IN ?`, time.Now(), ids).Error; err != nil {
+				return err
+			}
+			return tx.Exec("DELETE FROM automation_tasks WHERE id IN ?", ids).Error
+		})
+		if err != nil {
+			return total, fmt.Errorf("error archiving tasks: %v", err)
+		}
+
+		total += len(ids)
+	}
+}
+
+// StartTaskArchiver archives tasks past the retention window once a day
+func StartTaskArchiver(db *gorm.DB) {
+	days := config.Get().TaskRetentionDays
+	if days <= 0 {
+		log.Println("Task archiving disabled")
+		return
+	}
+
+	jobs.Register(jobs.Job{
+		Name:        heartbeat.JobTaskArchive,
+		Description: "Move finished tasks past the retention window to the archive",
+		Interval:    24 * time.Hour,
+		RunOnStart:  true,
+		Run: func() error {
+			// Tasks archived before requests were left behind still have one
+			if err := db.Exec("UPDATE automation_tasks_archive SET request = NULL WHERE request IS NOT NULL").Error; err != nil {
+				return fmt.Errorf("error clearing archived requests: %v", err)
+			}
+
+			cutoff := time.Now().AddDate(0, 0, -days)
+			moved, err := ArchiveTasks(db, cutoff)
+			if err != nil {
+				return err
+			}
+			if moved > 0 {
+				log.Printf("Archived %d tasks older than %d days", moved, days)
+			}
+			return nil
+		},
+	})
+}
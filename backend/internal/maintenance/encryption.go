@@ -0,0 +1,140 @@
+package maintenance
+
+import (
+	"fmt"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/fieldcrypt"
+	"github.com/aliselcukkaya/account-editor/internal/heartbeat"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+)
+
+// EncryptedColumn counts the stored values of an encrypted column by the key
+// they are encrypted with, "clear" for values stored before encryption
+type EncryptedColumn struct {
+	Column    string           `json:"column"`
+	Keys      map[string]int64 `json:"keys"`
+	Rewritten int64            `json:"rewritten"`
+}
+
+// EncryptionReport describes a re-encryption run
+type EncryptionReport struct {
+	DryRun     bool              `json:"dry_run"`
+	CurrentKey string            `json:"current_key"`
+	Columns    []EncryptedColumn `json:"columns"`
+	Settings   []string          `json:"settings"`
+}
+
+// encryptedColumns are the columns stored with the encrypted serializers. Each
+// entry rewrites the rows of one table whose value is not under the current key.
+var encryptedColumns = []struct {
+	table   string
+	column  string
+	rewrite func(db *gorm.DB, ids []int) error
+}{
+	{"user_settings", "api_key", func(db *gorm.DB, ids []int) error {
+		return rewriteColumn[models.UserSettings](db, "api_key", ids)
+	}},
+	{"user_settings", "webhook_secret", func(db *gorm.DB, ids []int) error {
+		return rewriteColumn[models.UserSettings](db, "webhook_secret", ids)
+	}},
+	{"webhook_deliveries", "url", func(db *gorm.DB, ids []int) error {
+		return rewriteColumn[models.WebhookDelivery](db, "url", ids)
+	}},
+	{"automation_tasks", "request", func(db *gorm.DB, ids []int) error {
+		return rewriteColumn[models.AutomationTask](db, "request", ids)
+	}},
+	{"task_imports", "records", func(db *gorm.DB, ids []int) error {
+		return rewriteColumn[models.TaskImport](db, "records", ids)
+	}},
+	{"users", "notification_defaults", func(db *gorm.DB, ids []int) error {
+		return rewriteColumn[models.User](db, "notification_defaults", ids)
+	}},
+}
+
+// rewriteColumn loads the rows through their serializer and saves the column
+// again, which encrypts it with the current key
+func rewriteColumn[T any](db *gorm.DB, column string, ids []int) error {
+	var rows []T
+	if err := db.Select("id", column).Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return err
+	}
+	for i := range rows {
+		if err := db.Model(&rows[i]).Select(column).UpdateColumns(&rows[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReencryptFields encrypts every stored secret with the current key: values
+// stored in clear and values under a previous key. Once it ran, previous keys
+// can be removed from FIELD_ENCRYPTION_PREVIOUS_KEYS. A dry run only counts
+// the values by key.
+func ReencryptFields(db *gorm.DB, dryRun bool) (*EncryptionReport, error) {
+	if !fieldcrypt.Enabled() && !dryRun {
+		return nil, fmt.Errorf("FIELD_ENCRYPTION_KEY is not set")
+	}
+	current := fieldcrypt.CurrentKeyID()
+	report := &EncryptionReport{DryRun: dryRun, CurrentKey: current, Columns: []EncryptedColumn{}, Settings: []string{}}
+
+	for _, ec := range encryptedColumns {
+		col := EncryptedColumn{Column: ec.table + "." + ec.column, Keys: map[string]int64{}}
+
+		var rows []struct {
+			ID    int
+			Value string
+		}
+		if err := db.Table(ec.table).Select("id, " + ec.column + " AS value").
+			Where(ec.column + " IS NOT NULL AND " + ec.column + " <> ''").Scan(&rows).Error; err != nil {
+			return report, fmt.Errorf("error reading %s: %v", col.Column, err)
+		}
+
+		stale := []int{}
+		for _, row := range rows {
+			id := fieldcrypt.KeyID(row.Value)
+			if id == "" {
+				id = "clear"
+			}
+			col.Keys[id]++
+			if id != current {
+				stale = append(stale, row.ID)
+			}
+		}
+
+		if !dryRun {
+			for start := 0; start < len(stale); start += 100 {
+				end := min(start+100, len(stale))
+				if err := ec.rewrite(db, stale[start:end]); err != nil {
+					return report, fmt.Errorf("error re-encrypting %s: %v", col.Column, err)
+				}
+			}
+			col.Rewritten = int64(len(stale))
+		}
+		report.Columns = append(report.Columns, col)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	// Secrets inside settings documents are encrypted when they are saved
+	if cfg, err := heartbeat.LoadConfig(db); err != nil {
+		return report, err
+	} else if cfg.AuthHeader != "" {
+		if err := heartbeat.SaveConfig(db, cfg, nil); err != nil {
+			return report, err
+		}
+		report.Settings = append(report.Settings, "heartbeats")
+	}
+	if cfg, err := audit.LoadForwardConfig(db); err != nil {
+		return report, err
+	} else if cfg.AuthHeader != "" {
+		if err := audit.SaveForwardConfig(db, cfg, nil); err != nil {
+			return report, err
+		}
+		report.Settings = append(report.Settings, "audit.forwarding")
+	}
+	return report, nil
+}
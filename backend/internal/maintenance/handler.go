@@ -0,0 +1,91 @@
+package maintenance
+
+import (
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// NormalizeTaskResults repairs or quarantines invalid task results (admin only)
+func NormalizeTaskResults(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+
+	report, err := NormalizeResults(database.GetDB(), dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to normalize task results"})
+		return
+	}
+
+	if !dryRun {
+		audit.Record(c, audit.ActionMaintenance, "tasks", nil, map[string]interface{}{
+			"operation":   "normalize_results",
+			"repaired":    report.Repaired,
+			"unwrapped":   report.Unwrapped,
+			"quarantined": report.Quarantined,
+		})
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetDBStatus reports database size, page statistics and the latest maintenance results (admin only)
+func GetDBStatus(c *gin.Context) {
+	db := database.GetDB()
+
+	var pageCount, pageSize, freelistCount int64
+	var journalMode string
+	db.Raw("PRAGMA page_count").Scan(&pageCount)
+	db.Raw("PRAGMA page_size").Scan(&pageSize)
+	db.Raw("PRAGMA freelist_count").Scan(&freelistCount)
+	db.Raw("PRAGMA journal_mode").Scan(&journalMode)
+
+	integrity, vacuum := LastMaintenance()
+	healthy := integrity == nil || integrity.OK
+
+	c.JSON(http.StatusOK, gin.H{
+		"healthy":        healthy,
+		"file_size":      databaseFileSize(),
+		"page_count":     pageCount,
+		"page_size":      pageSize,
+		"freelist_count": freelistCount,
+		"journal_mode":   journalMode,
+		"maintenance":    gin.H{"window": config.Get().DBMaintenanceWindow, "enabled": config.Get().DBMaintenanceEnabled},
+		"last_integrity": integrity,
+		"last_vacuum":    vacuum,
+	})
+}
+
+// RunDBMaintenance triggers an integrity check or vacuum immediately (admin only)
+func RunDBMaintenance(c *gin.Context) {
+	db := database.GetDB()
+
+	switch c.Query("action") {
+	case "integrity_check":
+		result, err := RunIntegrityCheck(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run integrity check"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	case "vacuum":
+		result := RunVacuum(db)
+		audit.Record(c, audit.ActionMaintenance, "database", nil, map[string]interface{}{"operation": "vacuum", "ok": result.OK})
+		c.JSON(http.StatusOK, result)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be integrity_check or vacuum"})
+	}
+}
+
+// SetupAdminRoutes configures the maintenance routes for admins
+func SetupAdminRoutes(router *gin.RouterGroup) {
+	router.POST("/maintenance/normalize-results", NormalizeTaskResults)
+	router.GET("/db/status", GetDBStatus)
+	router.POST("/db/maintenance", RunDBMaintenance)
+	router.GET("/jobs", GetJobs)
+	router.POST("/jobs/:name/run", RunJob)
+	router.GET("/settings/jobs", GetJobSettings)
+	router.PUT("/settings/jobs", UpdateJobSettings)
+}
@@ -0,0 +1,80 @@
+package maintenance
+
+import (
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetJobs lists the background jobs with their schedule and last run (admin only)
+func GetJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs.Statuses()})
+}
+
+// RunJob queues a run of a background job outside its schedule (admin only)
+func RunJob(c *gin.Context) {
+	name := c.Param("name")
+	switch err := jobs.Trigger(name); err {
+	case nil:
+	case jobs.ErrUnknownJob:
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Unknown job")})
+		return
+	case jobs.ErrAlreadyQueued:
+		c.JSON(http.StatusConflict, gin.H{"error": i18n.T(c, "A run of this job is already queued")})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	audit.Record(c, audit.ActionJobTriggered, "job", name, nil)
+
+	c.JSON(http.StatusAccepted, gin.H{"queued": true, "job": name})
+}
+
+// GetJobSettings returns the schedule overrides and alerting threshold (admin only)
+func GetJobSettings(c *gin.Context) {
+	cfg, err := jobs.LoadConfig(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to load settings")})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"config": cfg, "jobs": jobs.Names()})
+}
+
+// UpdateJobSettings replaces the schedule overrides and alerting threshold (admin only)
+func UpdateJobSettings(c *gin.Context) {
+	var req jobs.Config
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var updatedBy *int
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			updatedBy = &u.ID
+		}
+	}
+
+	if err := jobs.SaveConfig(database.GetDB(), req, updatedBy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update settings")})
+		return
+	}
+
+	audit.Record(c, audit.ActionJobSchedulesUpdated, "settings", "jobs", map[string]interface{}{
+		"schedules":            req.Schedules,
+		"alert_after_failures": req.AlertAfterFailures,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"config": req, "jobs": jobs.Statuses()})
+}
@@ -0,0 +1,138 @@
+package maintenance
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+)
+
+// Placeholder results written in place of missing or unreadable data
+var (
+	missingResult     = []byte(`{"success":false,"error":"Result data was not recorded"}`)
+	quarantinedResult = []byte(`{"success":false,"error":"Result data was unreadable and has been quarantined"}`)
+)
+
+// NormalizeReport holds statistics from a result normalization run
+type NormalizeReport struct {
+	DryRun      bool  `json:"dry_run"`
+	Scanned     int   `json:"scanned"`
+	Valid       int   `json:"valid"`
+	Pending     int   `json:"pending"`
+	Repaired    int   `json:"repaired"`
+	Unwrapped   int   `json:"unwrapped"`
+	Quarantined int   `json:"quarantined"`
+	TaskIDs     []int `json:"task_ids"`
+}
+
+type resultRow struct {
+	ID               int
+	Status           string
+	Result           sql.NullString
+	ResultGzip       []byte
+	ResultCompressed bool
+}
+
+// NormalizeResults scans automation_tasks.result for NULL or invalid JSON.
+// Finished tasks with no result get a placeholder, double-encoded JSON strings are
+// unwrapped, and anything else unreadable is copied to the quarantine table and replaced.
+func NormalizeResults(db *gorm.DB, dryRun bool) (*NormalizeReport, error) {
+	report := &NormalizeReport{DryRun: dryRun, TaskIDs: []int{}}
+
+	var rows []resultRow
+	if err := db.Raw("SELECT id, status, result, result_gzip, result_compressed FROM automation_tasks ORDER BY id").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error scanning task results: %v", err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			report.Scanned++
+			corrupt := false
+
+			// Compressed results are checked in their decompressed form; corrupt
+			// ones are quarantined as base64
+			if row.ResultCompressed {
+				decompressed, err := models.DecompressResult(row.ResultGzip)
+				if err != nil {
+					decompressed = []byte(base64.StdEncoding.EncodeToString(row.ResultGzip))
+					corrupt = true
+				}
+				row.Result = sql.NullString{String: string(decompressed), Valid: true}
+			}
+			raw := bytes.TrimSpace([]byte(row.Result.String))
+
+			// Tasks that have not finished yet legitimately have no result
+			if !row.Result.Valid || len(raw) == 0 {
+				if row.Status == "pending" || row.Status == "running" {
+					report.Pending++
+					continue
+				}
+
+				report.Repaired++
+				report.TaskIDs = append(report.TaskIDs, row.ID)
+				if err := updateResult(tx, row.ID, missingResult, dryRun); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if !corrupt && json.Valid(raw) && raw[0] != '"' {
+				report.Valid++
+				continue
+			}
+
+			// Older rows sometimes stored the JSON document as an encoded string
+			var inner string
+			if !corrupt && json.Unmarshal(raw, &inner) == nil && json.Valid([]byte(inner)) {
+				report.Unwrapped++
+				report.TaskIDs = append(report.TaskIDs, row.ID)
+				if err := updateResult(tx, row.ID, []byte(inner), dryRun); err != nil {
+					return err
+				}
+				continue
+			}
+
+			report.Quarantined++
+			report.TaskIDs = append(report.TaskIDs, row.ID)
+			if dryRun {
+				continue
+			}
+
+			reason := "invalid JSON"
+			if corrupt {
+				reason = "corrupt compressed result"
+			}
+			quarantine := models.TaskResultQuarantine{
+				TaskID:    row.ID,
+				RawResult: row.Result.String,
+				Reason:    reason,
+			}
+			if err := tx.Create(&quarantine).Error; err != nil {
+				return fmt.Errorf("error quarantining result of task %d: %v", row.ID, err)
+			}
+			if err := updateResult(tx, row.ID, quarantinedResult, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func updateResult(tx *gorm.DB, taskID int, result []byte, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+	if err := tx.Exec("UPDATE automation_tasks SET result = ?, result_gzip = NULL, result_compressed = ? WHERE id = ?", string(result), false, taskID).Error; err != nil {
+		return fmt.Errorf("error updating result of task %d: %v", taskID, err)
+	}
+	return nil
+}
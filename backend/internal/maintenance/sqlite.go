@@ -0,0 +1,173 @@
+package maintenance
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/heartbeat"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/notify"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// IntegrityResult is the outcome of a PRAGMA integrity_check run
+type IntegrityResult struct {
+	OK        bool      `json:"ok"`
+	Messages  []string  `json:"messages"`
+	CheckedAt time.Time `json:"checked_at"`
+	Duration  string    `json:"duration"`
+}
+
+// VacuumResult is the outcome of a VACUUM/ANALYZE run
+type VacuumResult struct {
+	OK          bool      `json:"ok"`
+	Error       string    `json:"error,omitempty"`
+	SizeBefore  int64     `json:"size_before"`
+	SizeAfter   int64     `json:"size_after"`
+	CompletedAt time.Time `json:"completed_at"`
+	Duration    string    `json:"duration"`
+}
+
+// sqliteStatus keeps the results of the latest maintenance runs
+var sqliteStatus struct {
+	mu            sync.RWMutex
+	lastIntegrity *IntegrityResult
+	lastVacuum    *VacuumResult
+}
+
+// RunIntegrityCheck runs PRAGMA integrity_check and records the result
+func RunIntegrityCheck(db *gorm.DB) (*IntegrityResult, error) {
+	start := time.Now()
+
+	var messages []string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&messages).Error; err != nil {
+		return nil, fmt.Errorf("error running integrity check: %v", err)
+	}
+
+	result := &IntegrityResult{
+		OK:        len(messages) == 1 && messages[0] == "ok",
+		Messages:  messages,
+		CheckedAt: time.Now(),
+		Duration:  time.Since(start).String(),
+	}
+
+	sqliteStatus.mu.Lock()
+	sqliteStatus.lastIntegrity = result
+	sqliteStatus.mu.Unlock()
+
+	if !result.OK {
+		log.Printf("ALERT: SQLite integrity check FAILED (%d problems): %s",
+			len(messages), strings.Join(messages, "; "))
+		notify.NotifySuperadmins(db, notify.Event{
+			Kind:  notify.KindDatabaseCorrupted,
+			Title: func(locale string) string { return i18n.Translate(locale, "Database integrity check failed") },
+			Body: func(locale string) string {
+				return i18n.Translate(locale, "The SQLite integrity check found %d problems: %s", len(messages), strings.Join(messages, "; "))
+			},
+			Data: map[string]interface{}{"problems": messages},
+		})
+	}
+
+	return result, nil
+}
+
+// RunVacuum runs VACUUM followed by ANALYZE and records the result
+func RunVacuum(db *gorm.DB) *VacuumResult {
+	start := time.Now()
+	result := &VacuumResult{SizeBefore: databaseFileSize()}
+
+	err := db.Exec("VACUUM").Error
+	if err == nil {
+		err = db.Exec("ANALYZE").Error
+	}
+
+	result.OK = err == nil
+	if err != nil {
+		result.Error = err.Error()
+		log.Printf("SQLite VACUUM/ANALYZE failed: %v", err)
+	}
+	result.SizeAfter = databaseFileSize()
+	result.CompletedAt = time.Now()
+	result.Duration = time.Since(start).String()
+
+	sqliteStatus.mu.Lock()
+	sqliteStatus.lastVacuum = result
+	sqliteStatus.mu.Unlock()
+
+	return result
+}
+
+// LastMaintenance returns the most recent integrity check and vacuum results
+func LastMaintenance() (*IntegrityResult, *VacuumResult) {
+	sqliteStatus.mu.RLock()
+	defer sqliteStatus.mu.RUnlock()
+	return sqliteStatus.lastIntegrity, sqliteStatus.lastVacuum
+}
+
+func databaseFileSize() int64 {
+	info, err := os.Stat(config.Get().DBPath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// inWindow reports whether t falls within a "HH:MM-HH:MM" window, which may wrap midnight
+func inWindow(window string, t time.Time) bool {
+	w, err := utils.ParseTimeWindow(window)
+	if err != nil {
+		return false
+	}
+	return w.Contains(t)
+}
+
+// StartSQLiteMaintenance schedules integrity checks and VACUUM/ANALYZE in the background
+func StartSQLiteMaintenance(db *gorm.DB) {
+	cfg := config.Get()
+	if !cfg.DBMaintenanceEnabled {
+		log.Println("SQLite maintenance job disabled")
+		return
+	}
+
+	integrityInterval := time.Duration(cfg.DBIntegrityCheckHours) * time.Hour
+	vacuumInterval := time.Duration(cfg.DBVacuumIntervalHours) * time.Hour
+	var lastIntegrity, lastVacuum time.Time
+
+	jobs.Register(jobs.Job{
+		Name:        heartbeat.JobSQLiteMaintenance,
+		Description: "Integrity checks and VACUUM/ANALYZE inside the maintenance window",
+		Interval:    15 * time.Minute,
+		RunOnStart:  true,
+		Run: func() error {
+			now := time.Now()
+
+			var runErr error
+			if now.Sub(lastIntegrity) >= integrityInterval {
+				result, err := RunIntegrityCheck(db)
+				if err != nil {
+					log.Printf("SQLite integrity check could not run: %v", err)
+					runErr = err
+				} else if !result.OK {
+					runErr = fmt.Errorf("integrity check failed: %s", strings.Join(result.Messages, "; "))
+				}
+				lastIntegrity = now
+			}
+
+			if now.Sub(lastVacuum) >= vacuumInterval && inWindow(cfg.DBMaintenanceWindow, now) {
+				if result := RunVacuum(db); result.Error != "" {
+					runErr = errors.Join(runErr, fmt.Errorf("vacuum failed: %s", result.Error))
+				}
+				lastVacuum = now
+			}
+			return runErr
+		},
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
 	"github.com/aliselcukkaya/account-editor/internal/models"
 	"github.com/aliselcukkaya/account-editor/pkg/utils"
 	"github.com/gin-gonic/gin"
@@ -15,9 +17,26 @@ func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 
+		// In cookie mode, fall back to the HttpOnly session cookie
+		if authHeader == "" && config.Get().CookieAuthEnabled() {
+			if token, err := c.Cookie(utils.SessionCookieName); err == nil && token != "" {
+				claims, err := utils.VerifyToken(token)
+				if err != nil {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+						"error": i18n.T(c, "Invalid or expired session"),
+					})
+					return
+				}
+
+				setClaims(c, claims, "cookie")
+				c.Next()
+				return
+			}
+		}
+
 		if authHeader == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Authorization header is required",
+				"error": i18n.T(c, "Authorization header is required"),
 			})
 			return
 		}
@@ -26,7 +45,7 @@ func AuthRequired() gin.HandlerFunc {
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Authorization header format must be Bearer {token}",
+				"error": i18n.T(c, "Authorization header format must be Bearer {token}"),
 			})
 			return
 		}
@@ -36,24 +55,34 @@ func AuthRequired() gin.HandlerFunc {
 		claims, err := utils.VerifyToken(tokenString)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid or expired token",
+				"error": i18n.T(c, "Invalid or expired token"),
 			})
 			return
 		}
 
-		// Set username in context
-		c.Set("username", claims.Username)
+		setClaims(c, claims, "header")
 		c.Next()
 	}
 }
 
+// setClaims stores the token's user, and the client and scope of tokens issued
+// to API clients, in the context, wherever the token came from
+func setClaims(c *gin.Context, claims *utils.Claims, source string) {
+	c.Set("username", claims.Username)
+	c.Set("auth_source", source)
+	if claims.ClientID != "" {
+		c.Set("client_id", claims.ClientID)
+		c.Set("scope", claims.Scope)
+	}
+}
+
 // GetCurrentUser retrieves the current user from the database based on the username in the token
 func GetCurrentUser(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		username, exists := c.Get("username")
 		if !exists {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "User not authenticated",
+				"error": i18n.T(c, "User not authenticated"),
 			})
 			return
 		}
@@ -61,18 +90,22 @@ func GetCurrentUser(db *gorm.DB) gin.HandlerFunc {
 		var user models.User
 		if err := db.Where("username = ?", username).First(&user).Error; err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "User not found",
+				"error": i18n.T(c, "User not found"),
 			})
 			return
 		}
 
 		if !user.IsActive {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "User is inactive",
+				"error": i18n.T(c, "User is inactive"),
 			})
 			return
 		}
 
+		if clientID := c.GetString("client_id"); clientID != "" && !authorizeClient(c, db, user, clientID) {
+			return
+		}
+
 		c.Set("user", user)
 		c.Next()
 	}
@@ -84,7 +117,7 @@ func AdminRequired() gin.HandlerFunc {
 		user, exists := c.Get("user")
 		if !exists {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "User not authenticated",
+				"error": i18n.T(c, "User not authenticated"),
 			})
 			return
 		}
@@ -92,14 +125,14 @@ func AdminRequired() gin.HandlerFunc {
 		u, ok := user.(models.User)
 		if !ok {
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error": "Internal server error",
+				"error": i18n.T(c, "Internal server error"),
 			})
 			return
 		}
 
 		if !u.IsAdmin {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-				"error": "Admin access required",
+				"error": i18n.T(c, "Admin access required"),
 			})
 			return
 		}
@@ -27,12 +27,14 @@ func CORSMiddleware() gin.HandlerFunc {
 			"Authorization",
 			"Content-Type",
 			"X-Requested-With",
+			"X-CSRF-Token",
+			"X-Request-ID",
 			"Accept",
 			"Origin",
 			"Access-Control-Request-Method",
 			"Access-Control-Request-Headers",
 		},
-		ExposeHeaders:    []string{"*"},
+		ExposeHeaders:    []string{"*", "X-Next-Cursor", "X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	})
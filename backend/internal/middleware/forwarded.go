@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// forwardedHostPattern accepts a host name or IP with an optional port
+	forwardedHostPattern = regexp.MustCompile(`^[A-Za-z0-9.\-\[\]:]+$`)
+	// forwardedPrefixPattern accepts a path prefix such as /api
+	forwardedPrefixPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~\-]+)+$`)
+)
+
+// firstForwarded returns the first value of a possibly comma separated forwarded header
+func firstForwarded(c *gin.Context, header string) string {
+	value, _, _ := strings.Cut(c.GetHeader(header), ",")
+	return strings.TrimSpace(value)
+}
+
+// HandleForwardedHeaders derives the public base URL from the X-Forwarded-Proto,
+// X-Forwarded-Host and X-Forwarded-Prefix headers set by a reverse proxy, for
+// utils.BaseURL. The headers are client-controlled, so they are only honored
+// when TRUST_PROXY_HEADERS is enabled; invalid values are ignored.
+func HandleForwardedHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.Get().TrustProxyHeaders {
+			c.Next()
+			return
+		}
+
+		proto := strings.ToLower(firstForwarded(c, "X-Forwarded-Proto"))
+		if proto != "http" && proto != "https" {
+			proto = "http"
+			if c.Request.TLS != nil {
+				proto = "https"
+			}
+		}
+
+		host := firstForwarded(c, "X-Forwarded-Host")
+		if host == "" || !forwardedHostPattern.MatchString(host) {
+			host = c.Request.Host
+		}
+
+		prefix := strings.TrimRight(firstForwarded(c, "X-Forwarded-Prefix"), "/")
+		if prefix != "" && !forwardedPrefixPattern.MatchString(prefix) {
+			prefix = ""
+		}
+
+		c.Set(utils.ForwardedBaseKey, proto+"://"+host+prefix)
+		c.Next()
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Admin permissions
+const (
+	PermissionViewUsers = "users.view"
+	PermissionViewTasks = "tasks.view"
+	PermissionViewAudit = "audit.view"
+)
+
+// allPermissions lists every admin permission; superadmins have all of them
+var allPermissions = []string{PermissionViewUsers, PermissionViewTasks, PermissionViewAudit}
+
+// routePermissions maps admin routes, as "METHOD /full/path", to the permission
+// they need. Routes that are not listed need the superadmin role, so a new admin
+// route is closed to support admins until it is added here.
+var routePermissions = map[string]string{
+	"GET /admin/users":                             PermissionViewUsers,
+	"GET /admin/onboarding":                        PermissionViewUsers,
+	"GET /admin/signups":                           PermissionViewUsers,
+	"GET /admin/deletions":                         PermissionViewUsers,
+	"GET /admin/tasks/stuck":                       PermissionViewTasks,
+	"GET /admin/tasks/failures/summary":            PermissionViewTasks,
+	"GET /admin/shadow/results":                    PermissionViewTasks,
+	"GET /admin/shadow/summary":                    PermissionViewTasks,
+	"GET /admin/panel-webhooks/rejections":         PermissionViewTasks,
+	"GET /admin/panel-webhooks/rejections/summary": PermissionViewTasks,
+	"GET /admin/audit-logs":                        PermissionViewAudit,
+}
+
+// rolePermissions lists the permissions of every admin role except superadmin,
+// which has them all
+var rolePermissions = map[string]map[string]bool{
+	models.AdminRoleSupport: {
+		PermissionViewUsers: true,
+		PermissionViewTasks: true,
+		PermissionViewAudit: true,
+	},
+}
+
+// Permissions returns the permissions of the user's admin role, empty for users
+// that are not admins. Superadmins may additionally use every unlisted admin route.
+func Permissions(u models.User) []string {
+	perms := []string{}
+	for _, perm := range allPermissions {
+		if HasPermission(u, perm) {
+			perms = append(perms, perm)
+		}
+	}
+	return perms
+}
+
+// HasPermission reports whether the user's admin role grants the permission
+func HasPermission(u models.User, perm string) bool {
+	role := u.Role()
+	return role == models.AdminRoleSuperadmin || rolePermissions[role][perm]
+}
+
+// PermissionRequired checks that the user's admin role may use the matched route.
+// It must run after AdminRequired.
+func PermissionRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": i18n.T(c, "User not authenticated"),
+			})
+			return
+		}
+
+		u, ok := user.(models.User)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": i18n.T(c, "Internal server error"),
+			})
+			return
+		}
+
+		role := u.Role()
+		if role == models.AdminRoleSuperadmin {
+			c.Next()
+			return
+		}
+
+		perm, listed := routePermissions[c.Request.Method+" "+c.FullPath()]
+		if !listed || !rolePermissions[role][perm] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": i18n.T(c, "Your admin role does not allow this action"),
+				"role":  role,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SignedURLRequired only lets requests through whose path carries a valid, unexpired signature
+func SignedURLRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := utils.VerifySignedURL(c.Request.URL.Path, c.Query("expires"), c.Query("signature"))
+		if err == utils.ErrSignatureExpired {
+			c.AbortWithStatusJSON(http.StatusGone, gin.H{
+				"error": "Download link has expired",
+			})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Invalid download link",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// subAccountWrites lists the routes, as "METHOD /full/path", that change data
+// and are open to sub-accounts. Sub-accounts may read every route of the group
+// except those in subAccountHidden.
+var subAccountWrites = map[string]bool{
+	"POST /automation/tasks":              true,
+	"POST /automation/tasks/validate":     true,
+	"POST /automation/tasks/bulk":         true,
+	"POST /automation/imports":            true,
+	"POST /automation/imports/:id/commit": true,
+}
+
+// subAccountHidden lists the read routes closed to sub-accounts
+var subAccountHidden = map[string]bool{
+	// The settings hold the parent's panel API key
+	"GET /automation/settings": true,
+}
+
+// SubAccountScope lets sub-accounts work in their parent's data: the parent
+// becomes the request's user and the sub-account is kept as "subaccount", so
+// tasks and credit roll up to the parent while audit entries name the
+// sub-account. It must run after GetCurrentUser.
+func SubAccountScope(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := c.Get("user")
+		u, ok := user.(models.User)
+		if !ok || u.ParentID == nil {
+			c.Next()
+			return
+		}
+
+		route := c.Request.Method + " " + c.FullPath()
+		if c.Request.Method == http.MethodGet && subAccountHidden[route] ||
+			c.Request.Method != http.MethodGet && !subAccountWrites[route] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": i18n.T(c, "Sub-accounts cannot use this endpoint"),
+			})
+			return
+		}
+
+		var parent models.User
+		if err := db.First(&parent, *u.ParentID).Error; err != nil || !parent.IsActive || parent.ErasedAt != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": i18n.T(c, "The reseller account of this sub-account is inactive"),
+			})
+			return
+		}
+
+		c.Set("subaccount", u)
+		c.Set("user", parent)
+		c.Next()
+	}
+}
+
+// SubAccountID returns the ID of the sub-account acting for the request's
+// user, or nil when the user acts themselves
+func SubAccountID(c *gin.Context) *int {
+	if sub, ok := c.Get("subaccount"); ok {
+		if u, ok := sub.(models.User); ok {
+			return &u.ID
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/settings"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// StatusTOSAcceptanceRequired is returned while the user has not accepted the current terms of service
+const StatusTOSAcceptanceRequired = http.StatusUnavailableForLegalReasons
+
+// TOSRequired blocks requests until the current user has accepted the current terms of service.
+// It must run after GetCurrentUser. When no version is configured every request passes.
+func TOSRequired(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version, err := settings.Get(db, settings.KeyTOSVersion)
+		if err != nil {
+			// Do not lock everyone out because the setting could not be read
+			log.Printf("Failed to read terms of service version: %v", err)
+			c.Next()
+			return
+		}
+		if version == "" {
+			c.Next()
+			return
+		}
+
+		user, exists := c.Get("user")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": i18n.T(c, "User not authenticated"),
+			})
+			return
+		}
+
+		u, ok := user.(models.User)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": i18n.T(c, "Internal server error"),
+			})
+			return
+		}
+
+		if u.TOSVersionAccepted != version {
+			tosURL, _ := settings.Get(db, settings.KeyTOSURL)
+			c.AbortWithStatusJSON(StatusTOSAcceptanceRequired, gin.H{
+				"error":            i18n.T(c, "Terms of service acceptance required"),
+				"code":             "tos_acceptance_required",
+				"tos_version":      version,
+				"tos_url":          tosURL,
+				"accepted_version": u.TOSVersionAccepted,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
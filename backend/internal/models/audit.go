@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditLog records a security relevant or administrative action
+type AuditLog struct {
+	ID            int                    `gorm:"primaryKey;autoIncrement" json:"id"`
+	ActorID       *int                   `gorm:"index" json:"actor_id"`
+	ActorUsername string                 `gorm:"column:actor_username" json:"actor_username"`
+	Action        string                 `gorm:"column:action;index" json:"action"`
+	TargetType    string                 `gorm:"column:target_type" json:"target_type"`
+	TargetID      string                 `gorm:"column:target_id" json:"target_id"`
+	Details       map[string]interface{} `gorm:"column:details;serializer:json" json:"details"`
+	IPAddress     string                 `gorm:"column:ip_address" json:"ip_address"`
+	RequestID     string                 `gorm:"column:request_id" json:"request_id"`
+	CreatedAt     time.Time              `gorm:"autoCreateTime;index" json:"created_at"`
+	// PrevHash and Hash chain the entries: Hash is an HMAC over the entry and
+	// the Hash of the entry before it, so changing or deleting an entry breaks
+	// the chain from there on. Entries written before chaining have neither.
+	PrevHash string `gorm:"column:prev_hash" json:"prev_hash"`
+	Hash     string `gorm:"column:hash" json:"hash"`
+	Actor    *User  `gorm:"foreignKey:ActorID;constraint:OnDelete:SET NULL" json:"-"`
+}
+
+// TableName specifies the table name for AuditLog
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
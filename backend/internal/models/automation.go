@@ -6,20 +6,116 @@ import (
 )
 
 type AutomationTask struct {
-	ID            int        `gorm:"primaryKey;autoIncrement"`
-	UserID        int        `gorm:"index"`
-	Name          string     `gorm:"column:name"`
-	TargetWebsite string     `gorm:"column:target_website"`
-	Status        string     `gorm:"column:status"` // pending, running, completed, failed
-	Result        JSON       `gorm:"type:json"`
-	CreatedAt     time.Time  `gorm:"autoCreateTime"`
-	UpdatedAt     time.Time  `gorm:"autoUpdateTime"`
-	CompletedAt   *time.Time `gorm:"column:completed_at"`
-	User          User       `gorm:"foreignKey:UserID"`
+	ID            int             `gorm:"primaryKey;autoIncrement"`
+	UserID        int             `gorm:"index:idx_tasks_user_status_created,priority:1;index:idx_tasks_user_created,priority:1"`
+	BatchID       *int            `gorm:"index"`
+	Name          string          `gorm:"column:name"`
+	TargetWebsite string          `gorm:"column:target_website"`
+	Status        string          `gorm:"column:status;index:idx_tasks_user_status_created,priority:2;index:idx_tasks_status_updated,priority:1"` // pending, held, running, completed, failed
+	Result        JSON            `gorm:"type:json"`
+	Request       json.RawMessage `gorm:"column:request;type:json;serializer:encrypted_json" json:"-"` // original task request, replayed on requeue; encrypted since it holds the line password
+	CreatedAt     time.Time       `gorm:"autoCreateTime;index:idx_tasks_user_status_created,priority:3;index:idx_tasks_user_created,priority:2"`
+	UpdatedAt     time.Time       `gorm:"autoUpdateTime;index:idx_tasks_status_updated,priority:2"`
+	CompletedAt   *time.Time      `gorm:"column:completed_at"`
+	// CreatedBy is the sub-account that created the task for its parent user
+	CreatedBy *int `gorm:"column:created_by;index"`
+	User      User `gorm:"foreignKey:UserID"`
+
+	// Results above ResultCompressionThreshold are stored gzipped in ResultGzip
+	// with ResultCompressed set and Result empty; see compress.go
+	ResultGzip       []byte `gorm:"column:result_gzip" json:"-"`
+	ResultCompressed bool   `gorm:"column:result_compressed;default:false" json:"-"`
+	uncompressed     JSON
 }
 
 func (AutomationTask) TableName() string {
 	return "automation_tasks"
 }
 
-type JSON json.RawMessage
+// TaskBatch groups tasks created together through the bulk endpoint
+type TaskBatch struct {
+	ID          int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      int        `gorm:"index" json:"user_id"`
+	Status      string     `gorm:"column:status" json:"status"` // pending, held, waiting_on_panel, running, completed
+	Source      string     `gorm:"column:source" json:"source"` // json, csv, import
+	Total       int        `gorm:"column:total" json:"total"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	CompletedAt *time.Time `gorm:"column:completed_at" json:"completed_at"`
+}
+
+func (TaskBatch) TableName() string {
+	return "task_batches"
+}
+
+// TaskImport is an uploaded spreadsheet kept until its columns are mapped to
+// task fields and it is committed as a batch
+type TaskImport struct {
+	ID       int      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID   int      `gorm:"index" json:"user_id"`
+	Filename string   `gorm:"column:filename" json:"filename"`
+	Header   []string `gorm:"column:header;serializer:json" json:"header"`
+	// Rows may hold line passwords, so they are encrypted and deleted some
+	// time after the import was committed
+	Rows [][]string `gorm:"column:records;serializer:encrypted_json" json:"-"`
+	// RowsPurgedAt is set once the rows were deleted; outcomes and counts stay
+	RowsPurgedAt *time.Time `gorm:"column:rows_purged_at" json:"rows_purged_at"`
+	Status       string     `gorm:"column:status" json:"status"` // pending, committed
+	// Total is the number of rows, which are not loaded when imports are listed
+	Total int `gorm:"column:total" json:"total"`
+	// BatchID is the batch the import was committed as
+	BatchID *int `gorm:"column:batch_id" json:"batch_id"`
+	// Outcomes has one entry per row once the import is committed
+	Outcomes    []ImportRowOutcome `gorm:"column:outcomes;serializer:json" json:"-"`
+	Rejected    int                `gorm:"column:rejected" json:"rejected"`
+	CommittedAt *time.Time         `gorm:"column:committed_at" json:"committed_at"`
+	ExpiresAt   time.Time          `gorm:"column:expires_at;index" json:"expires_at"`
+	CreatedAt   time.Time          `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ImportRowOutcome is what became of a row of a committed import: the task
+// created for it, or why it was rejected. Row counts from 1.
+type ImportRowOutcome struct {
+	Row    int    `json:"row"`
+	TaskID *int   `json:"task_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (TaskImport) TableName() string {
+	return "task_imports"
+}
+
+// TaskResultQuarantine keeps the original result of a task whose stored JSON was unreadable
+type TaskResultQuarantine struct {
+	ID            int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	TaskID        int       `gorm:"index" json:"task_id"`
+	RawResult     string    `gorm:"column:raw_result" json:"raw_result"`
+	Reason        string    `gorm:"column:reason" json:"reason"`
+	QuarantinedAt time.Time `gorm:"autoCreateTime" json:"quarantined_at"`
+}
+
+func (TaskResultQuarantine) TableName() string {
+	return "automation_task_result_quarantine"
+}
+
+// AutomationTaskArchive holds finished tasks moved out of automation_tasks after the retention window
+type AutomationTaskArchive struct {
+	ID            int        `gorm:"primaryKey" json:"id"`
+	UserID        int        `gorm:"index:idx_archive_user_created,priority:1" json:"user_id"`
+	Name          string     `gorm:"column:name" json:"name"`
+	TargetWebsite string     `gorm:"column:target_website" json:"target_website"`
+	Status        string     `gorm:"column:status" json:"status"`
+	Result        string     `gorm:"type:json" json:"-"`
+	Request       string     `gorm:"type:json" json:"-"` // no longer copied from the task since it holds the line password
+	CreatedAt     time.Time  `gorm:"index:idx_archive_user_created,priority:2" json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	CompletedAt   *time.Time `gorm:"column:completed_at" json:"completed_at"`
+	ArchivedAt    time.Time  `gorm:"column:archived_at" json:"archived_at"`
+
+	ResultGzip       []byte `gorm:"column:result_gzip" json:"-"`
+	ResultCompressed bool   `gorm:"column:result_compressed;default:false" json:"-"`
+}
+
+func (AutomationTaskArchive) TableName() string {
+	return "automation_tasks_archive"
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// ServiceClient is an integration, e.g. a billing system, that authenticates
+// with the client credentials grant and acts on behalf of its user within its
+// scopes. Only a hash of the secret is stored.
+type ServiceClient struct {
+	ID         int      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ClientID   string   `gorm:"column:client_id;uniqueIndex" json:"client_id"`
+	SecretHash string   `gorm:"column:secret_hash" json:"-"`
+	Name       string   `gorm:"column:name" json:"name"`
+	UserID     int      `gorm:"index" json:"user_id"`
+	Scopes     []string `gorm:"column:scopes;serializer:json" json:"scopes"`
+	// RateLimitPerMinute limits the requests made with the client's tokens
+	RateLimitPerMinute int        `gorm:"column:rate_limit_per_minute" json:"rate_limit_per_minute"`
+	IsActive           bool       `gorm:"column:is_active;default:true" json:"is_active"`
+	CreatedBy          *int       `gorm:"column:created_by" json:"created_by"`
+	LastUsedAt         *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	CreatedAt          time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for ServiceClient
+func (ServiceClient) TableName() string {
+	return "service_clients"
+}
+
+// HasScope reports whether the client was granted the scope
+func (c ServiceClient) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// ResultCompressionThreshold is the size in bytes above which task results are
+// stored gzipped; 0 disables compression. Set from RESULT_COMPRESS_THRESHOLD_BYTES.
+var ResultCompressionThreshold = 16 << 10
+
+// CompressResult gzips a task result
+func CompressResult(result []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(result); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecompressResult reverses CompressResult
+func DecompressResult(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// BeforeSave moves a large result into the compressed column
+func (t *AutomationTask) BeforeSave(tx *gorm.DB) error {
+	t.ResultGzip = nil
+	t.ResultCompressed = false
+	if ResultCompressionThreshold <= 0 || len(t.Result) <= ResultCompressionThreshold {
+		return nil
+	}
+
+	compressed, err := CompressResult(t.Result)
+	if err != nil {
+		return err
+	}
+	t.uncompressed = t.Result
+	t.Result = nil
+	t.ResultGzip = compressed
+	t.ResultCompressed = true
+	return nil
+}
+
+// AfterSave puts the uncompressed result back, so callers keep using Result
+func (t *AutomationTask) AfterSave(tx *gorm.DB) error {
+	if t.uncompressed != nil {
+		t.Result = t.uncompressed
+		t.uncompressed = nil
+	}
+	return nil
+}
+
+// AfterFind decompresses a compressed result into Result
+func (t *AutomationTask) AfterFind(tx *gorm.DB) error {
+	if !t.ResultCompressed {
+		return nil
+	}
+	result, err := DecompressResult(t.ResultGzip)
+	if err != nil {
+		// Leave the result empty; readers already treat that as unreadable
+		log.Printf("Failed to decompress result of task ID %d: %v", t.ID, err)
+		return nil
+	}
+	t.Result = JSON(result)
+	t.ResultGzip = nil
+	return nil
+}
+
+// AfterFind decompresses a compressed result into Result
+func (t *AutomationTaskArchive) AfterFind(tx *gorm.DB) error {
+	if !t.ResultCompressed {
+		return nil
+	}
+	result, err := DecompressResult(t.ResultGzip)
+	if err != nil {
+		log.Printf("Failed to decompress result of archived task ID %d: %v", t.ID, err)
+		return nil
+	}
+	t.Result = string(result)
+	t.ResultGzip = nil
+	return nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+// Coupon kinds
+const (
+	CouponKindPlan   = "plan"
+	CouponKindCredit = "credit"
+)
+
+// Coupon is an admin-managed code that grants a plan or credit when redeemed
+type Coupon struct {
+	ID          int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code        string `gorm:"column:code;uniqueIndex" json:"code"`
+	Description string `gorm:"column:description" json:"description"`
+	Kind        string `gorm:"column:kind" json:"kind"`
+	// PlanID and PlanDays apply to plan coupons; 0 days grants the plan permanently
+	PlanID   *int `gorm:"column:plan_id" json:"plan_id"`
+	PlanDays int  `gorm:"column:plan_days" json:"plan_days"`
+	// CreditAmount applies to credit coupons
+	CreditAmount float64 `gorm:"column:credit_amount" json:"credit_amount"`
+	// MaxRedemptions limits how many users may redeem the code; 0 means unlimited
+	MaxRedemptions int        `gorm:"column:max_redemptions" json:"max_redemptions"`
+	Redemptions    int        `gorm:"column:redemptions" json:"redemptions"`
+	ExpiresAt      *time.Time `gorm:"column:expires_at" json:"expires_at"`
+	Active         bool       `gorm:"column:active" json:"active"`
+	CreatedBy      *int       `gorm:"column:created_by" json:"created_by"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for Coupon
+func (Coupon) TableName() string {
+	return "coupons"
+}
+
+// CouponRedemption records a user redeeming a coupon; each user may redeem a code once
+type CouponRedemption struct {
+	ID            int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	CouponID      int        `gorm:"uniqueIndex:idx_coupon_redemptions_coupon_user,priority:1" json:"coupon_id"`
+	UserID        int        `gorm:"uniqueIndex:idx_coupon_redemptions_coupon_user,priority:2;index" json:"user_id"`
+	PlanID        *int       `gorm:"column:plan_id" json:"plan_id"`
+	PlanExpiresAt *time.Time `gorm:"column:plan_expires_at" json:"plan_expires_at"`
+	CreditAmount  float64    `gorm:"column:credit_amount" json:"credit_amount"`
+	IPAddress     string     `gorm:"column:ip_address" json:"ip_address"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the table name for CouponRedemption
+func (CouponRedemption) TableName() string {
+	return "coupon_redemptions"
+}
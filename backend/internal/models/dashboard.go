@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// WidgetSpec is the query behind a dashboard widget: the rows of a source
+// matching the filters, optionally grouped, reduced by an aggregate
+type WidgetSpec struct {
+	// Source is tasks, transactions or lines
+	Source string `json:"source"`
+	// Filters match fields of the source exactly, e.g. {"status": "failed"}
+	Filters map[string]string `json:"filters,omitempty"`
+	// Aggregate is count, sum, avg, min or max; all but count need Field
+	Aggregate string `json:"aggregate"`
+	Field     string `json:"field,omitempty"`
+	// GroupBy is a field of the source, or day, week or month of the creation time
+	GroupBy string `json:"group_by,omitempty"`
+	// Days limits the rows to those created in the last days; 0 includes all
+	Days int `json:"days,omitempty"`
+}
+
+// DashboardWidget is a user-defined widget of the dashboard
+type DashboardWidget struct {
+	ID        int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int        `gorm:"index" json:"user_id"`
+	Name      string     `gorm:"column:name" json:"name"`
+	Position  int        `gorm:"column:position" json:"position"`
+	Spec      WidgetSpec `gorm:"column:spec;serializer:json" json:"spec"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for DashboardWidget
+func (DashboardWidget) TableName() string {
+	return "dashboard_widgets"
+}
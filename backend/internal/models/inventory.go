@@ -0,0 +1,108 @@
+package models
+
+import (
+	"time"
+)
+
+// Transaction types
+const (
+	TransactionPurchase   = "purchase"
+	TransactionRenewal    = "renewal"
+	TransactionTopUp      = "topup"
+	TransactionAdjustment = "adjustment"
+)
+
+// Line is a panel line (customer account) known from the user's task results
+type Line struct {
+	ID        int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int        `gorm:"uniqueIndex:idx_lines_user_line,priority:1;index:idx_lines_user_expire,priority:1" json:"user_id"`
+	LineID    string     `gorm:"column:line_id;uniqueIndex:idx_lines_user_line,priority:2" json:"line_id"`
+	Username  string     `gorm:"column:username;index" json:"username"`
+	PackageID int        `gorm:"column:package_id" json:"package_id"`
+	ExpireAt  *time.Time `gorm:"column:expire_at;index:idx_lines_user_expire,priority:2" json:"expire_at"`
+	// RenewalQueuedAt is set when a renewal rule created an extend task for the current expiry
+	RenewalQueuedAt *time.Time `gorm:"column:renewal_queued_at" json:"renewal_queued_at"`
+	// DisableQueuedAt is set when the expiry rule created a disable task for the current expiry
+	DisableQueuedAt *time.Time `gorm:"column:disable_queued_at" json:"disable_queued_at"`
+	// DisabledAt is set when a disable task succeeded; a new expiry clears it
+	DisabledAt *time.Time `gorm:"column:disabled_at" json:"disabled_at"`
+	LastTaskID *int       `gorm:"column:last_task_id" json:"last_task_id"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for Line
+func (Line) TableName() string {
+	return "lines"
+}
+
+// Transaction is a change of the user's panel credit. Spending is negative,
+// top-ups are positive, so the balance is the sum of all amounts.
+type Transaction struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int       `gorm:"index:idx_transactions_user_created,priority:1" json:"user_id"`
+	TaskID    *int      `gorm:"column:task_id" json:"task_id"`
+	LineID    string    `gorm:"column:line_id" json:"line_id,omitempty"`
+	Type      string    `gorm:"column:type" json:"type"`
+	PackageID int       `gorm:"column:package_id" json:"package_id,omitempty"`
+	Amount    float64   `gorm:"column:amount" json:"amount"`
+	Note      string    `gorm:"column:note" json:"note,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index:idx_transactions_user_created,priority:2" json:"created_at"`
+}
+
+// TableName specifies the table name for Transaction
+func (Transaction) TableName() string {
+	return "transactions"
+}
+
+// RenewalRule automatically extends lines a number of days before they expire
+type RenewalRule struct {
+	ID     int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID int    `gorm:"index" json:"user_id"`
+	Name   string `gorm:"column:name" json:"name"`
+	// LineID limits the rule to one line; empty applies it to all of the user's lines
+	LineID           string     `gorm:"column:line_id" json:"line_id"`
+	Package          int        `gorm:"column:package" json:"package"`
+	DaysBeforeExpiry int        `gorm:"column:days_before_expiry" json:"days_before_expiry"`
+	Enabled          bool       `gorm:"column:enabled" json:"enabled"`
+	LastRunAt        *time.Time `gorm:"column:last_run_at" json:"last_run_at"`
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for RenewalRule
+func (RenewalRule) TableName() string {
+	return "renewal_rules"
+}
+
+// ExpiryDisableRule is a user's opt-in rule that disables lines a number of
+// days after they expired
+type ExpiryDisableRule struct {
+	ID        int        `gorm:"primaryKey;autoIncrement" json:"-"`
+	UserID    int        `gorm:"uniqueIndex" json:"-"`
+	Enabled   bool       `gorm:"column:enabled" json:"enabled"`
+	GraceDays int        `gorm:"column:grace_days" json:"grace_days"`
+	LastRunAt *time.Time `gorm:"column:last_run_at" json:"last_run_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for ExpiryDisableRule
+func (ExpiryDisableRule) TableName() string {
+	return "expiry_disable_rules"
+}
+
+// ExpiryDisableExclusion keeps the lines of a customer (line username) from
+// being disabled by the expiry rule
+type ExpiryDisableExclusion struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int       `gorm:"uniqueIndex:idx_expiry_exclusions_user_username,priority:1" json:"user_id"`
+	Username  string    `gorm:"column:username;uniqueIndex:idx_expiry_exclusions_user_username,priority:2" json:"username"`
+	Note      string    `gorm:"column:note" json:"note"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the table name for ExpiryDisableExclusion
+func (ExpiryDisableExclusion) TableName() string {
+	return "expiry_disable_exclusions"
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// JSON is a raw JSON document stored in a json column. It reads NULL, []byte
+// and string driver values, so it works the same on SQLite, Postgres and MySQL.
+// Like any []byte it is base64 encoded in API responses, which clients rely on.
+type JSON []byte
+
+// Value stores the document as text, or NULL when it is empty
+func (j JSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan reads a json column. Byte values are copied, since drivers may reuse
+// their buffer after Scan returns.
+func (j *JSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append(JSON(nil), v...)
+	case string:
+		*j = JSON(v)
+	default:
+		return fmt.Errorf("cannot scan %T into models.JSON", value)
+	}
+	return nil
+}
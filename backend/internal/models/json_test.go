@@ -0,0 +1,95 @@
+package models
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestJSONValue(t *testing.T) {
+	tests := []struct {
+		name string
+		json JSON
+		want interface{}
+	}{
+		{"nil", nil, nil},
+		{"empty", JSON{}, nil},
+		{"document", JSON(`{"success":true}`), `{"success":true}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.json.Value()
+			if err != nil {
+				t.Fatalf("Value() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Value() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONScan(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  JSON
+	}{
+		{"nil", nil, nil},
+		{"empty bytes", []byte{}, nil},
+		{"bytes", []byte(`{"success":true}`), JSON(`{"success":true}`)},
+		{"empty string", "", JSON{}},
+		{"string", `{"success":true}`, JSON(`{"success":true}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := JSON(`{"stale":true}`)
+			if err := j.Scan(tt.value); err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if !bytes.Equal(j, tt.want) {
+				t.Errorf("Scan() = %#v, want %#v", j, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONScanCopiesBytes(t *testing.T) {
+	buf := []byte(`{"success":true}`)
+
+	var j JSON
+	if err := j.Scan(buf); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	// Drivers may reuse their buffer once Scan returns
+	copy(buf, `{"success":fals`)
+
+	if string(j) != `{"success":true}` {
+		t.Errorf("Scan() kept the driver's buffer: %s", j)
+	}
+}
+
+func TestJSONScanUnsupportedType(t *testing.T) {
+	var j JSON
+	if err := j.Scan(42); err == nil {
+		t.Error("Scan(42) error = nil, want an error")
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, doc := range []JSON{nil, JSON(`{"data":{"line_id":"1"}}`)} {
+		value, err := doc.Value()
+		if err != nil {
+			t.Fatalf("Value() error = %v", err)
+		}
+
+		var got JSON
+		if err := got.Scan(value); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		if !bytes.Equal(got, doc) {
+			t.Errorf("round trip of %q = %q", doc, got)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// Notification is an in-app notification shown to a user
+type Notification struct {
+	ID        int                    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int                    `gorm:"index:idx_notifications_user_created,priority:1" json:"user_id"`
+	Kind      string                 `gorm:"column:kind" json:"kind"`
+	Title     string                 `gorm:"column:title" json:"title"`
+	Body      string                 `gorm:"column:body" json:"body"`
+	Data      map[string]interface{} `gorm:"column:data;serializer:json" json:"data"`
+	ReadAt    *time.Time             `gorm:"column:read_at" json:"read_at"`
+	CreatedAt time.Time              `gorm:"autoCreateTime;index:idx_notifications_user_created,priority:2" json:"created_at"`
+}
+
+// TableName specifies the table name for Notification
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+// NotificationDigestItem is a notification held back by a digest rule until
+// its group is sent
+type NotificationDigestItem struct {
+	ID     int                    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID int                    `gorm:"index:idx_digest_items_user_kind,priority:1" json:"user_id"`
+	Kind   string                 `gorm:"column:kind;index:idx_digest_items_user_kind,priority:2" json:"kind"`
+	Title  string                 `gorm:"column:title" json:"title"`
+	Body   string                 `gorm:"column:body" json:"body"`
+	Data   map[string]interface{} `gorm:"column:data;serializer:json" json:"data"`
+	// SendAt is when the group the item belongs to is delivered
+	SendAt    time.Time `gorm:"column:send_at;index" json:"send_at"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the table name for NotificationDigestItem
+func (NotificationDigestItem) TableName() string {
+	return "notification_digest_items"
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// PanelErrorMapping explains a raw panel error to users. Pattern is matched
+// case-insensitively against the error text, which includes the panel's
+// message and HTTP status (e.g. "status 402").
+type PanelErrorMapping struct {
+	ID           int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Pattern      string    `gorm:"column:pattern;uniqueIndex" json:"pattern"`
+	Explanation  string    `gorm:"column:explanation" json:"explanation"`
+	SuggestedFix string    `gorm:"column:suggested_fix" json:"suggested_fix"`
+	UpdatedBy    *int      `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for PanelErrorMapping
+func (PanelErrorMapping) TableName() string {
+	return "panel_error_mappings"
+}
+
+// PanelErrorNormalization maps a panel error message, usually a localized one,
+// to a canonical error code. Pattern is matched case-insensitively against the
+// error text; Provider is the panel host the entry applies to, empty for all.
+type PanelErrorNormalization struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Provider  string    `gorm:"column:provider;uniqueIndex:idx_panel_error_normalizations,priority:1" json:"provider"`
+	Pattern   string    `gorm:"column:pattern;uniqueIndex:idx_panel_error_normalizations,priority:2" json:"pattern"`
+	Code      string    `gorm:"column:code" json:"code"`
+	UpdatedBy *int      `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for PanelErrorNormalization
+func (PanelErrorNormalization) TableName() string {
+	return "panel_error_normalizations"
+}
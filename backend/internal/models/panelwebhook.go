@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+)
+
+// PanelWebhookRejection is an inbound panel webhook delivery that was refused
+// although it was signed, for a replayed nonce or a malformed body
+type PanelWebhookRejection struct {
+	ID       int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID   int    `gorm:"index" json:"user_id"`
+	Reason   string `gorm:"index" json:"reason"`
+	Event    string `json:"event,omitempty"`
+	Nonce    string `json:"nonce,omitempty"`
+	RemoteIP string `gorm:"column:remote_ip" json:"remote_ip"`
+	// Timestamp is the delivery's X-Panel-Timestamp as sent, when it had one
+	Timestamp string    `json:"timestamp,omitempty"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for PanelWebhookRejection
+func (PanelWebhookRejection) TableName() string {
+	return "panel_webhook_rejections"
+}
+
+// PanelWebhookRejectionCount counts the unsigned deliveries refused for a user
+// and reason on a day. Anyone can send those, so they are not stored one by one.
+type PanelWebhookRejectionCount struct {
+	ID int `gorm:"primaryKey;autoIncrement" json:"id"`
+	// UserID is 0 for deliveries to users without a panel profile
+	UserID int    `gorm:"uniqueIndex:idx_panel_webhook_rejection_counts_user_reason_day,priority:1" json:"user_id"`
+	Reason string `gorm:"uniqueIndex:idx_panel_webhook_rejection_counts_user_reason_day,priority:2" json:"reason"`
+	// Day is the UTC date (YYYY-MM-DD)
+	Day          string    `gorm:"column:day;uniqueIndex:idx_panel_webhook_rejection_counts_user_reason_day,priority:3" json:"day"`
+	Count        int64     `gorm:"column:count" json:"count"`
+	LastRemoteIP string    `gorm:"column:last_remote_ip" json:"last_remote_ip"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for PanelWebhookRejectionCount
+func (PanelWebhookRejectionCount) TableName() string {
+	return "panel_webhook_rejection_counts"
+}
+
+// PanelWebhookNonce is the nonce of an accepted panel webhook delivery, kept
+// until the delivery's timestamp falls out of the tolerance so a replay is
+// refused by every instance
+type PanelWebhookNonce struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int       `gorm:"uniqueIndex:idx_panel_webhook_nonces_user_nonce,priority:1" json:"user_id"`
+	Nonce     string    `gorm:"uniqueIndex:idx_panel_webhook_nonces_user_nonce,priority:2" json:"nonce"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+}
+
+// TableName specifies the table name for PanelWebhookNonce
+func (PanelWebhookNonce) TableName() string {
+	return "panel_webhook_nonces"
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// Plan is a billing plan with the limits it grants; a limit of 0 means
+// unlimited. Sub-accounts share the plan of their reseller account.
+type Plan struct {
+	ID          int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string `gorm:"column:name;uniqueIndex" json:"name"`
+	Description string `gorm:"column:description" json:"description"`
+	// IsDefault makes the plan apply to users without an assigned plan
+	IsDefault bool `gorm:"column:is_default" json:"is_default"`
+	// StripePriceID is the recurring Stripe price users subscribe to for this plan
+	StripePriceID string `gorm:"column:stripe_price_id;index" json:"stripe_price_id"`
+
+	TasksPerDay             int `gorm:"column:tasks_per_day" json:"tasks_per_day"`
+	MaxBatchSize            int `gorm:"column:max_batch_size" json:"max_batch_size"`
+	MaxRenewalRules         int `gorm:"column:max_renewal_rules" json:"max_renewal_rules"`
+	ResultStorageMB         int `gorm:"column:result_storage_mb" json:"result_storage_mb"`
+	WebhookDeliveriesPerDay int `gorm:"column:webhook_deliveries_per_day" json:"webhook_deliveries_per_day"`
+	// MaxPanelProfiles limits the configured panel profiles of the account and its sub-accounts
+	MaxPanelProfiles int `gorm:"column:max_panel_profiles" json:"max_panel_profiles"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for Plan
+func (Plan) TableName() string {
+	return "plans"
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryToken is a one-time login token issued from the server host to
+// recover a locked-out admin. Only a hash of the token is stored.
+type RecoveryToken struct {
+	ID        int        `gorm:"primaryKey;autoIncrement"`
+	UserID    int        `gorm:"index"`
+	TokenHash string     `gorm:"column:token_hash;uniqueIndex"`
+	ExpiresAt time.Time  `gorm:"column:expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	UsedIP    string     `gorm:"column:used_ip"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+}
+
+// TableName specifies the table name for RecoveryToken
+func (RecoveryToken) TableName() string {
+	return "recovery_tokens"
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// SystemSetting is an admin-configurable key/value setting that applies to the whole deployment
+type SystemSetting struct {
+	Key       string    `gorm:"primaryKey;column:key" json:"key"`
+	Value     string    `gorm:"column:value" json:"value"`
+	UpdatedBy *int      `gorm:"column:updated_by" json:"updated_by"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for SystemSetting
+func (SystemSetting) TableName() string {
+	return "system_settings"
+}
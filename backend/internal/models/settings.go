@@ -4,16 +4,34 @@ import (
 	"time"
 )
 
+// Panel credential states, as seen by the credential monitor
+const (
+	CredentialStatusUnknown = "unknown"
+	CredentialStatusValid   = "valid"
+	CredentialStatusInvalid = "invalid"
+)
+
 // UserSettings represents the settings for a user's automation tasks
 type UserSettings struct {
-	ID         int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID     int       `gorm:"unique;index" json:"user_id"`
-	WebsiteURL string    `gorm:"column:website_url" json:"website_url"`
-	APIKey     string    `gorm:"column:api_key" json:"api_key"`
-	AuthUser   string    `gorm:"column:auth_user" json:"auth_user"`
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
-	User       User      `gorm:"foreignKey:UserID" json:"-"`
+	ID         int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     int    `gorm:"unique;index" json:"user_id"`
+	WebsiteURL string `gorm:"column:website_url" json:"website_url"`
+	APIKey     string `gorm:"column:api_key;serializer:encrypted" json:"api_key"`
+	AuthUser   string `gorm:"column:auth_user" json:"auth_user"`
+	// WebhookSecret signs the panel's webhook deliveries; empty refuses them
+	WebhookSecret string `gorm:"column:webhook_secret;serializer:encrypted" json:"-"`
+	// ExecutionWindow limits task execution to a daily HH:MM-HH:MM range in the user's timezone
+	ExecutionWindow string `gorm:"column:execution_window" json:"execution_window"`
+	// ConnectionVerifiedAt is set when a connection test passes and cleared when the panel settings change
+	ConnectionVerifiedAt *time.Time `gorm:"column:connection_verified_at" json:"connection_verified_at"`
+	// CredentialStatus is set by the credential monitor and reset when the panel settings change.
+	// While it is invalid, new tasks are rejected and renewal rules are paused.
+	CredentialStatus    string     `gorm:"column:credential_status;default:unknown" json:"credential_status"`
+	CredentialError     string     `gorm:"column:credential_error" json:"credential_error,omitempty"`
+	CredentialCheckedAt *time.Time `gorm:"column:credential_checked_at" json:"credential_checked_at"`
+	CreatedAt           time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	User                User       `gorm:"foreignKey:UserID" json:"-"`
 }
 
 // TableName specifies the database table name
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// TaskShadowResult is the outcome of a candidate implementation run in shadow
+// alongside a task, next to the result of the legacy path that served it
+type TaskShadowResult struct {
+	ID           int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	TaskID       int       `gorm:"index" json:"task_id"`
+	UserID       int       `gorm:"index" json:"user_id"`
+	TaskType     string    `gorm:"index:idx_task_shadow_results_type_created,priority:1" json:"task_type"`
+	LegacyResult JSON      `gorm:"type:json" json:"legacy_result"`
+	ShadowResult JSON      `gorm:"type:json" json:"shadow_result"`
+	Match        bool      `gorm:"column:matched" json:"match"`
+	Differences  JSON      `gorm:"type:json" json:"differences"`
+	DurationMS   int       `gorm:"column:duration_ms" json:"duration_ms"`
+	CreatedAt    time.Time `gorm:"index:idx_task_shadow_results_type_created,priority:2" json:"created_at"`
+}
+
+// TableName specifies the table name for TaskShadowResult
+func (TaskShadowResult) TableName() string {
+	return "task_shadow_results"
+}
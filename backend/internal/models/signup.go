@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// Signup request statuses
+const (
+	SignupPendingVerification = "pending_verification"
+	SignupPendingApproval     = "pending_approval"
+	SignupApproved            = "approved"
+	SignupRejected            = "rejected"
+)
+
+// SignupRequest is a self-registration waiting for email verification and,
+// if required, admin approval. The user is only created once it is approved.
+// Only a hash of the verification token is stored.
+type SignupRequest struct {
+	ID             int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Username       string    `gorm:"column:username;index" json:"username"`
+	Email          string    `gorm:"column:email;index" json:"email"`
+	HashedPassword string    `gorm:"column:hashed_password" json:"-"`
+	TokenHash      string    `gorm:"column:token_hash;uniqueIndex" json:"-"`
+	ExpiresAt      time.Time `gorm:"column:expires_at" json:"expires_at"`
+	Status         string    `gorm:"column:status;index" json:"status"`
+	IPAddress      string    `gorm:"column:ip_address" json:"ip_address"`
+	// Locale is taken from the signup request and used for the emails to the applicant
+	Locale       string     `gorm:"column:locale" json:"locale"`
+	VerifiedAt   *time.Time `gorm:"column:verified_at" json:"verified_at"`
+	ReviewedBy   *int       `gorm:"column:reviewed_by" json:"reviewed_by"`
+	ReviewedAt   *time.Time `gorm:"column:reviewed_at" json:"reviewed_at"`
+	RejectReason string     `gorm:"column:reject_reason" json:"reject_reason,omitempty"`
+	// UserID is the user created on approval
+	UserID    *int      `gorm:"column:user_id" json:"user_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the table name for SignupRequest
+func (SignupRequest) TableName() string {
+	return "signup_requests"
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// Latency rollup kinds
+const (
+	LatencyKindRoute = "route"
+	LatencyKindTask  = "task"
+)
+
+// LatencyRollup is the latency histogram of a route or task type for one day
+type LatencyRollup struct {
+	ID int `gorm:"primaryKey;autoIncrement" json:"id"`
+	// Day is the UTC date (YYYY-MM-DD)
+	Day  string `gorm:"column:day;uniqueIndex:idx_latency_rollups_day_kind_name,priority:1" json:"day"`
+	Kind string `gorm:"column:kind;uniqueIndex:idx_latency_rollups_day_kind_name,priority:2" json:"kind"`
+	// Name is the route as "METHOD /path" or the task name
+	Name    string `gorm:"column:name;uniqueIndex:idx_latency_rollups_day_kind_name,priority:3" json:"name"`
+	Count   int64  `gorm:"column:count" json:"count"`
+	TotalMS int64  `gorm:"column:total_ms" json:"total_ms"`
+	MaxMS   int64  `gorm:"column:max_ms" json:"max_ms"`
+	// Buckets counts the samples per latency bucket, see slo.BucketBoundsMS
+	Buckets   []int64   `gorm:"column:buckets;serializer:json" json:"buckets"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for LatencyRollup
+func (LatencyRollup) TableName() string {
+	return "latency_rollups"
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// Subscription statuses as reported by Stripe, plus "lapsed" for subscriptions
+// downgraded after their paid period ended without renewal
+const (
+	SubscriptionActive   = "active"
+	SubscriptionTrialing = "trialing"
+	SubscriptionPastDue  = "past_due"
+	SubscriptionCanceled = "canceled"
+	SubscriptionLapsed   = "lapsed"
+)
+
+// Subscription links a user to a paid Stripe subscription for a plan
+type Subscription struct {
+	ID                   int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID               int        `gorm:"uniqueIndex" json:"user_id"`
+	PlanID               *int       `gorm:"column:plan_id" json:"plan_id"`
+	StripeCustomerID     string     `gorm:"column:stripe_customer_id;index" json:"stripe_customer_id"`
+	StripeSubscriptionID string     `gorm:"column:stripe_subscription_id;index" json:"stripe_subscription_id"`
+	StripePriceID        string     `gorm:"column:stripe_price_id" json:"stripe_price_id"`
+	Status               string     `gorm:"column:status;index" json:"status"`
+	AmountCents          int64      `gorm:"column:amount_cents" json:"amount_cents"`
+	Currency             string     `gorm:"column:currency" json:"currency"`
+	Interval             string     `gorm:"column:interval" json:"interval"`
+	CurrentPeriodEnd     *time.Time `gorm:"column:current_period_end" json:"current_period_end"`
+	CancelAtPeriodEnd    bool       `gorm:"column:cancel_at_period_end" json:"cancel_at_period_end"`
+	StripeEventAt        *time.Time `gorm:"column:stripe_event_at" json:"-"` // creation time of the newest Stripe event applied
+	CreatedAt            time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt            time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for Subscription
+func (Subscription) TableName() string {
+	return "subscriptions"
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// NotificationTemplate is an admin-edited Go template replacing the built-in
+// format of a notification channel. A template with an empty Kind applies to
+// every kind without a template of its own.
+type NotificationTemplate struct {
+	ID      int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Channel string `gorm:"column:channel;uniqueIndex:idx_notification_templates_channel_kind,priority:1" json:"channel"`
+	Kind    string `gorm:"column:kind;uniqueIndex:idx_notification_templates_channel_kind,priority:2" json:"kind"`
+	// Subject and HTMLBody apply to email only; without HTMLBody emails are sent as plain text
+	Subject   string    `gorm:"column:subject" json:"subject"`
+	Body      string    `gorm:"column:body" json:"body"`
+	HTMLBody  string    `gorm:"column:html_body" json:"html_body"`
+	UpdatedBy *int      `gorm:"column:updated_by" json:"updated_by"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for NotificationTemplate
+func (NotificationTemplate) TableName() string {
+	return "notification_templates"
+}
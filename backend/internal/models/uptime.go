@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+// Panel health states
+const (
+	PanelStatusUnknown = "unknown"
+	PanelStatusUp      = "up"
+	PanelStatusDown    = "down"
+)
+
+// PanelProbe is a single health probe of a user's panel
+type PanelProbe struct {
+	ID         int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     int       `gorm:"index:idx_panel_probes_user_checked,priority:1" json:"user_id"`
+	WebsiteURL string    `gorm:"column:website_url" json:"-"`
+	OK         bool      `gorm:"column:ok" json:"ok"`
+	LatencyMS  int       `gorm:"column:latency_ms" json:"latency_ms"`
+	Error      string    `gorm:"column:error" json:"error,omitempty"`
+	CheckedAt  time.Time `gorm:"index:idx_panel_probes_user_checked,priority:2" json:"checked_at"`
+}
+
+// TableName specifies the table name for PanelProbe
+func (PanelProbe) TableName() string {
+	return "panel_probes"
+}
+
+// PanelHealth is the current health of a user's panel as seen by the uptime monitor
+type PanelHealth struct {
+	UserID              int        `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	WebsiteURL          string     `gorm:"column:website_url" json:"website_url"`
+	Status              string     `gorm:"column:status;default:unknown" json:"status"`
+	ConsecutiveFailures int        `gorm:"column:consecutive_failures" json:"consecutive_failures"`
+	LatencyMS           int        `gorm:"column:latency_ms" json:"latency_ms"`
+	LastError           string     `gorm:"column:last_error" json:"last_error,omitempty"`
+	LastCheckedAt       *time.Time `gorm:"column:last_checked_at" json:"last_checked_at"`
+	LastChangedAt       *time.Time `gorm:"column:last_changed_at" json:"last_changed_at"`
+}
+
+// TableName specifies the table name for PanelHealth
+func (PanelHealth) TableName() string {
+	return "panel_health"
+}
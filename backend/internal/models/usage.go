@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// UsageCounter counts a metered event per user and day, e.g. webhook deliveries
+type UsageCounter struct {
+	ID     int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID int    `gorm:"uniqueIndex:idx_usage_counters_user_metric_day,priority:1" json:"user_id"`
+	Metric string `gorm:"column:metric;uniqueIndex:idx_usage_counters_user_metric_day,priority:2" json:"metric"`
+	// Day is the date in the user's timezone (YYYY-MM-DD)
+	Day       string    `gorm:"column:day;uniqueIndex:idx_usage_counters_user_metric_day,priority:3" json:"day"`
+	Count     int64     `gorm:"column:count" json:"count"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for UsageCounter
+func (UsageCounter) TableName() string {
+	return "usage_counters"
+}
+
+// QuotaAlert records that a user was told about a quota level in a period, so
+// each warning is sent only once
+type QuotaAlert struct {
+	ID     int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID int    `gorm:"uniqueIndex:idx_quota_alerts_user_metric_level_period,priority:1" json:"user_id"`
+	Metric string `gorm:"column:metric;uniqueIndex:idx_quota_alerts_user_metric_level_period,priority:2" json:"metric"`
+	Level  string `gorm:"column:level;uniqueIndex:idx_quota_alerts_user_metric_level_period,priority:3" json:"level"`
+	// Period is the day (YYYY-MM-DD) or month (YYYY-MM) the alert applies to
+	Period    string    `gorm:"column:period;uniqueIndex:idx_quota_alerts_user_metric_level_period,priority:4" json:"period"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName specifies the table name for QuotaAlert
+func (QuotaAlert) TableName() string {
+	return "quota_alerts"
+}
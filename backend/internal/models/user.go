@@ -12,20 +12,117 @@ const (
 	UserStatusActive UserStatus = "active"
 )
 
+// Admin roles. Support admins have read-only access to users, tasks and audit logs.
+const (
+	AdminRoleSuperadmin = "superadmin"
+	AdminRoleSupport    = "support"
+)
+
+// NotificationDefaults are the user's default notification preferences
+type NotificationDefaults struct {
+	TaskCompleted bool     `json:"task_completed"`
+	TaskFailed    bool     `json:"task_failed"`
+	Channels      []string `json:"channels"`
+
+	// Destinations for the external channels
+	Email          string `json:"email,omitempty"`
+	WebhookURL     string `json:"webhook_url,omitempty"`
+	TelegramChatID string `json:"telegram_chat_id,omitempty"`
+
+	// Digests group notifications of a kind into one message per interval
+	Digests []DigestRule `json:"digests,omitempty"`
+
+	// Filters, when set, limit notifications to those matching at least one
+	Filters []NotificationFilter `json:"filters,omitempty"`
+}
+
+// NotificationFilter matches notifications by their kind and task details.
+// Every list that is set must contain the notification's value; tags match
+// when the task has any of them. Task fields never match other kinds.
+type NotificationFilter struct {
+	Kinds     []string `json:"kinds,omitempty"`
+	TaskNames []string `json:"task_names,omitempty"`
+	Statuses  []string `json:"statuses,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	BatchIDs  []int    `json:"batch_ids,omitempty"`
+}
+
+// DigestRule batches the notifications of one kind: the first one opens a
+// group and everything of that kind arriving within the interval is sent with it
+type DigestRule struct {
+	Kind            string `json:"kind"`
+	IntervalMinutes int    `json:"interval_minutes"`
+}
+
+// TaskPresets are the user's default task form values, applied to the fields
+// a task request leaves empty
+type TaskPresets struct {
+	Package        int `json:"package,omitempty"`
+	MaxConnections int `json:"max_connections,omitempty"`
+	// NoteTemplate is the reseller note of new lines; {username}, {package}
+	// and {date} are replaced when the task is created
+	NoteTemplate string `json:"note_template,omitempty"`
+}
+
 // User represents a user in the system
 type User struct {
-	ID              int              `gorm:"primaryKey;autoIncrement"`
-	Username        string           `gorm:"unique;index"`
-	HashedPassword  string           `gorm:"column:hashed_password"`
-	IsActive        bool             `gorm:"default:true"`
-	IsAdmin         bool             `gorm:"default:false"`
-	CreatedAt       time.Time        `gorm:"autoCreateTime"`
-	UpdatedAt       time.Time        `gorm:"autoUpdateTime"`
-	LastLoginAt     *time.Time       `gorm:"column:last_login_at"`
+	ID             int        `gorm:"primaryKey;autoIncrement"`
+	Username       string     `gorm:"unique;index"`
+	HashedPassword string     `gorm:"column:hashed_password"`
+	IsActive       bool       `gorm:"default:true"`
+	IsAdmin        bool       `gorm:"default:false"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `gorm:"autoUpdateTime"`
+	LastLoginAt    *time.Time `gorm:"column:last_login_at"`
+	// PasswordChangedAt is set when the user picks their own password
+	PasswordChangedAt *time.Time `gorm:"column:password_changed_at"`
+	// PlanID is the assigned billing plan; nil falls back to the default plan
+	PlanID *int `gorm:"column:plan_id;index"`
+	// PlanExpiresAt ends a time-limited plan, e.g. one granted by a coupon
+	PlanExpiresAt *time.Time `gorm:"column:plan_expires_at"`
+	// IsDemo marks a provisioned demo user that runs in simulation mode with seeded data
+	IsDemo bool `gorm:"column:is_demo;default:false"`
+	// ExternalID is the identity provider's ID of a user provisioned through SCIM
+	ExternalID string `gorm:"column:external_id;index"`
+	// AdminRole scopes an admin's access; empty means superadmin, as for admins created before roles
+	AdminRole string `gorm:"column:admin_role"`
+	// ParentID makes the user a sub-account (staff) of a reseller: it works in the
+	// parent's automation data, quotas and panel settings, and is managed by the parent
+	ParentID *int `gorm:"column:parent_id;index"`
+
+	// Profile fields managed by the user
+	DisplayName          string               `gorm:"column:display_name"`
+	AvatarKey            string               `gorm:"column:avatar_key"`
+	Timezone             string               `gorm:"column:timezone"`
+	Locale               string               `gorm:"column:locale"`
+	NotificationDefaults NotificationDefaults `gorm:"column:notification_defaults;serializer:encrypted_json"`
+	TaskPresets          TaskPresets          `gorm:"column:task_presets;serializer:json"`
+
+	// Terms of service acceptance
+	TOSVersionAccepted string     `gorm:"column:tos_version_accepted"`
+	TOSAcceptedAt      *time.Time `gorm:"column:tos_accepted_at"`
+
+	// Self-service deletion: the account is erased at DeletionScheduledAt
+	// unless the user cancels first; ErasedAt is set once it was anonymized
+	DeletionRequestedAt *time.Time `gorm:"column:deletion_requested_at"`
+	DeletionScheduledAt *time.Time `gorm:"column:deletion_scheduled_at;index"`
+	ErasedAt            *time.Time `gorm:"column:erased_at"`
+
 	AutomationTasks []AutomationTask `gorm:"foreignKey:UserID"`
 	Settings        *UserSettings    `gorm:"foreignKey:UserID"`
 }
 
+// Role returns the user's admin role, or "" for users that are not admins
+func (u User) Role() string {
+	if !u.IsAdmin {
+		return ""
+	}
+	if u.AdminRole == AdminRoleSupport {
+		return AdminRoleSupport
+	}
+	return AdminRoleSuperadmin
+}
+
 // TableName specifies the table name for User
 func (User) TableName() string {
 	return "users"
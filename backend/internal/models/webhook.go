@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Webhook delivery states
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+// WebhookDelivery is a webhook notification waiting to be sent or retried.
+// Deliveries are persisted so retries survive restarts.
+type WebhookDelivery struct {
+	ID      int                    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID  int                    `gorm:"index:idx_webhook_deliveries_user_created,priority:1" json:"user_id"`
+	URL     string                 `gorm:"column:url;serializer:encrypted" json:"url"`
+	Kind    string                 `gorm:"column:kind" json:"kind"`
+	Title   string                 `gorm:"column:title" json:"title"`
+	Body    string                 `gorm:"column:body" json:"body"`
+	Data    map[string]interface{} `gorm:"column:data;serializer:json" json:"data"`
+	Product string                 `gorm:"column:product" json:"-"`
+	Status  string                 `gorm:"column:status;index:idx_webhook_deliveries_due,priority:1" json:"status"`
+	// Attempts counts the sends made so far
+	Attempts      int       `gorm:"column:attempts" json:"attempts"`
+	NextAttemptAt time.Time `gorm:"column:next_attempt_at;index:idx_webhook_deliveries_due,priority:2" json:"next_attempt_at"`
+	// LockedUntil is set while a dispatcher sends the delivery, so no other one picks it up
+	LockedUntil *time.Time `gorm:"column:locked_until" json:"-"`
+	LastError   string     `gorm:"column:last_error" json:"last_error"`
+	DeliveredAt *time.Time `gorm:"column:delivered_at" json:"delivered_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime;index:idx_webhook_deliveries_user_created,priority:2" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName specifies the table name for WebhookDelivery
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
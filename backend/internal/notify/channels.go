@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+)
+
+// deliveryClient is used for webhook and Telegram deliveries
+var deliveryClient = &http.Client{Timeout: 15 * time.Second}
+
+// sendEmail delivers a rendered message over SMTP, as plain text or, when the
+// template has an HTML body, as multipart/alternative with both versions
+func sendEmail(to string, out Rendered) error {
+	cfg := config.Get()
+	if cfg.SMTPHost == "" || cfg.SMTPFrom == "" {
+		return fmt.Errorf("SMTP is not configured")
+	}
+
+	var body strings.Builder
+	body.WriteString("From: " + cfg.SMTPFrom + "\r\n")
+	body.WriteString("To: " + to + "\r\n")
+	body.WriteString("Subject: " + mime.QEncoding.Encode("UTF-8", out.Subject) + "\r\n")
+	body.WriteString("MIME-Version: 1.0\r\n")
+
+	if out.HTML == "" {
+		body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		body.WriteString("\r\n")
+		body.WriteString(out.Text + "\r\n")
+	} else {
+		var parts bytes.Buffer
+		mw := multipart.NewWriter(&parts)
+		for _, part := range []struct{ contentType, content string }{
+			{"text/plain; charset=UTF-8", out.Text},
+			{"text/html; charset=UTF-8", out.HTML},
+		} {
+			w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+			if err != nil {
+				return err
+			}
+			io.WriteString(w, part.content+"\r\n")
+		}
+		if err := mw.Close(); err != nil {
+			return err
+		}
+
+		body.WriteString("Content-Type: multipart/alternative; boundary=" + mw.Boundary() + "\r\n")
+		body.WriteString("\r\n")
+		body.Write(parts.Bytes())
+	}
+
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+
+	addr := cfg.SMTPHost + ":" + strconv.Itoa(cfg.SMTPPort)
+	return smtp.SendMail(addr, auth, cfg.SMTPFrom, []string{to}, []byte(body.String()))
+}
+
+// SendEmail delivers a plain text email outside of the notification
+// preferences, e.g. for account verification
+func SendEmail(to, subject, text string) error {
+	return sendEmail(to, Rendered{Subject: subject, Text: text})
+}
+
+// sendWebhook posts a rendered JSON payload to the user's webhook URL. The
+// built-in payload carries a delivery ID that stays the same across retries,
+// so receivers can discard duplicates.
+func sendWebhook(url string, out Rendered) error {
+	return postJSON(url, []byte(out.Text))
+}
+
+// sendTelegram sends a rendered message to a Telegram chat through the configured bot
+func sendTelegram(chatID string, out Rendered) error {
+	token := config.Get().TelegramBotToken
+	if token == "" {
+		return fmt.Errorf("Telegram bot token is not configured")
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"chat_id": chatID,
+		"text":    out.Text,
+	})
+	if err != nil {
+		return err
+	}
+
+	return postJSON("https://api.telegram.org/bot"+token+"/sendMessage", payload)
+}
+
+// postJSON posts a JSON payload and treats any non-2xx response as an error
+func postJSON(url string, payload []byte) error {
+	resp, err := deliveryClient.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("error making request: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package notify
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/branding"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// digestFlushInterval is how often due digests are looked for
+	digestFlushInterval = 30 * time.Second
+	// MaxDigestMinutes is the longest interval a digest rule may use
+	MaxDigestMinutes = 24 * 60
+	// digestBodyLines limits the notifications listed in a digest body
+	digestBodyLines = 20
+	// digestDataItems limits the notification data included in a digest
+	digestDataItems = 100
+)
+
+// errDigestClaimed is returned when another flusher took the digest first
+var errDigestClaimed = errors.New("digest already claimed")
+
+// digestTitle renders the title of a digest of n notifications; kinds without
+// their own title use a generic one
+func digestTitle(locale, kind string, n int) string {
+	switch kind {
+	case KindTaskCompleted:
+		return i18n.Translate(locale, "%d tasks completed", n)
+	case KindTaskFailed:
+		return i18n.Translate(locale, "%d tasks failed", n)
+	default:
+		return i18n.Translate(locale, "%d notifications", n)
+	}
+}
+
+// digestRule returns the user's digest rule for a kind
+func digestRule(prefs models.NotificationDefaults, kind string) (models.DigestRule, bool) {
+	for _, rule := range prefs.Digests {
+		if rule.Kind == kind && rule.IntervalMinutes > 0 {
+			return rule, true
+		}
+	}
+	return models.DigestRule{}, false
+}
+
+// queueDigest holds a notification back for the user's digest of its kind.
+// The first notification opens the group; later ones join it until it is sent.
+func queueDigest(db *gorm.DB, userID int, msg Message, rule models.DigestRule) error {
+	sendAt := time.Now().Add(time.Duration(rule.IntervalMinutes) * time.Minute)
+
+	var open models.NotificationDigestItem
+	err := db.Where("user_id = ? AND kind = ?", userID, msg.Kind).Order("send_at").First(&open).Error
+	switch {
+	case err == nil:
+		sendAt = open.SendAt
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+
+	return db.Create(&models.NotificationDigestItem{
+		UserID: userID,
+		Kind:   msg.Kind,
+		Title:  msg.Title,
+		Body:   msg.Body,
+		Data:   msg.Data,
+		SendAt: sendAt,
+	}).Error
+}
+
+// StartDigestFlusher sends digests whose interval has passed. Pending items
+// are stored, so digests survive restarts and are sent by whichever instance
+// claims them first.
+func StartDigestFlusher(db *gorm.DB) {
+	jobs.Register(jobs.Job{
+		Name:        "digests",
+		Description: "Send notification digests whose interval has passed",
+		Interval:    digestFlushInterval,
+		MinInterval: time.Second,
+		RunOnStart:  true,
+		Run: func() error {
+			flushDigests(db)
+			return nil
+		},
+	})
+}
+
+// flushDigests sends every digest that is due
+func flushDigests(db *gorm.DB) {
+	var due []struct {
+		UserID int
+		Kind   string
+	}
+	if err := db.Model(&models.NotificationDigestItem{}).
+		Distinct("user_id", "kind").
+		Where("send_at <= ?", time.Now()).
+		Scan(&due).Error; err != nil {
+		log.Printf("Failed to load due notification digests: %v", err)
+		return
+	}
+
+	for _, group := range due {
+		if err := flushDigest(db, group.UserID, group.Kind); err != nil && err != errDigestClaimed {
+			log.Printf("Failed to send %s digest to user ID %d: %v", group.Kind, group.UserID, err)
+		}
+	}
+}
+
+// flushDigest claims the pending items of one user and kind and delivers them as one message
+func flushDigest(db *gorm.DB, userID int, kind string) error {
+	var items []models.NotificationDigestItem
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND kind = ?", userID, kind).Order("id").Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return errDigestClaimed
+		}
+
+		ids := make([]int, len(items))
+		for i, item := range items {
+			ids[i] = item.ID
+		}
+		result := tx.Where("id IN ?", ids).Delete(&models.NotificationDigestItem{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(items)) {
+			return errDigestClaimed
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return err
+	}
+	brand, _ := branding.Load(db)
+
+	deliver(db, user, digestMessage(i18n.ForUser(user), kind, items, brand), brand)
+	return nil
+}
+
+// digestMessage combines held back notifications into one message. A group of
+// one is sent unchanged.
+func digestMessage(locale, kind string, items []models.NotificationDigestItem, brand branding.Branding) Message {
+	if len(items) == 1 {
+		return Message{
+			Kind:    kind,
+			Title:   items[0].Title,
+			Body:    items[0].Body,
+			Data:    items[0].Data,
+			Product: brand.ProductName,
+		}
+	}
+
+	var body strings.Builder
+	data := make([]map[string]interface{}, 0, min(len(items), digestDataItems))
+	for i, item := range items {
+		if i < digestBodyLines {
+			line := item.Body
+			if line == "" {
+				line = item.Title
+			}
+			body.WriteString("- " + line + "\n")
+		}
+		if i < digestDataItems {
+			data = append(data, item.Data)
+		}
+	}
+	if len(items) > digestBodyLines {
+		body.WriteString(i18n.Translate(locale, "and %d more", len(items)-digestBodyLines))
+	}
+
+	return Message{
+		Kind:  kind,
+		Title: digestTitle(locale, kind, len(items)),
+		Body:  strings.TrimRight(body.String(), "\n"),
+		Data: map[string]interface{}{
+			"digest": true,
+			"count":  len(items),
+			"since":  items[0].CreatedAt,
+			"items":  data,
+		},
+		Product: brand.ProductName,
+	}
+}
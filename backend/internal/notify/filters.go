@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/aliselcukkaya/account-editor/internal/models"
+)
+
+// MaxFilters is the most notification filters a user may set
+const MaxFilters = 20
+
+// FilterStatuses lists the task statuses notifications are sent for
+var FilterStatuses = map[string]bool{
+	"completed": true,
+	"failed":    true,
+}
+
+// subscribed reports whether the user's filters let a message through. Without
+// filters every message is sent.
+func subscribed(prefs models.NotificationDefaults, msg Message) bool {
+	if len(prefs.Filters) == 0 {
+		return true
+	}
+	for _, filter := range prefs.Filters {
+		if matchesFilter(filter, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFilter reports whether a message matches every list the filter sets
+func matchesFilter(filter models.NotificationFilter, msg Message) bool {
+	if len(filter.Kinds) > 0 && !contains(filter.Kinds, msg.Kind) {
+		return false
+	}
+	if len(filter.TaskNames) > 0 && !contains(filter.TaskNames, dataString(msg.Data, "name")) {
+		return false
+	}
+	if len(filter.Statuses) > 0 && !contains(filter.Statuses, dataString(msg.Data, "status")) {
+		return false
+	}
+	if len(filter.BatchIDs) > 0 {
+		batchID := dataString(msg.Data, "batch_id")
+		found := false
+		for _, id := range filter.BatchIDs {
+			if batchID == fmt.Sprint(id) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(filter.Tags) > 0 {
+		tags, _ := msg.Data["tags"].([]string)
+		found := false
+		for _, tag := range tags {
+			if contains(filter.Tags, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// dataString returns a message data value as text, or "" when it is missing
+func dataString(data map[string]interface{}, key string) string {
+	v, ok := data[key]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
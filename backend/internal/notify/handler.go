@@ -0,0 +1,366 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetNotifications lists the current user's in-app notifications, newest first
+func GetNotifications(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	page, err := utils.ParsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page == nil {
+		page = &utils.Page{Limit: utils.DefaultPageSize}
+	}
+
+	query := database.GetDB().Model(&models.Notification{}).Where("user_id = ?", u.ID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+
+	var notifications []models.Notification
+	if err := page.Apply(query).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+
+	n, next := page.NextCursor(len(notifications), func(i int) utils.Cursor {
+		return utils.Cursor{CreatedAt: notifications[i].CreatedAt, ID: notifications[i].ID}
+	})
+	notifications = notifications[:n]
+	c.Header("X-Next-Cursor", next)
+
+	utils.RespondList(c, notifications, total, next)
+}
+
+// MarkRead marks one of the current user's notifications as read
+func MarkRead(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	now := time.Now()
+	result := database.GetDB().Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", c.Param("id"), u.ID).
+		Update("read_at", &now)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+}
+
+// MarkAllRead marks all of the current user's notifications as read
+func MarkAllRead(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	now := time.Now()
+	result := database.GetDB().Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", u.ID).
+		Update("read_at", &now)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+}
+
+// GetWebhookDeliveries lists the current user's webhook deliveries, newest first
+func GetWebhookDeliveries(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	page, err := utils.ParsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page == nil {
+		page = &utils.Page{Limit: utils.DefaultPageSize}
+	}
+
+	query := database.GetDB().Model(&models.WebhookDelivery{}).Where("user_id = ?", u.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := page.Apply(query).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	n, next := page.NextCursor(len(deliveries), func(i int) utils.Cursor {
+		return utils.Cursor{CreatedAt: deliveries[i].CreatedAt, ID: deliveries[i].ID}
+	})
+	deliveries = deliveries[:n]
+	c.Header("X-Next-Cursor", next)
+
+	utils.RespondList(c, deliveries, total, next)
+}
+
+// RetryWebhookDelivery queues one of the current user's failed webhook deliveries again
+func RetryWebhookDelivery(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	result := database.GetDB().Model(&models.WebhookDelivery{}).
+		Where("id = ? AND user_id = ? AND status = ?", c.Param("id"), u.ID, models.WebhookFailed).
+		Updates(map[string]interface{}{
+			"status":          models.WebhookPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "No failed webhook delivery with this ID")})
+		return
+	}
+
+	wakeWebhookDispatcher()
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Webhook delivery queued")})
+}
+
+// SetupRoutes sets up the notification routes
+func SetupRoutes(router *gin.RouterGroup) {
+	router.GET("", GetNotifications)
+	router.POST("/:id/read", MarkRead)
+	router.POST("/read-all", MarkAllRead)
+	router.GET("/webhooks", GetWebhookDeliveries)
+	router.POST("/webhooks/:id/retry", RetryWebhookDelivery)
+}
+
+// TemplateRequest creates, replaces or previews a notification template
+type TemplateRequest struct {
+	Channel  string `json:"channel" binding:"required"`
+	Kind     string `json:"kind"`
+	Subject  string `json:"subject"`
+	Body     string `json:"body"`
+	HTMLBody string `json:"html_body"`
+}
+
+// template returns the request as a template model
+func (r TemplateRequest) template() models.NotificationTemplate {
+	return models.NotificationTemplate{
+		Channel:  r.Channel,
+		Kind:     r.Kind,
+		Subject:  r.Subject,
+		Body:     r.Body,
+		HTMLBody: r.HTMLBody,
+	}
+}
+
+// GetTemplates lists the stored notification templates together with the
+// built-in formats they replace (admin only)
+func GetTemplates(c *gin.Context) {
+	var templates []models.NotificationTemplate
+	if err := database.GetDB().Order("channel, kind").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	defaults := gin.H{}
+	for channel, tpl := range defaultTemplates {
+		defaults[channel] = gin.H{"subject": tpl.Subject, "body": tpl.Body}
+	}
+
+	kinds := make([]string, 0, len(Kinds))
+	for kind := range Kinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"defaults":  defaults,
+		"kinds":     kinds,
+	})
+}
+
+// SaveTemplate creates or replaces the template of a channel and kind (admin only)
+func SaveTemplate(c *gin.Context) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	tpl := req.template()
+	if err := validateTemplate(db, &tpl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			tpl.UpdatedBy = &u.ID
+		}
+	}
+
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "channel"}, {Name: "kind"}},
+		DoUpdates: clause.AssignmentColumns([]string{"subject", "body", "html_body", "updated_by", "updated_at"}),
+	}).Create(&tpl).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to save template")})
+		return
+	}
+
+	var saved models.NotificationTemplate
+	if err := db.Where("channel = ? AND kind = ?", tpl.Channel, tpl.Kind).First(&saved).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionTemplateSaved, "notification_template", saved.ID, map[string]interface{}{
+		"channel": saved.Channel,
+		"kind":    saved.Kind,
+	})
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// DeleteTemplate removes a template so the channel falls back to the
+// channel-wide template or the built-in format (admin only)
+func DeleteTemplate(c *gin.Context) {
+	db := database.GetDB()
+
+	var tpl models.NotificationTemplate
+	if err := db.First(&tpl, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Template not found")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	if err := db.Delete(&tpl).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	audit.Record(c, audit.ActionTemplateDeleted, "notification_template", tpl.ID, map[string]interface{}{
+		"channel": tpl.Channel,
+		"kind":    tpl.Kind,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c, "Template deleted")})
+}
+
+// PreviewTemplate renders a template with sample data without saving it. Empty
+// fields show the built-in format (admin only).
+func PreviewTemplate(c *gin.Context) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := database.GetDB()
+	tpl := req.template()
+	if err := checkTemplateFields(&tpl); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	data := sampleTemplateData(db, tpl.Kind)
+	out, err := renderTemplate(withDefaults(tpl, tpl.Channel), data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("template error: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rendered":  out,
+		"variables": data,
+	})
+}
+
+// SetupAdminRoutes sets up the admin notification template routes
+func SetupAdminRoutes(router *gin.RouterGroup) {
+	router.GET("/notification-templates", GetTemplates)
+	router.PUT("/notification-templates", SaveTemplate)
+	router.DELETE("/notification-templates/:id", DeleteTemplate)
+	router.POST("/notification-templates/preview", PreviewTemplate)
+}
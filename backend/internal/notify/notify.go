@@ -0,0 +1,215 @@
+package notify
+
+import (
+	"log"
+
+	"github.com/aliselcukkaya/account-editor/internal/branding"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+)
+
+// Notification channels a user can choose
+const (
+	ChannelInApp    = "in_app"
+	ChannelEmail    = "email"
+	ChannelWebhook  = "webhook"
+	ChannelTelegram = "telegram"
+)
+
+// Notification kinds
+const (
+	KindPanelDown           = "panel_down"
+	KindPanelRecovered      = "panel_recovered"
+	KindCredentialsInvalid  = "credentials_invalid"
+	KindCredentialsRestored = "credentials_restored"
+	KindQuotaWarning        = "quota_warning"
+	KindQuotaExceeded       = "quota_exceeded"
+	KindPlanDowngraded      = "plan_downgraded"
+	KindTaskCompleted       = "task_completed"
+	KindTaskFailed          = "task_failed"
+	// KindDeletionRequested tells admins that a user scheduled their account for deletion
+	KindDeletionRequested = "account_deletion_requested"
+	// KindJobFailed and KindJobRecovered tell admins that a background job keeps failing, or works again
+	KindJobFailed    = "job_failed"
+	KindJobRecovered = "job_recovered"
+	// KindDatabaseCorrupted tells admins that the SQLite integrity check found problems
+	KindDatabaseCorrupted = "database_corrupted"
+)
+
+// Channels lists every supported channel
+var Channels = map[string]bool{
+	ChannelInApp:    true,
+	ChannelEmail:    true,
+	ChannelWebhook:  true,
+	ChannelTelegram: true,
+}
+
+// Kinds lists every notification kind
+var Kinds = map[string]bool{
+	KindPanelDown:           true,
+	KindPanelRecovered:      true,
+	KindCredentialsInvalid:  true,
+	KindCredentialsRestored: true,
+	KindQuotaWarning:        true,
+	KindQuotaExceeded:       true,
+	KindPlanDowngraded:      true,
+	KindTaskCompleted:       true,
+	KindTaskFailed:          true,
+	KindDeletionRequested:   true,
+	KindJobFailed:           true,
+	KindJobRecovered:        true,
+	KindDatabaseCorrupted:   true,
+}
+
+// Text renders a message in a locale. It calls i18n.Translate with a literal
+// catalog key, so the key is found by extraction and its format checked by vet.
+type Text func(locale string) string
+
+// Event is a notification before it is rendered for a user; Title and Body
+// are rendered in the recipient's locale
+type Event struct {
+	Kind  string
+	Title Text
+	Body  Text
+	Data  map[string]interface{}
+}
+
+// Message is a notification rendered for one recipient
+type Message struct {
+	Kind    string                 `json:"kind"`
+	Title   string                 `json:"title"`
+	Body    string                 `json:"body"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+	Product string                 `json:"product"`
+}
+
+// Notify stores an in-app notification for the user and delivers it to the user's
+// other chosen channels in the background. Notifications the user's filters do
+// not match are dropped, and kinds the user has a digest rule for are held back
+// and sent as one message per interval. Delivery failures are logged only.
+func Notify(userID int, event Event) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Failed to load user ID %d for notification %s: %v", userID, event.Kind, err)
+		return
+	}
+
+	locale := i18n.ForUser(user)
+	brand, _ := branding.Load(db)
+	msg := Message{
+		Kind:    event.Kind,
+		Title:   event.Title(locale),
+		Body:    event.Body(locale),
+		Data:    event.Data,
+		Product: brand.ProductName,
+	}
+
+	if !subscribed(user.NotificationDefaults, msg) {
+		return
+	}
+
+	if rule, ok := digestRule(user.NotificationDefaults, msg.Kind); ok {
+		err := queueDigest(db, user.ID, msg, rule)
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to queue %s notification for user ID %d in a digest, sending it now: %v", msg.Kind, userID, err)
+	}
+
+	deliver(db, user, msg, brand)
+}
+
+// NotifySuperadmins notifies every active superadmin in the background
+func NotifySuperadmins(db *gorm.DB, event Event) {
+	var admins []models.User
+	if err := db.Where("is_admin = ? AND is_active = ? AND (admin_role = '' OR admin_role = ?)", true, true, models.AdminRoleSuperadmin).
+		Find(&admins).Error; err != nil {
+		log.Printf("Failed to load admins to notify of %s: %v", event.Kind, err)
+		return
+	}
+	for _, admin := range admins {
+		go Notify(admin.ID, event)
+	}
+}
+
+// JobAlert notifies the superadmins that a background job failed too many
+// times in a row, or recovered
+func JobAlert(a jobs.Alert) {
+	event := Event{
+		Kind:  KindJobFailed,
+		Title: func(locale string) string { return i18n.Translate(locale, "Background job %s is failing", a.Job) },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "The %s job failed %d times in a row: %s", a.Job, a.ConsecutiveFailures, a.Error)
+		},
+		Data: map[string]interface{}{
+			"job":                  a.Job,
+			"consecutive_failures": a.ConsecutiveFailures,
+			"error":                a.Error,
+		},
+	}
+	if a.Recovered {
+		event = Event{
+			Kind:  KindJobRecovered,
+			Title: func(locale string) string { return i18n.Translate(locale, "Background job %s recovered", a.Job) },
+			Body: func(locale string) string {
+				return i18n.Translate(locale, "The %s job ran successfully again after %d failures.", a.Job, a.ConsecutiveFailures)
+			},
+			Data: map[string]interface{}{
+				"job":                  a.Job,
+				"consecutive_failures": a.ConsecutiveFailures,
+			},
+		}
+	}
+	NotifySuperadmins(database.GetDB(), event)
+}
+
+// deliver stores the in-app notification and sends the message to the user's
+// other chosen channels in the background
+func deliver(db *gorm.DB, user models.User, msg Message, brand branding.Branding) {
+	notification := models.Notification{
+		UserID: user.ID,
+		Kind:   msg.Kind,
+		Title:  msg.Title,
+		Body:   msg.Body,
+		Data:   msg.Data,
+	}
+	if err := db.Create(&notification).Error; err != nil {
+		log.Printf("Failed to store notification %s for user ID %d: %v", msg.Kind, user.ID, err)
+	}
+
+	data := newTemplateData(msg, brand, templateUser(user))
+	prefs := user.NotificationDefaults
+	for _, channel := range prefs.Channels {
+		var send func() error
+		switch channel {
+		case ChannelEmail:
+			if prefs.Email == "" {
+				continue
+			}
+			send = func() error { return sendEmail(prefs.Email, render(db, ChannelEmail, data)) }
+		case ChannelWebhook:
+			if prefs.WebhookURL == "" {
+				continue
+			}
+			send = func() error { return deliverWebhook(user, msg) }
+		case ChannelTelegram:
+			if prefs.TelegramChatID == "" {
+				continue
+			}
+			send = func() error { return sendTelegram(prefs.TelegramChatID, render(db, ChannelTelegram, data)) }
+		default:
+			continue
+		}
+
+		go func(channel string, send func() error) {
+			if err := send(); err != nil {
+				log.Printf("Failed to deliver %s notification to user ID %d via %s: %v", msg.Kind, user.ID, channel, err)
+			}
+		}(channel, send)
+	}
+}
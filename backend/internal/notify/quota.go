@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/quota"
+)
+
+// quotaMessage renders the notification body for a quota and level
+func quotaMessage(locale, metric, level, used, limit string) string {
+	exceeded := level == quota.StatusExceeded
+	switch {
+	case metric == quota.MetricTasksToday && exceeded:
+		return i18n.Translate(locale, "You have used all %[2]s tasks for today; new tasks are rejected until tomorrow", used, limit)
+	case metric == quota.MetricTasksToday:
+		return i18n.Translate(locale, "You have created %s of your %s tasks for today", used, limit)
+	case metric == quota.MetricResultStorage && exceeded:
+		return i18n.Translate(locale, "Task results use %s of your %s storage; archive or delete old tasks", used, limit)
+	case metric == quota.MetricResultStorage:
+		return i18n.Translate(locale, "Task results use %s of your %s storage", used, limit)
+	case metric == quota.MetricWebhookDeliveriesToday && exceeded:
+		return i18n.Translate(locale, "You have used all %[2]s webhook deliveries for today; webhook notifications are skipped until tomorrow", used, limit)
+	default:
+		return i18n.Translate(locale, "%s of your %s webhook deliveries for today have been sent", used, limit)
+	}
+}
+
+// formatQuota renders a quota amount, using MB for storage
+func formatQuota(metric string, n int64) string {
+	if metric == quota.MetricResultStorage {
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+// CheckQuota notifies the user about quotas that reached the warning level or
+// their limit since the last check. Each level is announced once per period.
+func CheckQuota(userID int) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Failed to load user ID %d for quota check: %v", userID, err)
+		return
+	}
+
+	crossings, err := quota.Crossings(db, user)
+	if err != nil {
+		log.Printf("Failed to check quotas for user ID %d: %v", userID, err)
+	}
+
+	for _, crossing := range crossings {
+		kind := KindQuotaWarning
+		title := Text(func(locale string) string { return i18n.Translate(locale, "Quota warning") })
+		if crossing.Level == quota.StatusExceeded {
+			kind = KindQuotaExceeded
+			title = func(locale string) string { return i18n.Translate(locale, "Quota reached") }
+		}
+
+		crossing := crossing
+		used := formatQuota(crossing.Metric, crossing.Usage.Used)
+		limit := formatQuota(crossing.Metric, *crossing.Usage.Limit)
+		Notify(userID, Event{
+			Kind:  kind,
+			Title: title,
+			Body: func(locale string) string {
+				return quotaMessage(locale, crossing.Metric, crossing.Level, used, limit)
+			},
+			Data: map[string]interface{}{
+				"metric": crossing.Metric,
+				"used":   crossing.Usage.Used,
+				"limit":  *crossing.Usage.Limit,
+			},
+		})
+	}
+}
+
+// deliverWebhook queues a webhook notification if the user's daily webhook quota allows it
+func deliverWebhook(user models.User, msg Message) error {
+	db := database.GetDB()
+
+	if err := quota.AllowWebhook(db, user); err != nil {
+		if err == quota.ErrExceeded {
+			log.Printf("Skipping %s webhook for user ID %d: daily webhook quota reached", msg.Kind, user.ID)
+			return nil
+		}
+		return err
+	}
+
+	err := enqueueWebhook(db, user, msg)
+	if incErr := quota.Increment(db, user, quota.MetricWebhookDeliveriesToday); incErr != nil {
+		log.Printf("Failed to count webhook delivery for user ID %d: %v", user.ID, incErr)
+	}
+	CheckQuota(user.ID)
+	return err
+}
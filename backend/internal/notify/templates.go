@@ -0,0 +1,398 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/branding"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/quota"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxTemplateSize limits the source of each template field
+	maxTemplateSize = 64 << 10
+	// maxRenderedSize limits the output of a template, so a loop cannot produce an unbounded message
+	maxRenderedSize = 256 << 10
+)
+
+// errRenderedTooLarge is returned when a template produces more than maxRenderedSize bytes
+var errRenderedTooLarge = errors.New("rendered template is too large")
+
+// TemplateChannels lists the channels whose format can be customized; in-app
+// notifications are shown by the UI and have no template
+var TemplateChannels = map[string]bool{
+	ChannelEmail:    true,
+	ChannelWebhook:  true,
+	ChannelTelegram: true,
+}
+
+// defaultTemplates reproduce the built-in formats and apply when no template
+// is stored, or to the fields a stored template leaves empty
+var defaultTemplates = map[string]models.NotificationTemplate{
+	ChannelEmail: {
+		Channel: ChannelEmail,
+		Subject: `{{if .Product}}[{{.Product}}] {{end}}{{.Title}}`,
+		Body:    `{{.Body}}`,
+	},
+	ChannelTelegram: {
+		Channel: ChannelTelegram,
+		Body:    "{{.Title}}\n\n{{.Body}}",
+	},
+	ChannelWebhook: {
+		Channel: ChannelWebhook,
+		Body: `{"event": {{json .Kind}}, "user_id": {{json .User.ID}}, "delivery_id": {{json .DeliveryID}}, "attempt": {{json .Attempt}}, ` +
+			`"title": {{json .Title}}, "body": {{json .Body}}, "data": {{json .Data}}, "sent_at": {{json .SentAt}}}`,
+	},
+}
+
+// sampleEvents are the notifications rendered by the preview and when a template is validated
+var sampleEvents = map[string]Event{
+	KindPanelDown: {
+		Kind:  KindPanelDown,
+		Title: func(locale string) string { return i18n.Translate(locale, "Your panel is unreachable") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "The panel at %s did not respond to health checks: %s. Tasks will fail until it recovers.", "https://panel.example.com", "connection refused")
+		},
+		Data: map[string]interface{}{"website_url": "https://panel.example.com"},
+	},
+	KindPanelRecovered: {
+		Kind:  KindPanelRecovered,
+		Title: func(locale string) string { return i18n.Translate(locale, "Your panel is reachable again") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "The panel at %s is responding again.", "https://panel.example.com")
+		},
+		Data: map[string]interface{}{"website_url": "https://panel.example.com"},
+	},
+	KindCredentialsInvalid: {
+		Kind:  KindCredentialsInvalid,
+		Title: func(locale string) string { return i18n.Translate(locale, "Your panel API key was rejected") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "The panel at %s rejected your API key: %s. New tasks and renewals are paused until you update your settings.", "https://panel.example.com", "API error: Invalid API key (RID: 1)")
+		},
+		Data: map[string]interface{}{"website_url": "https://panel.example.com"},
+	},
+	KindCredentialsRestored: {
+		Kind:  KindCredentialsRestored,
+		Title: func(locale string) string { return i18n.Translate(locale, "Your panel API key works again") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "The panel at %s accepts your API key again. Tasks and renewals have resumed.", "https://panel.example.com")
+		},
+		Data: map[string]interface{}{"website_url": "https://panel.example.com"},
+	},
+	KindQuotaWarning: {
+		Kind:  KindQuotaWarning,
+		Title: func(locale string) string { return i18n.Translate(locale, "Quota warning") },
+		Body: func(locale string) string {
+			return quotaMessage(locale, quota.MetricTasksToday, quota.StatusWarning, "80", "100")
+		},
+		Data: map[string]interface{}{"metric": quota.MetricTasksToday, "used": 80, "limit": 100},
+	},
+	KindQuotaExceeded: {
+		Kind:  KindQuotaExceeded,
+		Title: func(locale string) string { return i18n.Translate(locale, "Quota reached") },
+		Body: func(locale string) string {
+			return quotaMessage(locale, quota.MetricTasksToday, quota.StatusExceeded, "100", "100")
+		},
+		Data: map[string]interface{}{"metric": quota.MetricTasksToday, "used": 100, "limit": 100},
+	},
+	KindPlanDowngraded: {
+		Kind:  KindPlanDowngraded,
+		Title: func(locale string) string { return i18n.Translate(locale, "Plan downgraded") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "Your %s subscription has ended and your account was moved to the default plan", "Pro")
+		},
+		Data: map[string]interface{}{"plan_id": 2, "status": "canceled"},
+	},
+	KindTaskCompleted: {
+		Kind:  KindTaskCompleted,
+		Title: func(locale string) string { return i18n.Translate(locale, "Task completed") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "Task %s #%d completed", "create_account", 42)
+		},
+		Data: map[string]interface{}{"task_id": 42, "name": "create_account"},
+	},
+	KindTaskFailed: {
+		Kind:  KindTaskFailed,
+		Title: func(locale string) string { return i18n.Translate(locale, "Task failed") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "Task %s #%d failed: %s", "create_account", 42, "Username already exists")
+		},
+		Data: map[string]interface{}{"task_id": 42, "name": "create_account", "error": "Username already exists"},
+	},
+	KindDeletionRequested: {
+		Kind:  KindDeletionRequested,
+		Title: func(locale string) string { return i18n.Translate(locale, "Account deletion requested") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "%s requested deletion of their account. It will be erased on %s unless they cancel.", "operator1", "2024-06-08")
+		},
+		Data: map[string]interface{}{"user_id": 7, "username": "operator1", "scheduled_at": "2024-06-08T12:00:00Z"},
+	},
+	KindJobFailed: {
+		Kind:  KindJobFailed,
+		Title: func(locale string) string { return i18n.Translate(locale, "Background job %s is failing", "renewals") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "The %s job failed %d times in a row: %s", "renewals", 3, "database is locked")
+		},
+		Data: map[string]interface{}{"job": "renewals", "consecutive_failures": 3, "error": "database is locked"},
+	},
+	KindJobRecovered: {
+		Kind:  KindJobRecovered,
+		Title: func(locale string) string { return i18n.Translate(locale, "Background job %s recovered", "renewals") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "The %s job ran successfully again after %d failures.", "renewals", 3)
+		},
+		Data: map[string]interface{}{"job": "renewals", "consecutive_failures": 3},
+	},
+	KindDatabaseCorrupted: {
+		Kind:  KindDatabaseCorrupted,
+		Title: func(locale string) string { return i18n.Translate(locale, "Database integrity check failed") },
+		Body: func(locale string) string {
+			return i18n.Translate(locale, "The SQLite integrity check found %d problems: %s", 1, "*** in database main ***")
+		},
+		Data: map[string]interface{}{"problems": []string{"*** in database main ***"}},
+	},
+}
+
+// TemplateUser is the recipient as seen by templates
+type TemplateUser struct {
+	ID          int
+	Username    string
+	DisplayName string
+}
+
+// TemplateData is the variable set available to notification templates. It
+// holds plain values only, so templates cannot reach the database or configuration.
+type TemplateData struct {
+	Kind    string
+	Title   string
+	Body    string
+	Data    map[string]interface{}
+	Product string
+	Brand   branding.Branding
+	User    TemplateUser
+	// DeliveryID and Attempt are set for webhooks only
+	DeliveryID int
+	Attempt    int
+	SentAt     time.Time
+}
+
+// Rendered is a notification formatted for one channel
+type Rendered struct {
+	Subject string `json:"subject,omitempty"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+}
+
+// templateFuncs are the only functions templates can call besides the builtins
+var templateFuncs = map[string]interface{}{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// limitedBuffer fails writes once maxRenderedSize is exceeded
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxRenderedSize {
+		return 0, errRenderedTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// newTemplateData returns the template variables for a message sent to user
+func newTemplateData(msg Message, brand branding.Branding, user TemplateUser) TemplateData {
+	return TemplateData{
+		Kind:    msg.Kind,
+		Title:   msg.Title,
+		Body:    msg.Body,
+		Data:    msg.Data,
+		Product: msg.Product,
+		Brand:   brand,
+		User:    user,
+		SentAt:  time.Now(),
+	}
+}
+
+// templateUser returns the template view of a user
+func templateUser(u models.User) TemplateUser {
+	return TemplateUser{ID: u.ID, Username: u.Username, DisplayName: u.DisplayName}
+}
+
+// sampleTemplateData returns example variables for the given kind, in English
+func sampleTemplateData(db *gorm.DB, kind string) TemplateData {
+	event, ok := sampleEvents[kind]
+	if !ok {
+		event = sampleEvents[KindPanelDown]
+	}
+
+	brand, _ := branding.Load(db)
+	data := newTemplateData(Message{
+		Kind:    event.Kind,
+		Title:   event.Title(i18n.DefaultLocale),
+		Body:    event.Body(i18n.DefaultLocale),
+		Data:    event.Data,
+		Product: brand.ProductName,
+	}, brand, TemplateUser{ID: 1, Username: "jane", DisplayName: "Jane Doe"})
+	data.DeliveryID = 1
+	data.Attempt = 1
+	return data
+}
+
+// loadTemplate returns the template for a channel and kind: the kind's own
+// template, else the channel-wide one, with empty fields taken from the built-in format
+func loadTemplate(db *gorm.DB, channel, kind string) (models.NotificationTemplate, error) {
+	var stored models.NotificationTemplate
+	err := db.Where("channel = ? AND kind IN ?", channel, []string{kind, ""}).
+		Order("kind DESC").
+		First(&stored).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return defaultTemplates[channel], err
+	}
+	return withDefaults(stored, channel), nil
+}
+
+// withDefaults fills the empty fields of a template from the built-in format
+func withDefaults(tpl models.NotificationTemplate, channel string) models.NotificationTemplate {
+	def := defaultTemplates[channel]
+	tpl.Channel = channel
+	if tpl.Subject == "" {
+		tpl.Subject = def.Subject
+	}
+	if tpl.Body == "" {
+		tpl.Body = def.Body
+	}
+	return tpl
+}
+
+// renderTemplate executes a template with the given variables
+func renderTemplate(tpl models.NotificationTemplate, data TemplateData) (Rendered, error) {
+	var out Rendered
+	var err error
+
+	if tpl.Channel == ChannelEmail {
+		subject, err := executeText("subject", tpl.Subject, data)
+		if err != nil {
+			return out, err
+		}
+		// A subject is a single header line
+		out.Subject = strings.Join(strings.Fields(subject), " ")
+	}
+
+	if out.Text, err = executeText("body", tpl.Body, data); err != nil {
+		return out, err
+	}
+
+	if tpl.Channel == ChannelEmail && tpl.HTMLBody != "" {
+		if out.HTML, err = executeHTML("html_body", tpl.HTMLBody, data); err != nil {
+			return out, err
+		}
+	}
+
+	if tpl.Channel == ChannelWebhook && !json.Valid([]byte(out.Text)) {
+		return out, fmt.Errorf("webhook template did not produce valid JSON")
+	}
+	return out, nil
+}
+
+// executeText renders a text/template
+func executeText(name, source string, data TemplateData) (string, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Parse(source)
+	if err != nil {
+		return "", err
+	}
+	var buf limitedBuffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// executeHTML renders an html/template, escaping the variables for HTML
+func executeHTML(name, source string, data TemplateData) (string, error) {
+	t, err := htmltemplate.New(name).Funcs(templateFuncs).Parse(source)
+	if err != nil {
+		return "", err
+	}
+	var buf limitedBuffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// checkTemplateFields normalizes the channel and kind of a template and checks
+// that its fields apply to the channel
+func checkTemplateFields(tpl *models.NotificationTemplate) error {
+	tpl.Channel = strings.TrimSpace(tpl.Channel)
+	tpl.Kind = strings.TrimSpace(tpl.Kind)
+
+	if !TemplateChannels[tpl.Channel] {
+		return fmt.Errorf("channel must be one of email, webhook, telegram")
+	}
+	if tpl.Kind != "" && !Kinds[tpl.Kind] {
+		return fmt.Errorf("unknown notification kind %q", tpl.Kind)
+	}
+	if tpl.Channel != ChannelEmail && (tpl.Subject != "" || tpl.HTMLBody != "") {
+		return fmt.Errorf("subject and html_body apply to email templates only")
+	}
+	return nil
+}
+
+// validateTemplate checks the fields of a template for its channel and renders
+// it with sample data, so a broken template is rejected before it is used
+func validateTemplate(db *gorm.DB, tpl *models.NotificationTemplate) error {
+	if err := checkTemplateFields(tpl); err != nil {
+		return err
+	}
+	if tpl.Subject == "" && tpl.Body == "" && tpl.HTMLBody == "" {
+		return fmt.Errorf("template is empty")
+	}
+	for _, field := range []string{tpl.Subject, tpl.Body, tpl.HTMLBody} {
+		if len(field) > maxTemplateSize {
+			return fmt.Errorf("template fields must be at most %d bytes", maxTemplateSize)
+		}
+	}
+
+	if _, err := renderTemplate(withDefaults(*tpl, tpl.Channel), sampleTemplateData(db, tpl.Kind)); err != nil {
+		return fmt.Errorf("template error: %v", err)
+	}
+	return nil
+}
+
+// render formats a message for a channel with its template. A stored template
+// that fails at send time falls back to the built-in format, so a template
+// mistake never loses a notification.
+func render(db *gorm.DB, channel string, data TemplateData) Rendered {
+	tpl, err := loadTemplate(db, channel, data.Kind)
+	if err != nil {
+		log.Printf("Failed to load %s template for %s: %v", channel, data.Kind, err)
+	}
+
+	out, err := renderTemplate(tpl, data)
+	if err == nil {
+		return out
+	}
+	log.Printf("Failed to render %s template for %s, using the built-in format: %v", channel, data.Kind, err)
+
+	out, err = renderTemplate(defaultTemplates[channel], data)
+	if err != nil {
+		log.Printf("Failed to render built-in %s template for %s: %v", channel, data.Kind, err)
+	}
+	return out
+}
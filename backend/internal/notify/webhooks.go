@@ -0,0 +1,188 @@
+package notify
+
+import (
+	"log"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/branding"
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// webhookDispatchInterval is how often due webhook deliveries are looked for
+	webhookDispatchInterval = 15 * time.Second
+	// webhookRetryBase is the delay before the first retry; it doubles with every attempt
+	webhookRetryBase = 30 * time.Second
+	// webhookRetryMax caps the delay between retries
+	webhookRetryMax = time.Hour
+	// webhookLockDuration is how long a claimed delivery is hidden from other
+	// dispatchers; a delivery whose sender crashed is picked up again after it
+	webhookLockDuration = 2 * time.Minute
+	// webhookBatchSize limits the deliveries claimed per round
+	webhookBatchSize = 50
+	// webhookRetention is how long finished deliveries are kept
+	webhookRetention = 7 * 24 * time.Hour
+)
+
+// webhookWake triggers a dispatch round right after a delivery is queued
+var webhookWake = make(chan struct{}, 1)
+
+// enqueueWebhook stores a webhook delivery and wakes the dispatcher to send it
+func enqueueWebhook(db *gorm.DB, user models.User, msg Message) error {
+	delivery := models.WebhookDelivery{
+		UserID:        user.ID,
+		URL:           user.NotificationDefaults.WebhookURL,
+		Kind:          msg.Kind,
+		Title:         msg.Title,
+		Body:          msg.Body,
+		Data:          msg.Data,
+		Product:       msg.Product,
+		Status:        models.WebhookPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := db.Create(&delivery).Error; err != nil {
+		return err
+	}
+
+	wakeWebhookDispatcher()
+	return nil
+}
+
+// wakeWebhookDispatcher starts a dispatch round without waiting for the next tick
+func wakeWebhookDispatcher() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// webhookBackoff returns the delay after the given failed attempt
+func webhookBackoff(attempt int) time.Duration {
+	delay := webhookRetryBase << (attempt - 1)
+	if attempt > 20 || delay > webhookRetryMax {
+		return webhookRetryMax
+	}
+	return delay
+}
+
+// StartWebhookDispatcher sends queued webhook deliveries and retries failed
+// ones with exponential backoff. Deliveries are claimed one by one with a
+// lock, so several server instances never send the same delivery twice.
+func StartWebhookDispatcher(db *gorm.DB) {
+	var lastCleanup time.Time
+	jobs.Register(jobs.Job{
+		Name:        "webhooks",
+		Description: "Send queued webhook deliveries, retry failed ones and remove old ones",
+		Interval:    webhookDispatchInterval,
+		MinInterval: time.Second,
+		RunOnStart:  true,
+		Wake:        webhookWake,
+		Run: func() error {
+			dispatchWebhooks(db)
+
+			if time.Since(lastCleanup) > time.Hour {
+				cleanupWebhooks(db)
+				lastCleanup = time.Now()
+			}
+			return nil
+		},
+	})
+}
+
+// dispatchWebhooks sends all deliveries that are due
+func dispatchWebhooks(db *gorm.DB) {
+	for {
+		now := time.Now()
+
+		var due []models.WebhookDelivery
+		if err := db.Where("status = ? AND next_attempt_at <= ? AND (locked_until IS NULL OR locked_until < ?)",
+			models.WebhookPending, now, now).
+			Order("next_attempt_at, id").
+			Limit(webhookBatchSize).
+			Find(&due).Error; err != nil {
+			log.Printf("Failed to load due webhook deliveries: %v", err)
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+
+		for _, delivery := range due {
+			// Claim the delivery; another dispatcher may have taken it since it was loaded
+			claim := db.Model(&models.WebhookDelivery{}).
+				Where("id = ? AND status = ? AND (locked_until IS NULL OR locked_until < ?)", delivery.ID, models.WebhookPending, now).
+				Update("locked_until", now.Add(webhookLockDuration))
+			if claim.Error != nil {
+				log.Printf("Failed to claim webhook delivery %d: %v", delivery.ID, claim.Error)
+				continue
+			}
+			if claim.RowsAffected == 0 {
+				continue
+			}
+
+			attemptWebhook(db, delivery)
+		}
+
+		if len(due) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// attemptWebhook sends a claimed delivery once and schedules a retry on failure
+func attemptWebhook(db *gorm.DB, delivery models.WebhookDelivery) {
+	attempt := delivery.Attempts + 1
+	msg := Message{
+		Kind:    delivery.Kind,
+		Title:   delivery.Title,
+		Body:    delivery.Body,
+		Data:    delivery.Data,
+		Product: delivery.Product,
+	}
+
+	updates := map[string]interface{}{
+		"attempts":     attempt,
+		"locked_until": nil,
+	}
+
+	// The recipient is only needed by templates; a deleted user still gets the delivery
+	var user models.User
+	if err := db.Select("id, username, display_name").First(&user, delivery.UserID).Error; err != nil {
+		user.ID = delivery.UserID
+	}
+	brand, _ := branding.Load(db)
+	data := newTemplateData(msg, brand, templateUser(user))
+	data.DeliveryID = delivery.ID
+	data.Attempt = attempt
+
+	err := sendWebhook(delivery.URL, render(db, ChannelWebhook, data))
+	switch {
+	case err == nil:
+		updates["status"] = models.WebhookDelivered
+		updates["delivered_at"] = time.Now()
+		updates["last_error"] = ""
+	case attempt >= config.Get().WebhookMaxAttempts:
+		updates["status"] = models.WebhookFailed
+		updates["last_error"] = err.Error()
+		log.Printf("Giving up %s webhook delivery %d to user ID %d after %d attempts: %v", delivery.Kind, delivery.ID, delivery.UserID, attempt, err)
+	default:
+		updates["next_attempt_at"] = time.Now().Add(webhookBackoff(attempt))
+		updates["last_error"] = err.Error()
+	}
+
+	if err := db.Model(&models.WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(updates).Error; err != nil {
+		log.Printf("Failed to update webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// cleanupWebhooks deletes finished deliveries past the retention period
+func cleanupWebhooks(db *gorm.DB) {
+	cutoff := time.Now().Add(-webhookRetention)
+	if err := db.Where("status IN ? AND updated_at < ?", []string{models.WebhookDelivered, models.WebhookFailed}, cutoff).
+		Delete(&models.WebhookDelivery{}).Error; err != nil {
+		log.Printf("Failed to clean up webhook deliveries: %v", err)
+	}
+}
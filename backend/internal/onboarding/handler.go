@@ -0,0 +1,106 @@
+package onboarding
+
+import (
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetOnboarding returns the current user's onboarding progress
+func GetOnboarding(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	state, err := ForUser(database.GetDB(), u)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+// GetAllOnboarding lists onboarding progress for every user (admin only).
+// Pass ?completed=true or ?completed=false to list only users who have or have
+// not completed onboarding.
+func GetAllOnboarding(c *gin.Context) {
+	db := database.GetReadDB()
+
+	page, err := utils.ParsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if page == nil {
+		page = &utils.Page{Limit: utils.DefaultPageSize}
+	}
+
+	query := db.Model(&models.User{})
+	switch c.Query("completed") {
+	case "true":
+		query = WhereCompleted(query, true)
+	case "false":
+		query = WhereCompleted(query, false)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var users []models.User
+	if err := page.Apply(query).Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	n, next := page.NextCursor(len(users), func(i int) utils.Cursor {
+		return utils.Cursor{CreatedAt: users[i].CreatedAt, ID: users[i].ID}
+	})
+	users = users[:n]
+
+	states, err := Compute(db, users)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	response := make([]gin.H, 0, len(users))
+	for _, u := range users {
+		state := states[u.ID]
+		response = append(response, gin.H{
+			"user_id":   u.ID,
+			"username":  u.Username,
+			"current":   state.Current,
+			"completed": state.Completed,
+			"steps":     state.Steps,
+		})
+	}
+
+	utils.RespondList(c, response, total, next)
+}
+
+// SetupRoutes sets up the onboarding routes
+func SetupRoutes(router *gin.RouterGroup) {
+	router.GET("", GetOnboarding)
+}
+
+// SetupAdminRoutes sets up the onboarding routes for admins
+func SetupAdminRoutes(router *gin.RouterGroup) {
+	router.GET("/onboarding", GetAllOnboarding)
+}
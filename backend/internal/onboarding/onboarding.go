@@ -0,0 +1,165 @@
+package onboarding
+
+import (
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+)
+
+// Onboarding steps, in the order a new user completes them
+const (
+	StepPasswordChanged    = "password_changed"
+	StepSettingsConfigured = "settings_configured"
+	StepConnectionTested   = "connection_tested"
+	StepFirstTaskRun       = "first_task_run"
+)
+
+// Steps lists the onboarding steps in order
+var Steps = []string{
+	StepPasswordChanged,
+	StepSettingsConfigured,
+	StepConnectionTested,
+	StepFirstTaskRun,
+}
+
+// Step is the state of a single onboarding step
+type Step struct {
+	Name   string     `json:"name"`
+	Done   bool       `json:"done"`
+	DoneAt *time.Time `json:"done_at"`
+}
+
+// State is a user's progress through onboarding. Current is the first step
+// that is not done yet, or empty once onboarding is completed.
+type State struct {
+	UserID    int    `json:"user_id"`
+	Steps     []Step `json:"steps"`
+	Current   string `json:"current"`
+	Completed bool   `json:"completed"`
+}
+
+// facts are the timestamps each step is derived from
+type facts struct {
+	passwordChangedAt    *time.Time
+	settingsConfiguredAt *time.Time
+	connectionVerifiedAt *time.Time
+	firstTaskAt          *time.Time
+}
+
+// build turns the collected facts into an onboarding state. A step only counts
+// once all earlier steps are done, so the state machine never skips ahead.
+func build(userID int, f facts) State {
+	doneAt := map[string]*time.Time{
+		StepPasswordChanged:    f.passwordChangedAt,
+		StepSettingsConfigured: f.settingsConfiguredAt,
+		StepConnectionTested:   f.connectionVerifiedAt,
+		StepFirstTaskRun:       f.firstTaskAt,
+	}
+
+	state := State{UserID: userID, Steps: make([]Step, 0, len(Steps))}
+	for _, name := range Steps {
+		step := Step{Name: name, DoneAt: doneAt[name]}
+		step.Done = step.DoneAt != nil && state.Current == ""
+		if !step.Done && state.Current == "" {
+			state.Current = name
+		}
+		state.Steps = append(state.Steps, step)
+	}
+	state.Completed = state.Current == ""
+	return state
+}
+
+// completedCondition matches users who have done every step, the way Compute
+// derives them, so lists can be filtered before they are paginated
+const completedCondition = `users.password_changed_at IS NOT NULL
+	AND EXISTS (SELECT 1 FROM user_settings s WHERE s.user_id = users.id
+		AND s.website_url <> '' AND s.api_key <> '' AND s.connection_verified_at IS NOT NULL)
+	AND (EXISTS (SELECT 1 FROM automation_tasks t WHERE t.user_id = users.id
+			AND t.status = 'completed' AND t.completed_at IS NOT NULL)
+		OR EXISTS (SELECT 1 FROM automation_tasks_archive a WHERE a.user_id = users.id
+			AND a.status = 'completed' AND a.completed_at IS NOT NULL))`
+
+// WhereCompleted limits a query on users to those who have completed
+// onboarding, or to those who have not
+func WhereCompleted(query *gorm.DB, completed bool) *gorm.DB {
+	if completed {
+		return query.Where(completedCondition)
+	}
+	return query.Where("NOT (" + completedCondition + ")")
+}
+
+// firstTaskRow holds the earliest completed task per user
+type firstTaskRow struct {
+	UserID      int
+	CompletedAt string
+}
+
+// Compute returns the onboarding state of each of the given users
+func Compute(db *gorm.DB, users []models.User) (map[int]State, error) {
+	ids := make([]int, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+
+	var settings []models.UserSettings
+	if err := db.Where("user_id IN ?", ids).Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	settingsByUser := make(map[int]models.UserSettings, len(settings))
+	for _, s := range settings {
+		settingsByUser[s.UserID] = s
+	}
+
+	// Archived tasks still count as a first run
+	var rows []firstTaskRow
+	if err := db.Raw(`SELECT user_id, MIN(completed_at) AS completed_at FROM (
+			SELECT user_id, completed_at FROM automation_tasks WHERE status = 'completed' AND user_id IN ?
+			UNION ALL
+			SELECT user_id, completed_at FROM automation_tasks_archive WHERE status = 'completed' AND user_id IN ?
+		) GROUP BY user_id`, ids, ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	firstTask := make(map[int]*time.Time, len(rows))
+	for _, r := range rows {
+		if t, ok := parseTime(r.CompletedAt); ok {
+			firstTask[r.UserID] = &t
+		}
+	}
+
+	states := make(map[int]State, len(users))
+	for _, u := range users {
+		f := facts{
+			passwordChangedAt: u.PasswordChangedAt,
+			firstTaskAt:       firstTask[u.ID],
+		}
+		if s, ok := settingsByUser[u.ID]; ok {
+			if s.WebsiteURL != "" && s.APIKey != "" {
+				createdAt := s.CreatedAt
+				f.settingsConfiguredAt = &createdAt
+			}
+			f.connectionVerifiedAt = s.ConnectionVerifiedAt
+		}
+		states[u.ID] = build(u.ID, f)
+	}
+	return states, nil
+}
+
+// ForUser returns the onboarding state of a single user
+func ForUser(db *gorm.DB, user models.User) (State, error) {
+	states, err := Compute(db, []models.User{user})
+	if err != nil {
+		return State{}, err
+	}
+	return states[user.ID], nil
+}
+
+// parseTime reads a timestamp aggregated by SQLite, which comes back as text
+func parseTime(v string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
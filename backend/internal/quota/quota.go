@@ -0,0 +1,367 @@
+package quota
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Metered quotas
+const (
+	MetricTasksToday             = "tasks_today"
+	MetricResultStorage          = "result_storage_bytes"
+	MetricWebhookDeliveriesToday = "webhook_deliveries_today"
+)
+
+// Quota states
+const (
+	StatusOK       = "ok"
+	StatusWarning  = "warning"
+	StatusExceeded = "exceeded"
+)
+
+// ErrExceeded is returned when an action would go over a hard quota
+var ErrExceeded = errors.New("quota exceeded")
+
+// Limits are a user's quotas; 0 means unlimited
+type Limits struct {
+	TasksPerDay             int64
+	ResultStorageBytes      int64
+	WebhookDeliveriesPerDay int64
+	MaxBatchSize            int64
+	MaxRenewalRules         int64
+	MaxPanelProfiles        int64
+}
+
+// Usage is the consumption of a single quota
+type Usage struct {
+	Used    int64    `json:"used"`
+	Limit   *int64   `json:"limit"`
+	Percent *float64 `json:"percent"`
+	Status  string   `json:"status"`
+}
+
+// Report is a user's consumption against all quotas
+type Report struct {
+	// Plan is the name of the plan the limits come from, empty for server-wide quotas
+	Plan string `json:"plan"`
+	// Day is the current date in the user's timezone; daily quotas reset when it changes
+	Day         string           `json:"day"`
+	WarnPercent int              `json:"warn_percent"`
+	Quotas      map[string]Usage `json:"quotas"`
+	// Per-request limits of the plan, 0 for unlimited
+	MaxBatchSize     int64 `json:"max_batch_size"`
+	MaxRenewalRules  int64 `json:"max_renewal_rules"`
+	MaxPanelProfiles int64 `json:"max_panel_profiles"`
+}
+
+// Crossing is a quota level a user reached for the first time in a period
+type Crossing struct {
+	Metric string
+	Level  string
+	Usage  Usage
+}
+
+// PlanFor returns the user's assigned plan, or the default plan when none is
+// assigned or the assigned plan has expired. Sub-accounts get the plan of their
+// reseller account. It returns nil if no plan applies.
+func PlanFor(db *gorm.DB, user models.User) (*models.Plan, error) {
+	if user.ParentID != nil {
+		var parent models.User
+		if err := db.First(&parent, *user.ParentID).Error; err != nil {
+			return nil, err
+		}
+		user = parent
+	}
+
+	var plan models.Plan
+	query := db.Where("is_default = ?", true)
+	if user.PlanID != nil && (user.PlanExpiresAt == nil || user.PlanExpiresAt.After(time.Now())) {
+		query = db.Where("id = ?", *user.PlanID)
+	}
+	if err := query.First(&plan).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// LimitsFor returns the quotas that apply to the user: those of their plan, or
+// the server-wide quotas when no plan applies
+func LimitsFor(db *gorm.DB, user models.User) Limits {
+	plan, err := PlanFor(db, user)
+	if err != nil {
+		log.Printf("Failed to load plan of user ID %d, using server quotas: %v", user.ID, err)
+	}
+	return limitsOf(plan)
+}
+
+// limitsOf returns the limits of a plan, or the server-wide quotas for nil
+func limitsOf(plan *models.Plan) Limits {
+	if plan != nil {
+		return Limits{
+			TasksPerDay:             int64(plan.TasksPerDay),
+			ResultStorageBytes:      int64(plan.ResultStorageMB) * 1024 * 1024,
+			WebhookDeliveriesPerDay: int64(plan.WebhookDeliveriesPerDay),
+			MaxBatchSize:            int64(plan.MaxBatchSize),
+			MaxRenewalRules:         int64(plan.MaxRenewalRules),
+			MaxPanelProfiles:        int64(plan.MaxPanelProfiles),
+		}
+	}
+
+	cfg := config.Get()
+	return Limits{
+		TasksPerDay:             int64(cfg.QuotaTasksPerDay),
+		ResultStorageBytes:      int64(cfg.QuotaResultStorageMB) * 1024 * 1024,
+		WebhookDeliveriesPerDay: int64(cfg.QuotaWebhookDeliveriesPerDay),
+	}
+}
+
+// location returns the user's timezone, used for daily quotas
+func location(user models.User) *time.Location {
+	if user.Timezone != "" {
+		if loc, err := time.LoadLocation(user.Timezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// startOfDay returns midnight of the current day in the user's timezone
+func startOfDay(user models.User) time.Time {
+	now := time.Now().In(location(user))
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// today returns the current date in the user's timezone
+func today(user models.User) string {
+	return startOfDay(user).Format("2006-01-02")
+}
+
+// usage builds the usage of one quota
+func usage(used, limit int64) Usage {
+	u := Usage{Used: used, Status: StatusOK}
+	if limit <= 0 {
+		return u
+	}
+
+	percent := float64(used) * 100 / float64(limit)
+	u.Limit = &limit
+	u.Percent = &percent
+	switch {
+	case used >= limit:
+		u.Status = StatusExceeded
+	case percent >= float64(config.Get().QuotaWarnPercent):
+		u.Status = StatusWarning
+	}
+	return u
+}
+
+// tasksToday counts the tasks the user created today
+func tasksToday(db *gorm.DB, user models.User) (int64, error) {
+	var count int64
+	err := db.Model(&models.AutomationTask{}).
+		Where("user_id = ? AND created_at >= ?", user.ID, startOfDay(user)).
+		Count(&count).Error
+	return count, err
+}
+
+// resultStorage sums the stored size of the user's task results, archived ones
+// included; compressed results count with their compressed size
+func resultStorage(db *gorm.DB, userID int) (int64, error) {
+	var size int64
+	err := db.Raw(`SELECT
+			(SELECT COALESCE(SUM(COALESCE(LENGTH(result), 0) + COALESCE(LENGTH(result_gzip), 0)), 0) FROM automation_tasks WHERE user_id = ?) +
+			(SELECT COALESCE(SUM(COALESCE(LENGTH(result), 0) + COALESCE(LENGTH(result_gzip), 0)), 0) FROM automation_tasks_archive WHERE user_id = ?)`,
+		userID, userID).Scan(&size).Error
+	return size, err
+}
+
+// counter returns today's value of a usage counter
+func counter(db *gorm.DB, user models.User, metric string) (int64, error) {
+	var count int64
+	err := db.Model(&models.UsageCounter{}).
+		Where("user_id = ? AND metric = ? AND day = ?", user.ID, metric, today(user)).
+		Select("COALESCE(SUM(count), 0)").
+		Scan(&count).Error
+	return count, err
+}
+
+// Increment adds one to today's value of a usage counter
+func Increment(db *gorm.DB, user models.User, metric string) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "metric"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("usage_counters.count + 1"), "updated_at": time.Now()}),
+	}).Create(&models.UsageCounter{
+		UserID: user.ID,
+		Metric: metric,
+		Day:    today(user),
+		Count:  1,
+	}).Error
+}
+
+// Compute returns the user's current consumption against all quotas
+func Compute(db *gorm.DB, user models.User) (Report, error) {
+	plan, err := PlanFor(db, user)
+	if err != nil {
+		return Report{}, err
+	}
+	limits := limitsOf(plan)
+
+	tasks, err := tasksToday(db, user)
+	if err != nil {
+		return Report{}, err
+	}
+	storage, err := resultStorage(db, user.ID)
+	if err != nil {
+		return Report{}, err
+	}
+	webhooks, err := counter(db, user, MetricWebhookDeliveriesToday)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{
+		MaxBatchSize:     limits.MaxBatchSize,
+		MaxRenewalRules:  limits.MaxRenewalRules,
+		MaxPanelProfiles: limits.MaxPanelProfiles,
+		Day:              today(user),
+		WarnPercent:      config.Get().QuotaWarnPercent,
+		Quotas: map[string]Usage{
+			MetricTasksToday:             usage(tasks, limits.TasksPerDay),
+			MetricResultStorage:          usage(storage, limits.ResultStorageBytes),
+			MetricWebhookDeliveriesToday: usage(webhooks, limits.WebhookDeliveriesPerDay),
+		},
+	}
+	if plan != nil {
+		report.Plan = plan.Name
+	}
+	return report, nil
+}
+
+// AllowBatch returns ErrExceeded if a batch of n tasks is larger than the plan allows
+func AllowBatch(db *gorm.DB, user models.User, n int) error {
+	limit := LimitsFor(db, user).MaxBatchSize
+	if limit > 0 && int64(n) > limit {
+		return ErrExceeded
+	}
+	return nil
+}
+
+// AllowRenewalRule returns ErrExceeded if the user already has as many renewal rules as the plan allows
+func AllowRenewalRule(db *gorm.DB, user models.User) error {
+	limit := LimitsFor(db, user).MaxRenewalRules
+	if limit <= 0 {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&models.RenewalRule{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count >= limit {
+		return ErrExceeded
+	}
+	return nil
+}
+
+// AllowPanelProfile returns ErrExceeded if the user's account and its
+// sub-accounts already have as many configured panel profiles as the plan
+// allows, not counting the user's own profile that is being saved
+func AllowPanelProfile(db *gorm.DB, user models.User) error {
+	limit := LimitsFor(db, user).MaxPanelProfiles
+	if limit <= 0 {
+		return nil
+	}
+
+	ownerID := user.ID
+	if user.ParentID != nil {
+		ownerID = *user.ParentID
+	}
+	var count int64
+	if err := db.Model(&models.UserSettings{}).
+		Where("user_id <> ? AND website_url <> ''", user.ID).
+		Where("user_id = ? OR user_id IN (?)", ownerID, db.Model(&models.User{}).Select("id").Where("parent_id = ?", ownerID)).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count >= limit {
+		return ErrExceeded
+	}
+	return nil
+}
+
+// AllowTasks returns ErrExceeded if creating n more tasks today would go over the daily task quota
+func AllowTasks(db *gorm.DB, user models.User, n int) error {
+	limit := LimitsFor(db, user).TasksPerDay
+	if limit <= 0 {
+		return nil
+	}
+	used, err := tasksToday(db, user)
+	if err != nil {
+		return err
+	}
+	if used+int64(n) > limit {
+		return ErrExceeded
+	}
+	return nil
+}
+
+// AllowWebhook returns ErrExceeded if the daily webhook delivery quota is used up
+func AllowWebhook(db *gorm.DB, user models.User) error {
+	limit := LimitsFor(db, user).WebhookDeliveriesPerDay
+	if limit <= 0 {
+		return nil
+	}
+	used, err := counter(db, user, MetricWebhookDeliveriesToday)
+	if err != nil {
+		return err
+	}
+	if used >= limit {
+		return ErrExceeded
+	}
+	return nil
+}
+
+// Crossings returns the quota levels the user reached that they have not been
+// alerted about yet in the current period, and records them as alerted
+func Crossings(db *gorm.DB, user models.User) ([]Crossing, error) {
+	report, err := Compute(db, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var crossings []Crossing
+	for _, metric := range []string{MetricTasksToday, MetricResultStorage, MetricWebhookDeliveriesToday} {
+		u := report.Quotas[metric]
+		if u.Status == StatusOK {
+			continue
+		}
+
+		// Daily quotas warn once a day, storage once a month
+		period := report.Day
+		if metric == MetricResultStorage {
+			period = report.Day[:7]
+		}
+
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.QuotaAlert{
+			UserID: user.ID,
+			Metric: metric,
+			Level:  u.Status,
+			Period: period,
+		})
+		if result.Error != nil {
+			return crossings, result.Error
+		}
+		if result.RowsAffected == 1 {
+			crossings = append(crossings, Crossing{Metric: metric, Level: u.Status, Usage: u})
+		}
+	}
+	return crossings, nil
+}
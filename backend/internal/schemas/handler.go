@@ -0,0 +1,39 @@
+package schemas
+
+import (
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ListSchemas returns the URL of every published schema
+func ListSchemas(c *gin.Context) {
+	base := utils.BaseURL(c)
+	items := make([]gin.H, 0, len(registry))
+	for _, name := range Names() {
+		items = append(items, gin.H{"name": name, "url": base + Path + name})
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"schemas": items})
+}
+
+// GetSchema returns a published JSON Schema
+func GetSchema(c *gin.Context) {
+	s, ok := Get(c.Param("name"), utils.BaseURL(c))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schema not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Header("Content-Type", "application/schema+json")
+	c.JSON(http.StatusOK, s)
+}
+
+// SetupRoutes sets up the public schema routes
+func SetupRoutes(router *gin.RouterGroup) {
+	router.GET("", ListSchemas)
+	router.GET("/*name", GetSchema)
+}
@@ -0,0 +1,255 @@
+package schemas
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/notify"
+)
+
+// TaskNames lists the task types executeTask knows how to run
+var TaskNames = []string{"create_account", "find_account", "extend_package", "disable_account"}
+
+// Path is the URL prefix the schemas are served under
+const Path = "/schemas/"
+
+func str(desc string) *Schema {
+	return &Schema{Type: "string", Description: desc}
+}
+
+func nonEmpty(desc string) *Schema {
+	return &Schema{Type: "string", Description: desc, MinLength: 1}
+}
+
+func positive(desc string) *Schema {
+	min := 1.0
+	return &Schema{Type: "integer", Description: desc, Minimum: &min}
+}
+
+func enum(values ...string) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
+
+// taskRequest returns the request schema of a task type
+func taskRequest(name string) *Schema {
+	s := &Schema{
+		Title: "Task request: " + name,
+		Type:  "object",
+		Properties: map[string]*Schema{
+			"name":           {Type: "string", Enum: enum(name)},
+			"target_website": nonEmpty("Panel URL; bulk requests default to the one in the user's settings"),
+			"username":       str("Line username"),
+			"password":       str("Line password; generated by the panel when empty"),
+			"package":        {Type: "integer", Description: "Panel package ID"},
+			"tags": {Type: "array", MaxItems: 10, Description: "Labels for filtering the task's notifications",
+				Items: &Schema{Type: "string", MinLength: 1, MaxLength: 32}},
+		},
+		Required: []string{"name", "target_website"},
+	}
+
+	switch name {
+	case "create_account":
+		s.Description = "Creates a line on the panel"
+		s.Properties["package"] = positive("Panel package ID; defaults to the user's preset")
+		s.Properties["max_connections"] = positive("Concurrent connections of the line; defaults to the user's preset")
+		s.Properties["note"] = str("Reseller note of the line; defaults to the user's note template")
+		s.Required = append(s.Required, "package")
+	case "find_account":
+		s.Description = "Looks up the lines of a username"
+		s.Properties["username"] = nonEmpty("Line username")
+		s.Required = append(s.Required, "username")
+	case "extend_package":
+		s.Description = "Renews the line of a username with a package"
+		s.Properties["username"] = nonEmpty("Line username")
+		s.Properties["package"] = positive("Panel package ID; defaults to the user's preset")
+		s.Required = append(s.Required, "username", "package")
+	case "disable_account":
+		s.Description = "Disables the line of a username; it keeps its expiry and is enabled again when renewed"
+		s.Properties["username"] = nonEmpty("Line username")
+		s.Properties["expired_before"] = &Schema{Type: "string", Format: "date-time",
+			Description: "Skip the line unless the panel shows it expired before this time; set by the expiry rule"}
+		s.Required = append(s.Required, "username")
+	}
+	return s
+}
+
+// baseTaskRequest accepts any task type and is used when the name is unknown,
+// so the error lists the valid names
+func baseTaskRequest() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name": {Type: "string", Enum: enum(TaskNames...)},
+		},
+		Required: []string{"name"},
+	}
+}
+
+func genericTaskRequest() *Schema {
+	s := baseTaskRequest()
+	s.Title = "Task request"
+	s.Description = "Body of POST /automation/tasks and each entry of POST /automation/tasks/bulk"
+	for _, name := range TaskNames {
+		s.OneOf = append(s.OneOf, taskRequest(name))
+	}
+	return s
+}
+
+func lineResult() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"line_id":            str("Panel line ID"),
+			"username":           str("Line username"),
+			"password":           str("Line password"),
+			"expire_at":          {Type: "string", Format: "date-time"},
+			"transaction_amount": {Type: "number", Description: "Credits charged by the panel"},
+			"is_enabled":         {Type: "boolean", Description: "Set by disable_account"},
+			"skipped":            {Type: "boolean", Description: "Set by disable_account when the line was renewed after expired_before"},
+			"reason":             str("Why the line was skipped"),
+			"rid":                str("Panel request ID"),
+		},
+		Required: []string{"line_id", "expire_at"},
+	}
+}
+
+func panelLine() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"line_id":         str("Panel line ID"),
+			"username":        str("Line username"),
+			"password":        str("Line password"),
+			"mac_addr":        str(""),
+			"owner":           str("Reseller owning the line"),
+			"type":            str(""),
+			"expire_at":       {Type: "string", Format: "date-time"},
+			"is_enabled":      {Type: "boolean"},
+			"is_restreamer":   {Type: "boolean"},
+			"is_trial":        {Type: "boolean"},
+			"package_id":      {Type: "integer"},
+			"bouquets":        {Type: "array", Nullable: true, Items: &Schema{Type: "integer"}},
+			"max_connections": {Type: "integer"},
+			"reseller_notes":  str(""),
+		},
+		Required: []string{"line_id", "username"},
+	}
+}
+
+func failureResult() *Schema {
+	return &Schema{
+		Title: "Failed task",
+		Type:  "object",
+		Properties: map[string]*Schema{
+			"success":       {Type: "boolean", Enum: []interface{}{false}},
+			"error":         str("Error shown to the user; the admin's friendly message when a panel error mapping matches"),
+			"error_code":    str("Stable error classification, e.g. credentials or not_found"),
+			"explanation":   str("Set when a panel error mapping matches"),
+			"suggested_fix": str("Set when a panel error mapping matches"),
+			"raw_error":     str("The panel's original error when a mapping matches"),
+		},
+		Required: []string{"success", "error"},
+	}
+}
+
+// taskResult returns the result schema of a task type
+func taskResult(name string) *Schema {
+	success := &Schema{
+		Title: "Successful task",
+		Type:  "object",
+		Properties: map[string]*Schema{
+			"success": {Type: "boolean", Enum: []interface{}{true}},
+			"data":    lineResult(),
+		},
+		Required: []string{"success", "data"},
+	}
+	if name == "find_account" {
+		success.Properties["data"] = &Schema{Type: "array", Nullable: true, Items: panelLine()}
+	}
+
+	return &Schema{
+		Title:       "Task result: " + name,
+		Description: "Result of a completed or failed " + name + " task",
+		OneOf:       []*Schema{success, failureResult()},
+	}
+}
+
+func webhook() *Schema {
+	kinds := make([]string, 0, len(notify.Kinds))
+	for kind := range notify.Kinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	return &Schema{
+		Title:       "Webhook notification",
+		Description: "Body of a notification webhook with the default template; a custom webhook template replaces it",
+		Type:        "object",
+		Properties: map[string]*Schema{
+			"event":       {Type: "string", Enum: enum(kinds...)},
+			"user_id":     {Type: "integer"},
+			"delivery_id": {Type: "integer", Description: "Same for every attempt of a delivery, for deduplication"},
+			"attempt":     positive("Delivery attempt, starting at 1"),
+			"title":       str("Translated to the recipient's language"),
+			"body":        str("Translated to the recipient's language"),
+			"data":        {Type: "object", Nullable: true, Description: "Event details, e.g. task_id or website_url"},
+			"sent_at":     {Type: "string", Format: "date-time"},
+		},
+		Required: []string{"event", "user_id", "delivery_id", "attempt", "title", "body", "sent_at"},
+	}
+}
+
+// registry maps schema names to their constructors; built on demand, since
+// schemas are small and Get stamps an $id on the returned copy
+var registry = map[string]func() *Schema{
+	"task-request": genericTaskRequest,
+	"webhook":      webhook,
+}
+
+func init() {
+	for _, name := range TaskNames {
+		name := name
+		registry["task-request/"+name] = func() *Schema { return taskRequest(name) }
+		registry["task-result/"+name] = func() *Schema { return taskResult(name) }
+	}
+}
+
+// Names lists the registered schemas
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the named schema, identified by baseURL + Path + name
+func Get(name, baseURL string) (*Schema, bool) {
+	name = strings.TrimSuffix(strings.Trim(name, "/"), ".json")
+	build, ok := registry[name]
+	if !ok {
+		return nil, false
+	}
+	s := build()
+	s.ID = baseURL + Path + name
+	s.Dialect = draft
+	return s, true
+}
+
+// ValidateTaskRequest checks a decoded task request against the schema of its
+// type, or against the list of task names when the type is unknown
+func ValidateTaskRequest(doc interface{}) []string {
+	if m, ok := doc.(map[string]interface{}); ok {
+		if name, ok := m["name"].(string); ok {
+			if build, ok := registry["task-request/"+name]; ok {
+				return build().Validate(doc)
+			}
+		}
+	}
+	return baseTaskRequest().Validate(doc)
+}
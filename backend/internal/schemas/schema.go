@@ -0,0 +1,221 @@
+package schemas
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// draft is the JSON Schema dialect the published schemas use
+const draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema the published schemas use. The same value
+// is served to integrators and used to validate payloads, so the two cannot drift.
+type Schema struct {
+	ID          string `json:"$id,omitempty"`
+	Dialect     string `json:"$schema,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"-"`
+	// Nullable also allows null, published as a type list
+	Nullable             bool               `json:"-"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	MinLength            int                `json:"minLength,omitempty"`
+	MaxLength            int                `json:"maxLength,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MaxItems             int                `json:"maxItems,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+// MarshalJSON publishes the type, as a list when the schema is nullable
+func (s Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	out := struct {
+		plain
+		Type interface{} `json:"type,omitempty"`
+	}{plain: plain(s)}
+
+	switch {
+	case s.Type != "" && s.Nullable:
+		out.Type = []string{s.Type, "null"}
+	case s.Type != "":
+		out.Type = s.Type
+	}
+	return json.Marshal(out)
+}
+
+// Validate checks a decoded JSON document (as produced by json.Unmarshal into
+// an interface{}) and returns one message per violation
+func (s *Schema) Validate(doc interface{}) []string {
+	var errs []string
+	s.validate("", doc, &errs)
+	return errs
+}
+
+// field names a property for messages
+func field(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func (s *Schema) validate(path string, v interface{}, errs *[]string) {
+	name := path
+	if name == "" {
+		name = "document"
+	}
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, name+" "+fmt.Sprintf(format, args...))
+	}
+
+	if v == nil {
+		if s.Type != "" && !s.Nullable {
+			fail("must not be null")
+		}
+		return
+	}
+
+	if s.Type != "" && !hasType(s.Type, v) {
+		fail("must be of type %s", s.Type)
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		values := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			values[i] = fmt.Sprint(e)
+		}
+		fail("must be one of %s", strings.Join(values, ", "))
+	}
+
+	switch v := v.(type) {
+	case string:
+		if s.MinLength > 0 && len([]rune(v)) < s.MinLength {
+			if s.MinLength == 1 {
+				fail("must not be empty")
+			} else {
+				fail("must be at least %d characters", s.MinLength)
+			}
+		}
+		if s.MaxLength > 0 && len([]rune(v)) > s.MaxLength {
+			fail("must be at most %d characters", s.MaxLength)
+		}
+		switch s.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				fail("must be an RFC 3339 date-time")
+			}
+		case "uri":
+			if u, err := url.Parse(v); err != nil || u.Scheme == "" || u.Host == "" {
+				fail("must be an absolute URI")
+			}
+		}
+
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("must be at least %v", *s.Minimum)
+		}
+
+	case map[string]interface{}:
+		for _, req := range s.Required {
+			if _, ok := v[req]; !ok {
+				*errs = append(*errs, field(path, req)+" is required")
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if prop, ok := s.Properties[key]; ok {
+				prop.validate(field(path, key), v[key], errs)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				*errs = append(*errs, field(path, key)+" is not allowed")
+			}
+		}
+
+	case []interface{}:
+		if s.MaxItems > 0 && len(v) > s.MaxItems {
+			fail("must have at most %d items", s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	}
+
+	if len(s.OneOf) > 0 {
+		matched := 0
+		var closest []string
+		for _, variant := range s.OneOf {
+			variantErrs := variant.Validate(v)
+			if len(variantErrs) == 0 {
+				matched++
+			} else if closest == nil || len(variantErrs) < len(closest) {
+				closest = variantErrs
+			}
+		}
+		switch {
+		case matched == 0:
+			*errs = append(*errs, closest...)
+		case matched > 1:
+			fail("matches more than one variant")
+		}
+	}
+}
+
+// hasType reports whether a decoded JSON value has the given JSON Schema type
+func hasType(t string, v interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		return t == "string"
+	case bool:
+		return t == "boolean"
+	case float64:
+		return t == "number" || (t == "integer" && v == math.Trunc(v))
+	case map[string]interface{}:
+		return t == "object"
+	case []interface{}:
+		return t == "array"
+	}
+	return false
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if n, ok := v.(float64); ok {
+			if en, ok := e.(int); ok && float64(en) == n {
+				return true
+			}
+		}
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Document converts a value into the generic form Validate expects
+func Document(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
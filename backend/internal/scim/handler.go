@@ -0,0 +1,442 @@
+package scim
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// actor is the audit log actor of changes made through SCIM
+const actor = "scim"
+
+// TokenRequired authenticates identity providers by the configured SCIM bearer token
+func TokenRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := config.Get().SCIMToken
+		if token == "" {
+			respondError(c, http.StatusNotFound, "", "SCIM provisioning is not enabled")
+			return
+		}
+
+		scheme, provided, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "bearer") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimSpace(provided)), []byte(token)) != 1 {
+			respondError(c, http.StatusUnauthorized, "", "Invalid SCIM token")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// findUser loads the user addressed by the :id parameter, responding on failure
+func findUser(c *gin.Context, db *gorm.DB) (models.User, bool) {
+	var user models.User
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "", "User not found")
+		return user, false
+	}
+	if err := db.First(&user, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, "", "User not found")
+		} else {
+			respondError(c, http.StatusInternalServerError, "", "Database error")
+		}
+		return user, false
+	}
+	return user, true
+}
+
+// apply copies the SCIM attributes onto the account and saves it. Roles and
+// active are left unchanged when the identity provider omits them.
+func apply(c *gin.Context, db *gorm.DB, user *models.User, in User) bool {
+	in.UserName = strings.TrimSpace(in.UserName)
+	if in.UserName == "" {
+		respondError(c, http.StatusBadRequest, "invalidValue", "userName is required")
+		return false
+	}
+
+	var taken int64
+	if err := db.Model(&models.User{}).Where("username = ? AND id <> ?", in.UserName, user.ID).Count(&taken).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "", "Database error")
+		return false
+	}
+	if taken > 0 {
+		respondError(c, http.StatusConflict, "uniqueness", "userName is already in use")
+		return false
+	}
+
+	if in.Timezone != "" {
+		if _, err := time.LoadLocation(in.Timezone); err != nil {
+			respondError(c, http.StatusBadRequest, "invalidValue", "Unknown timezone")
+			return false
+		}
+	}
+
+	if in.Roles != nil {
+		role, err := adminRole(in.Roles)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalidValue", err.Error())
+			return false
+		}
+		user.IsAdmin = role != ""
+		user.AdminRole = role
+	}
+	if in.Active != nil {
+		user.IsActive = *in.Active
+	}
+
+	if in.Password != "" {
+		hashed, err := utils.HashPassword(in.Password)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "", "Failed to hash password")
+			return false
+		}
+		user.HashedPassword = hashed
+		user.PasswordChangedAt = nil
+	}
+
+	user.Username = in.UserName
+	user.ExternalID = in.ExternalID
+	user.DisplayName = in.displayName()
+	user.Locale = in.Locale
+	user.Timezone = in.Timezone
+
+	if err := db.Save(user).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "", "Failed to save user")
+		return false
+	}
+	return true
+}
+
+// GetServiceProviderConfig describes the supported SCIM features
+func GetServiceProviderConfig(c *gin.Context) {
+	respond(c, http.StatusOK, gin.H{
+		"schemas":        []string{SchemaSPConfig},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": maxResults},
+		"changePassword": gin.H{"supported": true},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "The token configured in SCIM_TOKEN",
+		}},
+	})
+}
+
+// ListUsers lists accounts, optionally filtered by userName, externalId or id
+func ListUsers(c *gin.Context) {
+	startIndex := 1
+	if v := c.Query("startIndex"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalidValue", "startIndex must be a number")
+			return
+		}
+		if n > 1 {
+			startIndex = n
+		}
+	}
+	count := 100
+	if v := c.Query("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalidValue", "count must be a number")
+			return
+		}
+		count = min(max(n, 0), maxResults)
+	}
+
+	query := database.GetDB().Model(&models.User{})
+	if filter := c.Query("filter"); filter != "" {
+		column, value, err := parseFilter(filter)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+		query = query.Where(column+" = ?", value)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "", "Database error")
+		return
+	}
+
+	var users []models.User
+	if count > 0 {
+		if err := query.Order("id").Offset(startIndex - 1).Limit(count).Find(&users).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, "", "Database error")
+			return
+		}
+	}
+
+	resources := make([]User, 0, len(users))
+	for _, u := range users {
+		resources = append(resources, toSCIM(c, u))
+	}
+
+	respond(c, http.StatusOK, ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// GetUser returns a single account
+func GetUser(c *gin.Context) {
+	user, ok := findUser(c, database.GetDB())
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, toSCIM(c, user))
+}
+
+// CreateUser provisions an account. Without a password the account gets a
+// random one until an admin or the identity provider sets it.
+func CreateUser(c *gin.Context) {
+	var in User
+	if err := c.ShouldBindJSON(&in); err != nil {
+		respondError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	if in.Password == "" {
+		password, err := randomPassword()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "", "Failed to generate password")
+			return
+		}
+		in.Password = password
+	}
+
+	db := database.GetDB()
+	user := models.User{IsActive: true}
+	if !apply(c, db, &user, in) {
+		return
+	}
+	// is_active defaults to true on insert, so an inactive account is updated afterwards
+	if !user.IsActive {
+		if err := db.Model(&user).Update("is_active", false).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, "", "Failed to save user")
+			return
+		}
+	}
+
+	audit.RecordService(c, actor, audit.ActionUserCreated, "user", user.ID, map[string]interface{}{
+		"username":    user.Username,
+		"is_admin":    user.IsAdmin,
+		"admin_role":  user.Role(),
+		"external_id": user.ExternalID,
+	})
+
+	c.Header("Location", utils.AbsoluteURL(c, "/scim/v2/Users/"+strconv.Itoa(user.ID)))
+	respond(c, http.StatusCreated, toSCIM(c, user))
+}
+
+// ReplaceUser replaces an account's attributes
+func ReplaceUser(c *gin.Context) {
+	var in User
+	if err := c.ShouldBindJSON(&in); err != nil {
+		respondError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	db := database.GetDB()
+	user, ok := findUser(c, db)
+	if !ok {
+		return
+	}
+	if !apply(c, db, &user, in) {
+		return
+	}
+
+	audit.RecordService(c, actor, audit.ActionUserUpdated, "user", user.ID, map[string]interface{}{
+		"is_admin":         user.IsAdmin,
+		"admin_role":       user.Role(),
+		"is_active":        user.IsActive,
+		"password_changed": in.Password != "",
+	})
+
+	respond(c, http.StatusOK, toSCIM(c, user))
+}
+
+// PatchUser applies add, replace and remove operations to an account
+func PatchUser(c *gin.Context) {
+	var req PatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	db := database.GetDB()
+	user, ok := findUser(c, db)
+	if !ok {
+		return
+	}
+
+	in := toSCIM(c, user)
+	for _, op := range req.Operations {
+		if err := patch(&in, op); err != nil {
+			respondError(c, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+	}
+	if !apply(c, db, &user, in) {
+		return
+	}
+
+	audit.RecordService(c, actor, audit.ActionUserUpdated, "user", user.ID, map[string]interface{}{
+		"is_admin":         user.IsAdmin,
+		"admin_role":       user.Role(),
+		"is_active":        user.IsActive,
+		"password_changed": in.Password != "",
+	})
+
+	respond(c, http.StatusOK, toSCIM(c, user))
+}
+
+// patch applies one PATCH operation to the SCIM representation of a user
+func patch(in *User, op PatchOperation) error {
+	kind := strings.ToLower(op.Op)
+	if kind != "add" && kind != "replace" && kind != "remove" {
+		return fmt.Errorf("unsupported operation %q", op.Op)
+	}
+
+	// Without a path the value holds the attributes to set
+	if op.Path == "" {
+		if kind == "remove" {
+			return fmt.Errorf("remove requires a path")
+		}
+		attrs, ok := op.Value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value must be an object when no path is given")
+		}
+		for path, value := range attrs {
+			if err := setAttribute(in, path, value, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return setAttribute(in, op.Path, op.Value, kind == "remove")
+}
+
+// setAttribute sets or removes a single attribute by its path
+func setAttribute(in *User, path string, value interface{}, remove bool) error {
+	str := func() (string, error) {
+		if remove || value == nil {
+			return "", nil
+		}
+		s, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("%s must be a string", path)
+		}
+		return s, nil
+	}
+
+	var err error
+	switch strings.ToLower(path) {
+	case "username":
+		in.UserName, err = str()
+	case "externalid":
+		in.ExternalID, err = str()
+	case "displayname", "name.formatted":
+		in.DisplayName, err = str()
+		in.Name = nil
+	case "locale":
+		in.Locale, err = str()
+	case "timezone":
+		in.Timezone, err = str()
+	case "password":
+		in.Password, err = str()
+	case "active":
+		if remove {
+			return fmt.Errorf("active cannot be removed")
+		}
+		var active bool
+		switch v := value.(type) {
+		case bool:
+			active = v
+		case string:
+			// Some identity providers send booleans as strings
+			active, err = strconv.ParseBool(v)
+		default:
+			err = fmt.Errorf("active must be a boolean")
+		}
+		in.Active = &active
+	case "roles":
+		if remove || value == nil {
+			in.Roles = []Role{}
+			return nil
+		}
+		raw, marshalErr := json.Marshal(value)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		var roles []Role
+		if json.Unmarshal(raw, &roles) != nil {
+			var role Role
+			if json.Unmarshal(raw, &role) != nil {
+				return fmt.Errorf("roles must be a list of {\"value\": ...} objects")
+			}
+			roles = []Role{role}
+		}
+		in.Roles = roles
+	case "name.givenname", "name.familyname":
+		// Only the formatted name is stored
+	default:
+		return fmt.Errorf("unsupported attribute %q", path)
+	}
+	return err
+}
+
+// DeleteUser deprovisions an account
+func DeleteUser(c *gin.Context) {
+	db := database.GetDB()
+	user, ok := findUser(c, db)
+	if !ok {
+		return
+	}
+
+	if err := db.Delete(&user).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "", "Failed to delete user")
+		return
+	}
+
+	audit.RecordService(c, actor, audit.ActionUserDeleted, "user", user.ID, map[string]interface{}{
+		"username":    user.Username,
+		"external_id": user.ExternalID,
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetupRoutes sets up the SCIM 2.0 routes
+func SetupRoutes(router *gin.RouterGroup) {
+	router.GET("/ServiceProviderConfig", GetServiceProviderConfig)
+	router.GET("/Users", ListUsers)
+	router.POST("/Users", CreateUser)
+	router.GET("/Users/:id", GetUser)
+	router.PUT("/Users/:id", ReplaceUser)
+	router.PATCH("/Users/:id", PatchUser)
+	router.DELETE("/Users/:id", DeleteUser)
+}
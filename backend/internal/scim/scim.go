@@ -0,0 +1,226 @@
+package scim
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// SCIM schema URNs (RFC 7643, RFC 7644)
+const (
+	SchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaSPConfig     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// ContentType is the media type of SCIM requests and responses
+const ContentType = "application/scim+json"
+
+// Role values accepted in the roles attribute
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+	RoleUser    = "user"
+)
+
+// maxResults caps the page size of list responses
+const maxResults = 200
+
+// Role is an entry of a user's roles attribute
+type Role struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Name is the SCIM name complex attribute; only formatted is stored
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// Meta is the SCIM resource metadata
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+// User is the SCIM representation of an operator account
+type User struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Locale      string   `json:"locale,omitempty"`
+	Timezone    string   `json:"timezone,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Password    string   `json:"password,omitempty"`
+	Roles       []Role   `json:"roles,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// ListResponse is a page of SCIM resources
+type ListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int64    `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []User   `json:"Resources"`
+}
+
+// PatchOperation is a single operation of a SCIM PATCH request
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// PatchRequest is the body of a SCIM PATCH request
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// Error is a SCIM error response
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// respondError writes a SCIM error response
+func respondError(c *gin.Context, status int, scimType, detail string) {
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(status, Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+// respond writes a SCIM resource response
+func respond(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", ContentType)
+	c.JSON(status, body)
+}
+
+// toSCIM maps an account to its SCIM representation
+func toSCIM(c *gin.Context, u models.User) User {
+	active := u.IsActive
+	role := RoleUser
+	switch u.Role() {
+	case models.AdminRoleSuperadmin:
+		role = RoleAdmin
+	case models.AdminRoleSupport:
+		role = RoleSupport
+	}
+
+	user := User{
+		Schemas:     []string{SchemaUser},
+		ID:          strconv.Itoa(u.ID),
+		ExternalID:  u.ExternalID,
+		UserName:    u.Username,
+		DisplayName: u.DisplayName,
+		Locale:      u.Locale,
+		Timezone:    u.Timezone,
+		Active:      &active,
+		Roles:       []Role{{Value: role, Primary: true}},
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      u.CreatedAt,
+			LastModified: u.UpdatedAt,
+			Location:     utils.AbsoluteURL(c, "/scim/v2/Users/"+strconv.Itoa(u.ID)),
+		},
+	}
+	if u.DisplayName != "" {
+		user.Name = &Name{Formatted: u.DisplayName}
+	}
+	return user
+}
+
+// adminRole returns the admin role granted by the roles, "" for none; admin
+// wins over support. Unknown role values are rejected so a typo in the identity
+// provider is not silently ignored.
+func adminRole(roles []Role) (string, error) {
+	granted := ""
+	for _, role := range roles {
+		switch strings.ToLower(role.Value) {
+		case RoleAdmin:
+			granted = models.AdminRoleSuperadmin
+		case RoleSupport:
+			if granted == "" {
+				granted = models.AdminRoleSupport
+			}
+		case RoleUser, "":
+		default:
+			return "", fmt.Errorf("unknown role %q", role.Value)
+		}
+	}
+	return granted, nil
+}
+
+// displayName returns the display name of a SCIM user, falling back to its formatted or composed name
+func (u User) displayName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	if u.Name == nil {
+		return ""
+	}
+	if u.Name.Formatted != "" {
+		return u.Name.Formatted
+	}
+	return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+}
+
+// filterPattern matches the simple equality filters identity providers send,
+// e.g. userName eq "jane"
+var filterPattern = regexp.MustCompile(`^\s*(\w+)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// filterColumns maps filterable attributes to their columns
+var filterColumns = map[string]string{
+	"username":   "username",
+	"externalid": "external_id",
+	"id":         "id",
+}
+
+// parseFilter turns a SCIM filter into a column and value. Only equality on
+// userName, externalId and id is supported.
+func parseFilter(filter string) (string, string, error) {
+	m := filterPattern.FindStringSubmatch(filter)
+	if m == nil {
+		return "", "", errors.New("only filters of the form 'attribute eq \"value\"' are supported")
+	}
+	column, ok := filterColumns[strings.ToLower(m[1])]
+	if !ok {
+		return "", "", fmt.Errorf("filtering on %q is not supported", m[1])
+	}
+	return column, strings.ReplaceAll(m[2], `\"`, `"`), nil
+}
+
+// randomPassword returns an unguessable password for accounts provisioned without one
+func randomPassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,55 @@
+package settings
+
+import (
+	"errors"
+
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Keys of system settings
+const (
+	// KeyTOSVersion is the terms of service version users must accept; empty disables the check
+	KeyTOSVersion = "tos.current_version"
+	// KeyTOSURL points to the published terms of service text
+	KeyTOSURL = "tos.url"
+	// KeyBranding holds the white-label branding as JSON
+	KeyBranding = "branding"
+	// KeyAuditForwarding holds the SIEM audit log forwarding configuration as JSON
+	KeyAuditForwarding = "audit.forwarding"
+	// KeyAuditForwardCursor is the ID of the last audit log entry forwarded to the SIEM
+	KeyAuditForwardCursor = "audit.forwarding_cursor"
+	// KeyAuditForwardLease names the instance forwarding audit entries; it is
+	// taken over when its holder has not renewed it in time
+	KeyAuditForwardLease = "audit.forwarding_lease"
+	// KeySLOTargets holds the latency targets per route and task type as JSON
+	KeySLOTargets = "slo.targets"
+	// KeyHeartbeats holds the heartbeat pings to an external uptime service as JSON
+	KeyHeartbeats = "heartbeats"
+	// KeyShadowMode holds the task types run in shadow and the sampled share as JSON
+	KeyShadowMode = "shadow_mode"
+	// KeyJobs holds the background job schedule overrides and alerting threshold as JSON
+	KeyJobs = "jobs"
+)
+
+// Get returns the value of a setting, or "" if it has not been set
+func Get(db *gorm.DB, key string) (string, error) {
+	var setting models.SystemSetting
+	if err := db.Where("key = ?", key).First(&setting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	return setting.Value, nil
+}
+
+// Set creates or updates a setting
+func Set(db *gorm.DB, key, value string, updatedBy *int) error {
+	setting := models.SystemSetting{Key: key, Value: value, UpdatedBy: updatedBy}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+	}).Create(&setting).Error
+}
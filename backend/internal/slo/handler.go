@@ -0,0 +1,197 @@
+package slo
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultReportDays = 7
+	maxReportDays     = rollupRetentionDays
+)
+
+// Report is the latency of a route or task type over the reporting period
+type Report struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Count  int64  `json:"count"`
+	MeanMS int64  `json:"mean_ms"`
+	P50MS  int64  `json:"p50_ms"`
+	P95MS  int64  `json:"p95_ms"`
+	P99MS  int64  `json:"p99_ms"`
+	MaxMS  int64  `json:"max_ms"`
+	Target Target `json:"target"`
+	// Breaches lists the percentiles above target over the whole period
+	Breaches []string `json:"breaches"`
+	Breached bool     `json:"breached"`
+	// BreachedDays lists the days on which any percentile was above target
+	BreachedDays []string `json:"breached_days"`
+}
+
+// GetSLO reports p50/p95/p99 latency per route and task type over the last
+// days, flagging those above their targets (admin only)
+func GetSLO(c *gin.Context) {
+	days := defaultReportDays
+	if v := c.Query("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxReportDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 90"})
+			return
+		}
+		days = n
+	}
+
+	kind := c.Query("kind")
+	if kind != "" && kind != models.LatencyKindRoute && kind != models.LatencyKindTask {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be route or task"})
+		return
+	}
+
+	db := database.GetDB()
+
+	// Include the samples of the last minute
+	Flush(db)
+
+	targets, err := LoadTargets(db)
+	if err != nil {
+		log.Printf("Failed to load SLO targets: %v", err)
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+	query := db.Where("day >= ?", since)
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	var rollups []models.LatencyRollup
+	if err := query.Order("day").Find(&rollups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	type entry struct {
+		hist         *histogram
+		breachedDays []string
+	}
+	entries := map[sampleKey]*entry{}
+	for _, r := range rollups {
+		key := sampleKey{Kind: r.Kind, Name: r.Name}
+		e, ok := entries[key]
+		if !ok {
+			e = &entry{hist: newHistogram(), breachedDays: []string{}}
+			entries[key] = e
+		}
+		e.hist.merge(r)
+
+		day := newHistogram()
+		day.merge(r)
+		target := targets.For(r.Kind, r.Name)
+		if len(target.breaches(day.percentile(0.50), day.percentile(0.95), day.percentile(0.99), day.Count)) > 0 {
+			e.breachedDays = append(e.breachedDays, r.Day)
+		}
+	}
+
+	reports := make([]Report, 0, len(entries))
+	breached := 0
+	for key, e := range entries {
+		h := e.hist
+		r := Report{
+			Kind:         key.Kind,
+			Name:         key.Name,
+			Count:        h.Count,
+			P50MS:        h.percentile(0.50),
+			P95MS:        h.percentile(0.95),
+			P99MS:        h.percentile(0.99),
+			MaxMS:        h.MaxMS,
+			Target:       targets.For(key.Kind, key.Name),
+			BreachedDays: e.breachedDays,
+		}
+		if h.Count > 0 {
+			r.MeanMS = h.TotalMS / h.Count
+		}
+		r.Breaches = r.Target.breaches(r.P50MS, r.P95MS, r.P99MS, r.Count)
+		r.Breached = len(r.Breaches) > 0
+		if r.Breached {
+			breached++
+		}
+		if c.Query("breached") == "true" && !r.Breached {
+			continue
+		}
+		reports = append(reports, r)
+	}
+
+	// Breaches first, then the slowest
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Breached != reports[j].Breached {
+			return reports[i].Breached
+		}
+		if reports[i].P95MS != reports[j].P95MS {
+			return reports[i].P95MS > reports[j].P95MS
+		}
+		return reports[i].Kind+reports[i].Name < reports[j].Kind+reports[j].Name
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"since":    since,
+		"days":     days,
+		"breached": breached,
+		"items":    reports,
+	})
+}
+
+// GetTargets returns the latency targets (admin only)
+func GetTargets(c *gin.Context) {
+	targets, err := LoadTargets(database.GetDB())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to load settings")})
+		return
+	}
+
+	c.JSON(http.StatusOK, targets)
+}
+
+// UpdateTargets replaces the latency targets (admin only)
+func UpdateTargets(c *gin.Context) {
+	var req Targets
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var updatedBy *int
+	if user, exists := c.Get("user"); exists {
+		if u, ok := user.(models.User); ok {
+			updatedBy = &u.ID
+		}
+	}
+
+	if err := SaveTargets(database.GetDB(), req, updatedBy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Failed to update settings")})
+		return
+	}
+
+	audit.Record(c, audit.ActionSLOTargetsUpdated, "settings", "slo", map[string]interface{}{
+		"targets": req,
+	})
+
+	c.JSON(http.StatusOK, req)
+}
+
+// SetupAdminRoutes sets up the admin latency SLO routes
+func SetupAdminRoutes(router *gin.RouterGroup) {
+	router.GET("/slo", GetSLO)
+	router.GET("/settings/slo", GetTargets)
+	router.PUT("/settings/slo", UpdateTargets)
+}
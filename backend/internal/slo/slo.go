@@ -0,0 +1,332 @@
+package slo
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/settings"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BucketBoundsMS are the upper bounds of the latency histogram buckets in
+// milliseconds. A final bucket holds everything slower than the last bound.
+var BucketBoundsMS = []int64{5, 10, 25, 50, 75, 100, 150, 250, 400, 600, 1000, 1500, 2500, 4000, 6000, 10000, 15000, 30000, 60000, 120000}
+
+const (
+	// flushInterval is how often recorded samples are added to the daily rollups
+	flushInterval = time.Minute
+	// rollupRetentionDays is how long daily rollups are kept
+	rollupRetentionDays = 90
+	// minBreachSamples is the number of samples below which a percentile is too noisy to count as a breach
+	minBreachSamples = 20
+)
+
+// Target is the latency objective for a route or task type; 0 means no objective
+type Target struct {
+	P50MS int64 `json:"p50_ms"`
+	P95MS int64 `json:"p95_ms"`
+	P99MS int64 `json:"p99_ms"`
+}
+
+// Targets holds the objectives per route ("METHOD /path") and task name. The
+// "*" entry applies to everything without an entry of its own.
+type Targets struct {
+	Routes map[string]Target `json:"routes"`
+	Tasks  map[string]Target `json:"tasks"`
+}
+
+// DefaultTargets are used until an admin configures targets
+var DefaultTargets = Targets{
+	Routes: map[string]Target{"*": {P95MS: 500, P99MS: 1500}},
+	Tasks:  map[string]Target{"*": {P95MS: 30000, P99MS: 60000}},
+}
+
+// histogram accumulates latency samples
+type histogram struct {
+	Count   int64
+	TotalMS int64
+	MaxMS   int64
+	Buckets []int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{Buckets: make([]int64, len(BucketBoundsMS)+1)}
+}
+
+func (h *histogram) add(ms int64) {
+	i := sort.Search(len(BucketBoundsMS), func(i int) bool { return ms <= BucketBoundsMS[i] })
+	h.Buckets[i]++
+	h.Count++
+	h.TotalMS += ms
+	if ms > h.MaxMS {
+		h.MaxMS = ms
+	}
+}
+
+// merge adds the samples of a stored rollup
+func (h *histogram) merge(r models.LatencyRollup) {
+	for i, n := range r.Buckets {
+		if i < len(h.Buckets) {
+			h.Buckets[i] += n
+		}
+	}
+	h.Count += r.Count
+	h.TotalMS += r.TotalMS
+	if r.MaxMS > h.MaxMS {
+		h.MaxMS = r.MaxMS
+	}
+}
+
+// percentile estimates the q-th quantile (0..1) in milliseconds by linear
+// interpolation within the bucket holding it
+func (h *histogram) percentile(q float64) int64 {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := q * float64(h.Count)
+	var seen int64
+	for i, n := range h.Buckets {
+		if n == 0 || float64(seen+n) < rank {
+			seen += n
+			continue
+		}
+
+		lower := int64(0)
+		if i > 0 {
+			lower = BucketBoundsMS[i-1]
+		}
+		upper := h.MaxMS
+		if i < len(BucketBoundsMS) && BucketBoundsMS[i] < upper {
+			upper = BucketBoundsMS[i]
+		}
+		if upper <= lower {
+			return upper
+		}
+		return lower + int64(float64(upper-lower)*(rank-float64(seen))/float64(n))
+	}
+	return h.MaxMS
+}
+
+// sampleKey identifies the histogram of a route or task type on a day
+type sampleKey struct {
+	Day  string
+	Kind string
+	Name string
+}
+
+var (
+	mu      sync.Mutex
+	pending = map[sampleKey]*histogram{}
+
+	// flushMu serializes flushes, so two never add to the same rollup at once
+	flushMu sync.Mutex
+)
+
+// Record adds a latency sample of a route or task type
+func Record(kind, name string, d time.Duration) {
+	key := sampleKey{Day: time.Now().UTC().Format("2006-01-02"), Kind: kind, Name: name}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	h, ok := pending[key]
+	if !ok {
+		h = newHistogram()
+		pending[key] = h
+	}
+	h.add(d.Milliseconds())
+}
+
+// RecordTask adds the execution time of a task
+func RecordTask(name string, d time.Duration) {
+	Record(models.LatencyKindTask, name, d)
+}
+
+// Middleware records the latency of every matched route. Unmatched paths are
+// skipped so scanners cannot create a rollup per random URL.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		if route := c.FullPath(); route != "" {
+			Record(models.LatencyKindRoute, c.Request.Method+" "+route, time.Since(start))
+		}
+	}
+}
+
+// StartFlusher adds recorded samples to the daily rollups every minute and
+// removes rollups past the retention period
+func StartFlusher(db *gorm.DB) {
+	jobs.Register(jobs.Job{
+		Name:        "slo_rollups",
+		Description: "Add recorded latencies to the daily rollups and remove old rollups",
+		Interval:    flushInterval,
+		Run: func() error {
+			Flush(db)
+			cutoff := time.Now().UTC().AddDate(0, 0, -rollupRetentionDays).Format("2006-01-02")
+			if err := db.Where("day < ?", cutoff).Delete(&models.LatencyRollup{}).Error; err != nil {
+				return fmt.Errorf("error deleting old latency rollups: %v", err)
+			}
+			return nil
+		},
+	})
+}
+
+// Flush adds the samples recorded since the last flush to the daily rollups.
+// Samples that cannot be saved are kept for the next flush.
+func Flush(db *gorm.DB) {
+	flushMu.Lock()
+	defer flushMu.Unlock()
+
+	mu.Lock()
+	batch := pending
+	pending = map[sampleKey]*histogram{}
+	mu.Unlock()
+
+	for key, h := range batch {
+		if err := addToRollup(db, key, h); err != nil {
+			log.Printf("Failed to save latency rollup for %s %s: %v", key.Kind, key.Name, err)
+
+			mu.Lock()
+			if cur, ok := pending[key]; ok {
+				h.merge(cur.rollup(key))
+			}
+			pending[key] = h
+			mu.Unlock()
+		}
+	}
+}
+
+// rollup returns the histogram as a rollup row
+func (h *histogram) rollup(key sampleKey) models.LatencyRollup {
+	return models.LatencyRollup{
+		Day:     key.Day,
+		Kind:    key.Kind,
+		Name:    key.Name,
+		Count:   h.Count,
+		TotalMS: h.TotalMS,
+		MaxMS:   h.MaxMS,
+		Buckets: h.Buckets,
+	}
+}
+
+// addToRollup adds a histogram to the stored rollup of its day
+func addToRollup(db *gorm.DB, key sampleKey, h *histogram) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing models.LatencyRollup
+		err := tx.Where("day = ? AND kind = ? AND name = ?", key.Day, key.Kind, key.Name).
+			First(&existing).Error
+		if err == gorm.ErrRecordNotFound {
+			r := h.rollup(key)
+			return tx.Create(&r).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		total := newHistogram()
+		total.merge(existing)
+		total.merge(h.rollup(key))
+		r := total.rollup(key)
+		r.ID = existing.ID
+		return tx.Save(&r).Error
+	})
+}
+
+// LoadTargets returns the configured targets, or the defaults
+func LoadTargets(db *gorm.DB) (Targets, error) {
+	value, err := settings.Get(db, settings.KeySLOTargets)
+	if err != nil || value == "" {
+		return DefaultTargets, err
+	}
+
+	var t Targets
+	if err := json.Unmarshal([]byte(value), &t); err != nil {
+		return DefaultTargets, fmt.Errorf("error decoding SLO targets: %v", err)
+	}
+	return t, nil
+}
+
+// SaveTargets stores the targets
+func SaveTargets(db *gorm.DB, t Targets, updatedBy *int) error {
+	value, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return settings.Set(db, settings.KeySLOTargets, string(value), updatedBy)
+}
+
+// Validate checks that every target is positive and ordered
+func (t *Targets) Validate() error {
+	if t.Routes == nil {
+		t.Routes = map[string]Target{}
+	}
+	if t.Tasks == nil {
+		t.Tasks = map[string]Target{}
+	}
+
+	for name, target := range t.Routes {
+		if name != "*" && !strings.Contains(name, " /") {
+			return fmt.Errorf("route %q must be \"*\" or \"METHOD /path\"", name)
+		}
+		if err := target.validate(); err != nil {
+			return fmt.Errorf("route %q: %v", name, err)
+		}
+	}
+	for name, target := range t.Tasks {
+		if err := target.validate(); err != nil {
+			return fmt.Errorf("task %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (t Target) validate() error {
+	if t.P50MS < 0 || t.P95MS < 0 || t.P99MS < 0 {
+		return fmt.Errorf("targets must not be negative")
+	}
+	if (t.P50MS > 0 && t.P95MS > 0 && t.P50MS > t.P95MS) || (t.P95MS > 0 && t.P99MS > 0 && t.P95MS > t.P99MS) {
+		return fmt.Errorf("targets must grow from p50 to p99")
+	}
+	return nil
+}
+
+// For returns the target of a route or task type, falling back to "*"
+func (t Targets) For(kind, name string) Target {
+	m := t.Routes
+	if kind == models.LatencyKindTask {
+		m = t.Tasks
+	}
+	if target, ok := m[name]; ok {
+		return target
+	}
+	return m["*"]
+}
+
+// breaches lists the percentiles above their target
+func (t Target) breaches(p50, p95, p99, count int64) []string {
+	breached := []string{}
+	if count < minBreachSamples {
+		return breached
+	}
+	if t.P50MS > 0 && p50 > t.P50MS {
+		breached = append(breached, "p50")
+	}
+	if t.P95MS > 0 && p95 > t.P95MS {
+		breached = append(breached, "p95")
+	}
+	if t.P99MS > 0 && p99 > t.P99MS {
+		breached = append(breached, "p99")
+	}
+	return breached
+}
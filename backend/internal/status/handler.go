@@ -0,0 +1,52 @@
+package status
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// GetPublicStatus returns coarse service health without authentication. Panel
+// connectivity is aggregated across all profiles so no panel or user is identifiable.
+func GetPublicStatus(c *gin.Context) {
+	db := database.GetReadDB()
+
+	dbState := StateOperational
+	if sqlDB, err := db.DB(); err != nil || sqlDB.PingContext(c.Request.Context()) != nil {
+		dbState = StateOutage
+	}
+
+	queue, err := queueStatus(db)
+	if err != nil {
+		log.Printf("Failed to read queue status: %v", err)
+		queue.Status = StateDegraded
+	}
+
+	panels := panelStatus(database.GetDB())
+
+	// Panels are external services, so losing them degrades but does not take down this service
+	panelImpact := panels.Status
+	if panelImpact == StateOutage {
+		panelImpact = StateDegraded
+	}
+
+	c.Header("Cache-Control", "public, max-age=30")
+	c.JSON(http.StatusOK, gin.H{
+		"status":     worst(dbState, queue.Status, panelImpact),
+		"checked_at": time.Now(),
+		"components": gin.H{
+			"api":      gin.H{"status": StateOperational},
+			"database": gin.H{"status": dbState},
+			"panels":   panels,
+			"queue":    queue,
+		},
+	})
+}
+
+// SetupRoutes sets up the public status routes
+func SetupRoutes(router *gin.RouterGroup) {
+	router.GET("/public", GetPublicStatus)
+}
@@ -0,0 +1,192 @@
+package status
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/automation"
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"gorm.io/gorm"
+)
+
+// Component states, from best to worst
+const (
+	StateOperational = "operational"
+	StateDegraded    = "degraded"
+	StateOutage      = "outage"
+)
+
+// panelProbeConcurrency limits how many panels are probed at once
+const panelProbeConcurrency = 8
+
+var stateRank = map[string]int{
+	StateOperational: 0,
+	StateDegraded:    1,
+	StateOutage:      2,
+}
+
+// worst returns the most severe of the given states
+func worst(states ...string) string {
+	result := StateOperational
+	for _, s := range states {
+		if stateRank[s] > stateRank[result] {
+			result = s
+		}
+	}
+	return result
+}
+
+// PanelSummary is the anonymous aggregate of panel connectivity across all profiles
+type PanelSummary struct {
+	Status    string    `json:"status"`
+	Total     int       `json:"total"`
+	Reachable int       `json:"reachable"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// QueueSummary describes how far behind task execution is
+type QueueSummary struct {
+	Status               string `json:"status"`
+	Pending              int64  `json:"pending"`
+	Running              int64  `json:"running"`
+	OldestPendingSeconds int    `json:"oldest_pending_seconds"`
+}
+
+var (
+	panelMu      sync.Mutex
+	panelSummary *PanelSummary
+)
+
+// panelStatus returns the cached panel summary, probing the panels again when it is stale.
+// The lock is held while probing so concurrent requests do not probe in parallel.
+func panelStatus(db *gorm.DB) PanelSummary {
+	panelMu.Lock()
+	defer panelMu.Unlock()
+
+	ttl := time.Duration(config.Get().StatusPanelCheckSeconds) * time.Second
+	if panelSummary != nil && time.Since(panelSummary.CheckedAt) < ttl {
+		return *panelSummary
+	}
+
+	var summary PanelSummary
+	if config.Get().UptimeMonitorEnabled {
+		summary = monitoredPanels(db)
+	} else {
+		summary = probePanels(db)
+	}
+	panelSummary = &summary
+	return summary
+}
+
+// monitoredPanels aggregates the health recorded by the uptime monitor instead of probing
+func monitoredPanels(db *gorm.DB) PanelSummary {
+	summary := PanelSummary{Status: StateOperational, CheckedAt: time.Now()}
+
+	var counts []struct {
+		Status string
+		Count  int
+	}
+	if err := db.Model(&models.PanelHealth{}).
+		Select("status, COUNT(*) AS count").
+		Where("status <> ?", models.PanelStatusUnknown).
+		Group("status").
+		Scan(&counts).Error; err != nil {
+		summary.Status = StateDegraded
+		return summary
+	}
+
+	for _, row := range counts {
+		summary.Total += row.Count
+		if row.Status == models.PanelStatusUp {
+			summary.Reachable += row.Count
+		}
+	}
+	summary.Status = panelState(summary.Reachable, summary.Total)
+	return summary
+}
+
+// panelState maps reachable/total panels to a component state
+func panelState(reachable, total int) string {
+	switch {
+	case total == 0 || reachable == total:
+		return StateOperational
+	case reachable == 0:
+		return StateOutage
+	default:
+		return StateDegraded
+	}
+}
+
+// probePanels pings every distinct configured panel, skipping simulation profiles
+func probePanels(db *gorm.DB) PanelSummary {
+	summary := PanelSummary{Status: StateOperational, CheckedAt: time.Now()}
+
+	var profiles []models.UserSettings
+	if err := db.Where("website_url <> ''").Find(&profiles).Error; err != nil {
+		summary.Status = StateDegraded
+		return summary
+	}
+
+	clients := make(map[string]*automation.APIClient)
+	for _, p := range profiles {
+		client := automation.NewAPIClient(p.WebsiteURL, p.APIKey, p.AuthUser)
+		client.HTTPClient.Timeout = 10 * time.Second
+		if client.IsSimulationMode() {
+			continue
+		}
+		clients[p.WebsiteURL] = client
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		reachable int
+		sem       = make(chan struct{}, panelProbeConcurrency)
+	)
+	for _, client := range clients {
+		wg.Add(1)
+		go func(client *automation.APIClient) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if _, err := client.Ping(); err == nil {
+				mu.Lock()
+				reachable++
+				mu.Unlock()
+			}
+		}(client)
+	}
+	wg.Wait()
+
+	summary.Total = len(clients)
+	summary.Reachable = reachable
+	summary.Status = panelState(reachable, summary.Total)
+	return summary
+}
+
+// queueStatus reports pending/running counts and the age of the oldest pending task
+func queueStatus(db *gorm.DB) (QueueSummary, error) {
+	summary := QueueSummary{Status: StateOperational}
+
+	if err := db.Model(&models.AutomationTask{}).Where("status = ?", "pending").Count(&summary.Pending).Error; err != nil {
+		return summary, err
+	}
+	if err := db.Model(&models.AutomationTask{}).Where("status = ?", "running").Count(&summary.Running).Error; err != nil {
+		return summary, err
+	}
+
+	if summary.Pending > 0 {
+		var oldest models.AutomationTask
+		if err := db.Select("created_at").Where("status = ?", "pending").Order("created_at ASC").First(&oldest).Error; err != nil {
+			return summary, err
+		}
+		summary.OldestPendingSeconds = int(time.Since(oldest.CreatedAt).Seconds())
+	}
+
+	if summary.OldestPendingSeconds > config.Get().StatusQueueDelayWarnSeconds {
+		summary.Status = StateDegraded
+	}
+	return summary, nil
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+)
+
+// Rule deletes objects under Prefix once they are older than MaxAge
+type Rule struct {
+	Prefix string
+	MaxAge time.Duration
+}
+
+// Cleanup applies the lifecycle rules once and returns the number of deleted objects
+func Cleanup(ctx context.Context, s Storage, rules []Rule) (int, error) {
+	deleted := 0
+	now := time.Now()
+
+	for _, rule := range rules {
+		objects, err := s.List(ctx, rule.Prefix)
+		if err != nil {
+			return deleted, err
+		}
+
+		for _, obj := range objects {
+			if now.Sub(obj.ModTime) < rule.MaxAge {
+				continue
+			}
+			if err := s.Delete(ctx, obj.Key); err != nil {
+				log.Printf("Failed to delete expired artifact %s: %v", obj.Key, err)
+				continue
+			}
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// StartCleanup runs the lifecycle rules periodically in the background
+func StartCleanup(s Storage, rules []Rule, interval time.Duration) {
+	jobs.Register(jobs.Job{
+		Name:        "artifact_cleanup",
+		Description: "Delete stored files past their lifecycle rules",
+		Interval:    interval,
+		Run: func() error {
+			deleted, err := Cleanup(context.Background(), s, rules)
+			if err != nil {
+				return err
+			}
+			if deleted > 0 {
+				log.Printf("Artifact cleanup removed %d expired objects", deleted)
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,403 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Options configures an S3-compatible storage backend
+type S3Options struct {
+	Endpoint  string // e.g. https://s3.eu-central-1.amazonaws.com or http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3Storage stores objects in an S3-compatible bucket using path-style requests
+type S3Storage struct {
+	opts       S3Options
+	HTTPClient *http.Client
+}
+
+// NewS3Storage creates an S3-compatible storage backend
+func NewS3Storage(opts S3Options) *S3Storage {
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	opts.Endpoint = strings.TrimRight(opts.Endpoint, "/")
+
+	return &S3Storage{
+		opts:       opts,
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// s3PartSize is the size of the parts of a multipart upload. Objects up to
+// this size are uploaded with a single request; at most one part is held in
+// memory at a time. S3 requires parts of at least 5 MiB and allows 10000.
+const s3PartSize = 16 << 20
+
+// Put uploads the object, with a multipart upload when it is larger than
+// s3PartSize so large objects are streamed part by part
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	part, err := readPart(r)
+	if err != nil {
+		return err
+	}
+	if len(part) < s3PartSize {
+		_, err := s.putPart(ctx, key, nil, part)
+		return err
+	}
+	return s.putMultipart(ctx, key, part, r)
+}
+
+// readPart reads up to s3PartSize bytes, fewer only at the end of r
+func readPart(r io.Reader) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(r, s3PartSize))
+}
+
+// putPart uploads a whole object or, with an upload ID and part number in the
+// query, one part of a multipart upload, and returns its ETag
+func (s *S3Storage) putPart(ctx context.Context, key string, query url.Values, body []byte) (string, error) {
+	resp, err := s.do(ctx, http.MethodPut, key, query, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", s.responseError(resp)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// putMultipart uploads the first part and the rest of r as a multipart
+// upload, aborting it when a part fails so no parts are left behind
+func (s *S3Storage) putMultipart(ctx context.Context, key string, first []byte, r io.Reader) error {
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return s.responseError(resp)
+	}
+	var initiated initiateMultipartUploadResult
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil || initiated.UploadID == "" {
+		return fmt.Errorf("error decoding multipart upload response: %v", err)
+	}
+
+	if err := s.uploadParts(ctx, key, initiated.UploadID, first, r); err != nil {
+		abort, abortErr := s.do(context.Background(), http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadID}}, nil)
+		if abortErr == nil {
+			abort.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+// uploadParts uploads the parts of a multipart upload one at a time and
+// completes it
+func (s *S3Storage) uploadParts(ctx context.Context, key, uploadID string, part []byte, r io.Reader) error {
+	var complete completeMultipartUpload
+	for number := 1; len(part) > 0; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		etag, err := s.putPart(ctx, key, query, part)
+		if err != nil {
+			return err
+		}
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: number, ETag: etag})
+
+		if len(part) < s3PartSize {
+			break
+		}
+		if part, err = readPart(r); err != nil {
+			return err
+		}
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// S3 reports some failures of a completed upload with status 200 and an
+	// Error document
+	result, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK || bytes.Contains(result, []byte("<Error>")) {
+		return fmt.Errorf("s3 error (status %d): %s", resp.StatusCode, strings.TrimSpace(string(result)))
+	}
+	return nil
+}
+
+// Get downloads the object; the caller must close the returned reader
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, s.responseError(resp)
+	}
+
+	return resp.Body, nil
+}
+
+// Stat issues a HEAD request for the object
+func (s *S3Storage) Stat(ctx context.Context, key string) (*Object, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("s3 error (status %d)", resp.StatusCode)
+	}
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &Object{Key: key, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+// Delete removes the object
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s.responseError(resp)
+	}
+	return nil
+}
+
+// List pages through ListObjectsV2 results for the prefix
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects := []Object{}
+	token := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := s.responseError(resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding list response: %v", err)
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, Object{Key: c.Key, Size: c.Size, ModTime: c.LastModified})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3Storage) responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 error (status %d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// do builds, signs (AWS Signature Version 4) and sends a request
+func (s *S3Storage) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	canonicalURI := "/" + uriEncode(s.opts.Bucket, false)
+	if key != "" {
+		canonicalURI += "/" + uriEncode(key, true)
+	}
+
+	canonicalQuery := canonicalQueryString(query)
+	rawURL := s.opts.Endpoint + canonicalURI
+	if canonicalQuery != "" {
+		rawURL += "?" + canonicalQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if body != nil {
+		req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalURI,
+		canonicalQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := dateStamp + "/" + s.opts.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.opts.SecretKey), dateStamp)
+	signingKey = hmacSHA256(signingKey, s.opts.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKey, scope, signedHeaders, signature,
+	))
+
+	return s.HTTPClient.Do(req)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode percent-encodes per the SigV4 rules, optionally keeping slashes
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9'),
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && keepSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func canonicalQueryString(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, false)+"="+uriEncode(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored artifact
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage is the interface implemented by artifact storage backends
+type Storage interface {
+	// Put writes the object at key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the object at key for reading
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat returns metadata for the object at key
+	Stat(ctx context.Context, key string) (*Object, error)
+	// Delete removes the object at key; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// List returns all objects whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+var (
+	store    Storage
+	initOnce sync.Once
+)
+
+// Initialize creates the storage backend selected in the configuration; only
+// the first call has an effect
+func Initialize() {
+	initOnce.Do(initialize)
+}
+
+func initialize() {
+	cfg := config.Get()
+
+	switch cfg.StorageBackend {
+	case "s3":
+		store = NewS3Storage(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+	default:
+		store = NewLocalStorage(cfg.ArtifactsDir)
+	}
+
+	log.Printf("Artifact storage initialized (backend: %s)", cfg.StorageBackend)
+}
+
+// Get returns the configured storage backend, creating it on first use
+func Get() Storage {
+	Initialize()
+	return store
+}
+
+// validateKey rejects keys that could escape the storage root
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") {
+		return fmt.Errorf("invalid storage key: %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package tenantexport
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/aliselcukkaya/account-editor/internal/artifacts"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/fieldcrypt"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/storage"
+	"gorm.io/gorm"
+)
+
+// FormatVersion is written to the archive header and changes when the layout does
+const FormatVersion = 1
+
+// ErrNoRecipients is returned when an export has no key to be encrypted to
+var ErrNoRecipients = errors.New("no age recipients given and TENANT_EXPORT_RECIPIENTS is not set")
+
+// accountData lists the tables of the primary database holding rows of the
+// organization's users besides the users themselves
+var accountData = []interface{}{
+	&models.Subscription{},
+	&models.CouponRedemption{},
+	&models.UsageCounter{},
+	&models.QuotaAlert{},
+	&models.SignupRequest{},
+	&models.ServiceClient{},
+}
+
+// Record is a line of the archive: the header, a table row or the trailer
+type Record struct {
+	Type string `json:"type"`
+	// Header fields
+	Version   int        `json:"version,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	OwnerID   int        `json:"owner_id,omitempty"`
+	UserIDs   []int      `json:"user_ids,omitempty"`
+	// Row fields; rows are stored as in the database, secrets in clear
+	Table string                 `json:"table,omitempty"`
+	Row   map[string]interface{} `json:"row,omitempty"`
+	// Trailer fields
+	Rows map[string]int64 `json:"rows,omitempty"`
+}
+
+// Result describes a finished export
+type Result struct {
+	Key       string           `json:"key"`
+	OwnerID   int              `json:"owner_id"`
+	UserIDs   []int            `json:"user_ids"`
+	Rows      map[string]int64 `json:"rows"`
+	Size      int64            `json:"size"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// ParseRecipients parses age X25519 public keys ("age1...")
+func ParseRecipients(keys []string) ([]age.Recipient, error) {
+	recipients := []age.Recipient{}
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		r, err := age.ParseX25519Recipient(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %v", key, err)
+		}
+		recipients = append(recipients, r)
+	}
+	if len(recipients) == 0 {
+		return nil, ErrNoRecipients
+	}
+	return recipients, nil
+}
+
+// Owner returns the user owning the organization of a user: the parent of a
+// sub-account, or the user itself
+func Owner(db *gorm.DB, userID int) (models.User, error) {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return user, err
+	}
+	if user.ParentID == nil {
+		return user, nil
+	}
+	var parent models.User
+	if err := db.First(&parent, *user.ParentID).Error; err != nil {
+		return user, fmt.Errorf("error loading the parent of user ID %d: %v", userID, err)
+	}
+	return parent, nil
+}
+
+// Export writes every row of an organization, its owner and sub-accounts, to
+// an age encrypted, gzipped JSON lines archive in the backups storage. The
+// archive is streamed, so it is never written unencrypted and only one upload
+// part of it is held in memory at a time.
+// Stored secrets such as panel API keys are written in clear inside the
+// archive, so it restores on a deployment with other keys; they are protected
+// by the recipients' keys alone.
+func Export(ctx context.Context, db *gorm.DB, ownerID int, recipients []age.Recipient) (Result, error) {
+	now := time.Now()
+	result := Result{
+		Key:       artifacts.Key(ownerID, artifacts.KindBackup, "tenant-"+strconv.Itoa(ownerID)+"-"+now.UTC().Format("20060102-150405")+".jsonl.gz.age"),
+		OwnerID:   ownerID,
+		CreatedAt: now,
+	}
+
+	userIDs := []int{ownerID}
+	var subIDs []int
+	if err := db.Model(&models.User{}).Where("parent_id = ?", ownerID).Order("id").Pluck("id", &subIDs).Error; err != nil {
+		return result, fmt.Errorf("error loading sub-accounts: %v", err)
+	}
+	result.UserIDs = append(userIDs, subIDs...)
+
+	pr, pw := io.Pipe()
+	counter := &countingReader{r: pr}
+
+	rows := make(chan map[string]int64, 1)
+	go func() {
+		counts, err := writeArchive(pw, db, result, recipients)
+		pw.CloseWithError(err)
+		rows <- counts
+	}()
+
+	err := storage.Get().Put(ctx, result.Key, counter)
+	// Unblock the writer if storing stopped early
+	pr.CloseWithError(errors.New("export aborted"))
+	result.Rows = <-rows
+	result.Size = counter.n
+	if err != nil {
+		storage.Get().Delete(context.Background(), result.Key)
+		return result, fmt.Errorf("error storing the export: %v", err)
+	}
+	return result, nil
+}
+
+// writeArchive encrypts and compresses the archive into w and returns the row
+// count per table
+func writeArchive(w io.Writer, db *gorm.DB, result Result, recipients []age.Recipient) (map[string]int64, error) {
+	counts := map[string]int64{}
+
+	encrypted, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		return counts, fmt.Errorf("error starting encryption: %v", err)
+	}
+	compressed := gzip.NewWriter(encrypted)
+	enc := json.NewEncoder(compressed)
+
+	if err := enc.Encode(Record{
+		Type:      "header",
+		Version:   FormatVersion,
+		CreatedAt: &result.CreatedAt,
+		OwnerID:   result.OwnerID,
+		UserIDs:   result.UserIDs,
+	}); err != nil {
+		return counts, err
+	}
+
+	write := func(db *gorm.DB, model interface{}, query string, args ...interface{}) error {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		table := stmt.Schema.Table
+
+		// Ordered by primary key, so exports of the same data are identical
+		tx := db.Model(model).Where(query, args...)
+		if field := stmt.Schema.PrioritizedPrimaryField; field != nil {
+			tx = tx.Order(field.DBName)
+		}
+		rows, err := tx.Rows()
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", table, err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			row := map[string]interface{}{}
+			if err := db.ScanRows(rows, &row); err != nil {
+				return fmt.Errorf("error reading %s: %v", table, err)
+			}
+			if err := decryptRow(table, row); err != nil {
+				return err
+			}
+			if err := enc.Encode(Record{Type: "row", Table: table, Row: row}); err != nil {
+				return err
+			}
+			counts[table]++
+		}
+		return rows.Err()
+	}
+
+	if err := write(db, &models.User{}, "id IN ?", result.UserIDs); err != nil {
+		return counts, err
+	}
+	for _, model := range accountData {
+		if err := write(db, model, "user_id IN ?", result.UserIDs); err != nil {
+			return counts, err
+		}
+	}
+	if err := write(db, &models.AuditLog{}, "actor_id IN ?", result.UserIDs); err != nil {
+		return counts, err
+	}
+
+	// Sub-accounts work in the owner's data, so it all lives under the owner
+	tenantDB := database.ForUser(result.OwnerID)
+	for _, model := range database.TenantModels() {
+		if _, ok := model.(*models.TaskResultQuarantine); ok {
+			err = write(tenantDB, model, "task_id IN (?)",
+				tenantDB.Model(&models.AutomationTask{}).Select("id").Where("user_id = ?", result.OwnerID))
+		} else {
+			err = write(tenantDB, model, "user_id = ?", result.OwnerID)
+		}
+		if err != nil {
+			return counts, err
+		}
+	}
+
+	if err := enc.Encode(Record{Type: "trailer", Rows: counts}); err != nil {
+		return counts, err
+	}
+	if err := compressed.Close(); err != nil {
+		return counts, err
+	}
+	return counts, encrypted.Close()
+}
+
+// decryptRow replaces the encrypted values of a row with their plaintext
+func decryptRow(table string, row map[string]interface{}) error {
+	for column, value := range row {
+		var stored string
+		switch v := value.(type) {
+		case string:
+			stored = v
+		case []byte:
+			stored = string(v)
+		default:
+			continue
+		}
+		if !fieldcrypt.IsEncrypted(stored) {
+			continue
+		}
+		plain, err := fieldcrypt.DecryptString(stored, table+"."+column)
+		if err != nil {
+			return fmt.Errorf("error decrypting %s.%s: %v", table, column, err)
+		}
+		row[column] = plain
+	}
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// List returns the stored exports of an organization owner
+func List(ctx context.Context, ownerID int) ([]storage.Object, error) {
+	return storage.Get().List(ctx, path.Join(artifacts.KindBackup, strconv.Itoa(ownerID))+"/")
+}
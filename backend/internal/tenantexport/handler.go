@@ -0,0 +1,125 @@
+package tenantexport
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/artifacts"
+	"github.com/aliselcukkaya/account-editor/internal/audit"
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ExportRequest names the age public keys the export is encrypted to
+type ExportRequest struct {
+	Recipients []string `json:"recipients"`
+}
+
+// ownerFromParam loads the organization owner of the user in the path. It responds on failure.
+func ownerFromParam(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+
+	owner, err := Owner(database.GetDB(), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "User not found")})
+		return 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return 0, false
+	}
+	return owner.ID, true
+}
+
+// CreateExport writes an encrypted export of the user's organization to the
+// backups storage (admin only)
+func CreateExport(c *gin.Context) {
+	ownerID, ok := ownerFromParam(c)
+	if !ok {
+		return
+	}
+
+	var req ExportRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	keys := req.Recipients
+	if len(keys) == 0 {
+		keys = strings.Split(config.Get().TenantExportRecipients, ",")
+	}
+	recipients, err := ParseRecipients(keys)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := Export(c.Request.Context(), database.GetDB(), ownerID, recipients)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   i18n.T(c, "Failed to export tenant data"),
+			"details": err.Error(),
+		})
+		return
+	}
+
+	audit.Record(c, audit.ActionTenantExported, "user", ownerID, map[string]interface{}{
+		"key":        result.Key,
+		"user_ids":   result.UserIDs,
+		"rows":       result.Rows,
+		"size":       result.Size,
+		"recipients": len(recipients),
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"export":       result,
+		"download_url": artifacts.DownloadURL(c, result.Key),
+	})
+}
+
+// ListExports lists the stored exports of the user's organization with signed
+// download URLs (admin only)
+func ListExports(c *gin.Context) {
+	ownerID, ok := ownerFromParam(c)
+	if !ok {
+		return
+	}
+
+	objects, err := List(c.Request.Context(), ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list artifacts"})
+		return
+	}
+
+	response := []artifacts.ArtifactInfo{}
+	for _, obj := range objects {
+		response = append(response, artifacts.ArtifactInfo{
+			Name:        path.Base(obj.Key),
+			Kind:        artifacts.KindBackup,
+			Size:        obj.Size,
+			CreatedAt:   obj.ModTime,
+			DownloadURL: artifacts.DownloadURL(c, obj.Key),
+		})
+	}
+
+	utils.RespondList(c, response, int64(len(response)), "")
+}
+
+// SetupAdminRoutes configures the tenant export routes for admins
+func SetupAdminRoutes(router *gin.RouterGroup) {
+	router.POST("/users/:id/export", CreateExport)
+	router.GET("/users/:id/exports", ListExports)
+}
@@ -0,0 +1,167 @@
+package uptime
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/automation"
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/notify"
+	"gorm.io/gorm"
+)
+
+// StartCredentialMonitor validates every stored panel API key on an interval
+// when CREDENTIAL_CHECK_ENABLED is set
+func StartCredentialMonitor(db *gorm.DB) {
+	cfg := config.Get()
+	if !cfg.CredentialCheckEnabled {
+		return
+	}
+
+	interval := time.Duration(cfg.CredentialCheckIntervalMinutes) * time.Minute
+	if interval < 5*time.Minute {
+		interval = 5 * time.Minute
+	}
+
+	jobs.Register(jobs.Job{
+		Name:        "credential_checks",
+		Description: "Validate every stored panel API key",
+		Interval:    interval,
+		MinInterval: 5 * time.Minute,
+		RunOnStart:  true,
+		Run: func() error {
+			return CheckAllCredentials(db)
+		},
+	})
+
+	log.Printf("Panel credential monitor started (every %s)", interval)
+}
+
+// CheckAllCredentials validates the API key of every user with settings
+func CheckAllCredentials(db *gorm.DB) error {
+	var profiles []models.UserSettings
+	if err := db.Where("website_url <> ''").Find(&profiles).Error; err != nil {
+		return fmt.Errorf("failed to load settings: %v", err)
+	}
+
+	var failed atomic.Int64
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, probeConcurrency)
+	for _, profile := range profiles {
+		wg.Add(1)
+		go func(profile models.UserSettings) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if _, err := checkCredentials(db, profile); err != nil {
+				failed.Add(1)
+			}
+		}(profile)
+	}
+	wg.Wait()
+
+	if n := failed.Load(); n > 0 {
+		return fmt.Errorf("failed to save the credential status of %d of %d profiles", n, len(profiles))
+	}
+	return nil
+}
+
+// CheckCredentials validates a single API key and updates the profile's credential
+// status, notifying the user when the key starts or stops being rejected. Errors
+// other than an authentication failure leave the status unchanged, since an
+// unreachable panel says nothing about the key.
+func CheckCredentials(db *gorm.DB, profile models.UserSettings) models.UserSettings {
+	profile, _ = checkCredentials(db, profile)
+	return profile
+}
+
+// checkCredentials is CheckCredentials, also returning the error of saving the
+// status
+func checkCredentials(db *gorm.DB, profile models.UserSettings) (models.UserSettings, error) {
+	client := automation.NewAPIClient(profile.WebsiteURL, profile.APIKey, profile.AuthUser)
+	client.HTTPClient.Timeout = probeTimeout
+
+	err := client.CheckCredentials()
+
+	var panelErr *automation.PanelError
+	status := models.CredentialStatusValid
+	message := ""
+	switch {
+	case err == nil:
+	case errors.As(err, &panelErr) && panelErr.Code == automation.PanelErrorCredentials:
+		status = models.CredentialStatusInvalid
+		message = err.Error()
+	default:
+		log.Printf("Credential monitor: check for user ID %d was inconclusive: %v", profile.UserID, err)
+		return profile, nil
+	}
+
+	previous := profile.CredentialStatus
+	now := time.Now()
+
+	// Only update the profile if its credentials did not change during the check.
+	// The API key is compared after loading, since it is stored encrypted.
+	changed := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var current models.UserSettings
+		if err := tx.First(&current, profile.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				changed = true
+				return nil
+			}
+			return err
+		}
+		if current.WebsiteURL != profile.WebsiteURL || current.APIKey != profile.APIKey || current.AuthUser != profile.AuthUser {
+			changed = true
+			return nil
+		}
+		return tx.Model(&current).Updates(map[string]interface{}{
+			"credential_status":     status,
+			"credential_error":      message,
+			"credential_checked_at": &now,
+		}).Error
+	})
+	if err != nil {
+		log.Printf("Credential monitor: failed to save status for user ID %d: %v", profile.UserID, err)
+		return profile, err
+	}
+	if changed {
+		return profile, nil
+	}
+
+	profile.CredentialStatus = status
+	profile.CredentialError = message
+	profile.CredentialCheckedAt = &now
+
+	switch {
+	case status == models.CredentialStatusInvalid && previous != models.CredentialStatusInvalid:
+		log.Printf("Panel credentials for user ID %d were rejected: %s", profile.UserID, message)
+		notify.Notify(profile.UserID, notify.Event{
+			Kind:  notify.KindCredentialsInvalid,
+			Title: func(locale string) string { return i18n.Translate(locale, "Your panel API key was rejected") },
+			Body: func(locale string) string {
+				return i18n.Translate(locale, "The panel at %s rejected your API key: %s. New tasks and renewals are paused until you update your settings.", profile.WebsiteURL, message)
+			},
+			Data: map[string]interface{}{"website_url": profile.WebsiteURL},
+		})
+	case status == models.CredentialStatusValid && previous == models.CredentialStatusInvalid:
+		log.Printf("Panel credentials for user ID %d are accepted again", profile.UserID)
+		notify.Notify(profile.UserID, notify.Event{
+			Kind:  notify.KindCredentialsRestored,
+			Title: func(locale string) string { return i18n.Translate(locale, "Your panel API key works again") },
+			Body: func(locale string) string {
+				return i18n.Translate(locale, "The panel at %s accepts your API key again. Tasks and renewals have resumed.", profile.WebsiteURL)
+			},
+			Data: map[string]interface{}{"website_url": profile.WebsiteURL},
+		})
+	}
+	return profile, nil
+}
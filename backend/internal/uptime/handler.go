@@ -0,0 +1,183 @@
+package uptime
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/database"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/pkg/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxUptimeHours limits the window of GET /automation/uptime
+const maxUptimeHours = 24 * 30
+
+// summarize computes uptime percentage and latency percentiles over probes
+func summarize(probes []models.PanelProbe) gin.H {
+	if len(probes) == 0 {
+		return gin.H{"probes": 0, "uptime_percent": nil, "avg_latency_ms": nil, "p95_latency_ms": nil}
+	}
+
+	ok := 0
+	var latencies []int
+	for _, p := range probes {
+		if p.OK {
+			ok++
+			latencies = append(latencies, p.LatencyMS)
+		}
+	}
+
+	summary := gin.H{
+		"probes":         len(probes),
+		"uptime_percent": float64(ok) * 100 / float64(len(probes)),
+		"avg_latency_ms": nil,
+		"p95_latency_ms": nil,
+	}
+	if len(latencies) > 0 {
+		sort.Ints(latencies)
+		sum := 0
+		for _, l := range latencies {
+			sum += l
+		}
+		summary["avg_latency_ms"] = sum / len(latencies)
+		summary["p95_latency_ms"] = latencies[(len(latencies)*95-1)/100]
+	}
+	return summary
+}
+
+// GetUptime returns the current user's panel health, uptime summary and probe history
+func GetUptime(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	hours := 24
+	if v := c.Query("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxUptimeHours {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hours must be between 1 and 720"})
+			return
+		}
+		hours = n
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	db := database.GetReadDB()
+
+	health := models.PanelHealth{UserID: u.ID, Status: models.PanelStatusUnknown}
+	if err := db.Where("user_id = ?", u.ID).First(&health).Error; err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	var probes []models.PanelProbe
+	if err := db.Where("user_id = ? AND checked_at >= ?", u.ID, since).
+		Order("checked_at ASC").
+		Find(&probes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"monitor_enabled": Enabled(),
+		"health":          health,
+		"since":           since,
+		"summary":         summarize(probes),
+		"history":         probes,
+	})
+}
+
+// CheckNow probes the current user's panel immediately and returns the result
+func CheckNow(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	db := database.GetDB()
+	var profile models.UserSettings
+	if err := db.Where("user_id = ?", u.ID).First(&profile).Error; err != nil || profile.WebsiteURL == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Settings not found")})
+		return
+	}
+
+	c.JSON(http.StatusOK, Probe(db, profile))
+}
+
+// CheckCredentialsNow validates the current user's panel API key immediately
+func CheckCredentialsNow(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c, "User not authenticated")})
+		return
+	}
+
+	u, ok := user.(models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	db := database.GetDB()
+	var profile models.UserSettings
+	if err := db.Where("user_id = ?", u.ID).First(&profile).Error; err != nil || profile.WebsiteURL == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.T(c, "Settings not found")})
+		return
+	}
+
+	profile = CheckCredentials(db, profile)
+	c.JSON(http.StatusOK, gin.H{
+		"monitor_enabled":       config.Get().CredentialCheckEnabled,
+		"credential_status":     profile.CredentialStatus,
+		"credential_error":      profile.CredentialError,
+		"credential_checked_at": profile.CredentialCheckedAt,
+	})
+}
+
+// GetAllPanelHealth lists the health of every monitored panel (admin only)
+func GetAllPanelHealth(c *gin.Context) {
+	var rows []models.PanelHealth
+	query := database.GetReadDB().Model(&models.PanelHealth{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("status, user_id").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": i18n.T(c, "Database error")})
+		return
+	}
+
+	utils.RespondList(c, rows, int64(len(rows)), "")
+}
+
+// SetupRoutes sets up the uptime routes for the current user
+func SetupRoutes(router *gin.RouterGroup) {
+	router.GET("/uptime", GetUptime)
+	router.POST("/uptime/check", CheckNow)
+	router.POST("/uptime/credentials/check", CheckCredentialsNow)
+}
+
+// SetupAdminRoutes sets up the uptime routes for admins
+func SetupAdminRoutes(router *gin.RouterGroup) {
+	router.GET("/uptime", GetAllPanelHealth)
+}
@@ -0,0 +1,197 @@
+package uptime
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/automation"
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/aliselcukkaya/account-editor/internal/i18n"
+	"github.com/aliselcukkaya/account-editor/internal/jobs"
+	"github.com/aliselcukkaya/account-editor/internal/models"
+	"github.com/aliselcukkaya/account-editor/internal/notify"
+	"gorm.io/gorm"
+)
+
+const (
+	// probeConcurrency limits how many panels are probed at once
+	probeConcurrency = 8
+	// probeTimeout bounds a single probe
+	probeTimeout = 10 * time.Second
+)
+
+// Enabled reports whether the periodic uptime monitor is running
+func Enabled() bool {
+	return config.Get().UptimeMonitorEnabled
+}
+
+// StartMonitor probes every configured panel on an interval when UPTIME_MONITOR_ENABLED is set
+func StartMonitor(db *gorm.DB) {
+	cfg := config.Get()
+	if !cfg.UptimeMonitorEnabled {
+		return
+	}
+
+	interval := time.Duration(cfg.UptimeProbeIntervalSeconds) * time.Second
+	if interval < 10*time.Second {
+		interval = 10 * time.Second
+	}
+
+	jobs.Register(jobs.Job{
+		Name:        "uptime_probes",
+		Description: "Probe every configured panel and prune old probes",
+		Interval:    interval,
+		RunOnStart:  true,
+		Run: func() error {
+			return errors.Join(ProbeAll(db), pruneProbes(db, time.Now().AddDate(0, 0, -cfg.UptimeHistoryDays)))
+		},
+	})
+
+	log.Printf("Panel uptime monitor started (every %s)", interval)
+}
+
+// ProbeAll probes the panel of every user with settings. Unreachable panels are
+// recorded as failed probes; it only fails when results could not be stored.
+func ProbeAll(db *gorm.DB) error {
+	var profiles []models.UserSettings
+	if err := db.Where("website_url <> ''").Find(&profiles).Error; err != nil {
+		return fmt.Errorf("failed to load settings: %v", err)
+	}
+
+	var failed atomic.Int64
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, probeConcurrency)
+	for _, profile := range profiles {
+		wg.Add(1)
+		go func(profile models.UserSettings) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if _, err := runProbe(db, profile); err != nil {
+				failed.Add(1)
+			}
+		}(profile)
+	}
+	wg.Wait()
+
+	if n := failed.Load(); n > 0 {
+		return fmt.Errorf("failed to record %d of %d probes", n, len(profiles))
+	}
+	return nil
+}
+
+// Probe pings a single panel, records the result and updates the panel's health,
+// notifying the user when the panel goes down or recovers
+func Probe(db *gorm.DB, profile models.UserSettings) models.PanelProbe {
+	probe, _ := runProbe(db, profile)
+	return probe
+}
+
+// runProbe is Probe, also returning the error of storing the result
+func runProbe(db *gorm.DB, profile models.UserSettings) (models.PanelProbe, error) {
+	client := automation.NewAPIClient(profile.WebsiteURL, profile.APIKey, profile.AuthUser)
+	client.HTTPClient.Timeout = probeTimeout
+
+	latency, err := client.Ping()
+	probe := models.PanelProbe{
+		UserID:     profile.UserID,
+		WebsiteURL: profile.WebsiteURL,
+		OK:         err == nil,
+		LatencyMS:  int(latency.Milliseconds()),
+		CheckedAt:  time.Now(),
+	}
+	if err != nil {
+		probe.Error = err.Error()
+	}
+
+	if err := db.Create(&probe).Error; err != nil {
+		log.Printf("Uptime monitor: failed to record probe for user ID %d: %v", profile.UserID, err)
+		return probe, err
+	}
+
+	if err := updateHealth(db, probe); err != nil {
+		return probe, err
+	}
+
+	// Start batches that were held because the panel was unreachable
+	if probe.OK {
+		automation.ResumeWaitingBatches(db, profile.UserID)
+	}
+	return probe, nil
+}
+
+// updateHealth applies a probe result to the panel's health and sends alerts on transitions
+func updateHealth(db *gorm.DB, probe models.PanelProbe) error {
+	var health models.PanelHealth
+	if err := db.Where("user_id = ?", probe.UserID).First(&health).Error; err != nil {
+		health = models.PanelHealth{UserID: probe.UserID, Status: models.PanelStatusUnknown}
+	}
+
+	// A changed URL is a different panel, so its history does not carry over
+	if health.WebsiteURL != probe.WebsiteURL {
+		health.Status = models.PanelStatusUnknown
+		health.ConsecutiveFailures = 0
+	}
+
+	previous := health.Status
+	health.WebsiteURL = probe.WebsiteURL
+	health.LatencyMS = probe.LatencyMS
+	health.LastError = probe.Error
+	health.LastCheckedAt = &probe.CheckedAt
+
+	if probe.OK {
+		health.ConsecutiveFailures = 0
+		health.Status = models.PanelStatusUp
+	} else {
+		health.ConsecutiveFailures++
+		if health.ConsecutiveFailures >= config.Get().UptimeFailureThreshold {
+			health.Status = models.PanelStatusDown
+		}
+	}
+
+	if health.Status != previous {
+		health.LastChangedAt = &probe.CheckedAt
+	}
+
+	if err := db.Save(&health).Error; err != nil {
+		log.Printf("Uptime monitor: failed to save health for user ID %d: %v", probe.UserID, err)
+		return err
+	}
+
+	switch {
+	case health.Status == models.PanelStatusDown && previous != models.PanelStatusDown:
+		log.Printf("Panel for user ID %d is down: %s", probe.UserID, probe.Error)
+		notify.Notify(probe.UserID, notify.Event{
+			Kind:  notify.KindPanelDown,
+			Title: func(locale string) string { return i18n.Translate(locale, "Your panel is unreachable") },
+			Body: func(locale string) string {
+				return i18n.Translate(locale, "The panel at %s did not respond to health checks: %s. Tasks will fail until it recovers.", probe.WebsiteURL, probe.Error)
+			},
+			Data: map[string]interface{}{"website_url": probe.WebsiteURL},
+		})
+	case health.Status == models.PanelStatusUp && previous == models.PanelStatusDown:
+		log.Printf("Panel for user ID %d recovered", probe.UserID)
+		notify.Notify(probe.UserID, notify.Event{
+			Kind:  notify.KindPanelRecovered,
+			Title: func(locale string) string { return i18n.Translate(locale, "Your panel is reachable again") },
+			Body: func(locale string) string {
+				return i18n.Translate(locale, "The panel at %s is responding again.", probe.WebsiteURL)
+			},
+			Data: map[string]interface{}{"website_url": probe.WebsiteURL},
+		})
+	}
+	return nil
+}
+
+// pruneProbes deletes probe results older than the history window
+func pruneProbes(db *gorm.DB, cutoff time.Time) error {
+	if err := db.Where("checked_at < ?", cutoff).Delete(&models.PanelProbe{}).Error; err != nil {
+		return fmt.Errorf("failed to prune probes: %v", err)
+	}
+	return nil
+}
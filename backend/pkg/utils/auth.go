@@ -21,6 +21,10 @@ var (
 // Claims represents JWT claims
 type Claims struct {
 	Username string `json:"username"`
+	// ClientID and Scope are set on tokens of service clients, which act on
+	// behalf of the user within the space separated scopes
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -52,6 +56,22 @@ func CreateAccessToken(username string) (string, error) {
 	return token.SignedString(SecretKey)
 }
 
+// CreateClientToken generates a JWT token for a service client acting on behalf of a user
+func CreateClientToken(username, clientID, scope string, ttl time.Duration) (string, error) {
+	claims := &Claims{
+		Username: username,
+		ClientID: clientID,
+		Scope:    scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(SecretKey)
+}
+
 // VerifyToken validates a JWT token
 func VerifyToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultPageSize is used when a cursor is given without a limit
+	DefaultPageSize = 50
+	// MaxPageSize caps the limit query parameter
+	MaxPageSize = 200
+)
+
+// ErrInvalidCursor is returned when a cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor holds the sort keys of the last row of a page. It is opaque to clients.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"id"`
+}
+
+// Page describes the requested page of a cursor-paginated list
+type Page struct {
+	Limit  int
+	Cursor *Cursor
+}
+
+// EncodeCursor serializes a cursor into an opaque URL-safe string
+func EncodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor produced by EncodeCursor
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// ParsePage reads the limit and cursor query parameters. It returns nil when neither
+// is given, so callers can keep returning the full list for older clients.
+func ParsePage(c *gin.Context) (*Page, error) {
+	limitParam := c.Query("limit")
+	cursorParam := c.Query("cursor")
+	if limitParam == "" && cursorParam == "" {
+		return nil, nil
+	}
+
+	page := &Page{Limit: DefaultPageSize}
+	if limitParam != "" {
+		n, err := strconv.Atoi(limitParam)
+		if err != nil || n < 1 {
+			return nil, errors.New("limit must be a positive integer")
+		}
+		if n > MaxPageSize {
+			n = MaxPageSize
+		}
+		page.Limit = n
+	}
+
+	if cursorParam != "" {
+		cursor, err := DecodeCursor(cursorParam)
+		if err != nil {
+			return nil, err
+		}
+		page.Cursor = cursor
+	}
+
+	return page, nil
+}
+
+// Apply restricts a query to the page, newest first, using keyset pagination on
+// (created_at, id). One extra row is fetched so NextCursor can tell if more rows exist.
+func (p *Page) Apply(db *gorm.DB) *gorm.DB {
+	if p.Cursor != nil {
+		db = db.Where("created_at < ? OR (created_at = ? AND id < ?)",
+			p.Cursor.CreatedAt, p.Cursor.CreatedAt, p.Cursor.ID)
+	}
+	return db.Order("created_at DESC, id DESC").Limit(p.Limit + 1)
+}
+
+// NextCursor trims the extra row fetched by Apply and returns the cursor for the
+// next page, or "" when this is the last page. key returns the sort keys of row i.
+func (p *Page) NextCursor(count int, key func(i int) Cursor) (int, string) {
+	if count <= p.Limit {
+		return count, ""
+	}
+	return p.Limit, EncodeCursor(key(p.Limit - 1))
+}
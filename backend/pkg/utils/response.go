@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// ListMeta is the metadata returned with every list response
+type ListMeta struct {
+	Total  int64  `json:"total"`
+	Cursor string `json:"cursor"`
+	TookMS int64  `json:"took_ms"`
+}
+
+// ListResponse is the standard envelope for list endpoints
+type ListResponse struct {
+	Data      interface{} `json:"data"`
+	Meta      ListMeta    `json:"meta"`
+	RequestID string      `json:"request_id"`
+}
+
+// RespondList writes a list in the standard envelope, or as a bare array when
+// legacy list responses are enabled for older clients
+func RespondList(c *gin.Context, data interface{}, total int64, cursor string) {
+	if config.Get().LegacyListResponses {
+		c.JSON(http.StatusOK, data)
+		return
+	}
+
+	var took int64
+	if start, ok := c.Get("request_start"); ok {
+		if t, ok := start.(time.Time); ok {
+			took = time.Since(t).Milliseconds()
+		}
+	}
+
+	c.JSON(http.StatusOK, ListResponse{
+		Data: data,
+		Meta: ListMeta{
+			Total:  total,
+			Cursor: cursor,
+			TookMS: took,
+		},
+		RequestID: c.GetString("request_id"),
+	})
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// SessionCookieName holds the access token in cookie auth mode
+	SessionCookieName = "access_token"
+	// CSRFCookieName holds the double-submit CSRF token readable by the frontend
+	CSRFCookieName = "csrf_token"
+	// CSRFHeaderName is the header the frontend echoes the CSRF token in
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// GenerateCSRFToken creates a random token for double-submit CSRF protection
+func GenerateCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CSRFTokensMatch compares the cookie and header CSRF tokens in constant time
+func CSRFTokensMatch(cookieToken, headerToken string) bool {
+	if cookieToken == "" || headerToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookieToken), []byte(headerToken)) == 1
+}
+
+// SetSessionCookies writes the HttpOnly session cookie and the CSRF cookie
+func SetSessionCookies(c *gin.Context, token, csrfToken string) {
+	cfg := config.Get()
+	maxAge := AccessTokenExpireMinutes * 60
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(SessionCookieName, token, maxAge, "/", cfg.CookieDomain, cfg.CookieSecure, true)
+	// The CSRF cookie must be readable by the frontend so it can echo it back
+	c.SetCookie(CSRFCookieName, csrfToken, maxAge, "/", cfg.CookieDomain, cfg.CookieSecure, false)
+}
+
+// ClearSessionCookies expires the session and CSRF cookies
+func ClearSessionCookies(c *gin.Context) {
+	cfg := config.Get()
+
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(SessionCookieName, "", -1, "/", cfg.CookieDomain, cfg.CookieSecure, true)
+	c.SetCookie(CSRFCookieName, "", -1, "/", cfg.CookieDomain, cfg.CookieSecure, false)
+}
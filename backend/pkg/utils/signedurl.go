@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrSignatureExpired is returned when a signed URL is past its expiry
+	ErrSignatureExpired = errors.New("signed URL has expired")
+	// ErrSignatureInvalid is returned when a signed URL has been tampered with
+	ErrSignatureInvalid = errors.New("invalid URL signature")
+)
+
+// signPath computes the HMAC over the path and expiry timestamp
+func signPath(path string, expires int64) string {
+	mac := hmac.New(sha256.New, SecretKey)
+	fmt.Fprintf(mac, "%s\n%d", path, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignURL returns the path with expires and signature query parameters appended
+func SignURL(path string, ttl time.Duration) string {
+	expires := time.Now().Add(ttl).Unix()
+
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("signature", signPath(path, expires))
+
+	return path + "?" + q.Encode()
+}
+
+// VerifySignedURL checks the signature and expiry for a signed path
+func VerifySignedURL(path, expiresParam, signature string) error {
+	expires, err := strconv.ParseInt(expiresParam, 10, 64)
+	if err != nil || signature == "" {
+		return ErrSignatureInvalid
+	}
+
+	expected := signPath(path, expires)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrSignatureInvalid
+	}
+
+	if time.Now().Unix() > expires {
+		return ErrSignatureExpired
+	}
+
+	return nil
+}
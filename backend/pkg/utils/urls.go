@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/aliselcukkaya/account-editor/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// ForwardedBaseKey is the context key holding the base URL derived from
+// trusted X-Forwarded-* headers
+const ForwardedBaseKey = "forwarded_base_url"
+
+// BaseURL returns the public base URL of the API for the request: the
+// configured PUBLIC_BASE_URL, else the one derived from trusted proxy headers,
+// else the scheme and host the request arrived with
+func BaseURL(c *gin.Context) string {
+	if base := config.Get().PublicBaseURL; base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	if base := c.GetString(ForwardedBaseKey); base != "" {
+		return base
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// AbsoluteURL turns an API path into an absolute URL that is correct behind reverse proxies
+func AbsoluteURL(c *gin.Context, path string) string {
+	return BaseURL(c) + path
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a daily time range such as "03:00-05:00". A range whose end is
+// before its start wraps past midnight, e.g. "06:00-02:00".
+type TimeWindow struct {
+	From int // minutes since midnight, inclusive
+	To   int // minutes since midnight, exclusive
+}
+
+// ParseTimeWindow parses a window in HH:MM-HH:MM format
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return TimeWindow{}, fmt.Errorf("window must look like HH:MM-HH:MM")
+	}
+
+	start, err1 := time.Parse("15:04", strings.TrimSpace(parts[0]))
+	end, err2 := time.Parse("15:04", strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil {
+		return TimeWindow{}, fmt.Errorf("window must look like HH:MM-HH:MM")
+	}
+
+	w := TimeWindow{
+		From: start.Hour()*60 + start.Minute(),
+		To:   end.Hour()*60 + end.Minute(),
+	}
+	if w.From == w.To {
+		return TimeWindow{}, fmt.Errorf("window start and end must differ")
+	}
+	return w, nil
+}
+
+// Contains reports whether t's wall clock time falls inside the window
+func (w TimeWindow) Contains(t time.Time) bool {
+	minutes := t.Hour()*60 + t.Minute()
+	if w.From <= w.To {
+		return minutes >= w.From && minutes < w.To
+	}
+	return minutes >= w.From || minutes < w.To
+}
+
+// NextOpen returns t if the window is open, otherwise the next time it opens in t's location
+func (w TimeWindow) NextOpen(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	open := time.Date(t.Year(), t.Month(), t.Day(), w.From/60, w.From%60, 0, 0, t.Location())
+	if !open.After(t) {
+		open = open.AddDate(0, 0, 1)
+	}
+	return open
+}
+
+// String formats the window as HH:MM-HH:MM
+func (w TimeWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.From/60, w.From%60, w.To/60, w.To%60)
+}
@@ -151,6 +151,9 @@ api.interceptors.response.use(
 			// Handle forbidden error
 			console.error('Access forbidden');
 			error.response.data = { error: 'You do not have permission to access this resource' };
+		} else if (error.response?.status === 451) {
+			// Terms of service must be accepted before continuing
+			window.dispatchEvent(new CustomEvent('auth:tos-required', { detail: error.response.data }));
 		} else if (error.response?.status === 404) {
 			// Handle not found error
 			console.error('Resource not found');
@@ -225,6 +228,8 @@ export interface Task {
 export interface User {
 	id: number;
 	username: string;
+	display_name?: string;
+	avatar_url?: string;
 	is_admin: boolean;
 	is_active: boolean;
 	created_at?: string;
@@ -232,6 +237,25 @@ export interface User {
 	message?: string;
 }
 
+// Standard envelope returned by list endpoints
+interface ListResponse<T> {
+	data: T[];
+	meta: {
+		total: number;
+		cursor: string;
+		took_ms: number;
+	};
+	request_id: string;
+}
+
+// Accept both the list envelope and legacy bare-array responses
+const unwrapList = <T>(payload: ListResponse<T> | T[]): T[] => {
+	if (Array.isArray(payload)) {
+		return payload;
+	}
+	return payload?.data ?? [];
+};
+
 export const auth = {
 	login: async (username: string, password: string) => {
 		try {
@@ -298,8 +322,8 @@ export const admin = {
 	
 	getUsers: async () => {
 		try {
-			const response = await api.get<User[]>('/admin/users');
-			return response.data;
+			const response = await api.get<ListResponse<User> | User[]>('/admin/users');
+			return unwrapList(response.data);
 		} catch (error) {
 			console.error('Get users error:', error);
 			throw error;
@@ -360,8 +384,8 @@ export const automation = {
 	},
 
 	getTasks: async () => {
-		const response = await api.get<any[]>('/automation/tasks');
-		return response.data.map(normalizeTask);
+		const response = await api.get<ListResponse<any> | any[]>('/automation/tasks');
+		return unwrapList(response.data).map(normalizeTask);
 	},
 
 	getTask: async (taskId: number | undefined) => {